@@ -1,17 +1,32 @@
+// Package ast defines the nodes of the abstract syntax tree of a
+// program and the functions that traverse, compare, copy and modify it.
+//
+// The nodes are not synchronised. A tree can be read by any number of
+// goroutines at once, e.g. evaluated or compiled concurrently, as long
+// as none of them changes it: Modify and the passes built on it, like
+// resolver.Resolve which annotates the identifiers, change nodes in
+// place. Such passes run before a tree is shared, or on a copy made
+// by Clone.
 package ast
 
 import (
 	"strings"
+
+	"github.com/Despire/interpreter/token"
 )
 
 type (
-	// PrefixParseHandler handles the prefix operators.
+	// PrefixParseHandler handles the prefix operators. The handlers
+	// are methods of a parser and are not safe for concurrent use.
 	PrefixParseHandler func() Expression
 
-	// InfixParseHandler handles infix operators.
+	// InfixParseHandler handles infix operators, like the prefix
+	// handlers they are not safe for concurrent use.
 	InfixParseHandler func(expression Expression) Expression
 
-	// Node represents top level node in ast.
+	// Node represents top level node in ast. Like every node type of
+	// the package, a node can be read concurrently but not changed
+	// meanwhile, see the package documentation.
 	Node interface {
 		// Literal returns the literal value associated with the token.
 		Literal() string
@@ -35,12 +50,38 @@ type (
 		expression()
 	}
 
+	// Pattern represents the patterns of match expressions and
+	// destructuring let statements. An identifier matches any value
+	// and binds it to its name, except '_' which binds nothing; a
+	// literal matches the values equal to it; array and hash patterns
+	// match the arrays and hashes whose elements match their own.
+	Pattern interface {
+		Node
+
+		// Used to distinguish patterns from other nodes.
+		pattern()
+	}
+
 	// Program represents the root node of the ast.
 	Program struct {
 		Statement []Statement // statement nodes.
+		Comments  []*Comment  // comments in the source, in order of appearance.
+	}
+
+	// Comment represents a single '//' line comment.
+	// Comments are not part of the statements, they are
+	// kept on the Program so tools can preserve them.
+	Comment struct {
+		Token token.Token
 	}
 )
 
+// Text returns the text of the comment including the leading '//'.
+func (c *Comment) Text() string { return c.Token.Literal }
+
+// Pos returns the position of the comment in the source.
+func (c *Comment) Pos() token.Position { return c.Token.Pos }
+
 func (p *Program) Literal() string {
 	if len(p.Statement) > 0 {
 		return p.Statement[0].Literal()
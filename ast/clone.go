@@ -0,0 +1,312 @@
+package ast
+
+import "fmt"
+
+// Clone returns a deep copy of node. The copy shares no
+// nodes with the original, so either can be modified
+// without affecting the other.
+func Clone(node Node) Node {
+	if isNil(node) {
+		return node
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		c := &Program{Statement: cloneStatements(n.Statement)}
+		for _, comment := range n.Comments {
+			cc := *comment
+			c.Comments = append(c.Comments, &cc)
+		}
+		return c
+	case *BlockStatement:
+		return cloneBlock(n)
+	case *LetStatement:
+		return &LetStatement{
+			Token:      n.Token,
+			Identifier: cloneIdentifier(n.Identifier),
+			Pattern:    clonePattern(n.Pattern),
+			Expression: cloneExpression(n.Expression),
+			Exported:   n.Exported,
+		}
+	case *FunctionStatement:
+		c := &FunctionStatement{}
+		if n.Function != nil {
+			c.Function = Clone(n.Function).(*FunctionLiteral)
+		}
+		return c
+	case *ImportStatement:
+		return &ImportStatement{
+			Token: n.Token,
+			Path:  n.Path,
+			Name:  cloneIdentifier(n.Name),
+		}
+	case *ReturnStatement:
+		return &ReturnStatement{
+			Token:      n.Token,
+			Expression: cloneExpression(n.Expression),
+		}
+	case *ThrowStatement:
+		return &ThrowStatement{
+			Token:      n.Token,
+			Expression: cloneExpression(n.Expression),
+		}
+	case *ExpressionStatement:
+		return &ExpressionStatement{
+			Token:      n.Token,
+			Expression: cloneExpression(n.Expression),
+		}
+	case *Identifier:
+		return cloneIdentifier(n)
+	case *IntegerLiteral:
+		c := *n
+		return &c
+	case *StringLiteral:
+		c := *n
+		return &c
+	case *BooleanLiteral:
+		c := *n
+		return &c
+	case *PrefixExpression:
+		return &PrefixExpression{
+			Token:    n.Token,
+			Operator: n.Operator,
+			Right:    cloneExpression(n.Right),
+		}
+	case *YieldExpression:
+		return &YieldExpression{
+			Token: n.Token,
+			Value: cloneExpression(n.Value),
+		}
+	case *SpawnExpression:
+		c := &SpawnExpression{Token: n.Token}
+		if n.Call != nil {
+			c.Call = Clone(n.Call).(*CallExpression)
+		}
+		return c
+	case *ChanExpression:
+		return &ChanExpression{
+			Token:    n.Token,
+			Capacity: cloneExpression(n.Capacity),
+		}
+	case *SelectExpression:
+		c := &SelectExpression{
+			Token: n.Token,
+			End:   n.End,
+		}
+		for _, a := range n.Arms {
+			c.Arms = append(c.Arms, &SelectArm{
+				Token:   a.Token,
+				Channel: cloneExpression(a.Channel),
+				Value:   cloneExpression(a.Value),
+				Binding: cloneIdentifier(a.Binding),
+				Body:    cloneExpression(a.Body),
+			})
+		}
+		return c
+	case *InfixExpression:
+		return &InfixExpression{
+			Token:    n.Token,
+			Left:     cloneExpression(n.Left),
+			Operator: n.Operator,
+			Right:    cloneExpression(n.Right),
+		}
+	case *IfExpression:
+		return &IfExpression{
+			Token:       n.Token,
+			Condition:   cloneExpression(n.Condition),
+			Consequence: cloneBlock(n.Consequence),
+			Alternative: cloneBlock(n.Alternative),
+		}
+	case *TryExpression:
+		c := &TryExpression{
+			Token:     n.Token,
+			Body:      cloneBlock(n.Body),
+			Parameter: cloneIdentifier(n.Parameter),
+			Catch:     cloneBlock(n.Catch),
+			Finally:   cloneBlock(n.Finally),
+		}
+		if n.Locals != nil {
+			c.Locals = append([]string{}, n.Locals...)
+		}
+		return c
+	case *MatchExpression:
+		c := &MatchExpression{
+			Token: n.Token,
+			Value: cloneExpression(n.Value),
+			End:   n.End,
+		}
+		for _, a := range n.Arms {
+			arm := &MatchArm{
+				Pattern: clonePattern(a.Pattern),
+				Guard:   cloneExpression(a.Guard),
+				Body:    cloneExpression(a.Body),
+			}
+			if a.Locals != nil {
+				arm.Locals = append([]string{}, a.Locals...)
+			}
+			c.Arms = append(c.Arms, arm)
+		}
+		return c
+	case *FunctionLiteral:
+		c := &FunctionLiteral{
+			Token:    n.Token,
+			Name:     cloneIdentifier(n.Name),
+			Defaults: cloneExpressions(n.Defaults),
+			Rest:     cloneIdentifier(n.Rest),
+			Body:     cloneBlock(n.Body),
+
+			Generator: n.Generator,
+		}
+		if n.Locals != nil {
+			c.Locals = append([]string{}, n.Locals...)
+		}
+		if n.Parameters != nil {
+			c.Parameters = []*Identifier{}
+		}
+		for _, p := range n.Parameters {
+			c.Parameters = append(c.Parameters, cloneIdentifier(p))
+		}
+		return c
+	case *MacroLiteral:
+		c := &MacroLiteral{
+			Token: n.Token,
+			Body:  cloneBlock(n.Body),
+		}
+		if n.Parameters != nil {
+			c.Parameters = []*Identifier{}
+		}
+		for _, p := range n.Parameters {
+			c.Parameters = append(c.Parameters, cloneIdentifier(p))
+		}
+		return c
+	case *CallExpression:
+		return &CallExpression{
+			Token:     n.Token,
+			Function:  cloneExpression(n.Function),
+			Arguments: cloneExpressions(n.Arguments),
+			End:       n.End,
+		}
+	case *NamedArgument:
+		return &NamedArgument{
+			Name:  cloneIdentifier(n.Name),
+			Value: cloneExpression(n.Value),
+		}
+	case *ArrayLiteral:
+		return &ArrayLiteral{
+			Token:    n.Token,
+			Elements: cloneExpressions(n.Elements),
+			End:      n.End,
+		}
+	case *HashLiteral:
+		c := &HashLiteral{
+			Token: n.Token,
+			End:   n.End,
+		}
+		for _, p := range n.Pairs {
+			c.Pairs = append(c.Pairs, &HashPair{
+				Key:   cloneExpression(p.Key),
+				Value: cloneExpression(p.Value),
+			})
+		}
+		return c
+	case *IndexExpression:
+		return &IndexExpression{
+			Token: n.Token,
+			Left:  cloneExpression(n.Left),
+			Index: cloneExpression(n.Index),
+			End:   n.End,
+		}
+	case *ArrayPattern:
+		return &ArrayPattern{
+			Token:    n.Token,
+			Elements: cloneElements(n.Elements),
+			Rest:     cloneIdentifier(n.Rest),
+			End:      n.End,
+		}
+	case *HashPattern:
+		return &HashPattern{
+			Token:    n.Token,
+			Elements: cloneElements(n.Elements),
+			End:      n.End,
+		}
+	case *MemberExpression:
+		return &MemberExpression{
+			Token:    n.Token,
+			Left:     cloneExpression(n.Left),
+			Property: cloneIdentifier(n.Property),
+		}
+	default:
+		panic(fmt.Sprintf("ast.Clone: unexpected node type %T", n))
+	}
+}
+
+func cloneStatements(list []Statement) []Statement {
+	if list == nil {
+		return nil
+	}
+	c := make([]Statement, 0, len(list))
+	for _, s := range list {
+		if isNil(s) {
+			c = append(c, s)
+			continue
+		}
+		c = append(c, Clone(s).(Statement))
+	}
+	return c
+}
+
+func cloneExpressions(list []Expression) []Expression {
+	if list == nil {
+		return nil
+	}
+	c := make([]Expression, 0, len(list))
+	for _, e := range list {
+		c = append(c, cloneExpression(e))
+	}
+	return c
+}
+
+func cloneExpression(e Expression) Expression {
+	if isNil(e) {
+		return e
+	}
+	return Clone(e).(Expression)
+}
+
+func clonePattern(p Pattern) Pattern {
+	if isNil(p) {
+		return p
+	}
+	return Clone(p).(Pattern)
+}
+
+func cloneElements(list []*PatternElement) []*PatternElement {
+	var c []*PatternElement
+	for _, e := range list {
+		c = append(c, &PatternElement{
+			Key:     cloneIdentifier(e.Key),
+			Pattern: clonePattern(e.Pattern),
+			Default: cloneExpression(e.Default),
+		})
+	}
+	return c
+}
+
+func cloneBlock(b *BlockStatement) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	return &BlockStatement{
+		Token:      b.Token,
+		Statements: cloneStatements(b.Statements),
+		End:        b.End,
+	}
+}
+
+func cloneIdentifier(i *Identifier) *Identifier {
+	if i == nil {
+		return nil
+	}
+	c := *i
+	return &c
+}
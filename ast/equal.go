@@ -0,0 +1,210 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Equal reports whether a and b are structurally equal. Tokens and
+// positions are ignored, only the kind of the nodes, their values
+// and their children are compared.
+func Equal(a, b Node) bool {
+	if isNil(a) || isNil(b) {
+		return isNil(a) && isNil(b)
+	}
+
+	switch a := a.(type) {
+	case *Program:
+		b, ok := b.(*Program)
+		return ok && equalStatements(a.Statement, b.Statement)
+	case *BlockStatement:
+		b, ok := b.(*BlockStatement)
+		return ok && equalStatements(a.Statements, b.Statements)
+	case *LetStatement:
+		b, ok := b.(*LetStatement)
+		return ok && a.Exported == b.Exported && Equal(a.Identifier, b.Identifier) &&
+			Equal(a.Pattern, b.Pattern) && Equal(a.Expression, b.Expression)
+	case *FunctionStatement:
+		b, ok := b.(*FunctionStatement)
+		return ok && Equal(a.Function, b.Function)
+	case *ImportStatement:
+		b, ok := b.(*ImportStatement)
+		return ok && a.Path == b.Path && Equal(a.Name, b.Name)
+	case *ReturnStatement:
+		b, ok := b.(*ReturnStatement)
+		return ok && Equal(a.Expression, b.Expression)
+	case *ThrowStatement:
+		b, ok := b.(*ThrowStatement)
+		return ok && Equal(a.Expression, b.Expression)
+	case *ExpressionStatement:
+		b, ok := b.(*ExpressionStatement)
+		return ok && Equal(a.Expression, b.Expression)
+	case *Identifier:
+		b, ok := b.(*Identifier)
+		return ok && a.Value == b.Value
+	case *IntegerLiteral:
+		b, ok := b.(*IntegerLiteral)
+		return ok && a.Value == b.Value
+	case *StringLiteral:
+		b, ok := b.(*StringLiteral)
+		return ok && a.Value == b.Value
+	case *BooleanLiteral:
+		b, ok := b.(*BooleanLiteral)
+		return ok && a.Value == b.Value
+	case *PrefixExpression:
+		b, ok := b.(*PrefixExpression)
+		return ok && a.Operator == b.Operator && Equal(a.Right, b.Right)
+	case *YieldExpression:
+		b, ok := b.(*YieldExpression)
+		return ok && Equal(a.Value, b.Value)
+	case *SpawnExpression:
+		b, ok := b.(*SpawnExpression)
+		return ok && Equal(a.Call, b.Call)
+	case *ChanExpression:
+		b, ok := b.(*ChanExpression)
+		return ok && Equal(a.Capacity, b.Capacity)
+	case *SelectExpression:
+		b, ok := b.(*SelectExpression)
+		if !ok || len(a.Arms) != len(b.Arms) {
+			return false
+		}
+		for i := range a.Arms {
+			x, y := a.Arms[i], b.Arms[i]
+			if !Equal(x.Channel, y.Channel) || !Equal(x.Value, y.Value) || !Equal(x.Binding, y.Binding) || !Equal(x.Body, y.Body) {
+				return false
+			}
+		}
+		return true
+	case *InfixExpression:
+		b, ok := b.(*InfixExpression)
+		return ok && a.Operator == b.Operator && Equal(a.Left, b.Left) && Equal(a.Right, b.Right)
+	case *IfExpression:
+		b, ok := b.(*IfExpression)
+		return ok && Equal(a.Condition, b.Condition) &&
+			Equal(a.Consequence, b.Consequence) &&
+			Equal(a.Alternative, b.Alternative)
+	case *TryExpression:
+		b, ok := b.(*TryExpression)
+		return ok && Equal(a.Body, b.Body) &&
+			Equal(a.Parameter, b.Parameter) &&
+			Equal(a.Catch, b.Catch) &&
+			Equal(a.Finally, b.Finally)
+	case *MatchExpression:
+		b, ok := b.(*MatchExpression)
+		if !ok || len(a.Arms) != len(b.Arms) || !Equal(a.Value, b.Value) {
+			return false
+		}
+		for i := range a.Arms {
+			x, y := a.Arms[i], b.Arms[i]
+			if !Equal(x.Pattern, y.Pattern) || !Equal(x.Guard, y.Guard) || !Equal(x.Body, y.Body) {
+				return false
+			}
+		}
+		return true
+	case *FunctionLiteral:
+		b, ok := b.(*FunctionLiteral)
+		if !ok || len(a.Parameters) != len(b.Parameters) || !Equal(a.Name, b.Name) {
+			return false
+		}
+		for i := range a.Parameters {
+			if !Equal(a.Parameters[i], b.Parameters[i]) || !Equal(a.Default(i), b.Default(i)) {
+				return false
+			}
+		}
+		return Equal(a.Rest, b.Rest) && Equal(a.Body, b.Body)
+	case *MacroLiteral:
+		b, ok := b.(*MacroLiteral)
+		if !ok || len(a.Parameters) != len(b.Parameters) {
+			return false
+		}
+		for i := range a.Parameters {
+			if !Equal(a.Parameters[i], b.Parameters[i]) {
+				return false
+			}
+		}
+		return Equal(a.Body, b.Body)
+	case *CallExpression:
+		b, ok := b.(*CallExpression)
+		if !ok || len(a.Arguments) != len(b.Arguments) || !Equal(a.Function, b.Function) {
+			return false
+		}
+		for i := range a.Arguments {
+			if !Equal(a.Arguments[i], b.Arguments[i]) {
+				return false
+			}
+		}
+		return true
+	case *NamedArgument:
+		b, ok := b.(*NamedArgument)
+		return ok && Equal(a.Name, b.Name) && Equal(a.Value, b.Value)
+	case *ArrayLiteral:
+		b, ok := b.(*ArrayLiteral)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !Equal(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *HashLiteral:
+		b, ok := b.(*HashLiteral)
+		if !ok || len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+		for i := range a.Pairs {
+			if !Equal(a.Pairs[i].Key, b.Pairs[i].Key) || !Equal(a.Pairs[i].Value, b.Pairs[i].Value) {
+				return false
+			}
+		}
+		return true
+	case *IndexExpression:
+		b, ok := b.(*IndexExpression)
+		return ok && Equal(a.Left, b.Left) && Equal(a.Index, b.Index)
+	case *ArrayPattern:
+		b, ok := b.(*ArrayPattern)
+		return ok && equalElements(a.Elements, b.Elements) && Equal(a.Rest, b.Rest)
+	case *HashPattern:
+		b, ok := b.(*HashPattern)
+		return ok && equalElements(a.Elements, b.Elements)
+	case *MemberExpression:
+		b, ok := b.(*MemberExpression)
+		return ok && Equal(a.Left, b.Left) && Equal(a.Property, b.Property)
+	default:
+		panic(fmt.Sprintf("ast.Equal: unexpected node type %T", a))
+	}
+}
+
+func equalStatements(a, b []Statement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalElements(a, b []*PatternElement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i].Key, b[i].Key) || !Equal(a[i].Pattern, b[i].Pattern) || !Equal(a[i].Default, b[i].Default) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNil reports whether n is nil or a typed nil pointer.
+func isNil(n Node) bool {
+	if n == nil {
+		return true
+	}
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
@@ -0,0 +1,131 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/Despire/interpreter/ast"
+)
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"1", "1", true},
+		{"1", "2", false},
+		{"a", "a", true},
+		{"a", "b", false},
+		{"true", "true", true},
+		{"true", "1", false},
+		{"1 + 2", "1   +   2", true},
+		{"1 + 2", "1 - 2", false},
+		{"-a", "!a", false},
+		{"let a = 1;", "let a = 1", true},
+		{"let a = 1;", "let b = 1", false},
+		{"return a;", "a;", false},
+		{"if (a) { 1 }", "if (a) { 1 }", true},
+		{"if (a) { 1 }", "if (a) { 1 } else { 2 }", false},
+		{"fn(x, y) { x }", "fn(x, y) { x }", true},
+		{"fn(x, y) { x }", "fn(x) { x }", false},
+		{"fn() { yield 1 }", "fn() { yield 1 }", true},
+		{"fn() { yield 1 }", "fn() { yield 2 }", false},
+		{"spawn f(1)", "spawn f(1)", true},
+		{"spawn f(1)", "spawn f(2)", false},
+		{"select { c.recv() as v => v, _ => 1 }", "select { c.recv() as v => v, _ => 1 }", true},
+		{"select { c.recv() as v => v }", "select { c.recv() as w => v }", false},
+		{"select { c.send(1) => 1 }", "select { c.recv() => 1 }", false},
+		{"chan(1)", "chan()", false},
+		{"f(1, 2)", "f(1, 2)", true},
+		{"f(1, 2)", "f(1)", false},
+		{"1; 2", "1", false},
+		{"export let a = 1;", "export let a = 1;", true},
+		{"export let a = 1;", "let a = 1;", false},
+		{`import "a" as b;`, `import "a" as b;`, true},
+		{`import "a" as b;`, `import "b" as b;`, false},
+		{"a.b", "a.b", true},
+		{"a.b", "a.c", false},
+		{`"a"`, `"a"`, true},
+		{`"a"`, `"b"`, false},
+		{"throw a;", "throw a", true},
+		{"throw a;", "throw b", false},
+		{"try { 1 } catch (e) { 2 }", "try { 1 } catch (e) { 2 }", true},
+		{"try { 1 } catch (e) { 2 }", "try { 1 } catch (f) { 2 }", false},
+		{"try { 1 } catch (e) { 2 }", "try { 1 } catch (e) { 2 } finally { 3 }", false},
+		{"try { 1 } finally { 2 }", "try { 1 } finally { 3 }", false},
+		{"match (x) { 1 => 2, n if n => 3 }", "match (x) { 1 => 2, n if n => 3, }", true},
+		{"match (x) { 1 => 2 }", "match (x) { -1 => 2 }", false},
+		{"match (x) { n => 2 }", "match (x) { n if n => 2 }", false},
+		{"match (x) { 1 => 2 }", "match (x) { 1 => 2, _ => 3 }", false},
+		{"[1, a]", "[1, a]", true},
+		{"[1, a]", "[1]", false},
+		{"{a: 1}", "{a: 1,}", true},
+		{"{a: 1}", `{"a": 1}`, false},
+		{"a[1]", "a[1]", true},
+		{"a[1]", "a[2]", false},
+		{"let [a, b = 1, ...c] = x;", "let [a, b = 1, ...c] = x;", true},
+		{"let [a, b = 1, ...c] = x;", "let [a, b = 2, ...c] = x;", false},
+		{"let [a, b] = x;", "let [a, b, ...c] = x;", false},
+		{"let {a, b: c} = x;", "let {a, b: c} = x;", true},
+		{"let {a, b: c} = x;", "let {a, c: b} = x;", false},
+		{"let {a} = x;", "let [a] = x;", false},
+		{"let a = x;", "let [a] = x;", false},
+		{"fn(x, y = 1, ...z) { x }", "fn(x, y = 1, ...z) { x }", true},
+		{"fn(x, y = 1) { x }", "fn(x, y = 2) { x }", false},
+		{"fn(x, y) { x }", "fn(x, y = 1) { x }", false},
+		{"fn f(x) { x }", "fn f(x) { x }", true},
+		{"fn f(x) { x }", "fn g(x) { x }", false},
+		{"fn f(x) { x }", "fn(x) { x }", false},
+		{"fn(x, ...y) { x }", "fn(x, y) { x }", false},
+		{"fn(...y) { 1 }", "fn(...z) { 1 }", false},
+		{"f(1, y: 2)", "f(1, y: 2)", true},
+		{"f(1, y: 2)", "f(1, z: 2)", false},
+		{"f(1, y: 2)", "f(1, 2)", false},
+	}
+
+	for _, tt := range tests {
+		if have := ast.Equal(parse(t, tt.a), parse(t, tt.b)); have != tt.equal {
+			t.Errorf("Equal(%q, %q) = %t, want %t", tt.a, tt.b, have, tt.equal)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	input := `
+// comment
+import "math.mk" as math;
+export let add = fn(x, y) { return math.sum(x, y); };
+if (!true) { add(1, -2) } else { add(3, 4) };
+try { throw "x"; } catch (e) { e } finally { add(5, 6) };
+match (add(1, 2)) { 3 => "three", n if n > 3 => "more", _ => "less" };
+let [a, {b: [c], d = 1}, ...e] = [1, {b: [2]}, 3][{k: 0}["k"]];
+let g = fn(x, y = x + 1, ...z) { z }; g(1, y: 2);
+fn h(x) { h(x) }
+fn i(x) { yield x }
+let c = chan(1); spawn i(c); select { c.recv() as v => v, c.send(1) => 2, _ => 3 }`
+
+	program := parse(t, input)
+	clone := ast.Clone(program).(*ast.Program)
+
+	if !ast.Equal(program, clone) {
+		t.Fatalf("clone differs from the original: %q != %q", clone.String(), program.String())
+	}
+
+	if len(clone.Comments) != 1 || clone.Comments[0] == program.Comments[0] {
+		t.Fatalf("comments were not copied")
+	}
+
+	// modifying the clone must not change the original.
+	ast.Inspect(clone, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Identifier:
+			n.Value = "z"
+		case *ast.IntegerLiteral:
+			n.Value = 0
+		}
+		return true
+	})
+
+	if !ast.Equal(program, parse(t, input)) {
+		t.Errorf("original was modified through the clone: %q", program.String())
+	}
+}
@@ -0,0 +1,1118 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/Despire/interpreter/token"
+)
+
+// JSONVersion is the version of the JSON encoding of the ast.
+// It is incremented on incompatible changes of the encoding.
+const JSONVersion = 1
+
+// EncodeJSON returns the JSON encoding of node.
+//
+// Every node is encoded as an object with a "kind" field holding the
+// name of its Go type (e.g. "InfixExpression"), a "pos" field with the
+// position of the node in the source when it is known and a field for
+// each of its children and values. Programs additionally carry the
+// "version" of the encoding and their comments.
+func EncodeJSON(node Node) ([]byte, error) {
+	return json.Marshal(encode(node))
+}
+
+// EncodeJSONIndent is like EncodeJSON but indents the output.
+func EncodeJSONIndent(node Node, indent string) ([]byte, error) {
+	return json.MarshalIndent(encode(node), "", indent)
+}
+
+type object map[string]interface{}
+
+func encode(node Node) interface{} {
+	if isNil(node) {
+		return nil
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		comments := []interface{}{}
+		for _, c := range n.Comments {
+			comments = append(comments, withPos(object{"text": c.Text()}, c.Pos()))
+		}
+		return object{
+			"kind":       "Program",
+			"version":    JSONVersion,
+			"statements": encodeStatements(n.Statement),
+			"comments":   comments,
+		}
+	case *BlockStatement:
+		return withPos(object{
+			"kind":       "BlockStatement",
+			"statements": encodeStatements(n.Statements),
+			"end":        encodePos(n.End.Pos),
+		}, n.Token.Pos)
+	case *LetStatement:
+		o := object{
+			"kind":  "LetStatement",
+			"name":  encode(n.Identifier),
+			"value": encode(n.Expression),
+		}
+		if n.Pattern != nil {
+			o["pattern"] = encode(n.Pattern)
+		}
+		if n.Exported {
+			o["exported"] = true
+		}
+		return withPos(o, n.Token.Pos)
+	case *FunctionStatement:
+		return object{
+			"kind":     "FunctionStatement",
+			"function": encode(n.Function),
+		}
+	case *ImportStatement:
+		return withPos(object{
+			"kind": "ImportStatement",
+			"path": n.Path,
+			"name": encode(n.Name),
+		}, n.Token.Pos)
+	case *ReturnStatement:
+		return withPos(object{
+			"kind":  "ReturnStatement",
+			"value": encode(n.Expression),
+		}, n.Token.Pos)
+	case *ThrowStatement:
+		return withPos(object{
+			"kind":  "ThrowStatement",
+			"value": encode(n.Expression),
+		}, n.Token.Pos)
+	case *ExpressionStatement:
+		return withPos(object{
+			"kind":       "ExpressionStatement",
+			"expression": encode(n.Expression),
+		}, n.Token.Pos)
+	case *Identifier:
+		return withPos(object{
+			"kind": "Identifier",
+			"name": n.Value,
+		}, n.Token.Pos)
+	case *IntegerLiteral:
+		o := object{
+			"kind":  "IntegerLiteral",
+			"value": n.Value,
+		}
+		// keep the literal when it is not the canonical
+		// representation of the value (e.g. "010").
+		if n.Token.Literal != "" && n.Token.Literal != strconv.Itoa(n.Value) {
+			o["literal"] = n.Token.Literal
+		}
+		return withPos(o, n.Token.Pos)
+	case *StringLiteral:
+		return withPos(object{
+			"kind":  "StringLiteral",
+			"value": n.Value,
+		}, n.Token.Pos)
+	case *BooleanLiteral:
+		return withPos(object{
+			"kind":  "BooleanLiteral",
+			"value": n.Value,
+		}, n.Token.Pos)
+	case *PrefixExpression:
+		return withPos(object{
+			"kind":     "PrefixExpression",
+			"operator": n.Operator,
+			"right":    encode(n.Right),
+		}, n.Token.Pos)
+	case *YieldExpression:
+		return withPos(object{
+			"kind":  "YieldExpression",
+			"value": encode(n.Value),
+		}, n.Token.Pos)
+	case *SpawnExpression:
+		return withPos(object{
+			"kind": "SpawnExpression",
+			"call": encode(n.Call),
+		}, n.Token.Pos)
+	case *ChanExpression:
+		return withPos(object{
+			"kind":     "ChanExpression",
+			"capacity": encode(n.Capacity),
+		}, n.Token.Pos)
+	case *SelectExpression:
+		arms := []interface{}{}
+		for _, a := range n.Arms {
+			arms = append(arms, withPos(object{
+				"channel": encode(a.Channel),
+				"value":   encode(a.Value),
+				"binding": encode(a.Binding),
+				"body":    encode(a.Body),
+			}, a.Token.Pos))
+		}
+		return withPos(object{
+			"kind": "SelectExpression",
+			"arms": arms,
+			"end":  encodePos(n.End.Pos),
+		}, n.Token.Pos)
+	case *InfixExpression:
+		return withPos(object{
+			"kind":     "InfixExpression",
+			"operator": n.Operator,
+			"left":     encode(n.Left),
+			"right":    encode(n.Right),
+		}, n.Token.Pos)
+	case *IfExpression:
+		return withPos(object{
+			"kind":        "IfExpression",
+			"condition":   encode(n.Condition),
+			"consequence": encode(n.Consequence),
+			"alternative": encode(n.Alternative),
+		}, n.Token.Pos)
+	case *TryExpression:
+		return withPos(object{
+			"kind":      "TryExpression",
+			"body":      encode(n.Body),
+			"parameter": encode(n.Parameter),
+			"catch":     encode(n.Catch),
+			"finally":   encode(n.Finally),
+		}, n.Token.Pos)
+	case *MatchExpression:
+		arms := []interface{}{}
+		for _, a := range n.Arms {
+			arms = append(arms, object{
+				"pattern": encode(a.Pattern),
+				"guard":   encode(a.Guard),
+				"body":    encode(a.Body),
+			})
+		}
+		return withPos(object{
+			"kind":  "MatchExpression",
+			"value": encode(n.Value),
+			"arms":  arms,
+			"end":   encodePos(n.End.Pos),
+		}, n.Token.Pos)
+	case *FunctionLiteral:
+		params := []interface{}{}
+		for _, p := range n.Parameters {
+			params = append(params, encode(p))
+		}
+		o := object{
+			"kind":       "FunctionLiteral",
+			"parameters": params,
+			"body":       encode(n.Body),
+		}
+		if n.Name != nil {
+			o["name"] = encode(n.Name)
+		}
+		if len(n.Defaults) > 0 {
+			defaults := []interface{}{}
+			for i := range n.Parameters {
+				defaults = append(defaults, encode(n.Default(i)))
+			}
+			o["defaults"] = defaults
+		}
+		if n.Rest != nil {
+			o["rest"] = encode(n.Rest)
+		}
+		return withPos(o, n.Token.Pos)
+	case *MacroLiteral:
+		params := []interface{}{}
+		for _, p := range n.Parameters {
+			params = append(params, encode(p))
+		}
+		return withPos(object{
+			"kind":       "MacroLiteral",
+			"parameters": params,
+			"body":       encode(n.Body),
+		}, n.Token.Pos)
+	case *CallExpression:
+		args := []interface{}{}
+		for _, a := range n.Arguments {
+			args = append(args, encode(a))
+		}
+		return withPos(object{
+			"kind":      "CallExpression",
+			"function":  encode(n.Function),
+			"arguments": args,
+			"end":       encodePos(n.End.Pos),
+		}, n.Token.Pos)
+	case *NamedArgument:
+		return object{
+			"kind":  "NamedArgument",
+			"name":  encode(n.Name),
+			"value": encode(n.Value),
+		}
+	case *ArrayLiteral:
+		elements := []interface{}{}
+		for _, e := range n.Elements {
+			elements = append(elements, encode(e))
+		}
+		return withPos(object{
+			"kind":     "ArrayLiteral",
+			"elements": elements,
+			"end":      encodePos(n.End.Pos),
+		}, n.Token.Pos)
+	case *HashLiteral:
+		pairs := []interface{}{}
+		for _, p := range n.Pairs {
+			pairs = append(pairs, object{
+				"key":   encode(p.Key),
+				"value": encode(p.Value),
+			})
+		}
+		return withPos(object{
+			"kind":  "HashLiteral",
+			"pairs": pairs,
+			"end":   encodePos(n.End.Pos),
+		}, n.Token.Pos)
+	case *IndexExpression:
+		return withPos(object{
+			"kind":  "IndexExpression",
+			"left":  encode(n.Left),
+			"index": encode(n.Index),
+			"end":   encodePos(n.End.Pos),
+		}, n.Token.Pos)
+	case *ArrayPattern:
+		return withPos(object{
+			"kind":     "ArrayPattern",
+			"elements": encodeElements(n.Elements),
+			"rest":     encode(n.Rest),
+			"end":      encodePos(n.End.Pos),
+		}, n.Token.Pos)
+	case *HashPattern:
+		return withPos(object{
+			"kind":     "HashPattern",
+			"elements": encodeElements(n.Elements),
+			"end":      encodePos(n.End.Pos),
+		}, n.Token.Pos)
+	case *MemberExpression:
+		return withPos(object{
+			"kind":     "MemberExpression",
+			"left":     encode(n.Left),
+			"property": encode(n.Property),
+		}, n.Token.Pos)
+	default:
+		panic(fmt.Sprintf("ast.EncodeJSON: unexpected node type %T", n))
+	}
+}
+
+func encodeStatements(list []Statement) []interface{} {
+	out := []interface{}{}
+	for _, s := range list {
+		out = append(out, encode(s))
+	}
+	return out
+}
+
+func encodeElements(list []*PatternElement) []interface{} {
+	out := []interface{}{}
+	for _, e := range list {
+		o := object{
+			"pattern": encode(e.Pattern),
+			"default": encode(e.Default),
+		}
+		if e.Key != nil {
+			o["key"] = encode(e.Key)
+		}
+		out = append(out, o)
+	}
+	return out
+}
+
+func encodePos(pos token.Position) interface{} {
+	if !pos.IsValid() {
+		return nil
+	}
+	return object{"line": pos.Line, "column": pos.Column}
+}
+
+func withPos(o object, pos token.Position) object {
+	if pos.IsValid() {
+		o["pos"] = encodePos(pos)
+	}
+	return o
+}
+
+// DecodeJSON rebuilds the ast from its JSON encoding produced by EncodeJSON.
+// The tokens of the nodes are reconstructed from their kind and values.
+func DecodeJSON(data []byte) (Node, error) {
+	return decode(data)
+}
+
+// DecodeProgramJSON is like DecodeJSON but requires the root to be a Program.
+func DecodeProgramJSON(data []byte) (*Program, error) {
+	node, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	program, ok := node.(*Program)
+	if !ok {
+		return nil, fmt.Errorf("expected a Program, got %T", node)
+	}
+
+	return program, nil
+}
+
+// fields holds the undecoded fields of a single encoded node.
+type fields map[string]json.RawMessage
+
+func decode(data json.RawMessage) (Node, error) {
+	if isNull(data) {
+		return nil, nil
+	}
+
+	var f fields
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	var kind string
+	if err := f.value("kind", &kind); err != nil {
+		return nil, err
+	}
+
+	pos, err := f.pos("pos")
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case "Program":
+		var version int
+		if err := f.value("version", &version); err != nil {
+			return nil, err
+		}
+		if version != JSONVersion {
+			return nil, fmt.Errorf("unsupported version %d, expected %d", version, JSONVersion)
+		}
+
+		n := &Program{}
+		if n.Statement, err = f.statements("statements"); err != nil {
+			return nil, err
+		}
+
+		var comments []fields
+		if err := f.optional("comments", &comments); err != nil {
+			return nil, err
+		}
+		for i, c := range comments {
+			var text string
+			if err := c.value("text", &text); err != nil {
+				return nil, fmt.Errorf("comments[%d]: %w", i, err)
+			}
+			pos, err := c.pos("pos")
+			if err != nil {
+				return nil, fmt.Errorf("comments[%d]: %w", i, err)
+			}
+			n.Comments = append(n.Comments, &Comment{
+				Token: token.Token{Typ: token.COMMENT, Literal: text, Pos: pos},
+			})
+		}
+		return n, nil
+	case "BlockStatement":
+		n := &BlockStatement{
+			Token: token.Token{Typ: token.LEFTBRACKET, Literal: "{", Pos: pos},
+		}
+		if n.Statements, err = f.statements("statements"); err != nil {
+			return nil, err
+		}
+		end, err := f.pos("end")
+		if err != nil {
+			return nil, err
+		}
+		n.End = token.Token{Typ: token.RIGHTBRACKET, Literal: "}", Pos: end}
+		return n, nil
+	case "LetStatement":
+		n := &LetStatement{
+			Token: token.Token{Typ: token.LET, Literal: "let", Pos: pos},
+		}
+		if data, ok := f["pattern"]; ok && !isNull(data) {
+			if n.Pattern, err = f.pattern("pattern"); err != nil {
+				return nil, err
+			}
+		} else if n.Identifier, err = f.identifier("name"); err != nil {
+			return nil, err
+		}
+		if n.Expression, err = f.expression("value"); err != nil {
+			return nil, err
+		}
+		if err := f.optional("exported", &n.Exported); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case "FunctionStatement":
+		data, ok := f["function"]
+		if !ok {
+			return nil, fmt.Errorf("missing field %q", "function")
+		}
+		fn, err := decodeAs(data, "FunctionLiteral")
+		if err != nil {
+			return nil, fmt.Errorf("function: %w", err)
+		}
+		n := &FunctionStatement{Function: fn.(*FunctionLiteral)}
+		if n.Function.Name == nil {
+			return nil, fmt.Errorf("function: missing name")
+		}
+		return n, nil
+	case "ImportStatement":
+		n := &ImportStatement{
+			Token: token.Token{Typ: token.IMPORT, Literal: "import", Pos: pos},
+		}
+		if err := f.value("path", &n.Path); err != nil {
+			return nil, err
+		}
+		if n.Name, err = f.identifier("name"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case "ReturnStatement":
+		n := &ReturnStatement{
+			Token: token.Token{Typ: token.RETURN, Literal: "return", Pos: pos},
+		}
+		if n.Expression, err = f.expression("value"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case "ThrowStatement":
+		n := &ThrowStatement{
+			Token: token.Token{Typ: token.THROW, Literal: "throw", Pos: pos},
+		}
+		if n.Expression, err = f.expression("value"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case "ExpressionStatement":
+		n := &ExpressionStatement{}
+		if n.Expression, err = f.expression("expression"); err != nil {
+			return nil, err
+		}
+		n.Token = firstToken(n.Expression)
+		n.Token.Pos = pos
+		return n, nil
+	case "Identifier":
+		var name string
+		if err := f.value("name", &name); err != nil {
+			return nil, err
+		}
+		return &Identifier{
+			Token: token.Token{Typ: token.IDENTIFIER, Literal: name, Pos: pos},
+			Value: name,
+		}, nil
+	case "IntegerLiteral":
+		var value int
+		if err := f.value("value", &value); err != nil {
+			return nil, err
+		}
+		literal := strconv.Itoa(value)
+		if err := f.optional("literal", &literal); err != nil {
+			return nil, err
+		}
+		return &IntegerLiteral{
+			Token: token.Token{Typ: token.INTEGER, Literal: literal, Pos: pos},
+			Value: value,
+		}, nil
+	case "StringLiteral":
+		var value string
+		if err := f.value("value", &value); err != nil {
+			return nil, err
+		}
+		return &StringLiteral{
+			Token: token.Token{Typ: token.STRING, Literal: value, Pos: pos},
+			Value: value,
+		}, nil
+	case "BooleanLiteral":
+		var value bool
+		if err := f.value("value", &value); err != nil {
+			return nil, err
+		}
+		return NewBoolean(value, pos), nil
+	case "PrefixExpression":
+		n := &PrefixExpression{}
+		if err := f.value("operator", &n.Operator); err != nil {
+			return nil, err
+		}
+		n.Token = token.Token{Typ: token.Type(n.Operator), Literal: n.Operator, Pos: pos}
+		if n.Right, err = f.expression("right"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case "YieldExpression":
+		n := &YieldExpression{
+			Token: token.Token{Typ: token.YIELD, Literal: "yield", Pos: pos},
+		}
+		if n.Value, err = f.expression("value"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case "SpawnExpression":
+		n := &SpawnExpression{
+			Token: token.Token{Typ: token.SPAWN, Literal: "spawn", Pos: pos},
+		}
+		data, ok := f["call"]
+		if !ok {
+			return nil, fmt.Errorf("missing field %q", "call")
+		}
+		call, err := decodeAs(data, "CallExpression")
+		if err != nil {
+			return nil, fmt.Errorf("call: %w", err)
+		}
+		n.Call = call.(*CallExpression)
+		return n, nil
+	case "ChanExpression":
+		n := &ChanExpression{
+			Token: token.Token{Typ: token.CHAN, Literal: "chan", Pos: pos},
+		}
+		if data, ok := f["capacity"]; ok && !isNull(data) {
+			if n.Capacity, err = f.expression("capacity"); err != nil {
+				return nil, err
+			}
+		}
+		return n, nil
+	case "SelectExpression":
+		n := &SelectExpression{
+			Token: token.Token{Typ: token.SELECT, Literal: "select", Pos: pos},
+		}
+		var arms []fields
+		if err := f.value("arms", &arms); err != nil {
+			return nil, err
+		}
+		for i, a := range arms {
+			arm := &SelectArm{}
+			if data, ok := a["channel"]; ok && !isNull(data) {
+				if arm.Channel, err = a.expression("channel"); err != nil {
+					return nil, fmt.Errorf("arms[%d]: %w", i, err)
+				}
+			}
+			if data, ok := a["value"]; ok && !isNull(data) {
+				if arm.Value, err = a.expression("value"); err != nil {
+					return nil, fmt.Errorf("arms[%d]: %w", i, err)
+				}
+			}
+			if data, ok := a["binding"]; ok && !isNull(data) {
+				if arm.Binding, err = a.identifier("binding"); err != nil {
+					return nil, fmt.Errorf("arms[%d]: %w", i, err)
+				}
+			}
+			if arm.Body, err = a.expression("body"); err != nil {
+				return nil, fmt.Errorf("arms[%d]: %w", i, err)
+			}
+			armPos, err := a.pos("pos")
+			if err != nil {
+				return nil, fmt.Errorf("arms[%d]: %w", i, err)
+			}
+			arm.Token = token.Token{Typ: token.IDENTIFIER, Literal: Wildcard, Pos: armPos}
+			if arm.Channel != nil {
+				arm.Token = firstToken(arm.Channel)
+				arm.Token.Pos = armPos
+			}
+			n.Arms = append(n.Arms, arm)
+		}
+		end, err := f.pos("end")
+		if err != nil {
+			return nil, err
+		}
+		n.End = token.Token{Typ: token.RIGHTBRACKET, Literal: "}", Pos: end}
+		return n, nil
+	case "InfixExpression":
+		n := &InfixExpression{}
+		if err := f.value("operator", &n.Operator); err != nil {
+			return nil, err
+		}
+		n.Token = token.Token{Typ: token.Type(n.Operator), Literal: n.Operator, Pos: pos}
+		if n.Left, err = f.expression("left"); err != nil {
+			return nil, err
+		}
+		if n.Right, err = f.expression("right"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case "IfExpression":
+		n := &IfExpression{
+			Token: token.Token{Typ: token.IF, Literal: "if", Pos: pos},
+		}
+		if n.Condition, err = f.expression("condition"); err != nil {
+			return nil, err
+		}
+		if n.Consequence, err = f.block("consequence"); err != nil {
+			return nil, err
+		}
+		if n.Alternative, err = f.block("alternative"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case "TryExpression":
+		n := &TryExpression{
+			Token: token.Token{Typ: token.TRY, Literal: "try", Pos: pos},
+		}
+		if n.Body, err = f.block("body"); err != nil {
+			return nil, err
+		}
+		if data, ok := f["parameter"]; ok && !isNull(data) {
+			if n.Parameter, err = f.identifier("parameter"); err != nil {
+				return nil, err
+			}
+		}
+		if n.Catch, err = f.block("catch"); err != nil {
+			return nil, err
+		}
+		if n.Finally, err = f.block("finally"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case "MatchExpression":
+		n := &MatchExpression{
+			Token: token.Token{Typ: token.MATCH, Literal: "match", Pos: pos},
+		}
+		if n.Value, err = f.expression("value"); err != nil {
+			return nil, err
+		}
+		var arms []fields
+		if err := f.value("arms", &arms); err != nil {
+			return nil, err
+		}
+		for i, a := range arms {
+			arm := &MatchArm{}
+			if arm.Pattern, err = a.pattern("pattern"); err != nil {
+				return nil, fmt.Errorf("arms[%d]: %w", i, err)
+			}
+			if data, ok := a["guard"]; ok && !isNull(data) {
+				if arm.Guard, err = a.expression("guard"); err != nil {
+					return nil, fmt.Errorf("arms[%d]: %w", i, err)
+				}
+			}
+			if arm.Body, err = a.expression("body"); err != nil {
+				return nil, fmt.Errorf("arms[%d]: %w", i, err)
+			}
+			n.Arms = append(n.Arms, arm)
+		}
+		end, err := f.pos("end")
+		if err != nil {
+			return nil, err
+		}
+		n.End = token.Token{Typ: token.RIGHTBRACKET, Literal: "}", Pos: end}
+		return n, nil
+	case "FunctionLiteral":
+		n := &FunctionLiteral{
+			Token: token.Token{Typ: token.FUNCTION, Literal: "fn", Pos: pos},
+		}
+		if data, ok := f["name"]; ok && !isNull(data) {
+			if n.Name, err = f.identifier("name"); err != nil {
+				return nil, err
+			}
+		}
+		if n.Parameters, err = f.parameters("parameters"); err != nil {
+			return nil, err
+		}
+		if _, ok := f["defaults"]; ok {
+			var defaults []json.RawMessage
+			if err := f.value("defaults", &defaults); err != nil {
+				return nil, err
+			}
+			if len(defaults) != len(n.Parameters) {
+				return nil, fmt.Errorf("defaults: want %d, have %d", len(n.Parameters), len(defaults))
+			}
+			for i, d := range defaults {
+				def, err := decodeExpression(d)
+				if err != nil {
+					return nil, fmt.Errorf("defaults[%d]: %w", i, err)
+				}
+				n.Defaults = append(n.Defaults, def)
+			}
+		}
+		if data, ok := f["rest"]; ok && !isNull(data) {
+			if n.Rest, err = f.identifier("rest"); err != nil {
+				return nil, err
+			}
+		}
+		if n.Body, err = f.block("body"); err != nil {
+			return nil, err
+		}
+		n.Generator = Yields(n.Body)
+		return n, nil
+	case "MacroLiteral":
+		n := &MacroLiteral{
+			Token: token.Token{Typ: token.MACRO, Literal: "macro", Pos: pos},
+		}
+		if n.Parameters, err = f.parameters("parameters"); err != nil {
+			return nil, err
+		}
+		if n.Body, err = f.block("body"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case "CallExpression":
+		n := &CallExpression{
+			Token:     token.Token{Typ: token.LEFTPARENTHESIS, Literal: "(", Pos: pos},
+			Arguments: []Expression{},
+		}
+		if n.Function, err = f.expression("function"); err != nil {
+			return nil, err
+		}
+		var args []json.RawMessage
+		if err := f.value("arguments", &args); err != nil {
+			return nil, err
+		}
+		for i, a := range args {
+			arg, err := decodeExpression(a)
+			if err != nil {
+				return nil, fmt.Errorf("arguments[%d]: %w", i, err)
+			}
+			n.Arguments = append(n.Arguments, arg)
+		}
+		end, err := f.pos("end")
+		if err != nil {
+			return nil, err
+		}
+		n.End = token.Token{Typ: token.RIGHTPARENTHESIS, Literal: ")", Pos: end}
+		return n, nil
+	case "ArrayLiteral":
+		n := &ArrayLiteral{
+			Token:    token.Token{Typ: token.LEFTSQUARE, Literal: "[", Pos: pos},
+			Elements: []Expression{},
+		}
+		var elements []json.RawMessage
+		if err := f.value("elements", &elements); err != nil {
+			return nil, err
+		}
+		for i, e := range elements {
+			element, err := decodeExpression(e)
+			if err != nil {
+				return nil, fmt.Errorf("elements[%d]: %w", i, err)
+			}
+			n.Elements = append(n.Elements, element)
+		}
+		end, err := f.pos("end")
+		if err != nil {
+			return nil, err
+		}
+		n.End = token.Token{Typ: token.RIGHTSQUARE, Literal: "]", Pos: end}
+		return n, nil
+	case "HashLiteral":
+		n := &HashLiteral{
+			Token: token.Token{Typ: token.LEFTBRACKET, Literal: "{", Pos: pos},
+		}
+		var pairs []fields
+		if err := f.value("pairs", &pairs); err != nil {
+			return nil, err
+		}
+		for i, p := range pairs {
+			pair := &HashPair{}
+			if pair.Key, err = p.expression("key"); err != nil {
+				return nil, fmt.Errorf("pairs[%d]: %w", i, err)
+			}
+			if pair.Value, err = p.expression("value"); err != nil {
+				return nil, fmt.Errorf("pairs[%d]: %w", i, err)
+			}
+			n.Pairs = append(n.Pairs, pair)
+		}
+		end, err := f.pos("end")
+		if err != nil {
+			return nil, err
+		}
+		n.End = token.Token{Typ: token.RIGHTBRACKET, Literal: "}", Pos: end}
+		return n, nil
+	case "IndexExpression":
+		n := &IndexExpression{
+			Token: token.Token{Typ: token.LEFTSQUARE, Literal: "[", Pos: pos},
+		}
+		if n.Left, err = f.expression("left"); err != nil {
+			return nil, err
+		}
+		if n.Index, err = f.expression("index"); err != nil {
+			return nil, err
+		}
+		end, err := f.pos("end")
+		if err != nil {
+			return nil, err
+		}
+		n.End = token.Token{Typ: token.RIGHTSQUARE, Literal: "]", Pos: end}
+		return n, nil
+	case "NamedArgument":
+		n := &NamedArgument{}
+		if n.Name, err = f.identifier("name"); err != nil {
+			return nil, err
+		}
+		if n.Value, err = f.expression("value"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case "ArrayPattern":
+		n := &ArrayPattern{
+			Token: token.Token{Typ: token.LEFTSQUARE, Literal: "[", Pos: pos},
+		}
+		if n.Elements, err = f.elements("elements"); err != nil {
+			return nil, err
+		}
+		if data, ok := f["rest"]; ok && !isNull(data) {
+			if n.Rest, err = f.identifier("rest"); err != nil {
+				return nil, err
+			}
+		}
+		end, err := f.pos("end")
+		if err != nil {
+			return nil, err
+		}
+		n.End = token.Token{Typ: token.RIGHTSQUARE, Literal: "]", Pos: end}
+		return n, nil
+	case "HashPattern":
+		n := &HashPattern{
+			Token: token.Token{Typ: token.LEFTBRACKET, Literal: "{", Pos: pos},
+		}
+		if n.Elements, err = f.elements("elements"); err != nil {
+			return nil, err
+		}
+		end, err := f.pos("end")
+		if err != nil {
+			return nil, err
+		}
+		n.End = token.Token{Typ: token.RIGHTBRACKET, Literal: "}", Pos: end}
+		return n, nil
+	case "MemberExpression":
+		n := &MemberExpression{
+			Token: token.Token{Typ: token.DOT, Literal: ".", Pos: pos},
+		}
+		if n.Left, err = f.expression("left"); err != nil {
+			return nil, err
+		}
+		if n.Property, err = f.identifier("property"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	default:
+		return nil, fmt.Errorf("unknown node kind %q", kind)
+	}
+}
+
+// decodeAs decodes a node that must be of the given kind.
+func decodeAs(data json.RawMessage, kind string) (Node, error) {
+	node, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil || nodeKind(node) != kind {
+		return nil, fmt.Errorf("expected %s, got %s", kind, nodeKind(node))
+	}
+	return node, nil
+}
+
+func decodeExpression(data json.RawMessage) (Expression, error) {
+	node, err := decode(data)
+	if err != nil || node == nil {
+		return nil, err
+	}
+	e, ok := node.(Expression)
+	if !ok {
+		return nil, fmt.Errorf("expected an expression, got %s", nodeKind(node))
+	}
+	return e, nil
+}
+
+func (f fields) value(name string, v interface{}) error {
+	data, ok := f[name]
+	if !ok {
+		return fmt.Errorf("missing field %q", name)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+func (f fields) optional(name string, v interface{}) error {
+	if _, ok := f[name]; !ok {
+		return nil
+	}
+	return f.value(name, v)
+}
+
+func (f fields) pos(name string) (token.Position, error) {
+	var pos *struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	}
+	if err := f.optional(name, &pos); err != nil || pos == nil {
+		return token.Position{}, err
+	}
+	return token.Position{Line: pos.Line, Column: pos.Column}, nil
+}
+
+func (f fields) expression(name string) (Expression, error) {
+	data, ok := f[name]
+	if !ok {
+		return nil, fmt.Errorf("missing field %q", name)
+	}
+	e, err := decodeExpression(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return e, nil
+}
+
+func (f fields) pattern(name string) (Pattern, error) {
+	data, ok := f[name]
+	if !ok {
+		return nil, fmt.Errorf("missing field %q", name)
+	}
+	node, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	p, ok := node.(Pattern)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a pattern, got %s", name, nodeKind(node))
+	}
+	return p, nil
+}
+
+func (f fields) identifier(name string) (*Identifier, error) {
+	data, ok := f[name]
+	if !ok {
+		return nil, fmt.Errorf("missing field %q", name)
+	}
+	node, err := decodeAs(data, "Identifier")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return node.(*Identifier), nil
+}
+
+func (f fields) parameters(name string) ([]*Identifier, error) {
+	var params []json.RawMessage
+	if err := f.value(name, &params); err != nil {
+		return nil, err
+	}
+
+	out := []*Identifier{}
+	for i, p := range params {
+		param, err := decodeAs(p, "Identifier")
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", name, i, err)
+		}
+		out = append(out, param.(*Identifier))
+	}
+	return out, nil
+}
+
+func (f fields) elements(name string) ([]*PatternElement, error) {
+	var list []fields
+	if err := f.value(name, &list); err != nil {
+		return nil, err
+	}
+
+	var out []*PatternElement
+	for i, e := range list {
+		element := &PatternElement{}
+		var err error
+		if data, ok := e["key"]; ok && !isNull(data) {
+			if element.Key, err = e.identifier("key"); err != nil {
+				return nil, fmt.Errorf("%s[%d]: %w", name, i, err)
+			}
+		}
+		if element.Pattern, err = e.pattern("pattern"); err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", name, i, err)
+		}
+		if data, ok := e["default"]; ok && !isNull(data) {
+			if element.Default, err = e.expression("default"); err != nil {
+				return nil, fmt.Errorf("%s[%d]: %w", name, i, err)
+			}
+		}
+		out = append(out, element)
+	}
+	return out, nil
+}
+
+func (f fields) block(name string) (*BlockStatement, error) {
+	data, ok := f[name]
+	if !ok || isNull(data) {
+		return nil, nil
+	}
+	node, err := decodeAs(data, "BlockStatement")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return node.(*BlockStatement), nil
+}
+
+func (f fields) statements(name string) ([]Statement, error) {
+	var list []json.RawMessage
+	if err := f.value(name, &list); err != nil {
+		return nil, err
+	}
+
+	var out []Statement
+	for i, data := range list {
+		node, err := decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", name, i, err)
+		}
+		s, ok := node.(Statement)
+		if !ok {
+			return nil, fmt.Errorf("%s[%d]: expected a statement, got %s", name, i, nodeKind(node))
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// firstToken returns the token at which the source of e starts.
+func firstToken(e Expression) token.Token {
+	switch e := e.(type) {
+	case *InfixExpression:
+		return firstToken(e.Left)
+	case *CallExpression:
+		return firstToken(e.Function)
+	case *MemberExpression:
+		return firstToken(e.Left)
+	case *IndexExpression:
+		return firstToken(e.Left)
+	case *NamedArgument:
+		return e.Name.Token
+	case *ArrayLiteral:
+		return e.Token
+	case *HashLiteral:
+		return e.Token
+	case *Identifier:
+		return e.Token
+	case *IntegerLiteral:
+		return e.Token
+	case *StringLiteral:
+		return e.Token
+	case *BooleanLiteral:
+		return e.Token
+	case *PrefixExpression:
+		return e.Token
+	case *YieldExpression:
+		return e.Token
+	case *SpawnExpression:
+		return e.Token
+	case *ChanExpression:
+		return e.Token
+	case *SelectExpression:
+		return e.Token
+	case *IfExpression:
+		return e.Token
+	case *TryExpression:
+		return e.Token
+	case *MatchExpression:
+		return e.Token
+	case *FunctionLiteral:
+		return e.Token
+	case *MacroLiteral:
+		return e.Token
+	default:
+		return token.Token{}
+	}
+}
+
+func nodeKind(n Node) string {
+	if isNil(n) {
+		return "null"
+	}
+	return reflect.TypeOf(n).Elem().Name()
+}
+
+func isNull(data json.RawMessage) bool {
+	return len(data) == 0 || string(data) == "null"
+}
@@ -0,0 +1,222 @@
+package ast
+
+import "fmt"
+
+// ModifierFunc is called for each node by Modify, the returned
+// node replaces the visited one. It is called on the goroutine
+// calling Modify, which must be the only one using the tree.
+type ModifierFunc func(Node) Node
+
+// Modify traverses an ast in depth-first order, replacing each
+// node with the result of calling modifier on it. The children of
+// a node are modified before the node itself. If the replacement of
+// a child does not fit into its parent (e.g. a statement in place of
+// an expression) the child is left unchanged. Modify returns the
+// result of calling modifier on node.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		n.Statement = modifyStatements(n.Statement, modifier)
+	case *BlockStatement:
+		n.Statements = modifyStatements(n.Statements, modifier)
+	case *LetStatement:
+		if n.Identifier != nil {
+			if i, ok := Modify(n.Identifier, modifier).(*Identifier); ok {
+				n.Identifier = i
+			}
+		}
+		n.Pattern = modifyPattern(n.Pattern, modifier)
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *FunctionStatement:
+		if n.Function != nil {
+			if f, ok := Modify(n.Function, modifier).(*FunctionLiteral); ok {
+				n.Function = f
+			}
+		}
+	case *ImportStatement:
+		if n.Name != nil {
+			if i, ok := Modify(n.Name, modifier).(*Identifier); ok {
+				n.Name = i
+			}
+		}
+	case *ReturnStatement:
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *ThrowStatement:
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *ExpressionStatement:
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *PrefixExpression:
+		n.Right = modifyExpression(n.Right, modifier)
+	case *YieldExpression:
+		n.Value = modifyExpression(n.Value, modifier)
+	case *SpawnExpression:
+		if n.Call != nil {
+			if c, ok := Modify(n.Call, modifier).(*CallExpression); ok {
+				n.Call = c
+			}
+		}
+	case *ChanExpression:
+		n.Capacity = modifyExpression(n.Capacity, modifier)
+	case *SelectExpression:
+		for _, a := range n.Arms {
+			a.Channel = modifyExpression(a.Channel, modifier)
+			a.Value = modifyExpression(a.Value, modifier)
+			if a.Binding != nil {
+				if i, ok := Modify(a.Binding, modifier).(*Identifier); ok {
+					a.Binding = i
+				}
+			}
+			a.Body = modifyExpression(a.Body, modifier)
+		}
+	case *InfixExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Right = modifyExpression(n.Right, modifier)
+	case *IfExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Consequence = modifyBlock(n.Consequence, modifier)
+		n.Alternative = modifyBlock(n.Alternative, modifier)
+	case *TryExpression:
+		n.Body = modifyBlock(n.Body, modifier)
+		if n.Parameter != nil {
+			if i, ok := Modify(n.Parameter, modifier).(*Identifier); ok {
+				n.Parameter = i
+			}
+		}
+		n.Catch = modifyBlock(n.Catch, modifier)
+		n.Finally = modifyBlock(n.Finally, modifier)
+	case *MatchExpression:
+		n.Value = modifyExpression(n.Value, modifier)
+		for _, a := range n.Arms {
+			a.Pattern = modifyPattern(a.Pattern, modifier)
+			a.Guard = modifyExpression(a.Guard, modifier)
+			a.Body = modifyExpression(a.Body, modifier)
+		}
+	case *FunctionLiteral:
+		if n.Name != nil {
+			if i, ok := Modify(n.Name, modifier).(*Identifier); ok {
+				n.Name = i
+			}
+		}
+		for i, p := range n.Parameters {
+			if p, ok := Modify(p, modifier).(*Identifier); ok {
+				n.Parameters[i] = p
+			}
+		}
+		for i, d := range n.Defaults {
+			n.Defaults[i] = modifyExpression(d, modifier)
+		}
+		if n.Rest != nil {
+			if i, ok := Modify(n.Rest, modifier).(*Identifier); ok {
+				n.Rest = i
+			}
+		}
+		n.Body = modifyBlock(n.Body, modifier)
+	case *MacroLiteral:
+		for i, p := range n.Parameters {
+			if p, ok := Modify(p, modifier).(*Identifier); ok {
+				n.Parameters[i] = p
+			}
+		}
+		n.Body = modifyBlock(n.Body, modifier)
+	case *CallExpression:
+		n.Function = modifyExpression(n.Function, modifier)
+		for i, a := range n.Arguments {
+			n.Arguments[i] = modifyExpression(a, modifier)
+		}
+	case *NamedArgument:
+		if n.Name != nil {
+			if i, ok := Modify(n.Name, modifier).(*Identifier); ok {
+				n.Name = i
+			}
+		}
+		n.Value = modifyExpression(n.Value, modifier)
+	case *ArrayLiteral:
+		for i, e := range n.Elements {
+			n.Elements[i] = modifyExpression(e, modifier)
+		}
+	case *HashLiteral:
+		for _, p := range n.Pairs {
+			p.Key = modifyExpression(p.Key, modifier)
+			p.Value = modifyExpression(p.Value, modifier)
+		}
+	case *IndexExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Index = modifyExpression(n.Index, modifier)
+	case *ArrayPattern:
+		modifyElements(n.Elements, modifier)
+		if n.Rest != nil {
+			if i, ok := Modify(n.Rest, modifier).(*Identifier); ok {
+				n.Rest = i
+			}
+		}
+	case *HashPattern:
+		modifyElements(n.Elements, modifier)
+	case *MemberExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		if n.Property != nil {
+			if i, ok := Modify(n.Property, modifier).(*Identifier); ok {
+				n.Property = i
+			}
+		}
+	case *Identifier, *IntegerLiteral, *StringLiteral, *BooleanLiteral:
+		// nothing to do
+	default:
+		panic(fmt.Sprintf("ast.Modify: unexpected node type %T", n))
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(list []Statement, modifier ModifierFunc) []Statement {
+	for i, s := range list {
+		if s == nil {
+			continue
+		}
+		if s, ok := Modify(s, modifier).(Statement); ok {
+			list[i] = s
+		}
+	}
+	return list
+}
+
+func modifyExpression(e Expression, modifier ModifierFunc) Expression {
+	if e == nil {
+		return nil
+	}
+	if m, ok := Modify(e, modifier).(Expression); ok {
+		return m
+	}
+	return e
+}
+
+func modifyPattern(p Pattern, modifier ModifierFunc) Pattern {
+	if p == nil {
+		return nil
+	}
+	if m, ok := Modify(p, modifier).(Pattern); ok {
+		return m
+	}
+	return p
+}
+
+func modifyElements(list []*PatternElement, modifier ModifierFunc) {
+	for _, e := range list {
+		if e.Key != nil {
+			if i, ok := Modify(e.Key, modifier).(*Identifier); ok {
+				e.Key = i
+			}
+		}
+		e.Pattern = modifyPattern(e.Pattern, modifier)
+		e.Default = modifyExpression(e.Default, modifier)
+	}
+}
+
+func modifyBlock(b *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	if m, ok := Modify(b, modifier).(*BlockStatement); ok {
+		return m
+	}
+	return b
+}
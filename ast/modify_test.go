@@ -0,0 +1,74 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/Despire/interpreter/ast"
+)
+
+func TestModify(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1", "2"},
+		{"1 + 2", "2 + 2"},
+		{"-1", "-2"},
+		{"let a = 1;", "let a = 2;"},
+		{"return 1;", "return 2;"},
+		{"if (1) { 1 } else { 1 }", "if (2) { 2 } else { 2 }"},
+		{"fn(x) { 1 }", "fn(x) { 2 }"},
+		{"f(1, 1)(1)", "f(2, 2)(2)"},
+	}
+
+	turnOneIntoTwo := func(n ast.Node) ast.Node {
+		integer, ok := n.(*ast.IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return n
+		}
+
+		integer.Value = 2
+		return integer
+	}
+
+	for _, tt := range tests {
+		have := ast.Modify(parse(t, tt.input), turnOneIntoTwo)
+		want := parse(t, tt.expected)
+
+		if !ast.Equal(have, want) {
+			t.Errorf("Modify(%q) = %q, want %q", tt.input, have.String(), want.String())
+		}
+	}
+}
+
+func TestModifyReplacesNodes(t *testing.T) {
+	program := parse(t, "a + b; let c = a;")
+
+	renamed := ast.Modify(program, func(n ast.Node) ast.Node {
+		if i, ok := n.(*ast.Identifier); ok && i.Value == "a" {
+			return &ast.Identifier{Token: i.Token, Value: "x"}
+		}
+		return n
+	})
+
+	want := parse(t, "x + b; let c = x;")
+	if !ast.Equal(renamed, want) {
+		t.Errorf("have %q, want %q", renamed.String(), want.String())
+	}
+}
+
+func TestModifyKeepsMismatchedReplacements(t *testing.T) {
+	program := parse(t, "if (true) { 1 }")
+
+	// a statement can not replace the condition of the if expression.
+	modified := ast.Modify(program, func(n ast.Node) ast.Node {
+		if _, ok := n.(*ast.BooleanLiteral); ok {
+			return &ast.BlockStatement{}
+		}
+		return n
+	})
+
+	if !ast.Equal(modified, parse(t, "if (true) { 1 }")) {
+		t.Errorf("invalid replacement was applied, have %q", modified.String())
+	}
+}
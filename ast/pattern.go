@@ -0,0 +1,36 @@
+package ast
+
+// Wildcard is the name of the pattern that matches
+// any value without binding it.
+const Wildcard = "_"
+
+// Bindings returns the identifiers bound by pattern, in order.
+func Bindings(pattern Pattern) []*Identifier {
+	var ids []*Identifier
+
+	switch p := pattern.(type) {
+	case *Identifier:
+		if p.Value != Wildcard {
+			ids = append(ids, p)
+		}
+	case *ArrayPattern:
+		for _, e := range p.Elements {
+			ids = append(ids, Bindings(e.Pattern)...)
+		}
+		if p.Rest != nil && p.Rest.Value != Wildcard {
+			ids = append(ids, p.Rest)
+		}
+	case *HashPattern:
+		for _, e := range p.Elements {
+			ids = append(ids, Bindings(e.Pattern)...)
+		}
+	}
+
+	return ids
+}
+
+// Irrefutable reports whether pattern matches every value.
+func Irrefutable(pattern Pattern) bool {
+	_, ok := pattern.(*Identifier)
+	return ok
+}
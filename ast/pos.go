@@ -0,0 +1,81 @@
+package ast
+
+import "github.com/Despire/interpreter/token"
+
+// Pos returns the position at which the source of node starts.
+// The zero position is returned when it is unknown.
+func Pos(node Node) token.Position {
+	if isNil(node) {
+		return token.Position{}
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		if len(n.Statement) > 0 {
+			return Pos(n.Statement[0])
+		}
+		return token.Position{}
+	case *InfixExpression:
+		return Pos(n.Left)
+	case *CallExpression:
+		return Pos(n.Function)
+	case *MemberExpression:
+		return Pos(n.Left)
+	case *IndexExpression:
+		return Pos(n.Left)
+	case *NamedArgument:
+		return Pos(n.Name)
+	case *BlockStatement:
+		return n.Token.Pos
+	case *LetStatement:
+		return n.Token.Pos
+	case *FunctionStatement:
+		return Pos(n.Function)
+	case *ImportStatement:
+		return n.Token.Pos
+	case *ReturnStatement:
+		return n.Token.Pos
+	case *ThrowStatement:
+		return n.Token.Pos
+	case *ExpressionStatement:
+		return n.Token.Pos
+	case *Identifier:
+		return n.Token.Pos
+	case *IntegerLiteral:
+		return n.Token.Pos
+	case *StringLiteral:
+		return n.Token.Pos
+	case *BooleanLiteral:
+		return n.Token.Pos
+	case *PrefixExpression:
+		return n.Token.Pos
+	case *YieldExpression:
+		return n.Token.Pos
+	case *SpawnExpression:
+		return n.Token.Pos
+	case *ChanExpression:
+		return n.Token.Pos
+	case *SelectExpression:
+		return n.Token.Pos
+	case *IfExpression:
+		return n.Token.Pos
+	case *TryExpression:
+		return n.Token.Pos
+	case *MatchExpression:
+		return n.Token.Pos
+	case *ArrayLiteral:
+		return n.Token.Pos
+	case *HashLiteral:
+		return n.Token.Pos
+	case *ArrayPattern:
+		return n.Token.Pos
+	case *HashPattern:
+		return n.Token.Pos
+	case *FunctionLiteral:
+		return n.Token.Pos
+	case *MacroLiteral:
+		return n.Token.Pos
+	default:
+		return token.Position{}
+	}
+}
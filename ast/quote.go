@@ -0,0 +1,58 @@
+package ast
+
+// The names of the calls that quote an expression and that
+// splice the value of an expression into a quoted one.
+const (
+	Quote   = "quote"
+	Unquote = "unquote"
+)
+
+// IsCallTo reports whether node is a call of the identifier name.
+func IsCallTo(node Node, name string) bool {
+	call, ok := node.(*CallExpression)
+	if !ok {
+		return false
+	}
+	id, ok := call.Function.(*Identifier)
+	return ok && id.Value == name
+}
+
+// Unquotes returns the calls of unquote in the quoted node that are
+// evaluated when it is quoted. Each quote nested in node quotes its
+// argument once more and each unquote removes one level, the calls
+// that remove the last level are returned, in depth-first order.
+// The calls nested in their arguments are not returned.
+func Unquotes(quoted Node) []*CallExpression {
+	var (
+		calls  []*CallExpression
+		levels []int
+		level  = 1
+	)
+
+	Inspect(quoted, func(n Node) bool {
+		if n == nil {
+			level -= levels[len(levels)-1]
+			levels = levels[:len(levels)-1]
+			return false
+		}
+
+		d := 0
+		switch {
+		case IsCallTo(n, Quote):
+			d = 1
+		case IsCallTo(n, Unquote):
+			d = -1
+		}
+
+		if level+d == 0 {
+			calls = append(calls, n.(*CallExpression))
+			return false
+		}
+
+		level += d
+		levels = append(levels, d)
+		return true
+	})
+
+	return calls
+}
@@ -10,6 +10,31 @@ type (
 	// FunctionLiteral represents a function
 	// expression.
 	FunctionLiteral struct {
+		Token token.Token
+		// Name is the name of a function declared by
+		// a FunctionStatement, nil for function expressions.
+		Name       *Identifier
+		Parameters []*Identifier
+		// Defaults holds the default value of each parameter, nil
+		// for the parameters without one. Only the last parameters
+		// can have a default value.
+		Defaults []Expression
+		// Rest is bound to the array of the surplus
+		// arguments of a call (fn(x, ...rest) {}), if any.
+		Rest *Identifier
+		Body *BlockStatement
+		// Generator is set if the body yields, a call of
+		// the function returns a generator running it.
+		Generator bool
+
+		// Set by the resolver to the name bound to each slot
+		// of the environment of a call of the function.
+		Locals []string
+	}
+
+	// MacroLiteral represents a macro, its body is evaluated
+	// with the quoted arguments of a call when macros are expanded.
+	MacroLiteral struct {
 		Token      token.Token
 		Parameters []*Identifier
 		Body       *BlockStatement
@@ -20,6 +45,7 @@ type (
 	BlockStatement struct {
 		Token      token.Token
 		Statements []Statement
+		End        token.Token // the closing '}'
 	}
 
 	// Identifier represents a value that is binded
@@ -27,6 +53,14 @@ type (
 	Identifier struct {
 		Token token.Token
 		Value string
+
+		// Set by the resolver when the binding of the identifier
+		// is known statically. The binding lives in the environment
+		// Depth levels up from the one the identifier is evaluated in,
+		// at the given Slot of that environment.
+		Resolved bool
+		Depth    int
+		Slot     int
 	}
 
 	// IntegerLiteral represents an integer expression.
@@ -35,6 +69,12 @@ type (
 		Value int
 	}
 
+	// StringLiteral represents a string expression.
+	StringLiteral struct {
+		Token token.Token
+		Value string
+	}
+
 	// BooleanLiteral represents an boolean expression.
 	BooleanLiteral struct {
 		Token token.Token
@@ -49,12 +89,120 @@ type (
 		Alternative *BlockStatement
 	}
 
+	// TryExpression evaluates Body, if it throws an error the
+	// error is bound to Parameter and Catch is evaluated. Finally
+	// is evaluated last in either case. Catch or Finally may be nil.
+	// The catch clause has a scope of its own, which binds Parameter
+	// and the names declared in Catch.
+	TryExpression struct {
+		Token     token.Token
+		Body      *BlockStatement
+		Parameter *Identifier
+		Catch     *BlockStatement
+		Finally   *BlockStatement
+
+		// Set by the resolver to the name bound to each slot
+		// of the environment of the catch clause.
+		Locals []string
+	}
+
+	// MatchExpression evaluates the Body of the first of its Arms
+	// whose Pattern matches the Value and whose Guard, if any, is
+	// truthy with the names bound by the pattern. Each arm has a
+	// scope of its own, which binds the names of its Pattern and the
+	// names declared in its Guard and Body.
+	MatchExpression struct {
+		Token token.Token
+		Value Expression
+		Arms  []*MatchArm
+		End   token.Token // the closing '}'
+	}
+
+	// MatchArm is a case of a match expression (pattern if guard => body).
+	MatchArm struct {
+		Pattern Pattern
+		Guard   Expression
+		Body    Expression
+
+		// Set by the resolver to the name bound to each slot of the
+		// environment of the arm, it is empty if the arm binds no names.
+		Locals []string
+	}
+
+	// ArrayLiteral represents an array expression ([1, 2, 3]).
+	ArrayLiteral struct {
+		Token    token.Token // the '['
+		Elements []Expression
+		End      token.Token // the closing ']'
+	}
+
+	// HashLiteral represents a hash expression ({name: "Ann", 1: 2}).
+	// A key that is an identifier stands for the string of its name.
+	HashLiteral struct {
+		Token token.Token // the '{'
+		Pairs []*HashPair
+		End   token.Token // the closing '}'
+	}
+
+	// HashPair is a key and its value in a hash literal.
+	HashPair struct {
+		Key   Expression
+		Value Expression
+	}
+
+	// IndexExpression selects an element of an
+	// array or a value of a hash (e.g. xs[0]).
+	IndexExpression struct {
+		Token token.Token // the '['
+		Left  Expression
+		Index Expression
+		End   token.Token // the closing ']'
+	}
+
+	// ArrayPattern matches the arrays whose elements match its
+	// Elements, with Rest bound to the array of the elements left
+	// over, if any ([a, b = 0, ...rest]). Without Rest the array
+	// can not have more elements than the pattern.
+	ArrayPattern struct {
+		Token    token.Token // the '['
+		Elements []*PatternElement
+		Rest     *Identifier
+		End      token.Token // the closing ']'
+	}
+
+	// HashPattern matches the hashes whose values of the keys
+	// of its Elements match their patterns ({name, age: years}).
+	HashPattern struct {
+		Token    token.Token // the '{'
+		Elements []*PatternElement
+		End      token.Token // the closing '}'
+	}
+
+	// PatternElement is an element of an array or a hash pattern.
+	// The Default is matched instead of a missing element, if the
+	// element has one. Key is the name of the key of an element of
+	// a hash pattern, it is nil in array patterns.
+	PatternElement struct {
+		Key     *Identifier
+		Pattern Pattern
+		Default Expression
+	}
+
 	// CallExpression represents a function
-	// call expresion.
+	// call expresion. The named arguments
+	// follow the positional ones.
 	CallExpression struct {
 		Token     token.Token
 		Function  Expression
 		Arguments []Expression
+		End       token.Token // the closing ')'
+	}
+
+	// NamedArgument is an argument of a call passed
+	// by the name of its parameter (f(y: 2)).
+	NamedArgument struct {
+		Name  *Identifier
+		Value Expression
 	}
 
 	// PrefixExpression represents an operator
@@ -65,6 +213,50 @@ type (
 		Right    Expression
 	}
 
+	// YieldExpression suspends the generator whose body it is
+	// in, handing Value to the caller of next (yield x). It
+	// evaluates to the argument of the next call of next.
+	YieldExpression struct {
+		Token token.Token
+		Value Expression
+	}
+
+	// SpawnExpression starts a task evaluating the Call (spawn f(x)),
+	// the function and the arguments are evaluated by the spawning task.
+	SpawnExpression struct {
+		Token token.Token
+		Call  *CallExpression
+	}
+
+	// ChanExpression creates a channel buffering up to Capacity
+	// values (chan(n)), an unbuffered one if Capacity is nil.
+	ChanExpression struct {
+		Token    token.Token
+		Capacity Expression
+	}
+
+	// SelectExpression performs the channel operation of the first of
+	// its Arms that can proceed and evaluates the Body of that arm. If
+	// none can it blocks until one can, unless it has a default arm.
+	SelectExpression struct {
+		Token token.Token
+		Arms  []*SelectArm
+		End   token.Token // the closing '}'
+	}
+
+	// SelectArm is a case of a select expression: a receive from
+	// Channel, whose value is bound to Binding if it is not nil
+	// (ch.recv() as v => body), a send of Value to Channel
+	// (ch.send(v) => body) or the default arm (_ => body), which
+	// has no Channel.
+	SelectArm struct {
+		Token   token.Token // the first token of the arm.
+		Channel Expression
+		Value   Expression
+		Binding *Identifier
+		Body    Expression
+	}
+
 	// InfixExpression represents an binary
 	// operator that contains a left, right expression.
 	InfixExpression struct {
@@ -80,10 +272,39 @@ type (
 	// In the case of the LetStatement the Identifier will have
 	// no value, since the value will be assigned after the
 	// evaluation of the statement.
+	// A destructuring let statement (let [a, b] = ...) has
+	// an array or hash Pattern instead of the Identifier.
 	LetStatement struct {
 		Identifier *Identifier
+		Pattern    Pattern
 		Expression Expression
 		Token      token.Token
+		// Exported is set for 'export let', the name
+		// is then visible to the importers of the module.
+		Exported bool
+	}
+
+	// FunctionStatement declares the named Function (fn name() {}).
+	// The declarations of a block are hoisted, the name is bound
+	// before the first statement of the block is evaluated.
+	FunctionStatement struct {
+		Function *FunctionLiteral
+	}
+
+	// ImportStatement binds the module loaded
+	// from Path to the Name (import "path" as name;).
+	ImportStatement struct {
+		Token token.Token
+		Path  string
+		Name  *Identifier
+	}
+
+	// MemberExpression selects the exported
+	// member of a module (e.g. math.max).
+	MemberExpression struct {
+		Token    token.Token // the '.'
+		Left     Expression
+		Property *Identifier
 	}
 
 	// ReturnStatement consists of the
@@ -93,6 +314,13 @@ type (
 		Expression Expression
 	}
 
+	// ThrowStatement throws the value of
+	// its expression (e.g. throw "bad input";).
+	ThrowStatement struct {
+		Token      token.Token
+		Expression Expression
+	}
+
 	// ExpressionStatement represents a single line
 	// that consist only of a single expression (e.g y + 2;)
 	ExpressionStatement struct {
@@ -120,6 +348,21 @@ func (fl *CallExpression) String() string {
 	return buff.String()
 }
 
+// implement the Expression interface for type checking.
+func (na *NamedArgument) expression()     {}
+func (na *NamedArgument) Literal() string { return na.Name.Literal() }
+func (na *NamedArgument) String() string {
+	return na.Name.String() + ": " + na.Value.String()
+}
+
+// Default returns the default value of the i-th parameter, or nil.
+func (fl *FunctionLiteral) Default(i int) Expression {
+	if i < len(fl.Defaults) {
+		return fl.Defaults[i]
+	}
+	return nil
+}
+
 // implement the Expression interface for type checking.
 func (fl *FunctionLiteral) expression()     {}
 func (fl *FunctionLiteral) Literal() string { return fl.Token.Literal }
@@ -127,11 +370,21 @@ func (fl *FunctionLiteral) String() string {
 	buff := new(strings.Builder)
 
 	params := []string{}
-	for _, p := range fl.Parameters {
+	for i, p := range fl.Parameters {
+		if d := fl.Default(i); d != nil {
+			params = append(params, p.String()+" = "+d.String())
+			continue
+		}
 		params = append(params, p.String())
 	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
+	}
 
 	buff.WriteString(fl.Literal())
+	if fl.Name != nil {
+		buff.WriteString(" " + fl.Name.String())
+	}
 	buff.WriteString("(")
 	buff.WriteString(strings.Join(params, ", "))
 	buff.WriteString(") ")
@@ -140,6 +393,26 @@ func (fl *FunctionLiteral) String() string {
 	return buff.String()
 }
 
+// implement the Expression interface for type checking.
+func (ml *MacroLiteral) expression()     {}
+func (ml *MacroLiteral) Literal() string { return ml.Token.Literal }
+func (ml *MacroLiteral) String() string {
+	buff := new(strings.Builder)
+
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+
+	buff.WriteString(ml.Literal())
+	buff.WriteString("(")
+	buff.WriteString(strings.Join(params, ", "))
+	buff.WriteString(") ")
+	buff.WriteString(ml.Body.String())
+
+	return buff.String()
+}
+
 // implement the Statement interface for type checking.
 func (bs *BlockStatement) statement()      {}
 func (bs *BlockStatement) Literal() string { return bs.Token.Literal }
@@ -176,6 +449,158 @@ func (ie *IfExpression) String() string {
 func (bl *BooleanLiteral) expression()     {}
 func (bl *BooleanLiteral) Literal() string { return bl.Token.Literal }
 func (bl *BooleanLiteral) String() string  { return bl.Token.Literal }
+func (bl *BooleanLiteral) pattern()        {}
+
+// NewBoolean returns the literal of value at pos.
+func NewBoolean(value bool, pos token.Position) *BooleanLiteral {
+	t := token.Token{Typ: token.FALSE, Literal: "false", Pos: pos}
+	if value {
+		t = token.Token{Typ: token.TRUE, Literal: "true", Pos: pos}
+	}
+	return &BooleanLiteral{Token: t, Value: value}
+}
+
+// implement Expression interface for type checking.
+func (te *TryExpression) expression()     {}
+func (te *TryExpression) Literal() string { return te.Token.Literal }
+func (te *TryExpression) String() string {
+	buff := new(strings.Builder)
+
+	buff.WriteString("try ")
+	buff.WriteString(te.Body.String())
+
+	if te.Catch != nil {
+		buff.WriteString(" catch(" + te.Parameter.String() + ") ")
+		buff.WriteString(te.Catch.String())
+	}
+
+	if te.Finally != nil {
+		buff.WriteString(" finally ")
+		buff.WriteString(te.Finally.String())
+	}
+
+	return buff.String()
+}
+
+// implement Expression interface for type checking.
+func (me *MatchExpression) expression()     {}
+func (me *MatchExpression) Literal() string { return me.Token.Literal }
+func (me *MatchExpression) String() string {
+	buff := new(strings.Builder)
+
+	arms := []string{}
+	for _, a := range me.Arms {
+		arms = append(arms, a.String())
+	}
+
+	buff.WriteString("match ")
+	buff.WriteString(me.Value.String())
+	buff.WriteString(" {")
+	buff.WriteString(strings.Join(arms, ", "))
+	buff.WriteString("}")
+
+	return buff.String()
+}
+
+func (a *MatchArm) String() string {
+	buff := new(strings.Builder)
+
+	buff.WriteString(a.Pattern.String())
+	if a.Guard != nil {
+		buff.WriteString(" if " + a.Guard.String())
+	}
+	buff.WriteString(" => ")
+	buff.WriteString(a.Body.String())
+
+	return buff.String()
+}
+
+// implement Expression interface for type checking.
+func (al *ArrayLiteral) expression()     {}
+func (al *ArrayLiteral) Literal() string { return al.Token.Literal }
+func (al *ArrayLiteral) String() string {
+	elements := []string{}
+	for _, e := range al.Elements {
+		elements = append(elements, e.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// implement Expression interface for type checking.
+func (hl *HashLiteral) expression()     {}
+func (hl *HashLiteral) Literal() string { return hl.Token.Literal }
+func (hl *HashLiteral) String() string {
+	pairs := []string{}
+	for _, p := range hl.Pairs {
+		pairs = append(pairs, p.Key.String()+": "+p.Value.String())
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// implement Expression interface for type checking.
+func (ie *IndexExpression) expression()     {}
+func (ie *IndexExpression) Literal() string { return ie.Token.Literal }
+func (ie *IndexExpression) String() string {
+	return "(" + ie.Left.String() + "[" + ie.Index.String() + "])"
+}
+
+// implement Pattern interface for type checking.
+func (ap *ArrayPattern) pattern()        {}
+func (ap *ArrayPattern) Literal() string { return ap.Token.Literal }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, e := range ap.Elements {
+		elements = append(elements, e.String())
+	}
+
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// implement Pattern interface for type checking.
+func (hp *HashPattern) pattern()        {}
+func (hp *HashPattern) Literal() string { return hp.Token.Literal }
+func (hp *HashPattern) String() string {
+	elements := []string{}
+	for _, e := range hp.Elements {
+		elements = append(elements, e.String())
+	}
+
+	return "{" + strings.Join(elements, ", ") + "}"
+}
+
+func (pe *PatternElement) String() string {
+	buff := new(strings.Builder)
+
+	if pe.Key != nil && !pe.Shorthand() {
+		buff.WriteString(pe.Key.String() + ": ")
+	}
+	buff.WriteString(pe.Pattern.String())
+
+	if pe.Default != nil {
+		buff.WriteString(" = " + pe.Default.String())
+	}
+
+	return buff.String()
+}
+
+// Shorthand reports whether the element of a hash pattern binds
+// the value of its key to the name of the key ({name}).
+func (pe *PatternElement) Shorthand() bool {
+	id, ok := pe.Pattern.(*Identifier)
+	return ok && pe.Key != nil && id.Value == pe.Key.Value
+}
+
+// implement Expression interface for type checking.
+func (sl *StringLiteral) expression()     {}
+func (sl *StringLiteral) Literal() string { return sl.Token.Literal }
+func (sl *StringLiteral) String() string  { return "\"" + sl.Value + "\"" }
+func (sl *StringLiteral) pattern()        {}
 
 // implement Expression interface for type checking.
 func (ie *InfixExpression) expression()     {}
@@ -206,14 +631,78 @@ func (pe *PrefixExpression) String() string {
 	return buff.String()
 }
 
+// implement expression interface for type checking.
+func (y *YieldExpression) expression()     {}
+func (y *YieldExpression) Literal() string { return y.Token.Literal }
+func (y *YieldExpression) String() string {
+	return "(" + y.Literal() + " " + y.Value.String() + ")"
+}
+
+func (s *SpawnExpression) expression()     {}
+func (s *SpawnExpression) Literal() string { return s.Token.Literal }
+func (s *SpawnExpression) String() string {
+	return "(" + s.Literal() + " " + s.Call.String() + ")"
+}
+
+func (c *ChanExpression) expression()     {}
+func (c *ChanExpression) Literal() string { return c.Token.Literal }
+func (c *ChanExpression) String() string {
+	if c.Capacity == nil {
+		return c.Literal() + "()"
+	}
+	return c.Literal() + "(" + c.Capacity.String() + ")"
+}
+
+func (se *SelectExpression) expression()     {}
+func (se *SelectExpression) Literal() string { return se.Token.Literal }
+func (se *SelectExpression) String() string {
+	arms := []string{}
+	for _, a := range se.Arms {
+		arms = append(arms, a.String())
+	}
+
+	return "select {" + strings.Join(arms, ", ") + "}"
+}
+
+// IsDefault reports whether a is the default arm of its select expression.
+func (a *SelectArm) IsDefault() bool { return a.Channel == nil }
+
+func (a *SelectArm) String() string {
+	buff := new(strings.Builder)
+
+	switch {
+	case a.IsDefault():
+		buff.WriteString(Wildcard)
+	case a.Value != nil:
+		buff.WriteString(a.Channel.String() + ".send(" + a.Value.String() + ")")
+	default:
+		buff.WriteString(a.Channel.String() + ".recv()")
+		if a.Binding != nil {
+			buff.WriteString(" as " + a.Binding.String())
+		}
+	}
+	buff.WriteString(" => ")
+	buff.WriteString(a.Body.String())
+
+	return buff.String()
+}
+
 // implement Statement interface for type checking.
 func (s *LetStatement) statement()      {}
 func (s *LetStatement) Literal() string { return s.Token.Literal }
 func (s *LetStatement) String() string {
 	buff := new(strings.Builder)
 
+	if s.Exported {
+		buff.WriteString("export ")
+	}
+
 	buff.WriteString(s.Literal() + " ")
-	buff.WriteString(s.Identifier.String())
+	if s.Pattern != nil {
+		buff.WriteString(s.Pattern.String())
+	} else {
+		buff.WriteString(s.Identifier.String())
+	}
 	buff.WriteString(" = ")
 
 	if s.Expression != nil {
@@ -225,15 +714,44 @@ func (s *LetStatement) String() string {
 	return buff.String()
 }
 
+// implement Statement interface for type checking.
+func (s *FunctionStatement) statement()      {}
+func (s *FunctionStatement) Literal() string { return s.Function.Literal() }
+func (s *FunctionStatement) String() string  { return s.Function.String() }
+
+// Name returns the name of the declared function.
+func (s *FunctionStatement) Name() *Identifier {
+	if s.Function == nil {
+		return nil
+	}
+	return s.Function.Name
+}
+
+// implement Statement interface for type checking.
+func (s *ImportStatement) statement()      {}
+func (s *ImportStatement) Literal() string { return s.Token.Literal }
+func (s *ImportStatement) String() string {
+	return s.Literal() + " \"" + s.Path + "\" as " + s.Name.String() + ";"
+}
+
+// implement Expression interface for type checking.
+func (me *MemberExpression) expression()     {}
+func (me *MemberExpression) Literal() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+	return me.Left.String() + "." + me.Property.String()
+}
+
 // implement Expression interface for type checking.
 func (i *Identifier) expression()     {}
 func (i *Identifier) Literal() string { return i.Token.Literal }
 func (i *Identifier) String() string  { return i.Value }
+func (i *Identifier) pattern()        {}
 
 // implement Expression interface for type checking.
 func (il *IntegerLiteral) expression()     {}
 func (il *IntegerLiteral) Literal() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string  { return il.Token.Literal }
+func (il *IntegerLiteral) pattern()        {}
 
 // implement Statement interface for type checking.
 func (r *ReturnStatement) statement()      {}
@@ -251,6 +769,22 @@ func (r *ReturnStatement) String() string {
 	return buff.String()
 }
 
+// implement Statement interface for type checking.
+func (t *ThrowStatement) statement()      {}
+func (t *ThrowStatement) Literal() string { return t.Token.Literal }
+func (t *ThrowStatement) String() string {
+	buff := new(strings.Builder)
+
+	buff.WriteString(t.Literal() + " ")
+	if t.Expression != nil {
+		buff.WriteString(t.Expression.String())
+	}
+
+	buff.WriteString(";")
+
+	return buff.String()
+}
+
 // implement Statement interface for type checking.
 func (e *ExpressionStatement) statement()      {}
 func (e *ExpressionStatement) Literal() string { return e.Token.Literal }
@@ -0,0 +1,276 @@
+package ast
+
+import "fmt"
+
+// Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+// Walk calls it on the calling goroutine only, a visitor need not be
+// safe for concurrent use unless it is shared by concurrent walks.
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an ast in depth-first order: It starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor
+// w for each of the non-nil children of node, followed by a call of
+// w.Visit(nil).
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statement)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		if n.Identifier != nil {
+			Walk(v, n.Identifier)
+		}
+		if n.Pattern != nil {
+			Walk(v, n.Pattern)
+		}
+		if n.Expression != nil {
+			Walk(v, n.Expression)
+		}
+	case *FunctionStatement:
+		if n.Function != nil {
+			Walk(v, n.Function)
+		}
+	case *ImportStatement:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+	case *ReturnStatement:
+		if n.Expression != nil {
+			Walk(v, n.Expression)
+		}
+	case *ThrowStatement:
+		if n.Expression != nil {
+			Walk(v, n.Expression)
+		}
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			Walk(v, n.Expression)
+		}
+	case *PrefixExpression:
+		if n.Right != nil {
+			Walk(v, n.Right)
+		}
+	case *YieldExpression:
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+	case *SpawnExpression:
+		if n.Call != nil {
+			Walk(v, n.Call)
+		}
+	case *ChanExpression:
+		if n.Capacity != nil {
+			Walk(v, n.Capacity)
+		}
+	case *SelectExpression:
+		for _, a := range n.Arms {
+			if a.Channel != nil {
+				Walk(v, a.Channel)
+			}
+			if a.Value != nil {
+				Walk(v, a.Value)
+			}
+			if a.Binding != nil {
+				Walk(v, a.Binding)
+			}
+			if a.Body != nil {
+				Walk(v, a.Body)
+			}
+		}
+	case *InfixExpression:
+		if n.Left != nil {
+			Walk(v, n.Left)
+		}
+		if n.Right != nil {
+			Walk(v, n.Right)
+		}
+	case *IfExpression:
+		if n.Condition != nil {
+			Walk(v, n.Condition)
+		}
+		if n.Consequence != nil {
+			Walk(v, n.Consequence)
+		}
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+	case *TryExpression:
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+		if n.Parameter != nil {
+			Walk(v, n.Parameter)
+		}
+		if n.Catch != nil {
+			Walk(v, n.Catch)
+		}
+		if n.Finally != nil {
+			Walk(v, n.Finally)
+		}
+	case *MatchExpression:
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+		for _, a := range n.Arms {
+			if a.Pattern != nil {
+				Walk(v, a.Pattern)
+			}
+			if a.Guard != nil {
+				Walk(v, a.Guard)
+			}
+			if a.Body != nil {
+				Walk(v, a.Body)
+			}
+		}
+	case *FunctionLiteral:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		for i, p := range n.Parameters {
+			Walk(v, p)
+			if d := n.Default(i); d != nil {
+				Walk(v, d)
+			}
+		}
+		if n.Rest != nil {
+			Walk(v, n.Rest)
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *MacroLiteral:
+		for _, p := range n.Parameters {
+			Walk(v, p)
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *CallExpression:
+		if n.Function != nil {
+			Walk(v, n.Function)
+		}
+		walkExpressions(v, n.Arguments)
+	case *NamedArgument:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *HashLiteral:
+		for _, p := range n.Pairs {
+			if p.Key != nil {
+				Walk(v, p.Key)
+			}
+			if p.Value != nil {
+				Walk(v, p.Value)
+			}
+		}
+	case *IndexExpression:
+		if n.Left != nil {
+			Walk(v, n.Left)
+		}
+		if n.Index != nil {
+			Walk(v, n.Index)
+		}
+	case *ArrayPattern:
+		walkElements(v, n.Elements)
+		if n.Rest != nil {
+			Walk(v, n.Rest)
+		}
+	case *HashPattern:
+		walkElements(v, n.Elements)
+	case *MemberExpression:
+		if n.Left != nil {
+			Walk(v, n.Left)
+		}
+		if n.Property != nil {
+			Walk(v, n.Property)
+		}
+	case *Identifier, *IntegerLiteral, *StringLiteral, *BooleanLiteral:
+		// nothing to do
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, list []Statement) {
+	for _, s := range list {
+		if s != nil {
+			Walk(v, s)
+		}
+	}
+}
+
+func walkExpressions(v Visitor, list []Expression) {
+	for _, e := range list {
+		if e != nil {
+			Walk(v, e)
+		}
+	}
+}
+
+func walkElements(v Visitor, list []*PatternElement) {
+	for _, e := range list {
+		if e.Key != nil {
+			Walk(v, e.Key)
+		}
+		if e.Pattern != nil {
+			Walk(v, e.Pattern)
+		}
+		if e.Default != nil {
+			Walk(v, e.Default)
+		}
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an ast in depth-first order: It starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Yields reports whether the body of a function yields, the yield
+// expressions of the functions nested in it do not count.
+func Yields(body *BlockStatement) bool {
+	yields := false
+	if body == nil {
+		return false
+	}
+
+	Inspect(body, func(n Node) bool {
+		switch n.(type) {
+		case *YieldExpression:
+			yields = true
+		case *FunctionLiteral, *MacroLiteral:
+			return false
+		}
+		return !yields
+	})
+	return yields
+}
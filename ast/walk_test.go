@@ -0,0 +1,98 @@
+package ast_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/lexer"
+	"github.com/Despire/interpreter/parser"
+)
+
+func TestInspect(t *testing.T) {
+	input := `
+let add = fn(x, y) { return x + y; };
+if (!true) { add(1, -2) } else { add(3, 4) };`
+
+	expected := []string{
+		"*ast.Program",
+		"*ast.LetStatement", "*ast.Identifier",
+		"*ast.FunctionLiteral", "*ast.Identifier", "*ast.Identifier",
+		"*ast.BlockStatement", "*ast.ReturnStatement",
+		"*ast.InfixExpression", "*ast.Identifier", "*ast.Identifier",
+		"*ast.ExpressionStatement", "*ast.IfExpression",
+		"*ast.PrefixExpression", "*ast.BooleanLiteral",
+		"*ast.BlockStatement", "*ast.ExpressionStatement",
+		"*ast.CallExpression", "*ast.Identifier", "*ast.IntegerLiteral",
+		"*ast.PrefixExpression", "*ast.IntegerLiteral",
+		"*ast.BlockStatement", "*ast.ExpressionStatement",
+		"*ast.CallExpression", "*ast.Identifier", "*ast.IntegerLiteral", "*ast.IntegerLiteral",
+	}
+
+	have := []string{}
+	ast.Inspect(parse(t, input), func(n ast.Node) bool {
+		if n != nil {
+			have = append(have, reflect.TypeOf(n).String())
+		}
+		return true
+	})
+
+	if !reflect.DeepEqual(have, expected) {
+		t.Errorf("wrong traversal order\nhave: %v\nwant: %v", have, expected)
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	input := `let f = fn(x) { x + y; }; z;`
+
+	identifiers := []string{}
+	ast.Inspect(parse(t, input), func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.Identifier:
+			identifiers = append(identifiers, n.Value)
+		}
+		return true
+	})
+
+	if !reflect.DeepEqual(identifiers, []string{"f", "z"}) {
+		t.Errorf("wrong identifiers visited, have %v", identifiers)
+	}
+}
+
+type depthVisitor struct {
+	depth    int
+	maxDepth *int
+}
+
+func (v depthVisitor) Visit(n ast.Node) ast.Visitor {
+	if n == nil {
+		return nil
+	}
+	if v.depth > *v.maxDepth {
+		*v.maxDepth = v.depth
+	}
+	return depthVisitor{depth: v.depth + 1, maxDepth: v.maxDepth}
+}
+
+func TestWalk(t *testing.T) {
+	maxDepth := 0
+	ast.Walk(depthVisitor{maxDepth: &maxDepth}, parse(t, "1 + 2 * 3;"))
+
+	// program -> statement -> infix -> infix -> integer
+	if maxDepth != 4 {
+		t.Errorf("wrong depth, have %d, want %d", maxDepth, 4)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	for _, err := range p.Errors() {
+		t.Fatalf("parser error: %q", err)
+	}
+
+	return program
+}
@@ -0,0 +1,11 @@
+// arithmetic heavy loop
+let step = fn(i, acc) {
+  (acc * 31 + i * i - i / 3) / 7 + i * 2 - (i - 1) * 3
+};
+
+let loop = fn(i, acc) {
+  if (i > 50000) { return acc; }
+  loop(i + 1, step(i, acc))
+};
+
+loop(1, 0);
@@ -0,0 +1,9 @@
+// creation and calls of closures
+let adder = fn(x) { fn(y) { x + y } };
+
+let sum = fn(n, acc) {
+  if (n < 1) { return acc; }
+  sum(n - 1, adder(n)(acc))
+};
+
+sum(20000, 0);
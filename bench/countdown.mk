@@ -0,0 +1,7 @@
+// tail recursion
+let countdown = fn(n) {
+  if (n == 0) { return 0; }
+  countdown(n - 1)
+};
+
+countdown(100000);
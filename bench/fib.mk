@@ -0,0 +1,7 @@
+// recursive function calls
+let fib = fn(n) {
+  if (n < 2) { return n; }
+  fib(n - 1) + fib(n - 2)
+};
+
+fib(22);
@@ -0,0 +1,170 @@
+// Package benchmark summarizes the running times of benchmark scripts
+// and compares them against a stored baseline.
+package benchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultTolerance is the relative change of the mean running time
+// below which a difference is not reported.
+const DefaultTolerance = 0.05
+
+// significance is the value of the t statistic above which a
+// difference of the means is considered significant, about the
+// 95% confidence level for the usual numbers of runs.
+const significance = 2.0
+
+// Summary describes the running times of a benchmark. It is a value,
+// safe to use concurrently.
+type Summary struct {
+	Runs   int     `json:"runs"`
+	Mean   float64 `json:"mean_ns"`
+	StdDev float64 `json:"stddev_ns"`
+}
+
+// Summarize returns the summary of the running times of the runs.
+func Summarize(runs []time.Duration) Summary {
+	s := Summary{Runs: len(runs)}
+	if len(runs) == 0 {
+		return s
+	}
+
+	for _, r := range runs {
+		s.Mean += float64(r)
+	}
+	s.Mean /= float64(len(runs))
+
+	if len(runs) > 1 {
+		var variance float64
+		for _, r := range runs {
+			d := float64(r) - s.Mean
+			variance += d * d
+		}
+		s.StdDev = math.Sqrt(variance / float64(len(runs)-1))
+	}
+
+	return s
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%v ± %v", time.Duration(s.Mean).Round(time.Microsecond), time.Duration(s.StdDev).Round(time.Microsecond))
+}
+
+// Verdict is the result of the comparison of a benchmark with its
+// baseline, safe to use concurrently.
+type Verdict int
+
+const (
+	Unchanged Verdict = iota
+	Improvement
+	Regression
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Improvement:
+		return "improvement"
+	case Regression:
+		return "regression"
+	default:
+		return "~"
+	}
+}
+
+// Comparison is the comparison of a benchmark with its baseline. Like
+// a Summary it is a value that can be used concurrently.
+type Comparison struct {
+	Name     string
+	Baseline Summary
+	Current  Summary
+	Delta    float64 // the relative change of the mean.
+	Verdict  Verdict
+}
+
+// Compare compares the current running times of a benchmark with the
+// baseline. A change is reported if the means differ by more than the
+// relative tolerance and the difference is significant by Welch's
+// t-test, so that the noise of the measurements is not reported.
+func Compare(name string, baseline, current Summary, tolerance float64) Comparison {
+	c := Comparison{Name: name, Baseline: baseline, Current: current}
+	if baseline.Mean == 0 {
+		return c
+	}
+
+	c.Delta = (current.Mean - baseline.Mean) / baseline.Mean
+	if math.Abs(c.Delta) <= tolerance || !significant(baseline, current) {
+		return c
+	}
+
+	if c.Delta > 0 {
+		c.Verdict = Regression
+	} else {
+		c.Verdict = Improvement
+	}
+
+	return c
+}
+
+// significant reports whether the means of a and b differ significantly.
+func significant(a, b Summary) bool {
+	if a.Runs < 2 || b.Runs < 2 {
+		return true
+	}
+
+	se := math.Sqrt(a.StdDev*a.StdDev/float64(a.Runs) + b.StdDev*b.StdDev/float64(b.Runs))
+	if se == 0 {
+		return a.Mean != b.Mean
+	}
+
+	return math.Abs(a.Mean-b.Mean)/se > significance
+}
+
+// Baseline holds the summaries of a corpus of benchmarks
+// executed by an engine. A Baseline is not safe for concurrent
+// use if it is modified, it can be read concurrently.
+type Baseline struct {
+	Engine     string             `json:"engine"`
+	Benchmarks map[string]Summary `json:"benchmarks"`
+}
+
+// Names returns the names of the benchmarks in sorted order.
+func (b *Baseline) Names() []string {
+	names := make([]string, 0, len(b.Benchmarks))
+	for name := range b.Benchmarks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Load reads the baseline stored at path.
+func Load(path string) (*Baseline, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	b := new(Baseline)
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return b, nil
+}
+
+// Save stores the baseline at path.
+func (b *Baseline) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,80 @@
+package benchmark
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		runs     []time.Duration
+		expected Summary
+	}{
+		{nil, Summary{}},
+		{[]time.Duration{10}, Summary{Runs: 1, Mean: 10}},
+		{[]time.Duration{2, 4, 4, 4, 5, 5, 7, 9}, Summary{Runs: 8, Mean: 5, StdDev: math.Sqrt(32.0 / 7)}},
+	}
+
+	for _, tt := range tests {
+		s := Summarize(tt.runs)
+		if s != tt.expected {
+			t.Errorf("wrong summary of %v. want=%+v, have=%+v", tt.runs, tt.expected, s)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		baseline Summary
+		current  Summary
+		expected Verdict
+	}{
+		{Summary{Runs: 10, Mean: 100, StdDev: 1}, Summary{Runs: 10, Mean: 101, StdDev: 1}, Unchanged},
+		{Summary{Runs: 10, Mean: 100, StdDev: 1}, Summary{Runs: 10, Mean: 120, StdDev: 1}, Regression},
+		{Summary{Runs: 10, Mean: 100, StdDev: 1}, Summary{Runs: 10, Mean: 80, StdDev: 1}, Improvement},
+		// the difference is within the noise of the measurements.
+		{Summary{Runs: 10, Mean: 100, StdDev: 40}, Summary{Runs: 10, Mean: 120, StdDev: 40}, Unchanged},
+		{Summary{Runs: 1, Mean: 100}, Summary{Runs: 1, Mean: 120}, Regression},
+		{Summary{Runs: 5, Mean: 100}, Summary{Runs: 5, Mean: 100}, Unchanged},
+		{Summary{}, Summary{Runs: 5, Mean: 100}, Unchanged},
+	}
+
+	for _, tt := range tests {
+		c := Compare("test", tt.baseline, tt.current, DefaultTolerance)
+		if c.Verdict != tt.expected {
+			t.Errorf("wrong verdict for %+v and %+v. want=%s, have=%s", tt.baseline, tt.current, tt.expected, c.Verdict)
+		}
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+
+	b := &Baseline{
+		Engine: "vm",
+		Benchmarks: map[string]Summary{
+			"fib.mk":     {Runs: 10, Mean: 1.5e6, StdDev: 2e4},
+			"closure.mk": {Runs: 10, Mean: 3e5, StdDev: 1e3},
+		},
+	}
+
+	if err := b.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(b, loaded) {
+		t.Errorf("baseline changed by saving it. want=%+v, have=%+v", b, loaded)
+	}
+
+	if names := loaded.Names(); !reflect.DeepEqual(names, []string{"closure.mk", "fib.mk"}) {
+		t.Errorf("wrong names %v", names)
+	}
+}
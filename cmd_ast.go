@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/optimizer"
+)
+
+// astCommand prints the ast of a source file, either as
+// an indented tree or, with -json, in its JSON encoding.
+func astCommand(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the ast in its JSON encoding")
+	optimize := flags.Bool("optimize", *optimizeFlag, "print the ast after optimizing it")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: interpreter ast [-json] [-optimize] [file]\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	src, err := readSource(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read from input: %v\n", err)
+		return 1
+	}
+
+	program, err := parseSource(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	if *optimize {
+		optimizer.Optimize(program)
+	}
+
+	if !*asJSON {
+		printTree(os.Stdout, program)
+		return 0
+	}
+
+	out, err := ast.EncodeJSONIndent(program, "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode the ast: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stdout, "%s\n", out)
+	return 0
+}
+
+// printTree writes each node of the ast on its own line,
+// indented by its depth in the tree.
+func printTree(w io.Writer, node ast.Node) {
+	depth := 0
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			depth--
+			return false
+		}
+
+		name := reflect.TypeOf(n).Elem().Name()
+		detail := ""
+
+		switch n := n.(type) {
+		case *ast.Identifier:
+			detail = " " + n.Value
+		case *ast.ImportStatement:
+			detail = " " + strconv.Quote(n.Path)
+		case *ast.StringLiteral:
+			detail = " " + strconv.Quote(n.Value)
+		case *ast.IntegerLiteral, *ast.BooleanLiteral:
+			detail = " " + n.String()
+		case *ast.PrefixExpression:
+			detail = " " + n.Operator
+		case *ast.InfixExpression:
+			detail = " " + n.Operator
+		}
+
+		fmt.Fprintf(w, "%s%s%s\n", strings.Repeat("  ", depth), name, detail)
+
+		depth++
+		return true
+	})
+}
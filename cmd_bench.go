@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Despire/interpreter/benchmark"
+	"github.com/Despire/interpreter/objects"
+)
+
+// defaultCorpus is the directory of the benchmark scripts.
+const defaultCorpus = "bench"
+
+// benchCommand runs a corpus of benchmark scripts and compares their
+// running times with a stored baseline. It exits with 1 if a script
+// fails or got significantly slower.
+func benchCommand(args []string) int {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	engine := flags.String("engine", *engineFlag, "engine that executes the scripts, eval or vm")
+	optimize := flags.Bool("optimize", *optimizeFlag, "optimize the scripts before executing them")
+	count := flags.Int("count", 10, "number of runs of each script")
+	baselinePath := flags.String("baseline", "", "baseline file, defaults to baseline-<engine>.json in the first directory")
+	update := flags.Bool("update", false, "store the results as the new baseline instead of comparing them")
+	tolerance := flags.Float64("tolerance", benchmark.DefaultTolerance, "relative change of the mean running time that is not reported")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: interpreter bench [flags] [files or directories...]\n")
+		flags.PrintDefaults()
+	}
+
+	paths, err := parseFlags(flags, args)
+	if err != nil {
+		return 2
+	}
+
+	if *count < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	if len(paths) == 0 {
+		paths = []string{defaultCorpus}
+	}
+
+	scripts, dir, err := benchmarkScripts(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	if *baselinePath == "" {
+		*baselinePath = filepath.Join(dir, "baseline-"+*engine+".json")
+	}
+
+	if _, err := newSession(*engine, *optimize, dir); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	current := &benchmark.Baseline{Engine: *engine, Benchmarks: make(map[string]benchmark.Summary)}
+	for _, path := range scripts {
+		runs, err := runBenchmark(path, *engine, *optimize, *count)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 1
+		}
+		current.Benchmarks[filepath.Base(path)] = benchmark.Summarize(runs)
+	}
+
+	if *update {
+		if err := current.Save(*baselinePath); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for _, name := range current.Names() {
+			fmt.Fprintf(w, "%s\t%s\n", name, current.Benchmarks[name])
+		}
+		w.Flush()
+		return 0
+	}
+
+	baseline, err := benchmark.Load(*baselinePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, create a baseline with -update\n", err)
+		return 1
+	}
+
+	if baseline.Engine != *engine {
+		fmt.Fprintf(os.Stderr, "%s: baseline of engine %s, not %s\n", *baselinePath, baseline.Engine, *engine)
+		return 2
+	}
+
+	code := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "name\tbaseline\tcurrent\tdelta\t\n")
+	for _, name := range current.Names() {
+		base, ok := baseline.Benchmarks[name]
+		if !ok {
+			fmt.Fprintf(w, "%s\t-\t%s\t-\tnew\n", name, current.Benchmarks[name])
+			continue
+		}
+
+		c := benchmark.Compare(name, base, current.Benchmarks[name], *tolerance)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%+.1f%%\t%s\n", name, c.Baseline, c.Current, c.Delta*100, c.Verdict)
+
+		if c.Verdict == benchmark.Regression {
+			code = 1
+		}
+	}
+	w.Flush()
+
+	return code
+}
+
+// benchmarkScripts returns the scripts in paths, the source files in
+// the directories, and the first directory, where the baseline is kept.
+func benchmarkScripts(paths []string) ([]string, string, error) {
+	var (
+		scripts []string
+		dir     string
+	)
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if !info.IsDir() {
+			if dir == "" {
+				dir = filepath.Dir(path)
+			}
+			scripts = append(scripts, path)
+			continue
+		}
+
+		if dir == "" {
+			dir = path
+		}
+
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, "", err
+		}
+
+		var found []string
+		for _, f := range files {
+			if !f.IsDir() && filepath.Ext(f.Name()) == ".mk" {
+				found = append(found, filepath.Join(path, f.Name()))
+			}
+		}
+		sort.Strings(found)
+		scripts = append(scripts, found...)
+	}
+
+	if len(scripts) == 0 {
+		return nil, "", fmt.Errorf("no benchmark scripts in %v", paths)
+	}
+
+	return scripts, dir, nil
+}
+
+// runBenchmark executes the script at path count times, after a
+// warm up run, and returns the running times. The scripts are parsed
+// before each run, only their execution is measured.
+func runBenchmark(path, engine string, optimize bool, count int) ([]time.Duration, error) {
+	src, err := readSource(path)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]time.Duration, 0, count)
+	for i := 0; i <= count; i++ {
+		program, err := parseSource(src)
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := newSession(engine, optimize, filepath.Dir(path))
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		result := s.Run(program)
+		elapsed := time.Since(start)
+
+		if result != nil && result.Type() == objects.ERROR {
+			return nil, fmt.Errorf("%s", result.Inspect())
+		}
+
+		if i > 0 {
+			runs = append(runs, elapsed)
+		}
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// compileCommand compiles a source file to a bytecode file
+// that can be executed with the run command.
+func compileCommand(args []string) int {
+	flags := flag.NewFlagSet("compile", flag.ContinueOnError)
+	output := flags.String("o", "", "output file, defaults to the source file with the extension .mkc")
+	optimize := flags.Bool("optimize", *optimizeFlag, "optimize the program before compiling it")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: interpreter compile file [-o output] [-optimize]\n")
+		flags.PrintDefaults()
+	}
+
+	paths, err := parseFlags(flags, args)
+	if err != nil {
+		return 2
+	}
+
+	if len(paths) != 1 {
+		flags.Usage()
+		return 2
+	}
+	path := paths[0]
+
+	src, err := readSource(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+
+	bytecode, err := compileSource(src, *optimize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+
+	data, err := bytecode.MarshalBinary()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+
+	out := *output
+	if out == "" {
+		out = strings.TrimSuffix(path, filepath.Ext(path)) + ".mkc"
+	}
+
+	if err := ioutil.WriteFile(out, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	return 0
+}
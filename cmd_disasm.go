@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// disasmCommand prints a listing of the bytecode
+// of a source file or of a bytecode file.
+func disasmCommand(args []string) int {
+	flags := flag.NewFlagSet("disasm", flag.ContinueOnError)
+	optimize := flags.Bool("optimize", *optimizeFlag, "optimize source files before compiling them")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: interpreter disasm [-optimize] [file]\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	path := flags.Arg(0)
+	src, err := readSource(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+
+	bytecode, err := compileSource(src, *optimize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+
+	if err := bytecode.Disassemble(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/Despire/interpreter/format"
+)
+
+// fmtCommand formats the given files in the canonical style.
+// Without -w the result is written to the standard output.
+// Without files the standard input is formatted.
+func fmtCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write result to (source) file instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: interpreter fmt [-w] [files...]\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read from input: %v\n", err)
+			return 1
+		}
+
+		out, err := format.Source(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "<standard input>: %v\n", err)
+			return 1
+		}
+
+		os.Stdout.Write(out)
+		return 0
+	}
+
+	code := 0
+	for _, path := range flags.Args() {
+		if err := formatFile(path, *write); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			code = 1
+		}
+	}
+
+	return code
+}
+
+func formatFile(path string, write bool) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	out, err := format.Source(src)
+	if err != nil {
+		return err
+	}
+
+	if !write {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+
+	if bytes.Equal(src, out) {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, out, info.Mode().Perm())
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Despire/interpreter/lint"
+)
+
+// lintCommand reports suspicious constructs in the given files.
+// It exits with 1 if any problem was found.
+func lintCommand(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	enable := flags.String("enable", "", "comma separated list of rules to enable")
+	disable := flags.String("disable", "", "comma separated list of rules to disable")
+	maxNesting := flags.Int("max-nesting", lint.DefaultMaxNesting, "maximum nesting depth of blocks")
+	list := flags.Bool("list", false, "list the available rules and exit")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: interpreter lint [flags] [files...]\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *list {
+		for _, r := range lint.Rules() {
+			state := "disabled"
+			if r.Default {
+				state = "enabled"
+			}
+			fmt.Printf("%-24s %-9s %s\n", r.Name, state, r.Doc)
+		}
+		return 0
+	}
+
+	config := lint.Config{
+		Enable:     splitList(*enable),
+		Disable:    splitList(*disable),
+		MaxNesting: *maxNesting,
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	code := 0
+	for _, path := range paths {
+		src, err := readSource(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			code = 1
+			continue
+		}
+
+		program, err := parseSource(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			code = 1
+			continue
+		}
+
+		diagnostics, err := lint.Run(program, config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 2
+		}
+
+		for _, d := range diagnostics {
+			fmt.Printf("%s:%s\n", path, d)
+			code = 1
+		}
+	}
+
+	return code
+}
+
+// splitList splits a comma separated list, ignoring empty elements.
+func splitList(s string) []string {
+	var out []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
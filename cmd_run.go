@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Despire/interpreter/compiler"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/vm"
+)
+
+// runCommand executes a source or a bytecode file and prints its
+// value. Bytecode files are always executed by the virtual machine.
+// It exits with 1 if the program fails.
+func runCommand(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	engine := flags.String("engine", *engineFlag, "engine that executes source files, eval or vm")
+	optimize := flags.Bool("optimize", *optimizeFlag, "optimize source files before executing them")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: interpreter run [-engine eval|vm] [-optimize] [file]\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	// the imports of the standard input are relative to the working directory.
+	path := flags.Arg(0)
+	dir := "."
+	if path != "" && path != "-" {
+		dir = filepath.Dir(path)
+	}
+
+	s, err := newSession(*engine, *optimize, dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	src, err := readSource(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+
+	if compiler.IsBytecode(src) {
+		bytecode, err := compileSource(src, false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 1
+		}
+
+		// the modules imported by bytecode are compiled from source.
+		machine := vm.New(bytecode)
+		machine.SetImporter(newLoader("vm", *optimize).Importer(dir))
+		if err := machine.Run(); err != nil {
+			return printResult(runtimeError(err))
+		}
+		return printResult(machine.LastPopped())
+	}
+
+	program, err := parseSource(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+
+	return printResult(s.Run(program))
+}
+
+// printResult prints the value of a program, errors are printed
+// to the standard error. It returns the exit code of the program.
+func printResult(result objects.Object) int {
+	if result == nil {
+		return 0
+	}
+
+	if result.Type() == objects.ERROR {
+		fmt.Fprintln(os.Stderr, result.Inspect())
+		return 1
+	}
+
+	fmt.Println(result.Inspect())
+	return 0
+}
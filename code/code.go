@@ -0,0 +1,341 @@
+// Package code defines the instruction set executed by the virtual machine.
+package code
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Instructions is a sequence of encoded instructions. Each instruction
+// is an opcode followed by its operands encoded in big endian. They are
+// only read once emitted, so any number of goroutines can execute them.
+type Instructions []byte
+
+// Opcode identifies an instruction, it is safe to use concurrently.
+type Opcode byte
+
+const (
+	// OpConstant pushes the constant at the index of its operand.
+	OpConstant Opcode = iota
+	// OpPop discards the top of the stack.
+	OpPop
+	// OpDup pushes the top of the stack again.
+	OpDup
+	// OpNil pushes the value of a statement that produces
+	// no value, e.g. a let statement or an empty block.
+	OpNil
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpGreaterThan
+
+	OpMinus
+	OpBang
+
+	// OpJump moves to the absolute offset of its operand,
+	// OpJumpNotTruthy does so only if the popped value is not truthy.
+	OpJump
+	OpJumpNotTruthy
+
+	// OpGet pushes the value in the slot of its second operand
+	// of the scope the number of its first operand levels up.
+	OpGet
+	// OpSet pops the value into the slot of its operand of the current scope.
+	OpSet
+
+	// OpClosure pushes a closure of the function constant
+	// of its operand over the current scope.
+	OpClosure
+	// OpCall calls the function below the number
+	// of arguments of its operand on the stack.
+	OpCall
+	// OpReturnValue returns the top of the stack to the caller.
+	OpReturnValue
+
+	// OpImport pushes the module imported from the path
+	// held by the string constant of its operand.
+	OpImport
+	// OpMember replaces the module on the top of the stack with its
+	// member named by the string constant of its operand.
+	OpMember
+
+	// OpArray replaces the number of values of its operand on the top
+	// of the stack with an array of them, the first pushed comes first.
+	OpArray
+	// OpHash replaces the number of pairs of a key and a value of its
+	// operand on the top of the stack with a hash of them.
+	OpHash
+	// OpIndex replaces an array or a hash and the index on the top
+	// of the stack with the element of the array or the value of the
+	// hash at the index.
+	OpIndex
+
+	// OpTry installs a handler for the errors raised until the next
+	// OpEndTry of the function. An error moves to the offset of its
+	// operand, with the stack cut back to its height at OpTry and the
+	// caught error pushed.
+	OpTry
+	// OpEndTry removes the handler installed last.
+	OpEndTry
+	// OpThrow throws the popped value.
+	OpThrow
+
+	// OpNoMatch raises the error of a match expression
+	// none of whose arms matches the popped value.
+	OpNoMatch
+
+	// OpMatchArray pushes whether the top of the stack is an array
+	// of at most the number of elements of its first operand, or of
+	// any number if its second operand is 1. OpMatchHash pushes whether
+	// the top of the stack is a hash. Both keep the value tested.
+	OpMatchArray
+	OpMatchHash
+	// OpElement pushes the element at the index of its second operand
+	// of the array on the top of the stack, or moves to the offset of
+	// its first operand if the array has none. OpRest pushes an array
+	// of the elements of the array from the index of its operand on.
+	OpElement
+	OpRest
+	// OpEntry pushes the value of the hash on the top of the stack for
+	// the key held by the string constant of its second operand, or
+	// moves to the offset of its first operand if the hash has none.
+	OpEntry
+	// OpMismatch raises the error of a destructuring let statement
+	// whose pattern, held by the string constant of its operand,
+	// does not match the popped value.
+	OpMismatch
+
+	// OpCallNamed calls the function below the number of arguments
+	// of its first operand on the stack. The last of them, as many as
+	// its second operand, are named by the string constants from the
+	// index of its third operand on.
+	OpCallNamed
+	// OpJumpBound moves to the offset of its first operand if the
+	// slot of its second operand of the current scope is assigned,
+	// skipping the default value of a parameter passed to a call.
+	OpJumpBound
+	// OpYield suspends the generator running the current function,
+	// handing the popped value to the caller of next. The argument
+	// of the call of next resuming the generator is pushed.
+	OpYield
+	// OpSpawn starts a task calling the function below the number of
+	// arguments of its first operand on the stack, named like those of
+	// OpCallNamed by its other operands. They are replaced by null.
+	OpSpawn
+	// OpChannel replaces the capacity on the top of the stack with a
+	// new channel buffering as many values.
+	OpChannel
+	// OpSelect performs the first operation that can proceed of the
+	// number of its first operand, each given by three values on the
+	// stack: the channel, whether the value is sent to it and the value
+	// sent, null for a receive. If none can it blocks until one can,
+	// unless its second operand is 1. The operations are replaced by
+	// the value received and the index of the operation performed,
+	// -1 if none was.
+	OpSelect
+)
+
+// Definition describes the name and the operands of an opcode. The
+// definitions returned by Lookup are shared by every goroutine, they
+// must not be modified.
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant:      {"OpConstant", []int{2}},
+	OpPop:           {"OpPop", []int{}},
+	OpDup:           {"OpDup", []int{}},
+	OpNil:           {"OpNil", []int{}},
+	OpTrue:          {"OpTrue", []int{}},
+	OpFalse:         {"OpFalse", []int{}},
+	OpNull:          {"OpNull", []int{}},
+	OpAdd:           {"OpAdd", []int{}},
+	OpSub:           {"OpSub", []int{}},
+	OpMul:           {"OpMul", []int{}},
+	OpDiv:           {"OpDiv", []int{}},
+	OpEqual:         {"OpEqual", []int{}},
+	OpNotEqual:      {"OpNotEqual", []int{}},
+	OpLessThan:      {"OpLessThan", []int{}},
+	OpGreaterThan:   {"OpGreaterThan", []int{}},
+	OpMinus:         {"OpMinus", []int{}},
+	OpBang:          {"OpBang", []int{}},
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpGet:           {"OpGet", []int{2, 2}},
+	OpSet:           {"OpSet", []int{2}},
+	OpClosure:       {"OpClosure", []int{2}},
+	OpCall:          {"OpCall", []int{1}},
+	OpReturnValue:   {"OpReturnValue", []int{}},
+	OpImport:        {"OpImport", []int{2}},
+	OpMember:        {"OpMember", []int{2}},
+	OpArray:         {"OpArray", []int{2}},
+	OpHash:          {"OpHash", []int{2}},
+	OpIndex:         {"OpIndex", []int{}},
+	OpTry:           {"OpTry", []int{2}},
+	OpEndTry:        {"OpEndTry", []int{}},
+	OpThrow:         {"OpThrow", []int{}},
+	OpNoMatch:       {"OpNoMatch", []int{}},
+	OpMatchArray:    {"OpMatchArray", []int{2, 1}},
+	OpMatchHash:     {"OpMatchHash", []int{}},
+	OpElement:       {"OpElement", []int{2, 2}},
+	OpRest:          {"OpRest", []int{2}},
+	OpEntry:         {"OpEntry", []int{2, 2}},
+	OpMismatch:      {"OpMismatch", []int{2}},
+	OpCallNamed:     {"OpCallNamed", []int{1, 1, 2}},
+	OpJumpBound:     {"OpJumpBound", []int{2, 2}},
+	OpYield:         {"OpYield", []int{}},
+	OpSpawn:         {"OpSpawn", []int{1, 1, 2}},
+	OpChannel:       {"OpChannel", []int{}},
+	OpSelect:        {"OpSelect", []int{2, 1}},
+}
+
+// Lookup returns the definition of op.
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes the instruction op with its operands.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		switch def.OperandWidths[i] {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += def.OperandWidths[i]
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of def from ins. It returns
+// the operands and the number of bytes they occupy.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, w := range def.OperandWidths {
+		switch w {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += w
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 { return binary.BigEndian.Uint16(ins) }
+func ReadUint8(ins Instructions) uint8   { return uint8(ins[0]) }
+
+// ReadInstruction decodes the instruction at offset. It returns its
+// definition, its operands and the offset of the next instruction.
+func ReadInstruction(ins Instructions, offset int) (*Definition, []int, int, error) {
+	def, err := Lookup(ins[offset])
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	width := 0
+	for _, w := range def.OperandWidths {
+		width += w
+	}
+	if offset+1+width > len(ins) {
+		return nil, nil, 0, fmt.Errorf("truncated %s", def.Name)
+	}
+
+	operands, read := ReadOperands(def, ins[offset+1:])
+	return def, operands, offset + 1 + read, nil
+}
+
+// String disassembles the instructions, one per line prefixed
+// with its offset. The listing stops at the first malformed instruction.
+func (ins Instructions) String() string {
+	out := new(strings.Builder)
+
+	for i := 0; i < len(ins); {
+		def, operands, next, err := ReadInstruction(ins, i)
+		if err != nil {
+			fmt.Fprintf(out, "%04d ERROR: %s\n", i, err)
+			break
+		}
+
+		fmt.Fprintf(out, "%04d %s\n", i, FormatInstruction(def, operands))
+		i = next
+	}
+
+	return out.String()
+}
+
+// FormatInstruction returns the name of the instruction followed by its operands.
+func FormatInstruction(def *Definition, operands []int) string {
+	switch len(operands) {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	case 3:
+		return fmt.Sprintf("%s %d %d %d", def.Name, operands[0], operands[1], operands[2])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operand count for %s", def.Name)
+}
+
+// Line maps the instructions starting at Offset to a line of the source.
+// It is a value, safe to use concurrently.
+type Line struct {
+	Offset int
+	Line   int
+}
+
+// LineTable maps the offsets of instructions to lines of the source,
+// ordered by offset. An entry holds until the offset of the next one.
+// Like Instructions, a table can be read by goroutines concurrently
+// once it is complete.
+type LineTable []Line
+
+// LineAt returns the source line of the instruction
+// at offset, or 0 if the line is unknown.
+func (t LineTable) LineAt(offset int) int {
+	i := sort.Search(len(t), func(i int) bool { return t[i].Offset > offset })
+	if i == 0 {
+		return 0
+	}
+	return t[i-1].Line
+}
@@ -0,0 +1,123 @@
+package code
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpCall, []int{255}, []byte{byte(OpCall), 255}},
+		{OpGet, []int{1, 258}, []byte{byte(OpGet), 0, 1, 1, 2}},
+		{OpCallNamed, []int{3, 2, 258}, []byte{byte(OpCallNamed), 3, 2, 1, 2}},
+		{OpYield, []int{}, []byte{byte(OpYield)}},
+		{OpSelect, []int{258, 1}, []byte{byte(OpSelect), 1, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		if len(instruction) != len(tt.expected) {
+			t.Fatalf("instruction has wrong length. want=%d, have=%d", len(tt.expected), len(instruction))
+		}
+
+		for i, b := range tt.expected {
+			if instruction[i] != b {
+				t.Errorf("wrong byte at pos %d. want=%d, have=%d", i, b, instruction[i])
+			}
+		}
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpCall, []int{255}, 1},
+		{OpGet, []int{3, 65535}, 4},
+		{OpCallNamed, []int{255, 1, 65535}, 4},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("definition not found: %q", err)
+		}
+
+		operands, n := ReadOperands(def, instruction[1:])
+		if n != tt.bytesRead {
+			t.Fatalf("n wrong. want=%d, have=%d", tt.bytesRead, n)
+		}
+
+		for i, want := range tt.operands {
+			if operands[i] != want {
+				t.Errorf("operand wrong. want=%d, have=%d", want, operands[i])
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGet, 1, 2),
+		Make(OpConstant, 65535),
+		Make(OpCall, 3),
+		Make(OpCallNamed, 3, 1, 7),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGet 1 2
+0006 OpConstant 65535
+0009 OpCall 3
+0011 OpCallNamed 3 1 7
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\nhave=%q", expected, concatted.String())
+	}
+
+	truncated := Instructions(Make(OpConstant, 1)[:2])
+	if want := "0000 ERROR: truncated OpConstant\n"; truncated.String() != want {
+		t.Errorf("truncated instructions wrongly formatted.\nwant=%q\nhave=%q", want, truncated.String())
+	}
+}
+
+func TestLineTable(t *testing.T) {
+	table := LineTable{{Offset: 0, Line: 1}, {Offset: 4, Line: 3}, {Offset: 9, Line: 2}}
+
+	tests := []struct {
+		offset int
+		line   int
+	}{
+		{0, 1},
+		{3, 1},
+		{4, 3},
+		{8, 3},
+		{9, 2},
+		{100, 2},
+	}
+
+	for _, tt := range tests {
+		if line := table.LineAt(tt.offset); line != tt.line {
+			t.Errorf("wrong line at offset %d. want=%d, have=%d", tt.offset, tt.line, line)
+		}
+	}
+
+	if line := (LineTable{}).LineAt(0); line != 0 {
+		t.Errorf("empty table has line %d", line)
+	}
+}
@@ -0,0 +1,1021 @@
+// Package compiler lowers the ast of a program to the
+// instructions executed by the virtual machine.
+package compiler
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/code"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/token"
+)
+
+// Bytecode is a compiled program. It is not changed by the virtual
+// machines running it, any number of them can run it concurrently as
+// long as each binds the globals in a scope of its own.
+type Bytecode struct {
+	Instructions code.Instructions
+	Lines        code.LineTable
+	Constants    []objects.Object
+	// Globals holds the name bound to each slot of the program scope.
+	Globals []string
+}
+
+var infixOperators = map[string]code.Opcode{
+	token.PLUS:     code.OpAdd,
+	token.MINUS:    code.OpSub,
+	token.ASTERISK: code.OpMul,
+	token.SLASH:    code.OpDiv,
+	token.EQUAL:    code.OpEqual,
+	token.NEQUAL:   code.OpNotEqual,
+	token.LESST:    code.OpLessThan,
+	token.GREATERT: code.OpGreaterThan,
+}
+
+var prefixOperators = map[string]code.Opcode{
+	token.MINUS: code.OpMinus,
+	token.BANG:  code.OpBang,
+}
+
+// compilationScope holds the output for a function being compiled.
+type compilationScope struct {
+	instructions code.Instructions
+	lines        code.LineTable
+	tries        []*tryBlock // the try expressions enclosing the code being compiled.
+}
+
+// tryBlock is a try expression of the function being compiled.
+type tryBlock struct {
+	// finally is the finally clause that has to run before a return
+	// statement leaves the try expression, it is nil if there is none
+	// or it is being compiled.
+	finally *ast.BlockStatement
+	// handler is set while a handler of the try expression is installed.
+	handler bool
+	// symbols is the table the finally clause is compiled with.
+	symbols *SymbolTable
+}
+
+// Compiler compiles programs to bytecode. A Compiler is not safe for
+// concurrent use, nor are the compilers continuing with its state.
+type Compiler struct {
+	constants []objects.Object
+	symbols   *SymbolTable
+	scopes    []*compilationScope // one per function being compiled.
+	line      int                 // the source line of the node being compiled.
+}
+
+// New returns a compiler for a single program.
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), nil)
+}
+
+// NewWithState returns a compiler that continues with the names and
+// constants of previously compiled programs, e.g. the inputs of a REPL.
+func NewWithState(symbols *SymbolTable, constants []objects.Object) *Compiler {
+	return &Compiler{
+		constants: constants,
+		symbols:   symbols,
+		scopes:    []*compilationScope{{}},
+	}
+}
+
+// Bytecode returns the program compiled so far.
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.current(),
+		Lines:        c.scope().lines,
+		Constants:    c.constants,
+		Globals:      c.symbols.Names(),
+	}
+}
+
+// Constants returns the constant pool, it is shared
+// by all the programs compiled by c.
+func (c *Compiler) Constants() []objects.Object { return c.constants }
+
+// Compile compiles a program. The value of its last statement is left
+// as the last value popped off the stack by the virtual machine.
+func (c *Compiler) Compile(program *ast.Program) error {
+	c.declareAll(program)
+
+	if err := c.hoist(program.Statement); err != nil {
+		return err
+	}
+
+	for i, s := range program.Statement {
+		if err := c.compile(s); err != nil {
+			return err
+		}
+
+		switch s.(type) {
+		case *ast.ExpressionStatement:
+			c.emit(code.OpPop)
+		case *ast.LetStatement, *ast.FunctionStatement, *ast.ImportStatement:
+			if i == len(program.Statement)-1 {
+				c.emit(code.OpNil)
+				c.emit(code.OpPop)
+			}
+		}
+	}
+
+	if len(c.constants) > math.MaxUint16+1 {
+		return fmt.Errorf("too many constants: %d", len(c.constants))
+	}
+
+	return c.checkSize()
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	if pos := ast.Pos(node); pos.IsValid() {
+		defer func(line int) { c.line = line }(c.line)
+		c.line = pos.Line
+	}
+
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		return c.compile(node.Expression)
+	case *ast.ReturnStatement:
+		if err := c.compile(node.Expression); err != nil {
+			return err
+		}
+		if err := c.leaveTries(); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.ThrowStatement:
+		if err := c.compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	case *ast.LetStatement:
+		if err := c.compile(node.Expression); err != nil {
+			return err
+		}
+		if node.Pattern != nil {
+			if _, err := c.pattern(node.Pattern, true); err != nil {
+				return err
+			}
+			c.emit(code.OpPop)
+			return nil
+		}
+		sym, _, _ := c.symbols.Resolve(node.Identifier.Value)
+		c.emit(code.OpSet, sym.Slot)
+	case *ast.FunctionStatement:
+		// the function was bound when its block was entered.
+		return nil
+	case *ast.ImportStatement:
+		c.emit(code.OpImport, c.addConstant(&objects.String{Value: node.Path}))
+		sym, _, _ := c.symbols.Resolve(node.Name.Value)
+		c.emit(code.OpSet, sym.Slot)
+	case *ast.MemberExpression:
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		c.emit(code.OpMember, c.addConstant(&objects.String{Value: node.Property.Value}))
+	case *ast.ArrayLiteral:
+		if len(node.Elements) > math.MaxUint16 {
+			return fmt.Errorf("too many elements in array literal")
+		}
+		for _, e := range node.Elements {
+			if err := c.compile(e); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		if len(node.Pairs) > math.MaxUint16 {
+			return fmt.Errorf("too many pairs in hash literal")
+		}
+		for _, p := range node.Pairs {
+			if id, ok := p.Key.(*ast.Identifier); ok {
+				c.emit(code.OpConstant, c.addConstant(&objects.String{Value: id.Value}))
+			} else if err := c.compile(p.Key); err != nil {
+				return err
+			}
+			if err := c.compile(p.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs))
+	case *ast.IndexExpression:
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.BlockStatement:
+		return c.block(node)
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(objects.NewInteger(int64(node.Value))))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&objects.String{Value: node.Value}))
+	case *ast.BooleanLiteral:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.Identifier:
+		c.identifier(node)
+	case *ast.PrefixExpression:
+		op, ok := prefixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+	case *ast.YieldExpression:
+		if len(c.scopes) == 1 {
+			return fmt.Errorf("yield outside of a function")
+		}
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpYield)
+	case *ast.InfixExpression:
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+	case *ast.IfExpression:
+		return c.ifExpression(node)
+	case *ast.TryExpression:
+		return c.tryExpression(node)
+	case *ast.MatchExpression:
+		return c.matchExpression(node)
+	case *ast.FunctionLiteral:
+		return c.function(node)
+	case *ast.MacroLiteral:
+		return fmt.Errorf("macros must be bound by a top-level let statement")
+	case *ast.CallExpression:
+		names, err := c.call(node)
+		if err != nil {
+			return err
+		}
+
+		if len(names) == 0 {
+			c.emit(code.OpCall, len(node.Arguments))
+			break
+		}
+		c.emit(code.OpCallNamed, len(node.Arguments), len(names), c.names(names))
+	case *ast.SpawnExpression:
+		names, err := c.call(node.Call)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpSpawn, len(node.Call.Arguments), len(names), c.names(names))
+	case *ast.ChanExpression:
+		if node.Capacity == nil {
+			c.emit(code.OpConstant, c.addConstant(objects.NewInteger(0)))
+		} else if err := c.compile(node.Capacity); err != nil {
+			return err
+		}
+		c.emit(code.OpChannel)
+	case *ast.SelectExpression:
+		return c.selectExpression(node)
+	default:
+		return fmt.Errorf("can not compile %T", node)
+	}
+
+	return nil
+}
+
+// call compiles the function and the arguments of a call, it
+// returns the names of the named arguments, which come last.
+func (c *Compiler) call(node *ast.CallExpression) ([]string, error) {
+	if ast.IsCallTo(node, ast.Quote) || ast.IsCallTo(node, ast.Unquote) {
+		return nil, fmt.Errorf("can not compile %s, it is only supported by the eval engine", node.Function)
+	}
+	if len(node.Arguments) > math.MaxUint8 {
+		return nil, fmt.Errorf("too many arguments in call to %s", node.Function.String())
+	}
+	if err := c.compile(node.Function); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, a := range node.Arguments {
+		if named, ok := a.(*ast.NamedArgument); ok {
+			names = append(names, named.Name.Value)
+			a = named.Value
+		}
+		if err := c.compile(a); err != nil {
+			return nil, err
+		}
+	}
+
+	return names, nil
+}
+
+// names adds the names of named arguments as consecutive
+// string constants, it returns the index of the first.
+func (c *Compiler) names(names []string) int {
+	first := len(c.constants)
+	for _, name := range names {
+		c.addConstant(&objects.String{Value: name})
+	}
+	return first
+}
+
+// block compiles the statements of b, leaving
+// the value of the last statement on the stack.
+func (c *Compiler) block(b *ast.BlockStatement) error {
+	if len(b.Statements) == 0 {
+		c.emit(code.OpNil)
+		return nil
+	}
+
+	if err := c.hoist(b.Statements); err != nil {
+		return err
+	}
+
+	for i, s := range b.Statements {
+		if err := c.compile(s); err != nil {
+			return err
+		}
+
+		last := i == len(b.Statements)-1
+		switch s.(type) {
+		case *ast.ExpressionStatement:
+			if !last {
+				c.emit(code.OpPop)
+			}
+		case *ast.LetStatement, *ast.FunctionStatement, *ast.ImportStatement:
+			if last {
+				c.emit(code.OpNil)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) ifExpression(ie *ast.IfExpression) error {
+	if err := c.compile(ie.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 0)
+
+	if err := c.block(ie.Consequence); err != nil {
+		return err
+	}
+
+	jump := c.emit(code.OpJump, 0)
+	c.patch(jumpNotTruthy, len(c.current()))
+
+	if ie.Alternative != nil {
+		if err := c.block(ie.Alternative); err != nil {
+			return err
+		}
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	c.patch(jump, len(c.current()))
+	return nil
+}
+
+// tryExpression compiles the body of te under a handler that moves to
+// the catch clause. The finally clause is compiled after the body, after
+// the catch clause and, under a second handler, before the rethrow of the
+// errors that are not caught or raised by the catch clause.
+func (c *Compiler) tryExpression(te *ast.TryExpression) error {
+	s := c.scope()
+	t := &tryBlock{finally: te.Finally, handler: true, symbols: c.symbols}
+	s.tries = append(s.tries, t)
+	defer func() { s.tries = s.tries[:len(s.tries)-1] }()
+
+	handler := c.emit(code.OpTry, 0)
+	if err := c.block(te.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpEndTry)
+	t.handler = false
+	if err := c.finally(t); err != nil {
+		return err
+	}
+	jumps := []int{c.emit(code.OpJump, 0)}
+
+	c.patch(handler, len(c.current()))
+
+	if te.Catch != nil {
+		// the catch clause binds its names in a scope of its own.
+		c.symbols = NewBlockSymbolTable(c.symbols)
+		defer func(symbols *SymbolTable) { c.symbols = symbols }(t.symbols)
+
+		sym := c.symbols.Define(te.Parameter.Value)
+		c.declareAll(te.Catch)
+		c.emit(code.OpSet, sym.Slot)
+
+		t.finally = te.Finally
+		if t.finally != nil {
+			handler = c.emit(code.OpTry, 0)
+			t.handler = true
+		}
+
+		if err := c.block(te.Catch); err != nil {
+			return err
+		}
+		c.symbols = t.symbols
+
+		if t.finally != nil {
+			c.emit(code.OpEndTry)
+			t.handler = false
+			if err := c.finally(t); err != nil {
+				return err
+			}
+		}
+		jumps = append(jumps, c.emit(code.OpJump, 0))
+
+		if te.Finally != nil {
+			c.patch(handler, len(c.current()))
+		}
+	}
+
+	if te.Finally != nil {
+		// the error is on the stack, it is rethrown after the finally clause.
+		t.finally = te.Finally
+		if err := c.finally(t); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	}
+
+	for _, j := range jumps {
+		c.patch(j, len(c.current()))
+	}
+
+	return nil
+}
+
+// matchExpression compiles the arms of me as a chain of tests of the
+// value, which is kept on the stack until an arm is taken or none of
+// them matches.
+func (c *Compiler) matchExpression(me *ast.MatchExpression) error {
+	if err := c.compile(me.Value); err != nil {
+		return err
+	}
+
+	jumps := []int{}
+
+	for _, arm := range me.Arms {
+		next, err := c.matchArm(arm)
+		if err != nil {
+			return err
+		}
+		jumps = append(jumps, c.emit(code.OpJump, 0))
+
+		for _, j := range next {
+			c.patch(j, len(c.current()))
+		}
+	}
+
+	c.emit(code.OpNoMatch)
+
+	for _, j := range jumps {
+		c.patch(j, len(c.current()))
+	}
+
+	return nil
+}
+
+// matchArm compiles the test of the value on the top of the stack
+// against arm and, if it passes, the body of arm. The names of the arm
+// are bound in a scope of its own. It returns the jumps to patch to the
+// test of the next arm.
+func (c *Compiler) matchArm(arm *ast.MatchArm) ([]int, error) {
+	c.symbols = NewBlockSymbolTable(c.symbols)
+	defer func(symbols *SymbolTable) { c.symbols = symbols }(c.symbols.Outer)
+
+	for _, id := range ast.Bindings(arm.Pattern) {
+		c.symbols.Define(id.Value)
+	}
+	c.declareAll(arm.Pattern)
+	if arm.Guard != nil {
+		c.declareAll(arm.Guard)
+	}
+	c.declareAll(arm.Body)
+
+	next, err := c.pattern(arm.Pattern, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if arm.Guard != nil {
+		if err := c.compile(arm.Guard); err != nil {
+			return nil, err
+		}
+		next = append(next, c.emit(code.OpJumpNotTruthy, 0))
+	}
+
+	c.emit(code.OpPop)
+	if err := c.compile(arm.Body); err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// selectExpression compiles the operations of the arms of se, for each
+// the channel, whether it sends and the value sent, null for a receive,
+// followed by OpSelect. The arm taken is found by a chain of tests of
+// the index pushed by OpSelect, on top of the value received.
+func (c *Compiler) selectExpression(se *ast.SelectExpression) error {
+	var arms []*ast.SelectArm
+	var def *ast.SelectArm
+
+	for _, arm := range se.Arms {
+		if arm.IsDefault() {
+			def = arm
+			continue
+		}
+		arms = append(arms, arm)
+	}
+
+	if len(arms) > math.MaxUint16 {
+		return fmt.Errorf("too many arms in select")
+	}
+
+	for _, arm := range arms {
+		if err := c.compile(arm.Channel); err != nil {
+			return err
+		}
+		if arm.Value == nil {
+			c.emit(code.OpFalse)
+			c.emit(code.OpNull)
+			continue
+		}
+		c.emit(code.OpTrue)
+		if err := c.compile(arm.Value); err != nil {
+			return err
+		}
+	}
+
+	hasDefault := 0
+	if def != nil {
+		hasDefault = 1
+	}
+	c.emit(code.OpSelect, len(arms), hasDefault)
+
+	if def != nil {
+		arms = append(arms, def)
+	}
+
+	if len(arms) == 0 {
+		// the value received is null, a select without arms blocks forever.
+		c.emit(code.OpPop)
+		return nil
+	}
+
+	jumps := []int{}
+
+	for i, arm := range arms {
+		next := -1
+		// the last arm is taken if none of the others is.
+		if i < len(arms)-1 {
+			c.emit(code.OpDup)
+			c.emit(code.OpConstant, c.addConstant(objects.NewInteger(int64(i))))
+			c.emit(code.OpEqual)
+			next = c.emit(code.OpJumpNotTruthy, 0)
+		}
+
+		c.emit(code.OpPop)
+		if arm.Binding != nil && arm.Binding.Value != ast.Wildcard {
+			sym, _, _ := c.symbols.Resolve(arm.Binding.Value)
+			c.emit(code.OpDup)
+			c.emit(code.OpSet, sym.Slot)
+		}
+		c.emit(code.OpPop)
+
+		if err := c.compile(arm.Body); err != nil {
+			return err
+		}
+
+		if next >= 0 {
+			jumps = append(jumps, c.emit(code.OpJump, 0))
+			c.patch(next, len(c.current()))
+		}
+	}
+
+	for _, j := range jumps {
+		c.patch(j, len(c.current()))
+	}
+
+	return nil
+}
+
+// pattern compiles the test of the value on the top of the stack
+// against p, which binds the names of p if it matches. The value is
+// kept on the stack. It returns the jumps to patch to the code run if
+// the value does not match, which is reached with the value on the top
+// of the stack. If strict is set, a value that does not match raises
+// the error of a destructuring let statement instead.
+func (c *Compiler) pattern(p ast.Pattern, strict bool) ([]int, error) {
+	var fail []int
+
+	switch p := p.(type) {
+	case *ast.Identifier:
+		if p.Value != ast.Wildcard {
+			sym, _, _ := c.symbols.Resolve(p.Value)
+			c.emit(code.OpDup)
+			c.emit(code.OpSet, sym.Slot)
+		}
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.BooleanLiteral:
+		c.emit(code.OpDup)
+		if err := c.compile(p); err != nil {
+			return nil, err
+		}
+		c.emit(code.OpEqual)
+		fail = append(fail, c.emit(code.OpJumpNotTruthy, 0))
+	case *ast.ArrayPattern:
+		if len(p.Elements) > math.MaxUint16 {
+			return nil, fmt.Errorf("too many elements in pattern %s", p)
+		}
+
+		rest := 0
+		if p.Rest != nil {
+			rest = 1
+		}
+		c.emit(code.OpMatchArray, len(p.Elements), rest)
+		fail = append(fail, c.emit(code.OpJumpNotTruthy, 0))
+
+		inner := []int{}
+		for i, e := range p.Elements {
+			missing, err := c.element(c.emit(code.OpElement, 0, i), e)
+			if err != nil {
+				return nil, err
+			}
+			fail = append(fail, missing...)
+
+			next, err := c.pattern(e.Pattern, strict)
+			if err != nil {
+				return nil, err
+			}
+			inner = append(inner, next...)
+			c.emit(code.OpPop)
+		}
+
+		if p.Rest != nil && p.Rest.Value != ast.Wildcard {
+			sym, _, _ := c.symbols.Resolve(p.Rest.Value)
+			c.emit(code.OpRest, len(p.Elements))
+			c.emit(code.OpSet, sym.Slot)
+		}
+
+		fail = append(fail, c.popElement(inner)...)
+	case *ast.HashPattern:
+		c.emit(code.OpMatchHash)
+		fail = append(fail, c.emit(code.OpJumpNotTruthy, 0))
+
+		inner := []int{}
+		for _, e := range p.Elements {
+			key := c.addConstant(&objects.String{Value: e.Key.Value})
+			missing, err := c.element(c.emit(code.OpEntry, 0, key), e)
+			if err != nil {
+				return nil, err
+			}
+			fail = append(fail, missing...)
+
+			next, err := c.pattern(e.Pattern, strict)
+			if err != nil {
+				return nil, err
+			}
+			inner = append(inner, next...)
+			c.emit(code.OpPop)
+		}
+
+		fail = append(fail, c.popElement(inner)...)
+	default:
+		return nil, fmt.Errorf("can not compile pattern %T", p)
+	}
+
+	if !strict || len(fail) == 0 {
+		return fail, nil
+	}
+
+	done := c.emit(code.OpJump, 0)
+	for _, j := range fail {
+		c.patch(j, len(c.current()))
+	}
+	c.emit(code.OpMismatch, c.addConstant(&objects.String{Value: p.String()}))
+	c.patch(done, len(c.current()))
+
+	return nil, nil
+}
+
+// element compiles the default of the element e of a pattern, which
+// is pushed if the OpElement or OpEntry instruction at pos finds no
+// element. If e has none, the jump of the instruction is returned.
+func (c *Compiler) element(pos int, e *ast.PatternElement) ([]int, error) {
+	if e.Default == nil {
+		return []int{pos}, nil
+	}
+
+	skip := c.emit(code.OpJump, 0)
+	c.patch(pos, len(c.current()))
+	if err := c.compile(e.Default); err != nil {
+		return nil, err
+	}
+	c.patch(skip, len(c.current()))
+
+	return nil, nil
+}
+
+// popElement compiles the code run if an element of an array or a hash
+// does not match its pattern, which pops the element. It returns the
+// jump to patch to the code run if the array or the hash does not match.
+func (c *Compiler) popElement(jumps []int) []int {
+	if len(jumps) == 0 {
+		return nil
+	}
+
+	done := c.emit(code.OpJump, 0)
+	for _, j := range jumps {
+		c.patch(j, len(c.current()))
+	}
+	c.emit(code.OpPop)
+	fail := c.emit(code.OpJump, 0)
+	c.patch(done, len(c.current()))
+
+	return []int{fail}
+}
+
+// finally compiles the finally clause of t, if it has one, discarding
+// its value. The clause is removed from t, so that a return statement
+// in it does not run it again.
+func (c *Compiler) finally(t *tryBlock) error {
+	block := t.finally
+	if block == nil {
+		return nil
+	}
+	t.finally = nil
+
+	symbols := c.symbols
+	c.symbols = t.symbols
+	defer func() { c.symbols = symbols }()
+
+	if err := c.block(block); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	return nil
+}
+
+// leaveTries compiles the exit of a return statement from the try
+// expressions enclosing it, innermost first: their handlers are removed
+// and their finally clauses run.
+func (c *Compiler) leaveTries() error {
+	tries := c.scope().tries
+
+	saved := make([]tryBlock, len(tries))
+	for i, t := range tries {
+		saved[i] = *t
+	}
+	defer func() {
+		for i, t := range tries {
+			*t = saved[i]
+		}
+	}()
+
+	for i := len(tries) - 1; i >= 0; i-- {
+		if tries[i].handler {
+			c.emit(code.OpEndTry)
+			tries[i].handler = false
+		}
+		if err := c.finally(tries[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) function(fn *ast.FunctionLiteral) error {
+	name := ""
+	if fn.Name != nil {
+		name = fn.Name.Value
+	}
+
+	c.symbols = NewEnclosedSymbolTable(c.symbols)
+	c.scopes = append(c.scopes, &compilationScope{})
+
+	for _, p := range fn.Parameters {
+		c.symbols.DefineParameter(p.Value)
+	}
+	if fn.Rest != nil {
+		c.symbols.DefineParameter(fn.Rest.Value)
+	}
+
+	// a parameter whose argument is missing is assigned its default value.
+	defaults := 0
+	for i := range fn.Parameters {
+		d := fn.Default(i)
+		if d == nil {
+			continue
+		}
+		defaults++
+
+		skip := c.emit(code.OpJumpBound, 9999, i)
+		if err := c.compile(d); err != nil {
+			return err
+		}
+		c.emit(code.OpSet, i)
+		c.patch(skip, len(c.current()))
+	}
+
+	c.declareAll(fn.Body)
+
+	if err := c.block(fn.Body); err != nil {
+		return err
+	}
+
+	line := c.line
+	if end := fn.Body.End.Pos; end.IsValid() {
+		c.line = end.Line
+	}
+	c.emit(code.OpReturnValue)
+	c.line = line
+
+	if err := c.checkSize(); err != nil {
+		return err
+	}
+
+	compiled := &objects.CompiledFunction{
+		Name:          name,
+		Instructions:  c.current(),
+		Lines:         c.scope().lines,
+		NumLocals:     c.symbols.NumDefinitions(),
+		NumParameters: len(fn.Parameters),
+		NumDefaults:   defaults,
+		Rest:          fn.Rest != nil,
+		Generator:     fn.Generator,
+		Names:         c.symbols.Names(),
+	}
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.symbols = c.symbols.Outer
+
+	c.emit(code.OpClosure, c.addConstant(compiled))
+	return nil
+}
+
+// hoist binds the functions declared in a block before its first
+// statement, so they can be called anywhere in the block and may
+// call each other.
+func (c *Compiler) hoist(list []ast.Statement) error {
+	for _, s := range list {
+		fs, ok := s.(*ast.FunctionStatement)
+		if !ok {
+			continue
+		}
+
+		if err := c.compile(fs.Function); err != nil {
+			return err
+		}
+
+		sym, _, _ := c.symbols.Resolve(fs.Name().Value)
+		c.emit(code.OpSet, sym.Slot)
+	}
+
+	return nil
+}
+
+// identifier emits the lookup of id. Names that are not bound in any
+// scope are bound in the program scope without ever being assigned,
+// looking them up fails at runtime like in the evaluator.
+func (c *Compiler) identifier(id *ast.Identifier) {
+	sym, depth, t, ok := c.symbols.resolve(id.Value)
+	if ok && depth == 0 && t.block {
+		// like the environments of the evaluator the name refers to
+		// the enclosing binding until the block binds it, e.g. in
+		// let x = x + 1.
+		if outer, d, ok := t.Outer.Resolve(id.Value); ok {
+			bound := c.emit(code.OpJumpBound, 9999, sym.Slot)
+			c.emit(code.OpGet, d, outer.Slot)
+			end := c.emit(code.OpJump, 9999)
+			c.patch(bound, len(c.current()))
+			c.emit(code.OpGet, 0, sym.Slot)
+			c.patch(end, len(c.current()))
+			return
+		}
+	}
+	if !ok {
+		global := c.symbols
+		for global.Outer != nil {
+			if !global.block {
+				depth++
+			}
+			global = global.Outer
+		}
+		sym = global.Define(id.Value)
+	}
+
+	c.emit(code.OpGet, depth, sym.Slot)
+}
+
+// declareAll binds the names of all the let, function and import
+// statements and select arms of the current scope up front, so every
+// let statement of a scope assigns the same slot.
+func (c *Compiler) declareAll(node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		case *ast.LetStatement:
+			if n.Identifier != nil {
+				c.symbols.Define(n.Identifier.Value)
+			}
+			for _, id := range ast.Bindings(n.Pattern) {
+				c.symbols.Define(id.Value)
+			}
+		case *ast.FunctionStatement:
+			if id := n.Name(); id != nil {
+				c.symbols.Define(id.Value)
+			}
+		case *ast.ImportStatement:
+			if n.Name != nil {
+				c.symbols.Define(n.Name.Value)
+			}
+		case *ast.TryExpression:
+			// the names of the catch clause are declared when it is compiled.
+			c.declareAll(n.Body)
+			if n.Finally != nil {
+				c.declareAll(n.Finally)
+			}
+			return false
+		case *ast.MatchExpression:
+			// the names of the arms are declared when they are compiled.
+			c.declareAll(n.Value)
+			return false
+		case *ast.SelectExpression:
+			for _, a := range n.Arms {
+				if a.Binding != nil && a.Binding.Value != ast.Wildcard {
+					c.symbols.Define(a.Binding.Value)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// checkSize reports whether the jumps of the function
+// being compiled can address all of its instructions.
+func (c *Compiler) checkSize() error {
+	if len(c.current()) > math.MaxUint16 {
+		return fmt.Errorf("function too large: %d bytes of instructions", len(c.current()))
+	}
+	return nil
+}
+
+func (c *Compiler) addConstant(o objects.Object) int {
+	c.constants = append(c.constants, o)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) scope() *compilationScope {
+	return c.scopes[len(c.scopes)-1]
+}
+
+func (c *Compiler) current() code.Instructions {
+	return c.scope().instructions
+}
+
+// emit appends an instruction to the function being compiled and
+// returns its offset. The line table is extended if the instruction
+// starts a new line.
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	s := c.scope()
+	pos := len(s.instructions)
+
+	if n := len(s.lines); c.line > 0 && (n == 0 || s.lines[n-1].Line != c.line) {
+		s.lines = append(s.lines, code.Line{Offset: pos, Line: c.line})
+	}
+
+	s.instructions = append(s.instructions, code.Make(op, operands...)...)
+	return pos
+}
+
+// patch replaces the first operand of the jump at pos with target.
+func (c *Compiler) patch(pos int, target int) {
+	op := code.Opcode(c.current()[pos])
+	def, _ := code.Lookup(byte(op))
+
+	operands, _ := code.ReadOperands(def, c.current()[pos+1:])
+	operands[0] = target
+
+	copy(c.current()[pos:], code.Make(op, operands...))
+}
@@ -0,0 +1,417 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/code"
+	"github.com/Despire/interpreter/lexer"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/parser"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input        string
+		constants    []interface{}
+		instructions []code.Instructions
+	}{
+		{
+			"1 + 2",
+			[]interface{}{1, 2},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"-1 < 2; !true",
+			[]interface{}{1, 2},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpPop),
+				code.Make(code.OpTrue),
+				code.Make(code.OpBang),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"if (true) { 10 }; 3333;",
+			[]interface{}{10, 3333},
+			[]code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"let a = 1; a; let b = a;",
+			[]interface{}{1},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSet, 0),
+				code.Make(code.OpGet, 0, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpGet, 0, 0),
+				code.Make(code.OpSet, 1),
+				code.Make(code.OpNil),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"a; let a = 1;",
+			[]interface{}{1},
+			[]code.Instructions{
+				code.Make(code.OpGet, 0, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSet, 0),
+				code.Make(code.OpNil),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"let x = 1; fn(y) { let z = x + y; }(2)",
+			[]interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGet, 1, 0),
+					code.Make(code.OpGet, 0, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpSet, 1),
+					code.Make(code.OpNil),
+					code.Make(code.OpReturnValue),
+				},
+				2,
+			},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSet, 0),
+				code.Make(code.OpClosure, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"fn() { return undefined; }",
+			[]interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGet, 1, 0),
+					code.Make(code.OpReturnValue),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			[]code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			`import "lib.mk" as m; m.f(m.x)`,
+			[]interface{}{"lib.mk", "f", "x"},
+			[]code.Instructions{
+				code.Make(code.OpImport, 0),
+				code.Make(code.OpSet, 0),
+				code.Make(code.OpGet, 0, 0),
+				code.Make(code.OpMember, 1),
+				code.Make(code.OpGet, 0, 0),
+				code.Make(code.OpMember, 2),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			`try { throw "x"; } catch (e) { e }`,
+			[]interface{}{"x"},
+			[]code.Instructions{
+				code.Make(code.OpTry, 11),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpThrow),
+				code.Make(code.OpEndTry),
+				code.Make(code.OpJump, 22),
+				code.Make(code.OpSet, 0),
+				code.Make(code.OpGet, 0, 0),
+				code.Make(code.OpJump, 22),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"match (1) { 2 => 3, n if n => n }",
+			[]interface{}{1, 2, 3},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpEqual),
+				code.Make(code.OpJumpNotTruthy, 18),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpJump, 40),
+				code.Make(code.OpDup),
+				code.Make(code.OpSet, 0),
+				code.Make(code.OpGet, 0, 0),
+				code.Make(code.OpJumpNotTruthy, 39),
+				code.Make(code.OpPop),
+				code.Make(code.OpGet, 0, 0),
+				code.Make(code.OpJump, 40),
+				code.Make(code.OpNoMatch),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"[1, 2][0]; {a: 1}",
+			[]interface{}{1, 2, 0, "a", 1},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpHash, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"let a = f(); fn f() { 1 }",
+			[]interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			[]code.Instructions{
+				code.Make(code.OpClosure, 1),
+				code.Make(code.OpSet, 1),
+				code.Make(code.OpGet, 0, 1),
+				code.Make(code.OpCall, 0),
+				code.Make(code.OpSet, 0),
+				code.Make(code.OpNil),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"let f = fn(x, y = x) { x }; f(1, y: 2);",
+			[]interface{}{
+				[]code.Instructions{
+					code.Make(code.OpJumpBound, 13, 1),
+					code.Make(code.OpGet, 0, 0),
+					code.Make(code.OpSet, 1),
+					code.Make(code.OpGet, 0, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1, 2, "y",
+			},
+			[]code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpSet, 0),
+				code.Make(code.OpGet, 0, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCallNamed, 2, 1, 3),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"let [a] = [1];",
+			[]interface{}{1, "[a]"},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpMatchArray, 1, 0),
+				code.Make(code.OpJumpNotTruthy, 26),
+				code.Make(code.OpElement, 26, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpSet, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 29),
+				code.Make(code.OpMismatch, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpNil),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"fn g(x) { yield x }",
+			[]interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGet, 0, 0),
+					code.Make(code.OpYield),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			[]code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpSet, 0),
+				code.Make(code.OpNil),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"spawn f(1, n: 2); let f = 1;",
+			[]interface{}{1, 2, "n", 1},
+			[]code.Instructions{
+				code.Make(code.OpGet, 0, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSpawn, 2, 1, 2),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpSet, 0),
+				code.Make(code.OpNil),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"let c = chan(); select { c.recv() as v => v, _ => 1 }",
+			[]interface{}{0, 0, 1},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpChannel),
+				code.Make(code.OpSet, 0),
+				code.Make(code.OpGet, 0, 0),
+				code.Make(code.OpFalse),
+				code.Make(code.OpNull),
+				code.Make(code.OpSelect, 1, 1),
+				code.Make(code.OpDup),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpEqual),
+				code.Make(code.OpJumpNotTruthy, 40),
+				code.Make(code.OpPop),
+				code.Make(code.OpDup),
+				code.Make(code.OpSet, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpGet, 0, 1),
+				code.Make(code.OpJump, 45),
+				code.Make(code.OpPop),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		c := New()
+		if err := c.Compile(parse(t, tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := c.Bytecode()
+		testInstructions(t, tt.input, tt.instructions, bytecode.Instructions)
+
+		if len(bytecode.Constants) != len(tt.constants) {
+			t.Fatalf("wrong number of constants for %q. want=%d, have=%d", tt.input, len(tt.constants), len(bytecode.Constants))
+		}
+
+		for i, want := range tt.constants {
+			switch want := want.(type) {
+			case int:
+				integer, ok := bytecode.Constants[i].(*objects.Integer)
+				if !ok || integer.Value != int64(want) {
+					t.Errorf("constant %d of %q is not %d. have=%s", i, tt.input, want, bytecode.Constants[i].Inspect())
+				}
+			case string:
+				s, ok := bytecode.Constants[i].(*objects.String)
+				if !ok || s.Value != want {
+					t.Errorf("constant %d of %q is not %q. have=%s", i, tt.input, want, bytecode.Constants[i].Inspect())
+				}
+			case []code.Instructions:
+				fn, ok := bytecode.Constants[i].(*objects.CompiledFunction)
+				if !ok {
+					t.Errorf("constant %d of %q is not a function. have=%T", i, tt.input, bytecode.Constants[i])
+					continue
+				}
+				testInstructions(t, tt.input, want, fn.Instructions)
+			}
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(1)", "can not compile quote, it is only supported by the eval engine"},
+		{"let f = fn() { unquote(1) }", "can not compile unquote, it is only supported by the eval engine"},
+		{"let m = macro(x) { x }", "macros must be bound by a top-level let statement"},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(t, tt.input))
+		if err == nil {
+			t.Errorf("expected an error for %q", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, have=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	global.Define("b")
+
+	local := NewEnclosedSymbolTable(global)
+	local.DefineParameter("x")
+	x := local.DefineParameter("x")
+
+	if x.Slot != 1 {
+		t.Errorf("duplicate parameter has wrong slot. want=1, have=%d", x.Slot)
+	}
+
+	if again := global.Define("a"); again != a {
+		t.Errorf("redefinition of a changed its symbol. want=%+v, have=%+v", a, again)
+	}
+
+	sym, depth, ok := local.Resolve("b")
+	if !ok || depth != 1 || sym.Slot != 1 {
+		t.Errorf("b resolved wrongly. have=%+v, depth=%d, ok=%t", sym, depth, ok)
+	}
+
+	if _, _, ok := local.Resolve("c"); ok {
+		t.Errorf("c must not resolve")
+	}
+}
+
+func testInstructions(t *testing.T, input string, want []code.Instructions, have code.Instructions) {
+	t.Helper()
+
+	concatted := code.Instructions{}
+	for _, ins := range want {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != have.String() {
+		t.Errorf("wrong instructions for %q.\nwant=\n%s\nhave=\n%s", input, concatted, have)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	for _, err := range p.Errors() {
+		t.Fatalf("parser error: %q", err)
+	}
+
+	return program
+}
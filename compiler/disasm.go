@@ -0,0 +1,113 @@
+package compiler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Despire/interpreter/code"
+	"github.com/Despire/interpreter/objects"
+)
+
+// Disassemble writes a listing of the bytecode to w: the globals,
+// the constant pool, the program and then each function. Every
+// instruction is prefixed with its offset and, when it starts a
+// new line, the source line. Operands referring to constants and
+// to slots of the current scope are annotated with their values.
+func (b *Bytecode) Disassemble(w io.Writer) error {
+	out := new(strings.Builder)
+
+	fmt.Fprintf(out, "globals: %s\n", strings.Join(b.Globals, ", "))
+
+	fmt.Fprintf(out, "constants:\n")
+	for i, c := range b.Constants {
+		fmt.Fprintf(out, "  %4d %s %s\n", i, c.Type(), c.Inspect())
+	}
+
+	fmt.Fprintf(out, "\nprogram:\n")
+	b.listing(out, b.Instructions, b.Lines, b.Globals)
+
+	for i, c := range b.Constants {
+		fn, ok := c.(*objects.CompiledFunction)
+		if !ok {
+			continue
+		}
+
+		params := fmt.Sprint(fn.NumParameters)
+		if fn.NumDefaults > 0 {
+			params += fmt.Sprintf(" defaults=%d", fn.NumDefaults)
+		}
+		if fn.Rest {
+			params += " rest=" + fn.Names[fn.NumParameters]
+		}
+
+		header := fmt.Sprintf("function %d: params=%s locals=%s", i, params, strings.Join(fn.Names, ", "))
+		if fn.Generator {
+			header += " generator"
+		}
+
+		fmt.Fprintf(out, "\n%s\n", header)
+		b.listing(out, fn.Instructions, fn.Lines, fn.Names)
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func (b *Bytecode) listing(out *strings.Builder, ins code.Instructions, lines code.LineTable, names []string) {
+	line := 0
+
+	for off := 0; off < len(ins); {
+		def, operands, next, err := code.ReadInstruction(ins, off)
+		if err != nil {
+			fmt.Fprintf(out, "  %04d       ERROR: %s\n", off, err)
+			return
+		}
+
+		source := ""
+		if l := lines.LineAt(off); l != line {
+			line = l
+			source = fmt.Sprint(l)
+		}
+
+		text := code.FormatInstruction(def, operands)
+		if note := b.annotation(code.Opcode(ins[off]), operands, names); note != "" {
+			text = fmt.Sprintf("%-22s ; %s", text, note)
+		}
+
+		fmt.Fprintf(out, "  %04d %5s %s\n", off, source, text)
+		off = next
+	}
+}
+
+func (b *Bytecode) annotation(op code.Opcode, operands []int, names []string) string {
+	switch op {
+	case code.OpConstant, code.OpClosure, code.OpImport, code.OpMember, code.OpMismatch:
+		if operands[0] < len(b.Constants) {
+			return b.Constants[operands[0]].Inspect()
+		}
+	case code.OpEntry:
+		if operands[1] < len(b.Constants) {
+			return b.Constants[operands[1]].Inspect()
+		}
+	case code.OpGet:
+		if operands[0] == 0 && operands[1] < len(names) {
+			return names[operands[1]]
+		}
+	case code.OpSet:
+		if operands[0] < len(names) {
+			return names[operands[0]]
+		}
+	case code.OpJumpBound:
+		if operands[1] < len(names) {
+			return names[operands[1]]
+		}
+	case code.OpCallNamed:
+		var named []string
+		for i := operands[2]; i < operands[2]+operands[1] && i < len(b.Constants); i++ {
+			named = append(named, b.Constants[i].Inspect())
+		}
+		return strings.Join(named, ", ")
+	}
+	return ""
+}
@@ -0,0 +1,308 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+
+	"github.com/Despire/interpreter/code"
+	"github.com/Despire/interpreter/objects"
+)
+
+// FormatVersion is the version of the binary encoding of bytecode.
+// It is incremented on every change of the encoding or of the
+// instruction set, files of other versions are rejected.
+const FormatVersion = 9
+
+// magic starts every file of encoded bytecode.
+var magic = []byte("MKBC")
+
+const headerSize = 6  // magic and version.
+const trailerSize = 4 // checksum.
+
+// tags of the encoded constants.
+const (
+	tagInteger  = 1
+	tagFunction = 2
+	tagString   = 3
+)
+
+// IsBytecode reports whether data starts like encoded bytecode.
+func IsBytecode(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// MarshalBinary encodes the bytecode.
+//
+// The encoding starts with a header of the magic "MKBC" and the
+// FormatVersion as an uint16, followed by the names of the globals,
+// the constant pool, the instructions and the line table of the
+// program. Functions are encoded in the constant pool with their
+// own instructions and line table, strings with their length. Counts, lengths and offsets are
+// encoded as unsigned varints, integer constants as signed varints.
+// The encoding ends with the CRC-32 checksum of everything before it.
+func (b *Bytecode) MarshalBinary() ([]byte, error) {
+	e := new(encoder)
+	e.Write(magic)
+	binary.Write(e, binary.BigEndian, uint16(FormatVersion))
+
+	e.strings(b.Globals)
+
+	e.uvarint(len(b.Constants))
+	for i, c := range b.Constants {
+		switch c := c.(type) {
+		case *objects.Integer:
+			e.WriteByte(tagInteger)
+			e.varint(c.Value)
+		case *objects.CompiledFunction:
+			e.WriteByte(tagFunction)
+			e.uvarint(len(c.Name))
+			e.WriteString(c.Name)
+			e.uvarint(c.NumLocals)
+			e.uvarint(c.NumParameters)
+			e.uvarint(c.NumDefaults)
+			e.boolean(c.Rest)
+			e.boolean(c.Generator)
+			e.strings(c.Names)
+			e.instructions(c.Instructions, c.Lines)
+		case *objects.String:
+			e.WriteByte(tagString)
+			e.uvarint(len(c.Value))
+			e.WriteString(c.Value)
+		default:
+			return nil, fmt.Errorf("constant %d: can not encode %s", i, c.Type())
+		}
+	}
+
+	e.instructions(b.Instructions, b.Lines)
+
+	binary.Write(e, binary.BigEndian, crc32.ChecksumIEEE(e.Bytes()))
+	return e.Bytes(), nil
+}
+
+// UnmarshalBinary decodes bytecode encoded by MarshalBinary. The input is
+// validated completely, corrupted or truncated data as well as bytecode
+// that could fail the virtual machine is rejected with an error.
+func (b *Bytecode) UnmarshalBinary(data []byte) error {
+	if !IsBytecode(data) {
+		return errors.New("not a bytecode file")
+	}
+
+	if len(data) < headerSize+trailerSize {
+		return errors.New("truncated bytecode: missing header")
+	}
+
+	if v := binary.BigEndian.Uint16(data[len(magic):]); v != FormatVersion {
+		return fmt.Errorf("unsupported bytecode version %d, want %d", v, FormatVersion)
+	}
+
+	body, trailer := data[:len(data)-trailerSize], data[len(data)-trailerSize:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(trailer) {
+		return errors.New("corrupted bytecode: checksum mismatch")
+	}
+
+	d := &decoder{data: body, off: headerSize}
+	out := &Bytecode{}
+
+	out.Globals = d.strings("globals")
+
+	n := d.count("constants")
+	for i := 0; i < n && d.err == nil; i++ {
+		switch tag := d.byte("constant tag"); tag {
+		case tagInteger:
+			out.Constants = append(out.Constants, objects.NewInteger(d.varint("integer")))
+		case tagFunction:
+			fn := &objects.CompiledFunction{
+				Name:          string(d.bytes("function name")),
+				NumLocals:     d.uvarint("number of locals"),
+				NumParameters: d.uvarint("number of parameters"),
+				NumDefaults:   d.uvarint("number of defaults"),
+				Rest:          d.boolean("rest parameter"),
+				Generator:     d.boolean("generator"),
+				Names:         d.strings("names"),
+			}
+			fn.Instructions, fn.Lines = d.instructions()
+			out.Constants = append(out.Constants, fn)
+		case tagString:
+			out.Constants = append(out.Constants, &objects.String{Value: string(d.bytes("string"))})
+		default:
+			d.fail(fmt.Errorf("constant %d has unknown tag %d", i, tag))
+		}
+	}
+
+	out.Instructions, out.Lines = d.instructions()
+
+	if d.err == nil && d.off != len(d.data) {
+		d.fail(fmt.Errorf("%d unexpected bytes after the program", len(d.data)-d.off))
+	}
+
+	if d.err != nil {
+		return fmt.Errorf("invalid bytecode at offset %d: %v", d.off, d.err)
+	}
+
+	if err := out.verify(); err != nil {
+		return fmt.Errorf("invalid bytecode: %v", err)
+	}
+
+	*b = *out
+	return nil
+}
+
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) uvarint(v int) {
+	var buf [binary.MaxVarintLen64]byte
+	e.Write(buf[:binary.PutUvarint(buf[:], uint64(v))])
+}
+
+func (e *encoder) varint(v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	e.Write(buf[:binary.PutVarint(buf[:], v)])
+}
+
+func (e *encoder) boolean(b bool) {
+	if b {
+		e.WriteByte(1)
+		return
+	}
+	e.WriteByte(0)
+}
+
+func (e *encoder) strings(list []string) {
+	e.uvarint(len(list))
+	for _, s := range list {
+		e.uvarint(len(s))
+		e.WriteString(s)
+	}
+}
+
+func (e *encoder) instructions(ins code.Instructions, lines code.LineTable) {
+	e.uvarint(len(ins))
+	e.Write(ins)
+
+	e.uvarint(len(lines))
+	for _, l := range lines {
+		e.uvarint(l.Offset)
+		e.uvarint(l.Line)
+	}
+}
+
+// decoder reads the values of the encoding from data. After the
+// first error all reads return zero values and err holds the error.
+type decoder struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) byte(what string) byte {
+	if d.err != nil {
+		return 0
+	}
+	if d.off >= len(d.data) {
+		d.fail(fmt.Errorf("truncated while reading the %s", what))
+		return 0
+	}
+	d.off++
+	return d.data[d.off-1]
+}
+
+func (d *decoder) boolean(what string) bool {
+	switch b := d.byte(what); b {
+	case 0, 1:
+		return b == 1
+	default:
+		d.fail(fmt.Errorf("malformed %s", what))
+		return false
+	}
+}
+
+func (d *decoder) uvarint(what string) int {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data[d.off:])
+	if n <= 0 {
+		d.fail(fmt.Errorf("truncated or malformed %s", what))
+		return 0
+	}
+	if v > math.MaxInt32 {
+		d.fail(fmt.Errorf("%s %d out of range", what, v))
+		return 0
+	}
+	d.off += n
+	return int(v)
+}
+
+func (d *decoder) varint(what string) int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data[d.off:])
+	if n <= 0 {
+		d.fail(fmt.Errorf("truncated or malformed %s", what))
+		return 0
+	}
+	d.off += n
+	return v
+}
+
+// count reads the number of elements of a list, each element
+// takes at least one byte so it can not exceed the remaining data.
+func (d *decoder) count(what string) int {
+	n := d.uvarint("number of " + what)
+	if d.err == nil && n > len(d.data)-d.off {
+		d.fail(fmt.Errorf("number of %s %d exceeds the size of the input", what, n))
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) bytes(what string) []byte {
+	n := d.uvarint("length of the " + what)
+	if d.err == nil && n > len(d.data)-d.off {
+		d.fail(fmt.Errorf("length of the %s %d exceeds the size of the input", what, n))
+	}
+	if d.err != nil {
+		return nil
+	}
+	out := make([]byte, n)
+	copy(out, d.data[d.off:])
+	d.off += n
+	return out
+}
+
+func (d *decoder) strings(what string) []string {
+	n := d.count(what)
+	out := make([]string, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		out = append(out, string(d.bytes("name")))
+	}
+	return out
+}
+
+func (d *decoder) instructions() (code.Instructions, code.LineTable) {
+	ins := code.Instructions(d.bytes("instructions"))
+
+	var lines code.LineTable
+	n := d.count("line table entries")
+	for i := 0; i < n && d.err == nil; i++ {
+		lines = append(lines, code.Line{
+			Offset: d.uvarint("line offset"),
+			Line:   d.uvarint("line"),
+		})
+	}
+
+	return ins, lines
+}
@@ -0,0 +1,375 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Despire/interpreter/code"
+	"github.com/Despire/interpreter/objects"
+)
+
+var encodingTestInputs = []string{
+	"",
+	"1 + 2 * -3",
+	"let a = 1; let b = a; b;",
+	"import \"lib.mk\" as m; m.f(m.x)",
+	"let f = fn(n) {\n  if (n < 2) {\n    return n;\n  }\n  f(n - 1) + f(n - 2)\n};\nf(10)",
+	"let c = fn(x) { fn(y) { fn(z) { x + y + z } } }; c(1)(2)(3)",
+	"let x = -9223372036854775807 - 1; !x; undefined",
+	"fn even(n) { if (n == 0) { true } else { odd(n - 1) } } fn odd(n) { even(n - 1) } even(4)",
+	"fn g(x) { let y = yield x; y } g(1).next()",
+	"let c = chan(1); spawn c.send(x: 1); select { c.recv() as v => v, c.send(2) => 0, _ => -1 }",
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	for _, input := range encodingTestInputs {
+		c := New()
+		if err := c.Compile(parse(t, input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		want := c.Bytecode()
+
+		data, err := want.MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to encode %q: %v", input, err)
+		}
+
+		have := new(Bytecode)
+		if err := have.UnmarshalBinary(data); err != nil {
+			t.Fatalf("failed to decode %q: %v", input, err)
+		}
+
+		if !reflect.DeepEqual(normalize(want), normalize(have)) {
+			t.Errorf("decoded bytecode of %q differs.\nwant=%+v\nhave=%+v", input, want, have)
+		}
+	}
+}
+
+func TestBinaryCorruption(t *testing.T) {
+	c := New()
+	if err := c.Compile(parse(t, encodingTestInputs[3])); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	data, err := c.Bytecode().MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+
+	for n := 0; n < len(data); n++ {
+		if err := new(Bytecode).UnmarshalBinary(data[:n]); err == nil {
+			t.Errorf("truncation to %d bytes was not detected", n)
+		}
+	}
+
+	for i := 0; i < len(data); i++ {
+		corrupted := append([]byte{}, data...)
+		corrupted[i] ^= 0x40
+
+		if err := new(Bytecode).UnmarshalBinary(corrupted); err == nil {
+			t.Errorf("corruption of byte %d was not detected", i)
+		}
+	}
+
+	version := append([]byte{}, data...)
+	version[5]++
+	if err := new(Bytecode).UnmarshalBinary(version); err == nil || !strings.Contains(err.Error(), fmt.Sprintf("unsupported bytecode version %d", FormatVersion+1)) {
+		t.Errorf("wrong error for an unknown version: %v", err)
+	}
+}
+
+func TestBinaryVerification(t *testing.T) {
+	fn := func(ins ...[]byte) *objects.CompiledFunction {
+		return &objects.CompiledFunction{Instructions: concat(ins...)}
+	}
+
+	tests := []struct {
+		bytecode *Bytecode
+		expected string
+	}{
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpConstant, 1))},
+			"program: offset 0: constant 1 does not exist",
+		},
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpAdd))},
+			"program: offset 0: stack underflow",
+		},
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpJump, 2))},
+			"program: offset 0: jump to 2 is not an instruction",
+		},
+		{
+			&Bytecode{Instructions: []byte{255}},
+			"program: offset 0: opcode 255 undefined",
+		},
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpGet, 0, 0))},
+			"program: offset 0: slot 0 of scope 0 does not exist",
+		},
+		{
+			&Bytecode{
+				Instructions: concat(
+					code.Make(code.OpTrue),
+					code.Make(code.OpJumpNotTruthy, 6),
+					code.Make(code.OpNull),
+					code.Make(code.OpNull),
+					code.Make(code.OpPop),
+				),
+			},
+			"program: offset 5: stack height 2 differs from 0 at 6",
+		},
+		{
+			&Bytecode{
+				Instructions: concat(code.Make(code.OpClosure, 0)),
+				Constants:    []objects.Object{fn(code.Make(code.OpNil))},
+			},
+			"program: constant 0: offset 0: function runs past its end",
+		},
+		{
+			&Bytecode{
+				Instructions: concat(code.Make(code.OpClosure, 0)),
+				Constants:    []objects.Object{fn(code.Make(code.OpClosure, 0), code.Make(code.OpReturnValue))},
+			},
+			"functions nested deeper than the number of constants",
+		},
+		{
+			&Bytecode{
+				Instructions: concat(code.Make(code.OpNil)),
+				Lines:        code.LineTable{{Offset: 0, Line: 1}, {Offset: 0, Line: 2}},
+			},
+			"program: line table entry 1 has invalid offset 0",
+		},
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpEndTry))},
+			"program: offset 0: no handler to remove",
+		},
+		{
+			&Bytecode{
+				Instructions: concat(
+					code.Make(code.OpNil),
+					code.Make(code.OpTry, 5),
+					code.Make(code.OpPop),
+					code.Make(code.OpPop),
+				),
+			},
+			"program: offset 4: stack underflow",
+		},
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpDup))},
+			"program: offset 0: stack underflow",
+		},
+		{
+			&Bytecode{
+				Instructions: concat(
+					code.Make(code.OpNull),
+					code.Make(code.OpMatchHash),
+					code.Make(code.OpEntry, 7, 0),
+				),
+				Constants: []objects.Object{objects.NewInteger(1)},
+			},
+			"program: offset 2: constant 0 is not a string",
+		},
+		{
+			&Bytecode{
+				Instructions: concat(
+					code.Make(code.OpNil),
+					code.Make(code.OpNil),
+					code.Make(code.OpCallNamed, 1, 1, 0),
+				),
+				Constants: []objects.Object{objects.NewInteger(1)},
+			},
+			"program: offset 2: constant 0 is not a string",
+		},
+		{
+			&Bytecode{
+				Instructions: concat(code.Make(code.OpClosure, 0)),
+				Constants:    []objects.Object{fn(code.Make(code.OpJumpBound, 5, 0), code.Make(code.OpNil), code.Make(code.OpReturnValue))},
+			},
+			"program: constant 0: offset 0: slot 0 does not exist",
+		},
+		{
+			&Bytecode{
+				Instructions: concat(code.Make(code.OpNil)),
+				Constants:    []objects.Object{&objects.CompiledFunction{NumParameters: 1, NumDefaults: 2, NumLocals: 1, Names: []string{"x"}}},
+			},
+			"constant 0: function has 2 defaults for 1 parameters",
+		},
+		{
+			&Bytecode{
+				Instructions: concat(code.Make(code.OpNil)),
+				Constants:    []objects.Object{&objects.CompiledFunction{NumParameters: 1, Rest: true, NumLocals: 1, Names: []string{"x"}}},
+			},
+			"constant 0: function has 1 names for 2 parameters and 1 locals",
+		},
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpElement, 0, 0))},
+			"program: offset 0: stack underflow",
+		},
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpNil), code.Make(code.OpNil), code.Make(code.OpHash, 2))},
+			"program: offset 2: stack underflow",
+		},
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpNil), code.Make(code.OpYield))},
+			"program: offset 1: yield outside of a generator",
+		},
+		{
+			&Bytecode{
+				Instructions: concat(code.Make(code.OpClosure, 0)),
+				Constants:    []objects.Object{fn(code.Make(code.OpNil), code.Make(code.OpYield), code.Make(code.OpReturnValue))},
+			},
+			"program: constant 0: offset 1: yield outside of a generator",
+		},
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpNil), code.Make(code.OpNil), code.Make(code.OpSelect, 1, 0))},
+			"program: offset 2: stack underflow",
+		},
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpTrue), code.Make(code.OpNil), code.Make(code.OpAdd), code.Make(code.OpPop))},
+			"program: offset 2: value of OpNil used by OpAdd",
+		},
+		{
+			&Bytecode{
+				Instructions: concat(code.Make(code.OpNil), code.Make(code.OpDup), code.Make(code.OpPop), code.Make(code.OpMember, 0), code.Make(code.OpPop)),
+				Constants:    []objects.Object{&objects.String{Value: "x"}},
+			},
+			"program: offset 3: value of OpNil used by OpMember",
+		},
+		{
+			// the value of OpNil reaches the comparison on one of the paths.
+			&Bytecode{
+				Instructions: concat(
+					code.Make(code.OpTrue),
+					code.Make(code.OpJumpNotTruthy, 8),
+					code.Make(code.OpNil),
+					code.Make(code.OpJump, 9),
+					code.Make(code.OpTrue),
+					code.Make(code.OpTrue),
+					code.Make(code.OpLessThan),
+					code.Make(code.OpPop),
+				),
+			},
+			"program: offset 10: value of OpNil used by OpLessThan",
+		},
+		{
+			&Bytecode{Constants: []objects.Object{fn(code.Make(code.OpNil), code.Make(code.OpMinus), code.Make(code.OpReturnValue))}, Instructions: concat(code.Make(code.OpClosure, 0), code.Make(code.OpPop))},
+			"constant 0: offset 1: value of OpNil used by OpMinus",
+		},
+	}
+
+	for _, tt := range tests {
+		data, err := tt.bytecode.MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to encode: %v", err)
+		}
+
+		err = new(Bytecode).UnmarshalBinary(data)
+		if err == nil {
+			t.Errorf("invalid bytecode was accepted, want %q", tt.expected)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("wrong error.\nwant=%q\nhave=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestBinaryNilOperand(t *testing.T) {
+	c := New()
+	if err := c.Compile(parse(t, "true == true")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	data, err := c.Bytecode().MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+
+	// the operand is replaced by OpNil under a valid checksum.
+	i := bytes.Index(data, concat(code.Make(code.OpTrue), code.Make(code.OpTrue), code.Make(code.OpEqual)))
+	if i < 0 {
+		t.Fatalf("instructions not found in %v", data)
+	}
+	data[i] = byte(code.OpNil)
+	body := data[:len(data)-trailerSize]
+	binary.BigEndian.PutUint32(data[len(body):], crc32.ChecksumIEEE(body))
+
+	err = new(Bytecode).UnmarshalBinary(data)
+	if err == nil || !strings.Contains(err.Error(), "value of OpNil used by OpEqual") {
+		t.Errorf("wrong error for an operand of OpNil: %v", err)
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	c := New()
+	if err := c.Compile(parse(t, "let a = 1;\nlet f = fn(x) {\n  x + a\n};\nf(2)")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	out := new(strings.Builder)
+	if err := c.Bytecode().Disassemble(out); err != nil {
+		t.Fatalf("failed to disassemble: %v", err)
+	}
+
+	expected := `globals: a, f
+constants:
+     0 INTEGER 1
+     1 COMPILED_FUNCTION compiled fn(x)
+     2 INTEGER 2
+
+program:
+  0000     1 OpConstant 0           ; 1
+  0003       OpSet 0                ; a
+  0006     2 OpClosure 1            ; compiled fn(x)
+  0009       OpSet 1                ; f
+  0012     5 OpGet 0 1              ; f
+  0017       OpConstant 2           ; 2
+  0020       OpCall 1
+  0022       OpPop
+
+function 1: params=1 locals=x
+  0000     3 OpGet 0 0              ; x
+  0005       OpGet 1 0
+  0010       OpAdd
+  0011     4 OpReturnValue
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong listing.\nwant=\n%s\nhave=\n%s", expected, out.String())
+	}
+}
+
+// normalize replaces empty slices by nil, they are not distinguished by the encoding.
+func normalize(b *Bytecode) *Bytecode {
+	out := *b
+	if len(out.Instructions) == 0 {
+		out.Instructions = nil
+	}
+	if len(out.Globals) == 0 {
+		out.Globals = nil
+	}
+	if len(out.Lines) == 0 {
+		out.Lines = nil
+	}
+	for _, c := range out.Constants {
+		if fn, ok := c.(*objects.CompiledFunction); ok && len(fn.Names) == 0 {
+			fn.Names = nil
+		}
+	}
+	return &out
+}
+
+func concat(ins ...[]byte) code.Instructions {
+	out := code.Instructions{}
+	for _, i := range ins {
+		out = append(out, i...)
+	}
+	return out
+}
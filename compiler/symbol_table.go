@@ -0,0 +1,106 @@
+package compiler
+
+// Symbol is a name bound to a slot of a scope, it is a value
+// that is safe to use concurrently.
+type Symbol struct {
+	Name string
+	Slot int
+}
+
+// SymbolTable holds the names bound in the program or in a function.
+// Like the environments of the evaluator blocks do not open a new
+// table, a let statement inside an if expression binds the name in
+// the enclosing function. Catch clauses are the exception, their names
+// are bound in a block table. A SymbolTable is not safe for concurrent
+// use, it belongs to the compiler using it.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store map[string]Symbol
+	names []string
+	// block is set for the tables of catch clauses, their names are
+	// bound to fresh slots of the scope of the enclosing table.
+	block bool
+}
+
+// NewSymbolTable returns the table of the program.
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: map[string]Symbol{}}
+}
+
+// NewEnclosedSymbolTable returns the table of a function defined in outer.
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// NewBlockSymbolTable returns the table of a block of the code compiled
+// with outer. It has no scope at runtime, its names shadow the names of
+// outer but are bound to slots of the same scope.
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+	return s
+}
+
+// Define binds name to a new slot, unless it is already bound.
+func (s *SymbolTable) Define(name string) Symbol {
+	if sym, ok := s.store[name]; ok {
+		return sym
+	}
+	return s.define(name)
+}
+
+// DefineParameter binds name to a new slot even if it is already bound,
+// so the last of duplicate parameters wins like in the evaluator.
+func (s *SymbolTable) DefineParameter(name string) Symbol {
+	return s.define(name)
+}
+
+func (s *SymbolTable) define(name string) Symbol {
+	scope := s.scope()
+	sym := Symbol{Name: name, Slot: len(scope.names)}
+	s.store[name] = sym
+	scope.names = append(scope.names, name)
+	return sym
+}
+
+// scope returns the table owning the slots of the names bound in s.
+func (s *SymbolTable) scope() *SymbolTable {
+	for s.block {
+		s = s.Outer
+	}
+	return s
+}
+
+// Resolve looks up name in s and its enclosing tables. It returns the
+// symbol and the number of scopes between s and the one binding it.
+func (s *SymbolTable) Resolve(name string) (Symbol, int, bool) {
+	sym, depth, _, ok := s.resolve(name)
+	return sym, depth, ok
+}
+
+// resolve is Resolve that also returns the table binding name.
+func (s *SymbolTable) resolve(name string) (Symbol, int, *SymbolTable, bool) {
+	depth := 0
+	for t := s; t != nil; t = t.Outer {
+		if sym, ok := t.store[name]; ok {
+			return sym, depth, t, true
+		}
+		if !t.block {
+			depth++
+		}
+	}
+	return Symbol{}, 0, nil, false
+}
+
+// Names returns the name bound to each slot.
+func (s *SymbolTable) Names() []string {
+	out := make([]string, len(s.names))
+	copy(out, s.names)
+	return out
+}
+
+// NumDefinitions returns the number of slots.
+func (s *SymbolTable) NumDefinitions() int { return len(s.names) }
@@ -0,0 +1,367 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/Despire/interpreter/code"
+	"github.com/Despire/interpreter/objects"
+)
+
+// verifier checks that bytecode can be executed by the virtual machine
+// without failing it: all the instructions are well formed, operands
+// refer to existing constants and slots, jumps land on instructions,
+// the stack never underflows, handlers of errors are installed and
+// removed in pairs, the value of OpNil is only popped, stored or
+// returned and functions can not run past their end.
+type verifier struct {
+	b *Bytecode
+	// done holds the functions verified for a chain of scope sizes.
+	done map[string]bool
+}
+
+func (b *Bytecode) verify() error {
+	v := &verifier{b: b, done: map[string]bool{}}
+
+	for i, c := range b.Constants {
+		fn, ok := c.(*objects.CompiledFunction)
+		if !ok {
+			continue
+		}
+		params := fn.NumParameters
+		if fn.Rest {
+			params++
+		}
+		if params > fn.NumLocals || len(fn.Names) != fn.NumLocals {
+			return fmt.Errorf("constant %d: function has %d names for %d parameters and %d locals", i, len(fn.Names), params, fn.NumLocals)
+		}
+		if fn.NumDefaults > fn.NumParameters {
+			return fmt.Errorf("constant %d: function has %d defaults for %d parameters", i, fn.NumDefaults, fn.NumParameters)
+		}
+	}
+
+	if err := verifyLines(b.Lines, b.Instructions); err != nil {
+		return fmt.Errorf("program: %v", err)
+	}
+
+	if err := v.function(b.Instructions, []int{len(b.Globals)}, nil); err != nil {
+		return fmt.Errorf("program: %v", err)
+	}
+
+	return nil
+}
+
+// function verifies ins of fn executed with the given scope sizes, the
+// scope of the function itself comes last. fn is nil for the program.
+func (v *verifier) function(ins code.Instructions, scopes []int, fn *objects.CompiledFunction) error {
+	type instruction struct {
+		op       code.Opcode
+		operands []int
+		next     int
+	}
+
+	decoded := map[int]instruction{}
+	for off := 0; off < len(ins); {
+		_, operands, next, err := code.ReadInstruction(ins, off)
+		if err != nil {
+			return fmt.Errorf("offset %d: %v", off, err)
+		}
+		decoded[off] = instruction{code.Opcode(ins[off]), operands, next}
+		off = next
+	}
+
+	// heights holds the height of the stack before each reachable
+	// instruction, the same on every path reaching it. handlers holds
+	// the heights of the stack at which the handlers installed before
+	// the instruction were installed, also the same on every path.
+	// nils holds the positions of the stack that may hold the value of
+	// OpNil before the instruction, on any path reaching it.
+	heights := map[int]int{0: 0}
+	handlers := map[int][]int{0: nil}
+	nils := map[int]map[int]bool{0: {}}
+	work := []int{0}
+
+	flow := func(from, to, height int, installed []int, maybeNil map[int]bool) error {
+		if to == len(ins) {
+			if fn != nil {
+				return fmt.Errorf("offset %d: function runs past its end", from)
+			}
+			return nil
+		}
+		if _, ok := decoded[to]; !ok {
+			return fmt.Errorf("offset %d: jump to %d is not an instruction", from, to)
+		}
+		if h, ok := heights[to]; ok {
+			if h != height {
+				return fmt.Errorf("offset %d: stack height %d differs from %d at %d", from, height, h, to)
+			}
+			if fmt.Sprint(handlers[to]) != fmt.Sprint(installed) {
+				return fmt.Errorf("offset %d: handlers %v differ from %v at %d", from, installed, handlers[to], to)
+			}
+			grown := false
+			for p := range maybeNil {
+				if !nils[to][p] {
+					nils[to][p] = true
+					grown = true
+				}
+			}
+			if grown {
+				work = append(work, to)
+			}
+			return nil
+		}
+		heights[to] = height
+		handlers[to] = installed
+		nils[to] = copyPositions(maybeNil, height)
+		work = append(work, to)
+		return nil
+	}
+
+	if len(ins) == 0 {
+		return flow(0, 0, 0, nil, nil)
+	}
+
+	for len(work) > 0 {
+		off := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		in := decoded[off]
+		height := heights[off]
+		installed := handlers[off]
+		maybeNil := nils[off]
+
+		pops, pushes := 0, 0
+		terminates := false
+		jump := -1
+
+		switch in.op {
+		case code.OpConstant:
+			if in.operands[0] >= len(v.b.Constants) {
+				return fmt.Errorf("offset %d: constant %d does not exist", off, in.operands[0])
+			}
+			pushes = 1
+		case code.OpNil, code.OpTrue, code.OpFalse, code.OpNull:
+			pushes = 1
+		case code.OpPop:
+			pops = 1
+		case code.OpDup:
+			pops, pushes = 1, 2
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpGreaterThan:
+			pops, pushes = 2, 1
+		case code.OpMinus, code.OpBang:
+			pops, pushes = 1, 1
+		case code.OpJump:
+			jump = in.operands[0]
+			terminates = true
+		case code.OpJumpNotTruthy:
+			pops = 1
+			jump = in.operands[0]
+		case code.OpGet:
+			depth, slot := in.operands[0], in.operands[1]
+			if depth >= len(scopes) || slot >= scopes[len(scopes)-1-depth] {
+				return fmt.Errorf("offset %d: slot %d of scope %d does not exist", off, slot, depth)
+			}
+			pushes = 1
+		case code.OpSet:
+			if in.operands[0] >= scopes[len(scopes)-1] {
+				return fmt.Errorf("offset %d: slot %d does not exist", off, in.operands[0])
+			}
+			pops = 1
+		case code.OpClosure:
+			idx := in.operands[0]
+			if idx >= len(v.b.Constants) {
+				return fmt.Errorf("offset %d: constant %d does not exist", off, idx)
+			}
+			fn, ok := v.b.Constants[idx].(*objects.CompiledFunction)
+			if !ok {
+				return fmt.Errorf("offset %d: constant %d is not a function", off, idx)
+			}
+			if err := v.closure(idx, fn, scopes); err != nil {
+				return err
+			}
+			pushes = 1
+		case code.OpCall:
+			pops, pushes = in.operands[0]+1, 1
+		case code.OpCallNamed, code.OpSpawn:
+			if in.operands[1] > in.operands[0] {
+				return fmt.Errorf("offset %d: %d named arguments of %d", off, in.operands[1], in.operands[0])
+			}
+			for i := 0; i < in.operands[1]; i++ {
+				if err := v.stringConstant(off, in.operands[2]+i); err != nil {
+					return err
+				}
+			}
+			pops, pushes = in.operands[0]+1, 1
+		case code.OpJumpBound:
+			if in.operands[1] >= scopes[len(scopes)-1] {
+				return fmt.Errorf("offset %d: slot %d does not exist", off, in.operands[1])
+			}
+			jump = in.operands[0]
+		case code.OpImport, code.OpMember:
+			if err := v.stringConstant(off, in.operands[0]); err != nil {
+				return err
+			}
+			if in.op == code.OpMember {
+				pops = 1
+			}
+			pushes = 1
+		case code.OpArray:
+			pops, pushes = in.operands[0], 1
+		case code.OpHash:
+			pops, pushes = 2*in.operands[0], 1
+		case code.OpIndex:
+			pops, pushes = 2, 1
+		case code.OpChannel:
+			pops, pushes = 1, 1
+		case code.OpSelect:
+			pops, pushes = 3*in.operands[0], 2
+		case code.OpMatchArray, code.OpMatchHash, code.OpRest:
+			pops, pushes = 1, 2
+		case code.OpElement, code.OpEntry:
+			if in.op == code.OpEntry {
+				if err := v.stringConstant(off, in.operands[1]); err != nil {
+					return err
+				}
+			}
+			if height < 1 {
+				return fmt.Errorf("offset %d: stack underflow", off)
+			}
+			// the array or the hash has no element, nothing is pushed.
+			if err := flow(off, in.operands[0], height, installed, maybeNil); err != nil {
+				return err
+			}
+			pops, pushes = 1, 2
+		case code.OpMismatch:
+			if err := v.stringConstant(off, in.operands[0]); err != nil {
+				return err
+			}
+			pops = 1
+			terminates = true
+		case code.OpReturnValue:
+			pops = 1
+			terminates = true
+		case code.OpYield:
+			if fn == nil || !fn.Generator {
+				return fmt.Errorf("offset %d: yield outside of a generator", off)
+			}
+			pops, pushes = 1, 1
+		case code.OpTry:
+			// the handler is removed when an error moves to it.
+			if err := flow(off, in.operands[0], height+1, installed, maybeNil); err != nil {
+				return err
+			}
+			installed = append(installed[:len(installed):len(installed)], height)
+		case code.OpEndTry:
+			if len(installed) == 0 {
+				return fmt.Errorf("offset %d: no handler to remove", off)
+			}
+			installed = installed[:len(installed)-1]
+		case code.OpThrow, code.OpNoMatch:
+			pops = 1
+			terminates = true
+		default:
+			return fmt.Errorf("offset %d: unexpected opcode %d", off, in.op)
+		}
+
+		if height < pops {
+			return fmt.Errorf("offset %d: stack underflow", off)
+		}
+		// the values below the height at which the innermost handler
+		// was installed are kept for the handler.
+		if n := len(installed); n > 0 && height-pops < installed[n-1] {
+			return fmt.Errorf("offset %d: stack underflow", off)
+		}
+
+		after := copyPositions(maybeNil, height-pops)
+		switch in.op {
+		case code.OpNil:
+			after[height] = true
+		case code.OpDup:
+			if maybeNil[height-1] {
+				after[height-1], after[height] = true, true
+			}
+		case code.OpPop, code.OpSet, code.OpReturnValue:
+			// the value is discarded, stored or returned as it is.
+		default:
+			for p := height - pops; p < height; p++ {
+				if maybeNil[p] {
+					def, _ := code.Lookup(byte(in.op))
+					return fmt.Errorf("offset %d: value of OpNil used by %s", off, def.Name)
+				}
+			}
+		}
+		height += pushes - pops
+
+		if jump >= 0 {
+			if err := flow(off, jump, height, installed, after); err != nil {
+				return err
+			}
+		}
+		if !terminates {
+			if err := flow(off, in.next, height, installed, after); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// copyPositions returns the positions below height of positions.
+func copyPositions(positions map[int]bool, height int) map[int]bool {
+	c := map[int]bool{}
+	for p := range positions {
+		if p < height {
+			c[p] = true
+		}
+	}
+	return c
+}
+
+// stringConstant checks that the constant idx
+// of the instruction at off is a string.
+func (v *verifier) stringConstant(off, idx int) error {
+	if idx >= len(v.b.Constants) {
+		return fmt.Errorf("offset %d: constant %d does not exist", off, idx)
+	}
+	if _, ok := v.b.Constants[idx].(*objects.String); !ok {
+		return fmt.Errorf("offset %d: constant %d is not a string", off, idx)
+	}
+	return nil
+}
+
+// closure verifies the function constant idx created in the innermost of scopes.
+func (v *verifier) closure(idx int, fn *objects.CompiledFunction, scopes []int) error {
+	// every function of a nesting is a distinct constant, deeper
+	// nesting means a function creates closures of itself.
+	if len(scopes) > len(v.b.Constants) {
+		return fmt.Errorf("constant %d: functions nested deeper than the number of constants", idx)
+	}
+
+	inner := append(append([]int{}, scopes...), fn.NumLocals)
+
+	key := fmt.Sprint(idx, inner)
+	if v.done[key] {
+		return nil
+	}
+	v.done[key] = true
+
+	if err := verifyLines(fn.Lines, fn.Instructions); err != nil {
+		return fmt.Errorf("constant %d: %v", idx, err)
+	}
+
+	if err := v.function(fn.Instructions, inner, fn); err != nil {
+		return fmt.Errorf("constant %d: %v", idx, err)
+	}
+
+	return nil
+}
+
+func verifyLines(lines code.LineTable, ins code.Instructions) error {
+	for i, l := range lines {
+		if l.Offset >= len(ins) || i > 0 && l.Offset <= lines[i-1].Offset {
+			return fmt.Errorf("line table entry %d has invalid offset %d", i, l.Offset)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/compiler"
+	"github.com/Despire/interpreter/eval"
+	"github.com/Despire/interpreter/module"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/optimizer"
+	"github.com/Despire/interpreter/resolver"
+	"github.com/Despire/interpreter/vm"
+)
+
+// engines lists the names accepted by the -engine flag.
+var engines = []string{"eval", "vm"}
+
+// modulePathVariable names the environment variable holding the
+// list of directories searched for imported modules.
+const modulePathVariable = "MKPATH"
+
+// session executes programs with one of the engines. The names
+// defined by a program are visible to the programs run after it.
+type session interface {
+	Run(program *ast.Program) objects.Object
+	// Get returns the value bound to name by the programs run so far.
+	Get(name string) (objects.Object, bool)
+}
+
+// newSession returns a session of engine, the macros of the programs
+// are expanded before they are executed. If optimize is set the
+// programs are optimized after the expansion. The modules imported
+// by the programs are looked up relative to dir and then in the
+// directories listed by the MKPATH environment variable.
+func newSession(engine string, optimize bool, dir string) (session, error) {
+	return openSession(engine, optimize, newLoader(engine, optimize).Importer(dir))
+}
+
+// newLoader returns a loader that executes the imported
+// modules in sessions of engine, each in its own.
+func newLoader(engine string, optimize bool) *module.Loader {
+	exec := func(program *ast.Program, imports objects.Importer) (module.Bindings, error) {
+		s, err := openSession(engine, optimize, imports)
+		if err != nil {
+			return nil, err
+		}
+
+		if err, ok := s.Run(program).(*objects.Error); ok {
+			return nil, err
+		}
+
+		return s, nil
+	}
+
+	return module.NewLoader(exec, filepath.SplitList(os.Getenv(modulePathVariable)))
+}
+
+// openSession returns a session of engine whose programs import modules with imports.
+func openSession(engine string, optimize bool, imports objects.Importer) (session, error) {
+	var s session
+
+	switch engine {
+	case "eval":
+		s = &evalSession{env: objects.NewEnvironment(), imports: imports, generators: context.Background()}
+	case "vm":
+		s = &vmSession{
+			symbols: compiler.NewSymbolTable(),
+			globals: &objects.Scope{},
+			imports: imports,
+		}
+	default:
+		return nil, fmt.Errorf("unknown engine %q, want one of %v", engine, engines)
+	}
+
+	if optimize {
+		s = optimizingSession{s}
+	}
+
+	return &macroSession{session: s, env: objects.NewEnvironment()}, nil
+}
+
+// macroSession expands the macros of the programs, the macros
+// defined by a program can be used by the programs run after it.
+type macroSession struct {
+	session
+	env *objects.Environment
+}
+
+func (s *macroSession) Run(program *ast.Program) objects.Object {
+	expanded, err := expandMacros(program, s.env)
+	if err != nil {
+		return runtimeError(err)
+	}
+
+	return s.session.Run(expanded)
+}
+
+// optimizingSession optimizes the programs before executing them.
+type optimizingSession struct {
+	session
+}
+
+func (s optimizingSession) Run(program *ast.Program) objects.Object {
+	return s.session.Run(optimizer.Optimize(program).(*ast.Program))
+}
+
+// evalSession walks the ast with the evaluator.
+type evalSession struct {
+	env     *objects.Environment
+	imports objects.Importer
+	// generators is the lifetime of the generators created by the
+	// programs, they can be resumed by the programs run after them.
+	generators context.Context
+}
+
+func (s *evalSession) Run(program *ast.Program) objects.Object {
+	// the diagnostics are not reported since the names
+	// defined by previous programs are unknown to the resolver.
+	resolver.Resolve(program)
+
+	return eval.EvalContext(context.Background(), program, s.env, eval.Options{Importer: s.imports, Generators: s.generators})
+}
+
+func (s *evalSession) Get(name string) (objects.Object, bool) {
+	return s.env.Get(name)
+}
+
+// vmSession compiles the program and executes it on the virtual machine.
+type vmSession struct {
+	symbols   *compiler.SymbolTable
+	constants []objects.Object
+	globals   *objects.Scope
+	imports   objects.Importer
+}
+
+func (s *vmSession) Run(program *ast.Program) objects.Object {
+	c := compiler.NewWithState(s.symbols, s.constants)
+	if err := c.Compile(program); err != nil {
+		return &objects.Error{Value: err.Error()}
+	}
+	s.constants = c.Constants()
+
+	machine := vm.NewWithGlobals(c.Bytecode(), s.globals)
+	machine.SetImporter(s.imports)
+	if err := machine.Run(); err != nil {
+		return runtimeError(err)
+	}
+
+	return machine.LastPopped()
+}
+
+func (s *vmSession) Get(name string) (objects.Object, bool) {
+	return s.globals.Lookup(name)
+}
+
+// runtimeError returns the error a program failed with, an Error
+// raised by the program or by the expansion of its macros is kept.
+func runtimeError(err error) *objects.Error {
+	if e, ok := err.(*objects.Error); ok {
+		return e
+	}
+	return &objects.Error{Value: err.Error()}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Despire/interpreter/objects"
+)
+
+func TestSessions(t *testing.T) {
+	tests := []struct {
+		inputs   []string
+		expected string
+	}{
+		{[]string{"let a = 1;", "a + 1"}, "2"},
+		{[]string{"fn g() { yield 1; yield 2 } let it = g(); it.next()", "it.next()"}, `{"value": 2, "done": false}`},
+		{[]string{"fn g() { yield 1 } let it = g();", "it.next();", "it.next()"}, `{"value": null, "done": true}`},
+	}
+
+	for _, engine := range engines {
+		for _, tt := range tests {
+			s, err := openSession(engine, false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var have string
+			for _, input := range tt.inputs {
+				program, err := parseSource([]byte(input))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if o := s.Run(program); o != nil {
+					have = o.Inspect()
+				}
+			}
+
+			if have != tt.expected {
+				t.Errorf("wrong result of %q with the %s engine. want=%s, have=%s", tt.inputs, engine, tt.expected, have)
+			}
+		}
+	}
+}
+
+func TestSessionErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "fail.mk"), []byte("let a = 1;\nexport let b = a + true;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input   string
+		message string
+		kind    string
+		line    int
+	}{
+		{"let m = macro() { 1 + true };\nm();", "2:1: macro m: type mismatch: INTEGER + BOOLEAN", objects.TypeError, 2},
+		{"let m = macro(x) { x };\n\nm();", "3:1: macro m: wrong number of arguments: want=1, have=0", objects.ArgumentError, 3},
+		{"1;\nimport \"fail.mk\" as f;", "fail.mk:2: type mismatch: INTEGER + BOOLEAN", objects.TypeError, 2},
+		{"import \"nope.mk\" as f;", `module "nope.mk" not found`, objects.ImportError, 1},
+	}
+
+	for _, engine := range engines {
+		for _, tt := range tests {
+			s, err := newSession(engine, false, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			program, err := parseSource([]byte(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			e, ok := s.Run(program).(*objects.Error)
+			if !ok {
+				t.Errorf("no error for %q with the %s engine", tt.input, engine)
+				continue
+			}
+
+			if !strings.HasSuffix(e.Value, tt.message) || e.Kind != tt.kind || e.Line != tt.line {
+				t.Errorf("wrong error for %q with the %s engine. want=%s at %d: %q, have=%s at %d: %q", tt.input, engine, tt.kind, tt.line, tt.message, e.Kind, e.Line, e.Value)
+			}
+		}
+	}
+}
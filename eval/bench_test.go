@@ -0,0 +1,97 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/lexer"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/parser"
+	"github.com/Despire/interpreter/resolver"
+)
+
+const fibonacci = `
+let fib = fn(n) {
+  if (n < 2) { return n; }
+  fib(n - 1) + fib(n - 2)
+};
+fib(20);`
+
+const closures = `
+let counter = fn(n) {
+  let step = fn(acc, i) {
+    if (i > n) { return acc; }
+    step(acc + i, i + 1)
+  };
+  step(0, 1)
+};
+counter(500);`
+
+const closureCreation = `
+let adder = fn(x) { fn(y) { x + y } };
+let sum = fn(n, acc) {
+  if (n < 1) { return acc; }
+  sum(n - 1, adder(n)(acc))
+};
+sum(1000, 0);`
+
+const arithmetic = `
+let step = fn(i, acc) {
+  (acc * 31 + i * i - i / 3) / 7 + i * 2 - (i - 1) * 3
+};
+let loop = fn(i, acc) {
+  if (i > 1000) { return acc; }
+  loop(i + 1, step(i, acc))
+};
+loop(1, 0);`
+
+// BenchmarkFibonacci compares looking up the names in the environments
+// with the slots assigned by the resolver.
+func BenchmarkFibonacci(b *testing.B) {
+	benchmarkLookups(b, fibonacci)
+}
+
+func BenchmarkClosures(b *testing.B) {
+	benchmarkLookups(b, closures)
+}
+
+func BenchmarkClosureCreation(b *testing.B) {
+	benchmarkLookups(b, closureCreation)
+}
+
+func BenchmarkArithmetic(b *testing.B) {
+	benchmarkLookups(b, arithmetic)
+}
+
+func benchmarkLookups(b *testing.B, input string) {
+	b.Run("names", func(b *testing.B) {
+		benchmarkEval(b, parseBenchmark(b, input))
+	})
+
+	b.Run("slots", func(b *testing.B) {
+		program := parseBenchmark(b, input)
+		resolver.Resolve(program)
+		benchmarkEval(b, program)
+	})
+}
+
+func benchmarkEval(b *testing.B, program *ast.Program) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if result := Eval(program, objects.NewEnvironment()); result.Type() == objects.ERROR {
+			b.Fatal(result.Inspect())
+		}
+	}
+}
+
+func parseBenchmark(b *testing.B, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	for _, err := range p.Errors() {
+		b.Fatalf("parser error: %q", err)
+	}
+
+	return program
+}
@@ -1,94 +1,586 @@
+// Package eval implements a tree-walking evaluator of programs.
+//
+// The evaluations of programs are independent of each other and can run
+// concurrently, as long as they do not share environments or values
+// that are changed by them. Global definitions are shared by evaluating
+// them once in an environment that is then frozen, each evaluation runs
+// in an environment of its own enclosed by it:
+//
+//	globals := objects.NewEnvironment()
+//	eval.Eval(definitions, globals)
+//	globals.Freeze()
+//
+//	// for each request, possibly concurrently:
+//	eval.EvalContext(ctx, script, objects.NewEnclosedEnvironment(globals), opts)
 package eval
 
 import (
-	"fmt"
+	"context"
 
 	"github.com/Despire/interpreter/ast"
 	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/sched"
 	"github.com/Despire/interpreter/token"
 )
 
-var (
-	TRUE  = &objects.Boolean{Value: true}
-	FALSE = &objects.Boolean{Value: false}
-	NULL  = &objects.Null{}
-)
+// DefaultMaxDepth is the maximum depth of nested function calls
+// if Options do not set one.
+const DefaultMaxDepth = 10000
+
+// cancelInterval is the number of steps between the checks
+// whether the context of an evaluation is done.
+const cancelInterval = 1024
+
+// Options limit the evaluation of a program. The same Options can be
+// passed to evaluations running concurrently, provided that each has a
+// Usage of its own and the Importer is safe for concurrent use.
+type Options struct {
+	// MaxDepth is the maximum depth of nested function calls, calls in
+	// tail position do not nest. If zero DefaultMaxDepth is used.
+	MaxDepth int
+
+	// MaxSteps is the maximum number of evaluated nodes,
+	// exceeding it fails with "step budget of N exceeded".
+	// If zero the number is not limited.
+	MaxSteps int
+
+	// MaxAllocations is the maximum number of allocated integers,
+	// functions and call environments, exceeding it fails with
+	// "allocation budget of N exceeded". If zero the number is
+	// not limited.
+	MaxAllocations int
+
+	// MaxMemory is the maximum approximate number of bytes of the
+	// values and environments in use, exceeding it fails with
+	// "memory limit of N bytes exceeded". If zero it is not limited.
+	MaxMemory int64
+
+	// Usage is set to the resources used by the evaluation if not nil.
+	Usage *Usage
+
+	// Importer loads the modules of import statements, if it is
+	// nil the evaluation of an import statement fails.
+	Importer objects.Importer
+
+	// Generators is the lifetime of the generators created by the
+	// evaluation, they can be resumed by later evaluations until it is
+	// done, e.g. by the following inputs of a REPL. If it is nil they
+	// are stopped when the evaluation returns.
+	Generators context.Context
+}
+
+// evaluator holds the state of the evaluation of a task or of the
+// body of a generator.
+type evaluator struct {
+	// the evaluators of an evaluation share its budget,
+	// only one of them runs at any time.
+	*budget
+
+	depth int
+	// base is the depth of the calls that resumed the generator
+	// whose body is evaluated, it counts towards the maximum depth.
+	base int
+
+	// coroutine is the generator whose body is evaluated,
+	// nil if it is not the one of a generator.
+	coroutine *coroutine
 
+	// task is the task evaluated, the one that resumed the
+	// generator whose body is evaluated.
+	task *sched.Task
+}
+
+// budget holds the resources used by an evaluation.
+type budget struct {
+	ctx  context.Context
+	life context.Context // the lifetime of the generators created.
+	opts Options
+
+	steps       int
+	allocations int
+
+	// memory is the number of bytes in use. The environment of a call
+	// and the values allocated by it are released when it returns,
+	// unless a function was created by the call, which may capture them,
+	// or it returns an array or a hash, which may refer to them.
+	memory   int64
+	peak     int64
+	closures int // the number of functions created.
+
+	// err is set once the evaluation was cancelled or exceeded a
+	// budget, every evaluation fails with it from then on.
+	err *objects.Error
+}
+
+// Eval evaluates node in env with the default options.
 func Eval(node ast.Node, env *objects.Environment) objects.Object {
+	return EvalWithOptions(node, env, Options{})
+}
+
+// EvalWithOptions evaluates node in env, the limits of opts that are
+// exceeded are reported as errors. The tasks spawned by the evaluation
+// are stopped when it returns, so are the generators it created unless
+// opts.Generators is set. They are done from then on.
+func EvalWithOptions(node ast.Node, env *objects.Environment, opts Options) objects.Object {
+	return EvalContext(context.Background(), node, env, opts)
+}
+
+// EvalContext is like EvalWithOptions but it stops once ctx is done,
+// failing with "execution cancelled: " followed by the error of ctx.
+//
+// env must not be frozen, the program binds its names in it.
+func EvalContext(ctx context.Context, node ast.Node, env *objects.Environment, opts Options) objects.Object {
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultMaxDepth
+	}
+
+	if env.Frozen() {
+		return objects.Errorf(objects.TypeError, "cannot evaluate in a frozen environment")
+	}
+
+	life := opts.Generators
+	if life == nil {
+		// the generators created by the evaluation are stopped when it returns.
+		var stop context.CancelFunc
+		life, stop = context.WithCancel(context.Background())
+		defer stop()
+	}
+
+	e := &evaluator{
+		budget: &budget{ctx: ctx, life: life, opts: opts},
+		task:   sched.New(),
+	}
+	defer e.task.Stop()
+	if opts.Usage != nil {
+		defer func() {
+			*opts.Usage = Usage{
+				Steps:       e.steps,
+				Allocations: e.allocations,
+				Memory:      e.memory,
+				PeakMemory:  e.peak,
+			}
+		}()
+	}
+
+	if err := e.cancelled(); err != nil {
+		return err
+	}
+
+	return e.eval(node, env)
+}
+
+// step accounts for the evaluation of a node, it returns an error
+// if the evaluation was cancelled or the step budget is exceeded.
+func (e *evaluator) step() *objects.Error {
+	if e.err != nil {
+		return e.err
+	}
+
+	e.steps++
+
+	if e.opts.MaxSteps > 0 && e.steps > e.opts.MaxSteps {
+		e.err = objects.Errorf(objects.LimitError, "step budget of %d exceeded", e.opts.MaxSteps)
+		return e.err
+	}
+
+	if e.steps%cancelInterval == 0 {
+		return e.cancelled()
+	}
+
+	return nil
+}
+
+func (e *evaluator) cancelled() *objects.Error {
+	if err := e.ctx.Err(); err != nil {
+		e.err = objects.Errorf(objects.LimitError, "execution cancelled: %v", err)
+	}
+	return e.err
+}
+
+// alloc accounts for the allocation of a value or an environment of
+// size bytes, it returns an error if a budget is exceeded.
+func (e *evaluator) alloc(size int64) *objects.Error {
+	e.allocations++
+
+	if e.opts.MaxAllocations > 0 && e.allocations > e.opts.MaxAllocations {
+		e.err = objects.Errorf(objects.LimitError, "allocation budget of %d exceeded", e.opts.MaxAllocations)
+		return e.err
+	}
+
+	return e.charge(size)
+}
+
+// charge accounts for size bytes in use, it returns
+// an error if the memory limit is exceeded.
+func (e *evaluator) charge(size int64) *objects.Error {
+	e.memory += size
+	if e.memory > e.peak {
+		e.peak = e.memory
+	}
+
+	if e.opts.MaxMemory > 0 && e.memory > e.opts.MaxMemory {
+		e.err = objects.Errorf(objects.LimitError, "memory limit of %d bytes exceeded", e.opts.MaxMemory)
+		return e.err
+	}
+
+	return nil
+}
+
+// allocated accounts for o if it is a newly allocated integer, string,
+// array or hash.
+func (e *evaluator) allocated(o objects.Object) objects.Object {
+	size := int64(0)
+
+	switch v := o.(type) {
+	case *objects.Integer:
+		if !objects.IsCachedInteger(v.Value) {
+			size = integerSize
+		}
+	case *objects.String, *objects.Array, *objects.Hash:
+		size = sizeOf(v)
+	}
+
+	if size > 0 {
+		if err := e.alloc(size); err != nil {
+			return err
+		}
+	}
+	return o
+}
+
+// eval evaluates node. The errors raised by the evaluation
+// of node are annotated with its line, unless they have one.
+func (e *evaluator) eval(node ast.Node, env *objects.Environment) objects.Object {
+	if err := e.step(); err != nil {
+		return err
+	}
+
+	result := e.evalNode(node, env)
+	if err, ok := result.(*objects.Error); ok && err.Line == 0 {
+		err.Line = ast.Pos(node).Line
+	}
+
+	return result
+}
+
+func (e *evaluator) evalNode(node ast.Node, env *objects.Environment) objects.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node.Statement, env)
+		return e.evalProgram(node.Statement, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.eval(node.Expression, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.Expression, env)
+		// the returned expression is in tail position.
+		val := e.evalTail(node.Expression, env)
 		if isError(val) {
 			return val
 		}
 		return &objects.Return{
 			Value: val,
 		}
+	case *ast.ThrowStatement:
+		val := e.eval(node.Expression, env)
+		if isError(val) {
+			return val
+		}
+		return objects.NewThrown(val)
 	case *ast.LetStatement:
-		val := Eval(node.Expression, env)
+		val := e.eval(node.Expression, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Identifier.Value, val)
-	case *ast.IntegerLiteral:
-		return &objects.Integer{
-			Value: int64(node.Value),
+		if node.Pattern != nil {
+			if _, err := e.match(node.Pattern, val, env, true); err != nil {
+				return err
+			}
+			return nil
+		}
+		if err := e.bind(node.Identifier, val, env); err != nil {
+			return err
+		}
+	case *ast.ImportStatement:
+		mod := e.evalImport(node)
+		if isError(mod) {
+			return mod
+		}
+		if err := e.bind(node.Name, mod, env); err != nil {
+			return err
 		}
+	case *ast.FunctionStatement:
+		// the function was bound when its block was entered.
+		return nil
+	case *ast.IntegerLiteral:
+		return e.allocated(objects.NewInteger(int64(node.Value)))
+	case *ast.StringLiteral:
+		return e.allocated(&objects.String{Value: node.Value})
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.BooleanLiteral:
-		if node.Value {
-			return TRUE
-		}
-		return FALSE
+		return objects.NewBoolean(node.Value)
 	case *ast.FunctionLiteral:
+		if err := e.alloc(functionSize); err != nil {
+			return err
+		}
+		e.closures++
+		name := ""
+		if node.Name != nil {
+			name = node.Name.Value
+		}
 		return &objects.Function{
+			Name:       name,
 			Parameters: node.Parameters,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
 			Body:       node.Body,
 			Env:        env,
+			Generator:  node.Generator,
+			Locals:     node.Locals,
 		}
 	case *ast.BlockStatement:
-		return evalBlock(node, env)
+		return e.evalBlock(node, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
+	case *ast.TryExpression:
+		return e.evalTry(node, env)
+	case *ast.MatchExpression:
+		arm, aenv, err := e.matchArm(node, env)
+		if err != nil {
+			return err
+		}
+		return e.eval(arm.Body, aenv)
 	case *ast.PrefixExpression:
-		exp := Eval(node.Right, env)
+		exp := e.eval(node.Right, env)
 		if isError(exp) {
 			return exp
 		}
-		return evalPrefix(node.Operator, exp)
+		return e.allocated(evalPrefix(node.Operator, exp))
+	case *ast.YieldExpression:
+		val := e.eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if e.coroutine == nil {
+			return objects.Errorf(objects.TypeError, "yield outside of a generator")
+		}
+		return e.coroutine.yield(val)
+	case *ast.SpawnExpression:
+		fn := e.eval(node.Call.Function, env)
+		if isError(fn) {
+			return fn
+		}
+
+		args, names, err := e.evalArguments(node.Call.Arguments, env)
+		if err != nil {
+			return err
+		}
+
+		e.spawn(fn, args, names)
+		return objects.NewNull()
+	case *ast.ChanExpression:
+		var capacity objects.Object = objects.NewInteger(0)
+		if node.Capacity != nil {
+			if capacity = e.eval(node.Capacity, env); isError(capacity) {
+				return capacity
+			}
+		}
+
+		ch, err := sched.NewChannel(capacity)
+		if err != nil {
+			return err
+		}
+		if err := e.alloc(channelSize); err != nil {
+			return err
+		}
+		return ch
+	case *ast.SelectExpression:
+		arm, err := e.selectArm(node, env)
+		if err != nil {
+			return err
+		}
+		return e.eval(arm.Body, env)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		left := e.eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		right := Eval(node.Right, env)
+		right := e.eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalInfix(node.Operator, left, right)
+		return e.allocated(evalInfix(node.Operator, left, right))
+	case *ast.MemberExpression:
+		left := e.eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		return evalMember(left, node.Property.Value)
+	case *ast.ArrayLiteral:
+		elements := e.evalExpressionList(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return e.allocated(&objects.Array{Elements: elements})
+	case *ast.HashLiteral:
+		return e.evalHash(node, env)
+	case *ast.IndexExpression:
+		left := e.eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+
+		index := e.eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndex(left, index)
+	case *ast.MacroLiteral:
+		return objects.Errorf(objects.TypeError, "macros must be bound by a top-level let statement")
 	case *ast.CallExpression:
-		fn := Eval(node.Function, env)
+		switch {
+		case ast.IsCallTo(node, ast.Quote):
+			return e.evalQuote(node, env)
+		case ast.IsCallTo(node, ast.Unquote):
+			return objects.Errorf(objects.TypeError, "unquote outside of quote")
+		}
+
+		fn := e.eval(node.Function, env)
 		if isError(fn) {
 			return fn
 		}
 
-		args := evalExpressionList(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		args, names, err := e.evalArguments(node.Arguments, env)
+		if err != nil {
+			return err
 		}
 
-		return applyFunction(fn, args)
+		return e.applyFunction(fn, args, names)
+	}
+
+	return nil
+}
+
+// bind binds val to the declared identifier id in env.
+func (e *evaluator) bind(id *ast.Identifier, val objects.Object, env *objects.Environment) *objects.Error {
+	if id.Resolved {
+		env.SetAt(id.Slot, id.Value, val)
+		return nil
 	}
 
+	if err := e.charge(bindingSize); err != nil {
+		return err
+	}
+	env.Set(id.Value, val)
 	return nil
 }
 
+func (e *evaluator) evalImport(node *ast.ImportStatement) objects.Object {
+	if e.opts.Importer == nil {
+		return objects.Errorf(objects.ImportError, "import %q: imports are not supported", node.Path)
+	}
+
+	mod, err := e.opts.Importer.Import(node.Path)
+	if err != nil {
+		return objects.NewImportError(err)
+	}
+
+	return mod
+}
+
+func (e *evaluator) evalHash(node *ast.HashLiteral, env *objects.Environment) objects.Object {
+	hash := objects.NewHash()
+
+	for _, p := range node.Pairs {
+		var key objects.Object
+		if id, ok := p.Key.(*ast.Identifier); ok {
+			key = e.allocated(&objects.String{Value: id.Value})
+		} else {
+			key = e.eval(p.Key, env)
+		}
+		if isError(key) {
+			return key
+		}
+
+		hashable, ok := key.(objects.Hashable)
+		if !ok {
+			return objects.Errorf(objects.TypeError, "unusable as hash key: %s", key.Type())
+		}
+
+		val := e.eval(p.Value, env)
+		if isError(val) {
+			return val
+		}
+
+		hash.Set(hashable, val)
+	}
+
+	return e.allocated(hash)
+}
+
+// evalIndex returns the element of an array at index, or the value of
+// a hash for the key index. Both are null if there is none.
+func evalIndex(left, index objects.Object) objects.Object {
+	switch left := left.(type) {
+	case *objects.Array:
+		i, ok := index.(*objects.Integer)
+		if !ok {
+			break
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return objects.NewNull()
+		}
+		return left.Elements[i.Value]
+	case *objects.Hash:
+		key, ok := index.(objects.Hashable)
+		if !ok {
+			return objects.Errorf(objects.TypeError, "unusable as hash key: %s", index.Type())
+		}
+		if val, ok := left.Get(key); ok {
+			return val
+		}
+		return objects.NewNull()
+	}
+
+	return objects.Errorf(objects.TypeError, "index operator not supported: %s[%s]", left.Type(), index.Type())
+}
+
+func evalMember(left objects.Object, name string) objects.Object {
+	if g, ok := left.(*generator); ok {
+		if name != "next" {
+			return objects.Errorf(objects.NameError, "generator has no member %s", name)
+		}
+		return &objects.Method{Receiver: g, Name: name}
+	}
+
+	if ch, ok := left.(*sched.Channel); ok {
+		member, ok := ch.Member(name)
+		if !ok {
+			return objects.Errorf(objects.NameError, "channel has no member %s", name)
+		}
+		return member
+	}
+
+	if ev, ok := left.(*objects.ErrorValue); ok {
+		member, ok := ev.Member(name)
+		if !ok {
+			return objects.Errorf(objects.NameError, "error has no member %s", name)
+		}
+		return member
+	}
+
+	mod, ok := left.(*objects.Module)
+	if !ok {
+		return objects.Errorf(objects.TypeError, "not a module: %s", left.Type())
+	}
+
+	member, ok := mod.Members[name]
+	if !ok {
+		return objects.Errorf(objects.NameError, "%s has no exported member %s", mod.Path, name)
+	}
+
+	return member
+}
+
 func unwrapreturnValue(o objects.Object) objects.Object {
 	if ret, ok := o.(*objects.Return); ok {
 		return ret.Value
@@ -97,32 +589,322 @@ func unwrapreturnValue(o objects.Object) objects.Object {
 	return o
 }
 
-func extendFunctionEnv(fn *objects.Function, args []objects.Object) *objects.Environment {
-	env := objects.NewEnclosedEnvironment(fn.Env)
+// extendFunctionEnv returns the environment of a call of fn with args,
+// the last of which are named by names. The default values of the
+// missing parameters are evaluated in it, in the order of the parameters.
+func (e *evaluator) extendFunctionEnv(fn *objects.Function, args []objects.Object, names []string) (*objects.Environment, objects.Object) {
+	env, missing, err := e.bindArguments(fn, args, names)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.bindDefaults(fn, env, missing); err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// bindArguments returns the environment of a call of fn with args bound
+// to the parameters, and the indexes of the parameters left missing.
+func (e *evaluator) bindArguments(fn *objects.Function, args []objects.Object, names []string) (*objects.Environment, []int, objects.Object) {
+	var env *objects.Environment
+	if fn.Locals != nil {
+		env = objects.NewSlotEnvironment(fn.Locals, fn.Env)
+	} else {
+		env = objects.NewEnclosedEnvironment(fn.Env)
+	}
+
+	// the arguments of most calls are bound as they are.
+	slots := args
+	if len(names) > 0 || len(args) < len(fn.Parameters) || fn.Rest != nil {
+		params := make([]string, len(fn.Parameters))
+		for i, p := range fn.Parameters {
+			params[i] = p.Value
+		}
+
+		rest := ""
+		if fn.Rest != nil {
+			rest = fn.Rest.Value
+		}
+
+		slots = make([]objects.Object, len(fn.Parameters)+1)
+		if err := objects.BindArguments(fn.Name, slots, params, optionalParameters(fn), rest, args, names); err != nil {
+			return nil, nil, err
+		}
+	}
 
+	var missing []int
 	for i, p := range fn.Parameters {
-		env.Set(p.Value, args[i])
+		if slots[i] == nil {
+			missing = append(missing, i)
+			continue
+		}
+		setParameter(fn, env, p, slots[i])
+	}
+
+	if fn.Rest != nil {
+		if err := e.alloc(sizeOf(slots[len(fn.Parameters)])); err != nil {
+			return nil, nil, err
+		}
+		setParameter(fn, env, fn.Rest, slots[len(fn.Parameters)])
 	}
 
-	return env
+	return env, missing, nil
 }
 
-func applyFunction(fn objects.Object, args []objects.Object) objects.Object {
-	function, ok := fn.(*objects.Function)
-	if !ok {
-		return newError(fmt.Sprintf("not a function: %s", fn.Type()))
+// bindDefaults evaluates the default values of the missing parameters
+// of a call of fn in its environment env, in the order of the parameters.
+func (e *evaluator) bindDefaults(fn *objects.Function, env *objects.Environment, missing []int) objects.Object {
+	for _, i := range missing {
+		val := e.eval(fn.Defaults[i], env)
+		if isError(val) {
+			return val
+		}
+		setParameter(fn, env, fn.Parameters[i], val)
 	}
 
-	eenv := extendFunctionEnv(function, args)
-	eval := Eval(function.Body, eenv)
-	return unwrapreturnValue(eval)
+	return nil
 }
 
-func evalExpressionList(exp []ast.Expression, env *objects.Environment) []objects.Object {
+// setParameter binds val to the parameter id in env, the environment of a call of fn.
+func setParameter(fn *objects.Function, env *objects.Environment, id *ast.Identifier, val objects.Object) {
+	if fn.Locals == nil {
+		env.Set(id.Value, val)
+		return
+	}
+	env.SetAt(id.Slot, id.Value, val)
+}
+
+// optionalParameters returns the number of the
+// parameters of fn that have a default value.
+func optionalParameters(fn *objects.Function) int {
+	n := 0
+	for _, d := range fn.Defaults {
+		if d != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// applyFunction calls fn with args. The calls in tail position of the
+// body are returned as a tailCall and are applied in a loop, so that
+// tail recursion does not grow the Go stack nor the depth of calls.
+func (e *evaluator) applyFunction(fn objects.Object, args []objects.Object, names []string) objects.Object {
+	if e.base+e.depth >= e.opts.MaxDepth {
+		return objects.Errorf(objects.LimitError, "maximum call depth %d exceeded", e.opts.MaxDepth)
+	}
+
+	e.depth++
+	defer func() { e.depth-- }()
+
+	memory, closures := e.memory, e.closures
+
+	// the line of the call in tail position being applied, the
+	// errors of the first call are annotated by the caller.
+	line := 0
+
+	for {
+		if m, ok := fn.(*objects.Method); ok {
+			return atLine(e.callMethod(m, args, names), line)
+		}
+
+		function, ok := fn.(*objects.Function)
+		if !ok {
+			err := objects.Errorf(objects.TypeError, "not a function: %s", fn.Type())
+			err.Line = line
+			return err
+		}
+
+		if err := e.alloc(environmentSizeOf(function)); err != nil {
+			return err
+		}
+
+		if function.Generator {
+			eenv, missing, err := e.bindArguments(function, args, names)
+			if err != nil {
+				return atLine(err, line)
+			}
+			// the generator keeps the environment of the call.
+			e.closures++
+			return e.newGenerator(function, eenv, missing)
+		}
+
+		eenv, err := e.extendFunctionEnv(function, args, names)
+		if err != nil {
+			return atLine(err, line)
+		}
+
+		eval := unwrapreturnValue(e.evalTailBlock(function.Body, eenv))
+
+		call, ok := eval.(*tailCall)
+		if !ok {
+			e.release(memory, closures, eval)
+			return eval
+		}
+		fn, args, names, line = call.fn, call.args, call.names, call.line
+		e.release(memory, closures, args...)
+	}
+}
+
+// atLine annotates o with line if it is an error without one.
+func atLine(o objects.Object, line int) objects.Object {
+	if err, ok := o.(*objects.Error); ok && err.Line == 0 {
+		err.Line = line
+	}
+	return o
+}
+
+// release releases the memory allocated since the start of a call,
+// except the values kept, unless a function was created meanwhile or
+// a kept value refers to other values, which the call may have
+// allocated as well.
+func (e *evaluator) release(memory int64, closures int, kept ...objects.Object) {
+	if e.closures != closures || e.err != nil {
+		return
+	}
+	for _, o := range kept {
+		if !scalar(o) {
+			return
+		}
+	}
+
+	e.memory = memory
+	for _, o := range kept {
+		e.memory += sizeOf(o)
+	}
+}
+
+// tailCall is a call in tail position that has not been applied yet.
+type tailCall struct {
+	fn    objects.Object
+	args  []objects.Object
+	names []string // the names of the named arguments, the last of args.
+	line  int
+}
+
+func (t *tailCall) Type() objects.Type { return "TAIL_CALL" }
+func (t *tailCall) Inspect() string    { return "tail call" }
+func (t *tailCall) Equals(other objects.Object) bool {
+	o, ok := other.(*tailCall)
+	return ok && o == t
+}
+
+// applyTail applies a call returned in tail position
+// outside of the function it was returned from.
+func (e *evaluator) applyTail(call *tailCall) objects.Object {
+	result := e.applyFunction(call.fn, call.args, call.names)
+	if err, ok := result.(*objects.Error); ok && err.Line == 0 {
+		err.Line = call.line
+	}
+	return result
+}
+
+// evalTail evaluates the expression in tail position of a function,
+// a call is not applied but returned as a tailCall.
+func (e *evaluator) evalTail(node ast.Expression, env *objects.Environment) objects.Object {
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		if ast.IsCallTo(node, ast.Quote) || ast.IsCallTo(node, ast.Unquote) {
+			return e.eval(node, env)
+		}
+
+		fn := e.eval(node.Function, env)
+		if isError(fn) {
+			return fn
+		}
+
+		args, names, err := e.evalArguments(node.Arguments, env)
+		if err != nil {
+			return err
+		}
+
+		return &tailCall{fn: fn, args: args, names: names, line: ast.Pos(node).Line}
+	case *ast.IfExpression:
+		condition := e.eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+
+		if isOk(condition) {
+			return e.evalTailBlock(node.Consequence, env)
+		} else if node.Alternative != nil {
+			return e.evalTailBlock(node.Alternative, env)
+		} else {
+			return objects.NewNull()
+		}
+	case *ast.MatchExpression:
+		arm, aenv, err := e.matchArm(node, env)
+		if err != nil {
+			return err
+		}
+		return e.evalTail(arm.Body, aenv)
+	case *ast.SelectExpression:
+		arm, err := e.selectArm(node, env)
+		if err != nil {
+			return err
+		}
+		return e.evalTail(arm.Body, env)
+	default:
+		return e.eval(node, env)
+	}
+}
+
+// evalTailBlock evaluates a block whose value is in tail position.
+func (e *evaluator) evalTailBlock(block *ast.BlockStatement, env *objects.Environment) objects.Object {
+	if err := e.hoist(block.Statements, env); err != nil {
+		return err
+	}
+
+	var result objects.Object
+
+	for i, statement := range block.Statements {
+		if es, ok := statement.(*ast.ExpressionStatement); ok && i == len(block.Statements)-1 {
+			return e.evalTail(es.Expression, env)
+		}
+
+		result = e.eval(statement, env)
+
+		if result != nil && (result.Type() == objects.RETURN || result.Type() == objects.ERROR) {
+			return result
+		}
+	}
+
+	return result
+}
+
+// evalArguments evaluates the arguments of a call, it returns
+// their values and the names of the named arguments, which
+// follow the positional ones.
+func (e *evaluator) evalArguments(exp []ast.Expression, env *objects.Environment) ([]objects.Object, []string, objects.Object) {
+	var (
+		args  []objects.Object
+		names []string
+	)
+
+	for _, x := range exp {
+		if named, ok := x.(*ast.NamedArgument); ok {
+			names = append(names, named.Name.Value)
+			x = named.Value
+		}
+
+		eval := e.eval(x, env)
+		if isError(eval) {
+			return nil, nil, eval
+		}
+
+		args = append(args, eval)
+	}
+
+	return args, names, nil
+}
+
+func (e *evaluator) evalExpressionList(exp []ast.Expression, env *objects.Environment) []objects.Object {
 	var result []objects.Object
 
-	for _, e := range exp {
-		eval := Eval(e, env)
+	for _, x := range exp {
+		eval := e.eval(x, env)
 		if isError(eval) {
 			return []objects.Object{eval}
 		}
@@ -141,85 +923,292 @@ func isError(o objects.Object) bool {
 }
 
 func isOk(o objects.Object) bool {
-	switch o {
-	case NULL:
-		return false
-	case TRUE:
-		return true
-	case FALSE:
+	switch o := o.(type) {
+	case *objects.Null:
 		return false
+	case *objects.Boolean:
+		return o.Value
 	default:
 		return true
 	}
 }
 
 func evalIdentifier(node *ast.Identifier, env *objects.Environment) objects.Object {
-	val, ok := env.Get(node.Value)
+	var (
+		val objects.Object
+		ok  bool
+	)
+
+	if node.Resolved {
+		val, ok = env.GetAt(node.Depth, node.Slot, node.Value)
+	} else {
+		val, ok = env.Get(node.Value)
+	}
+
 	if !ok {
-		return newError(fmt.Sprintf("identifier not found: " + node.Value))
+		return objects.Errorf(objects.NameError, "identifier not found: %s", node.Value)
 	}
 
 	return val
 }
 
-func evalIfExpression(exp *ast.IfExpression, env *objects.Environment) objects.Object {
-	condition := Eval(exp.Condition, env)
+func (e *evaluator) evalIfExpression(exp *ast.IfExpression, env *objects.Environment) objects.Object {
+	condition := e.eval(exp.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
 	if isOk(condition) {
-		return Eval(exp.Consequence, env)
+		return e.eval(exp.Consequence, env)
 	} else if exp.Alternative != nil {
-		return Eval(exp.Alternative, env)
+		return e.eval(exp.Alternative, env)
 	} else {
-		return NULL
+		return objects.NewNull()
 	}
 }
 
+// matchArm returns the first arm of a match expression whose pattern
+// matches the value and whose guard is truthy, with the environment of
+// the arm. The names bound by the pattern are bound in the environment
+// of the arm, so the arms that are not taken leave env unchanged.
+func (e *evaluator) matchArm(node *ast.MatchExpression, env *objects.Environment) (*ast.MatchArm, *objects.Environment, objects.Object) {
+	val := e.eval(node.Value, env)
+	if isError(val) {
+		return nil, nil, val
+	}
+
+	for _, arm := range node.Arms {
+		aenv, err := e.enclose(arm.Locals, env)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ok, merr := e.match(arm.Pattern, val, aenv, false)
+		if merr != nil {
+			return nil, nil, merr
+		}
+		if !ok {
+			continue
+		}
+
+		if arm.Guard == nil {
+			return arm, aenv, nil
+		}
+
+		guard := e.eval(arm.Guard, aenv)
+		if isError(guard) {
+			return nil, nil, guard
+		}
+
+		if isOk(guard) {
+			return arm, aenv, nil
+		}
+	}
+
+	// the error is annotated here, it may be returned from a tail position.
+	err := objects.Errorf(objects.MatchError, "no pattern matches %s", val.Inspect())
+	err.Line = node.Token.Pos.Line
+	return nil, nil, err
+}
+
+// match reports whether val matches the pattern p, binding the names
+// of p in env while it is matched. The defaults of the missing elements
+// of arrays and hashes are evaluated in env. If strict is set a value
+// that does not match fails with an error naming the innermost pattern
+// it does not match, like those of destructuring let statements.
+func (e *evaluator) match(p ast.Pattern, val objects.Object, env *objects.Environment, strict bool) (bool, objects.Object) {
+	mismatch := func() (bool, objects.Object) {
+		if !strict {
+			return false, nil
+		}
+		return false, objects.Errorf(objects.MatchError, "value %s does not match the pattern %s", val.Inspect(), p)
+	}
+
+	switch p := p.(type) {
+	case *ast.Identifier:
+		if p.Value != ast.Wildcard {
+			if err := e.bind(p, val, env); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case *ast.ArrayPattern:
+		arr, ok := val.(*objects.Array)
+		if !ok || p.Rest == nil && len(arr.Elements) > len(p.Elements) {
+			return mismatch()
+		}
+
+		for i, element := range p.Elements {
+			var v objects.Object
+			if i < len(arr.Elements) {
+				v = arr.Elements[i]
+			} else if element.Default == nil {
+				return mismatch()
+			}
+			if ok, err := e.matchElement(element, v, env, strict); !ok || err != nil {
+				return ok, err
+			}
+		}
+
+		if p.Rest != nil && p.Rest.Value != ast.Wildcard {
+			rest := []objects.Object{}
+			if len(arr.Elements) > len(p.Elements) {
+				rest = append(rest, arr.Elements[len(p.Elements):]...)
+			}
+
+			v := e.allocated(&objects.Array{Elements: rest})
+			if isError(v) {
+				return false, v
+			}
+			if err := e.bind(p.Rest, v, env); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case *ast.HashPattern:
+		hash, ok := val.(*objects.Hash)
+		if !ok {
+			return mismatch()
+		}
+
+		for _, element := range p.Elements {
+			v, ok := hash.Get(&objects.String{Value: element.Key.Value})
+			if !ok && element.Default == nil {
+				return mismatch()
+			}
+			if ok, err := e.matchElement(element, v, env, strict); !ok || err != nil {
+				return ok, err
+			}
+		}
+		return true, nil
+	default:
+		if !matches(p, val) {
+			return mismatch()
+		}
+		return true, nil
+	}
+}
+
+// matchElement matches the value v of an element of an array or a hash
+// against the pattern of element, the default of element if v is nil.
+func (e *evaluator) matchElement(element *ast.PatternElement, v objects.Object, env *objects.Environment, strict bool) (bool, objects.Object) {
+	if v == nil {
+		if v = e.eval(element.Default, env); isError(v) {
+			return false, v
+		}
+	}
+
+	return e.match(element.Pattern, v, env, strict)
+}
+
+// matches reports whether the literal pattern matches val.
+func matches(pattern ast.Pattern, val objects.Object) bool {
+	switch p := pattern.(type) {
+	case *ast.IntegerLiteral:
+		i, ok := val.(*objects.Integer)
+		return ok && i.Value == int64(p.Value)
+	case *ast.StringLiteral:
+		s, ok := val.(*objects.String)
+		return ok && s.Value == p.Value
+	case *ast.BooleanLiteral:
+		b, ok := val.(*objects.Boolean)
+		return ok && b.Value == p.Value
+	default:
+		return false
+	}
+}
+
+// evalTry evaluates the body of a try expression and, if it fails with
+// an error that can be caught, the catch clause with the error bound
+// to its parameter. The finally clause is evaluated last, its value is
+// discarded unless it returns or fails.
+func (e *evaluator) evalTry(node *ast.TryExpression, env *objects.Environment) objects.Object {
+	result := e.evalGuarded(node.Body, env)
+
+	if err, ok := result.(*objects.Error); ok && node.Catch != nil && err.Catchable() {
+		// the catch clause is evaluated in an environment of its own.
+		cenv, aerr := e.enclose(node.Locals, env)
+		if aerr != nil {
+			return aerr
+		}
+		if err := e.bind(node.Parameter, &objects.ErrorValue{Error: err}, cenv); err != nil {
+			return err
+		}
+		result = e.evalGuarded(node.Catch, cenv)
+	}
+
+	if node.Finally != nil {
+		final := e.evalGuarded(node.Finally, env)
+		if final != nil && (final.Type() == objects.RETURN || final.Type() == objects.ERROR) {
+			return final
+		}
+	}
+
+	return result
+}
+
+// enclose returns an environment enclosed by env for a scope that is not
+// a function, binding the resolved locals in slots if there are any. A
+// scope the resolver found to bind no names shares env.
+func (e *evaluator) enclose(locals []string, env *objects.Environment) (*objects.Environment, *objects.Error) {
+	if locals != nil && len(locals) == 0 {
+		return env, nil
+	}
+	if err := e.alloc(scopeSize(locals)); err != nil {
+		return nil, err
+	}
+
+	if locals != nil {
+		return objects.NewSlotEnvironment(locals, env), nil
+	}
+	return objects.NewEnclosedEnvironment(env), nil
+}
+
+// evalGuarded evaluates a block of a try expression. A call returned
+// in tail position is applied, so that its errors are raised inside
+// the try expression.
+func (e *evaluator) evalGuarded(block *ast.BlockStatement, env *objects.Environment) objects.Object {
+	result := e.eval(block, env)
+
+	if ret, ok := result.(*objects.Return); ok {
+		if call, ok := ret.Value.(*tailCall); ok {
+			val := e.applyTail(call)
+			if isError(val) {
+				return val
+			}
+			return &objects.Return{Value: val}
+		}
+	}
+
+	return result
+}
+
 func evalIntegerInfix(op string, left objects.Object, right objects.Object) objects.Object {
 	lVal := left.(*objects.Integer).Value
 	rVal := right.(*objects.Integer).Value
 
 	switch op {
 	case token.PLUS:
-		return &objects.Integer{
-			Value: int64(lVal) + int64(rVal),
-		}
+		return objects.NewInteger(int64(lVal) + int64(rVal))
 	case token.MINUS:
-		return &objects.Integer{
-			Value: int64(lVal) - int64(rVal),
-		}
+		return objects.NewInteger(int64(lVal) - int64(rVal))
 	case token.ASTERISK:
-		return &objects.Integer{
-			Value: int64(lVal) * int64(rVal),
-		}
+		return objects.NewInteger(int64(lVal) * int64(rVal))
 	case token.SLASH:
-		return &objects.Integer{
-			Value: int64(lVal) / int64(rVal),
+		if rVal == 0 {
+			return objects.Errorf(objects.ArithmeticError, "division by zero")
 		}
+		return objects.NewInteger(int64(lVal) / int64(rVal))
 	case token.LESST:
-		if lVal < rVal {
-			return TRUE
-		}
-		return FALSE
+		return objects.NewBoolean(lVal < rVal)
 	case token.GREATERT:
-		if lVal > rVal {
-			return TRUE
-		}
-		return FALSE
+		return objects.NewBoolean(lVal > rVal)
 	case token.EQUAL:
-		if lVal == rVal {
-			return TRUE
-		}
-		return FALSE
+		return objects.NewBoolean(lVal == rVal)
 	case token.NEQUAL:
-		if lVal != rVal {
-			return TRUE
-		}
-		return FALSE
+		return objects.NewBoolean(lVal != rVal)
 	default:
-		return newError(fmt.Sprintf("unknown operator: %s %s %s", left.Type(), op, right.Type()))
+		return objects.Errorf(objects.TypeError, "unknown operator: %s %s %s", left.Type(), op, right.Type())
 	}
 }
 
@@ -227,44 +1216,29 @@ func evalInfix(op string, left objects.Object, right objects.Object) objects.Obj
 	switch {
 	case left.Type() == objects.INTEGER && right.Type() == objects.INTEGER:
 		return evalIntegerInfix(op, left, right)
+	case left.Type() == objects.STRING && right.Type() == objects.STRING && op == token.PLUS:
+		return &objects.String{Value: left.(*objects.String).Value + right.(*objects.String).Value}
 	case op == token.EQUAL:
-		if left == right {
-			return TRUE
-		}
-		return FALSE
+		return objects.NewBoolean(left.Equals(right))
 	case op == token.NEQUAL:
-		if left != right {
-			return TRUE
-		}
-		return FALSE
+		return objects.NewBoolean(!left.Equals(right))
 	case left.Type() != right.Type():
-		return newError(fmt.Sprintf("type mismatch: %s %s %s", left.Type(), op, right.Type()))
+		return objects.Errorf(objects.TypeError, "type mismatch: %s %s %s", left.Type(), op, right.Type())
 	default:
-		return newError(fmt.Sprintf("unknown operator: %s %s %s", left.Type(), op, right.Type()))
+		return objects.Errorf(objects.TypeError, "unknown operator: %s %s %s", left.Type(), op, right.Type())
 	}
 }
 
 func evalBang(exp objects.Object) objects.Object {
-	switch exp {
-	case TRUE:
-		return FALSE
-	case FALSE:
-		return TRUE
-	case NULL:
-		return TRUE
-	default:
-		return FALSE
-	}
+	return objects.NewBoolean(!isOk(exp))
 }
 
 func evalMinus(exp objects.Object) objects.Object {
 	if exp.Type() != objects.INTEGER {
-		return newError(fmt.Sprintf("unknown operator: -%s", exp.Type()))
+		return objects.Errorf(objects.TypeError, "unknown operator: -%s", exp.Type())
 	}
 
-	return &objects.Integer{
-		Value: -(exp.(*objects.Integer).Value),
-	}
+	return objects.NewInteger(-exp.(*objects.Integer).Value)
 }
 
 func evalPrefix(op string, exp objects.Object) objects.Object {
@@ -274,18 +1248,25 @@ func evalPrefix(op string, exp objects.Object) objects.Object {
 	case token.MINUS:
 		return evalMinus(exp)
 	default:
-		return newError(fmt.Sprintf("unknown operator: %s%s", op, exp.Type()))
+		return objects.Errorf(objects.TypeError, "unknown operator: %s%s", op, exp.Type())
 	}
 }
 
-func evalProgram(statements []ast.Statement, env *objects.Environment) objects.Object {
+func (e *evaluator) evalProgram(statements []ast.Statement, env *objects.Environment) objects.Object {
+	if err := e.hoist(statements, env); err != nil {
+		return err
+	}
+
 	var result objects.Object
 
 	for _, statement := range statements {
-		result = Eval(statement, env)
+		result = e.eval(statement, env)
 
 		switch result := result.(type) {
 		case *objects.Return:
+			if call, ok := result.Value.(*tailCall); ok {
+				return e.applyTail(call)
+			}
 			return result.Value
 		case *objects.Error:
 			return result
@@ -295,11 +1276,15 @@ func evalProgram(statements []ast.Statement, env *objects.Environment) objects.O
 	return result
 }
 
-func evalBlock(block *ast.BlockStatement, env *objects.Environment) objects.Object {
+func (e *evaluator) evalBlock(block *ast.BlockStatement, env *objects.Environment) objects.Object {
+	if err := e.hoist(block.Statements, env); err != nil {
+		return err
+	}
+
 	var result objects.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.eval(statement, env)
 
 		if result != nil && (result.Type() == objects.RETURN || result.Type() == objects.ERROR) {
 			return result
@@ -309,8 +1294,25 @@ func evalBlock(block *ast.BlockStatement, env *objects.Environment) objects.Obje
 	return result
 }
 
-func newError(s string) *objects.Error {
-	return &objects.Error{
-		Value: s,
+// hoist binds the functions declared in a block before its first
+// statement is evaluated, so they can be called anywhere in the
+// block and may call each other.
+func (e *evaluator) hoist(statements []ast.Statement, env *objects.Environment) objects.Object {
+	for _, s := range statements {
+		fs, ok := s.(*ast.FunctionStatement)
+		if !ok {
+			continue
+		}
+
+		fn := e.eval(fs.Function, env)
+		if isError(fn) {
+			return fn
+		}
+
+		if err := e.bind(fs.Function.Name, fn, env); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
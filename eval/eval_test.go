@@ -1,13 +1,392 @@
 package eval
 
 import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Despire/interpreter/compiler"
 	"github.com/Despire/interpreter/lexer"
 	"github.com/Despire/interpreter/objects"
 	"github.com/Despire/interpreter/parser"
+	"github.com/Despire/interpreter/resolver"
+	"github.com/Despire/interpreter/vm"
 )
 
+func TestResolvedIdentifiers(t *testing.T) {
+	tests := []string{
+		"let a = 5; let f = fn(x) { fn(y) { x + y + a } }; f(1)(2);",
+		"let x = 1; let f = fn(x) { x * 10 }; f(2) + x;",
+		"let x = 1; let f = fn() { let y = x; let x = 2; y + x }; f();",
+		"let f = fn() { g() }; let g = fn() { 7 }; f();",
+		"let f = fn(n) { if (n < 1) { return 0; } n + f(n - 1) }; f(10);",
+		"let f = fn(c) { if (c) { let x = 1; } x }; let x = 10; f(false) + f(true);",
+		"let f = fn(x, x) { x }; f(1, 2);",
+		"let f = fn() { let a = 1; let a = a + 1; a }; f();",
+		"let f = fn(n) { let g = fn() { n + m }; let m = 2; g() }; f(1);",
+		"let f = fn(x) { match (x) { 0 => 1, n if n > 1 => n * 2, m => m - 1 } }; f(0) + f(5) + f(1);",
+		"let f = fn(xs) { let [a, b = a, ...r] = xs; let {k} = {k: b}; [a, k, r] }; f([1]);",
+		"let n = 2; let f = fn(a, b = a * n, ...r) { let c = b; [a, c, r] }; [f(1), f(1, 2, 3), f(b: 1, a: 2)];",
+		"let r = even(10); fn even(n) { if (n == 0) { true } else { odd(n - 1) } } fn odd(n) { if (n == 0) { false } else { even(n - 1) } } [r, odd(3)];",
+		"let f = fn(x) { let y = g(x); fn g(a) { a + x } y }; f(1);",
+		"let n = 1; fn g(a, b = a + n) { let c = yield a; yield b + c } let it = g(1); [it.next(), it.next(5)];",
+		"let f = fn() { let e = 5; let r = try { throw 1 } catch (e) { let x = e.value; fn() { x + e.value } }; [e, r()] }; f();",
+		"let e = 1; let g = try { throw 2; } catch (e) { match (e.value) { v if v > 5 => 0, v => fn() { v + e.value } } }; [e, g()];",
+		"let f = fn(a) { match ([a, 2]) { [a, 3] => 0, [x, y] if x > y => x, [_, y] => a + y } }; [f(1), f(5)];",
+		"let f = fn(n) { match (n) { 0 => 0, n => if (true) { let m = n - 1; f(m) } } }; f(3);",
+		"let f = fn() { let x = 5; try { throw 1 } catch (e) { let x = x + 1; x } }; f();",
+		"let x = 5; match (1) { 1 => if (true) { let x = x + 1; x } };",
+		"foobar",
+	}
+
+	for _, input := range tests {
+		unresolved := testEval(t, input)
+
+		program := parser.New(lexer.New(input)).ParseProgram()
+		resolver.Resolve(program)
+		resolved := Eval(program, objects.NewEnvironment())
+
+		if resolved.Inspect() != unresolved.Inspect() {
+			t.Errorf("resolved program evaluates differently for %q, have %s, want %s", input, resolved.Inspect(), unresolved.Inspect())
+		}
+	}
+}
+
+func TestBindingAllocations(t *testing.T) {
+	program := parser.New(lexer.New("fn(a, b) { let c = a + b; c }")).ParseProgram()
+	resolver.Resolve(program)
+
+	fn := Eval(program, objects.NewEnvironment()).(*objects.Function)
+	args := []objects.Object{objects.NewInteger(1), objects.NewInteger(2)}
+	e := &evaluator{budget: &budget{ctx: context.Background()}}
+
+	// a positional call allocates the environment and its slots only.
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, _, err := e.bindArguments(fn, args, nil); err != nil {
+			t.Fatal(err.Inspect())
+		}
+	})
+	if allocs > 2 {
+		t.Errorf("wrong number of allocations binding the arguments. want=2, have=%v", allocs)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let f = fn(n, acc) { if (n < 1) { return acc; } f(n - 1, acc + n) }; f(100, 0);", 5050},
+		{"let f = fn(n) { if (n > 0) { f(n - 1) } else { 7 } }; f(100);", 7},
+		{"let f = fn(n) { if (n > 0) { return f(n - 1); } 8 }; f(100);", 8},
+		{"let even = fn(n) { if (n == 0) { 1 } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { 0 } else { even(n - 1) } }; even(100);", 1},
+		{"let f = fn(n) { if (n < 1) { return 0; } 1 + f(n - 1) }; f(100);", 100},
+		{"let id = fn(x) { x }; return id(3);", 3},
+		{"let id = fn(x) { x }; if (true) { return id(4); } 5", 4},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestDeepTailRecursion(t *testing.T) {
+	// without tail calls each level of the recursion takes a few
+	// kilobytes of Go stack, exceeding the limit thousands of times.
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	input := `
+let countdown = fn(n) {
+  if (n == 0) { return 0; }
+  countdown(n - 1)
+};
+countdown(1000000);`
+
+	for _, resolve := range []bool{false, true} {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		if resolve {
+			resolver.Resolve(program)
+		}
+
+		testIntegerObject(t, Eval(program, objects.NewEnvironment()), 0)
+	}
+
+	testIntegerObject(t, testRun(t, input, 0), 0)
+}
+
+func TestCallDepth(t *testing.T) {
+	sum := "let sum = fn(n) { if (n < 1) { return 0; } n + sum(n - 1) };"
+
+	tests := []struct {
+		input    string
+		maxDepth int
+		expected interface{}
+	}{
+		{sum + "sum(100000);", 0, "maximum call depth 10000 exceeded"},
+		{"let f = fn() { 1 + f() }; f()", 0, "maximum call depth 10000 exceeded"},
+		{"let c = fn(n) { if (n == 0) { 0 } else { 1 + c(n - 1) } }; c(100000)", 0, "maximum call depth 10000 exceeded"},
+		{"let c = fn(n) { if (n == 0) { 0 } else { c(n - 1) } }; c(100000)", 0, 0},
+		{"let c = fn(n) { match (n) { 0 => 0, _ => c(n - 1) } }; c(100000)", 0, 0},
+		{sum + "sum(9999);", 0, 49995000},
+		{sum + "sum(9);", 10, 45},
+		{sum + "sum(10);", 10, "maximum call depth 10 exceeded"},
+		{"let f = fn(n) { if (n > 0) { f(n - 1) } else { 0 } }; f(1000);", 10, 0},
+		{"let f = fn(n) { if (n > 0) { return f(n - 1); } fn() { 1 }() }; f(1000);", 2, 1},
+		{"let f = fn(n) { if (n > 0) { return f(n - 1); } fn() { 1 }() + 1 }; f(1000);", 2, 2},
+		{"let f = fn(n) { if (n > 0) { return f(n - 1); } fn() { 1 }() + fn() { fn() { 1 }() + 1 }() }; f(1000);", 2, "maximum call depth 2 exceeded"},
+		{"fn g(n) { if (n > 0) { yield g(n - 1).next()[\"value\"] } else { yield n } } g(3).next()[\"value\"];", 10, 0},
+		{"fn g(n) { if (n > 0) { yield g(n - 1).next()[\"value\"] } else { yield n } } g(20).next()[\"value\"];", 10, "maximum call depth 10 exceeded"},
+	}
+
+	for _, tt := range tests {
+		result := testEvalWithOptions(t, tt.input, Options{MaxDepth: tt.maxDepth})
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, result, int64(expected))
+		case string:
+			err, ok := result.(*objects.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q, got %T(%+v)", tt.input, result, result)
+				continue
+			}
+			if err.Value != expected {
+				t.Errorf("wrong error message for %q. want=%q, have=%q", tt.input, expected, err.Value)
+			}
+		}
+	}
+}
+
+func TestExecutionLimits(t *testing.T) {
+	loop := "let loop = fn(n) { loop(n + 1) }; loop(0);"
+	sum := "let sum = fn(n, acc) { if (n < 1) { return acc; } sum(n - 1, acc + n) }; sum(100, 0);"
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expired, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		ctx      context.Context
+		input    string
+		opts     Options
+		expected interface{}
+	}{
+		{cancelled, "1 + 1", Options{}, "execution cancelled: context canceled"},
+		{expired, loop, Options{}, "execution cancelled: context deadline exceeded"},
+		{context.Background(), loop, Options{MaxSteps: 10000}, "step budget of 10000 exceeded"},
+		{context.Background(), loop, Options{MaxAllocations: 1000}, "allocation budget of 1000 exceeded"},
+		{context.Background(), "1 + 2", Options{MaxSteps: 5}, 3},
+		{context.Background(), "1 + 2", Options{MaxSteps: 4}, "step budget of 4 exceeded"},
+		{context.Background(), "1 + 2", Options{MaxAllocations: 1}, 3},
+		{context.Background(), "2000 + 3000", Options{MaxAllocations: 3}, 5000},
+		{context.Background(), "2000 + 3000", Options{MaxAllocations: 2}, "allocation budget of 2 exceeded"},
+		{context.Background(), "let f = fn() { 1 }; 1 == 1", Options{MaxAllocations: 3}, true},
+		{context.Background(), sum, Options{MaxSteps: 5000, MaxAllocations: 1000}, 5050},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		result := EvalContext(tt.ctx, program, objects.NewEnvironment(), tt.opts)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, result, int64(expected))
+		case bool:
+			testBooleanObject(t, result, expected)
+		case string:
+			err, ok := result.(*objects.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q, got %T(%+v)", tt.input, result, result)
+				continue
+			}
+			if err.Value != expected {
+				t.Errorf("wrong error message for %q. want=%q, have=%q", tt.input, expected, err.Value)
+			}
+		}
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	sum := "let sum = fn(n) { if (n < 1) { return 0; } n + sum(n - 1) };"
+	countdown := "let countdown = fn(n) { if (n < 1) { return 0; } countdown(n - 1) };"
+	adders := "let adders = fn(n) { if (n < 1) { return 0; } let add = fn(x) { x + n }; adders(n - 1) };"
+	tree := "let tree = fn(n) { if (n == 0) { [1, 2, 3, 4, 5, 6, 7, 8] } else { [tree(n - 1), tree(n - 1)] } };"
+
+	usage := func(input string, opts Options) (objects.Object, Usage) {
+		var u Usage
+		opts.Usage = &u
+		program := parser.New(lexer.New(input)).ParseProgram()
+		return EvalWithOptions(program, objects.NewEnvironment(), opts), u
+	}
+
+	_, small := usage(sum+"sum(10);", Options{})
+	_, large := usage(sum+"sum(1000);", Options{})
+	if large.PeakMemory < 50*small.PeakMemory {
+		t.Errorf("peak memory does not grow with the depth of calls. sum(10)=%d, sum(1000)=%d", small.PeakMemory, large.PeakMemory)
+	}
+	if large.Memory != small.Memory {
+		t.Errorf("memory of the calls is not released. sum(10)=%d, sum(1000)=%d", small.Memory, large.Memory)
+	}
+	if large.Steps <= small.Steps || large.Allocations <= small.Allocations {
+		t.Errorf("usage is not reported. sum(10)=%+v, sum(1000)=%+v", small, large)
+	}
+
+	_, short := usage(countdown+"countdown(2000);", Options{})
+	_, long := usage(countdown+"countdown(200000);", Options{})
+	if long.PeakMemory != short.PeakMemory {
+		t.Errorf("peak memory grows with tail calls. countdown(2000)=%d, countdown(200000)=%d", short.PeakMemory, long.PeakMemory)
+	}
+
+	_, few := usage(adders+"adders(10);", Options{})
+	_, many := usage(adders+"adders(1000);", Options{})
+	if many.Memory < 50*few.Memory {
+		t.Errorf("memory captured by functions is released. adders(10)=%d, adders(1000)=%d", few.Memory, many.Memory)
+	}
+
+	// the values returned by the calls are kept, along with those they refer to.
+	_, leaf := usage(tree+"let t = tree(0); 1", Options{})
+	_, nested := usage(tree+"let t = tree(10); 1", Options{})
+	if nested.Memory < 100*leaf.Memory {
+		t.Errorf("memory of the returned values is released. tree(0)=%d, tree(10)=%d", leaf.Memory, nested.Memory)
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{sum + "sum(10);", 55},
+		{sum + "sum(1000);", "memory limit of 16384 bytes exceeded"},
+		{countdown + "countdown(100000);", 0},
+		{adders + "adders(1000);", "memory limit of 16384 bytes exceeded"},
+		{tree + "let t = tree(16); 1", "memory limit of 16384 bytes exceeded"},
+		{tree + "let t = tree(2); 1", 1},
+	}
+
+	for _, tt := range tests {
+		result, _ := usage(tt.input, Options{MaxMemory: 16384})
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, result, int64(expected))
+		case string:
+			err, ok := result.(*objects.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q, got %T(%+v)", tt.input, result, result)
+				continue
+			}
+			if err.Value != expected {
+				t.Errorf("wrong error message for %q. want=%q, have=%q", tt.input, expected, err.Value)
+			}
+		}
+	}
+}
+
+func TestHostValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"no == false", true},
+		{"no != false", false},
+		{"yes == true", true},
+		{"!no", true},
+		{"if (no) { 1 } else { 2 }", 2},
+		{"if (nothing) { 1 } else { 2 }", 2},
+		{"big == 100000", true},
+		{"big == yes", false},
+	}
+
+	for _, tt := range tests {
+		env := objects.NewEnvironment()
+		env.Set("yes", &objects.Boolean{Value: true})
+		env.Set("no", &objects.Boolean{Value: false})
+		env.Set("nothing", &objects.Null{})
+		env.Set("big", &objects.Integer{Value: 100000})
+
+		result := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, result, int64(expected))
+		case bool:
+			testBooleanObject(t, result, expected)
+		}
+	}
+}
+
+// modules is an importer of the modules it holds.
+type modules map[string]*objects.Module
+
+func (m modules) Import(path string) (*objects.Module, error) {
+	if mod, ok := m[path]; ok {
+		return mod, nil
+	}
+	return nil, fmt.Errorf("module %q not found", path)
+}
+
+func TestImports(t *testing.T) {
+	lib := objects.NewEnvironment()
+	Eval(parser.New(lexer.New("let square = fn(x) { x * x };")).ParseProgram(), lib)
+	square, _ := lib.Get("square")
+
+	importer := modules{
+		"math.mk": {
+			Path: "math.mk",
+			Members: map[string]objects.Object{
+				"square": square,
+				"answer": objects.NewInteger(42),
+			},
+		},
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "math.mk" as m; m.answer`, 42},
+		{`import "math.mk" as m; m.square(m.answer)`, 1764},
+		{`import "math.mk" as m; let f = fn(x) { m.square(x) + 1 }; f(3)`, 10},
+		{`import "math.mk" as m; import "math.mk" as n; m == n`, true},
+		{`import "math.mk" as m;`, nil},
+		{`import "math.mk" as m; m.cube`, "math.mk has no exported member cube"},
+		{`let m = 1; m.answer`, "not a module: INTEGER"},
+		{`import "nope.mk" as m; 1`, `module "nope.mk" not found`},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		resolver.Resolve(program)
+
+		result := EvalWithOptions(program, objects.NewEnvironment(), Options{Importer: importer})
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, result, int64(expected))
+		case bool:
+			testBooleanObject(t, result, expected)
+		case string:
+			if err, ok := result.(*objects.Error); !ok || err.Value != expected {
+				t.Errorf("wrong result for %q. want error %q, have=%s", tt.input, expected, inspect(result))
+			}
+		case nil:
+			if result != nil {
+				t.Errorf("wrong result for %q. want <nil>, have=%s", tt.input, inspect(result))
+			}
+		}
+	}
+
+	result := Eval(parser.New(lexer.New(`import "math.mk" as m;`)).ParseProgram(), objects.NewEnvironment())
+	if err, ok := result.(*objects.Error); !ok || err.Value != `import "math.mk": imports are not supported` {
+		t.Errorf("wrong result of an import without an importer: %s", inspect(result))
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 let newAdder = fn(x) {
@@ -17,7 +396,7 @@ let newAdder = fn(x) {
 let addTwo = newAdder(2);
 addTwo(2);`
 
-	testIntegerObject(t, testEval(input), 4)
+	testIntegerObject(t, testEval(t, input), 4)
 }
 
 func TestFunctionCalls(t *testing.T) {
@@ -33,14 +412,332 @@ func TestFunctionCalls(t *testing.T) {
 		{"fn(x) { x; }(5)", 5},
 	}
 	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(x, y = 10) { x + y }; [f(1), f(1, 2)]", "[11, 3]"},
+		{"let f = fn(x, y = x * 2, z = x + y) { [x, y, z] }; [f(1), f(1, 5)]", "[[1, 2, 3], [1, 5, 6]]"},
+		{"let n = 1; let f = fn(x = n) { x }; let a = f(); let n = 5; [a, f()]", "[1, 5]"},
+		{"let f = fn(xs = []) { xs }; f() == f()", "true"},
+		{"let f = fn(x, ...rest) { [x, rest] }; [f(1), f(1, 2, 3)]", "[[1, []], [1, [2, 3]]]"},
+		{"let f = fn(...all) { all }; f(1, [2])", "[1, [2]]"},
+		{"let f = fn(x, y = 2, ...rest) { [x, y, rest] }; f(1, 3, 4)", "[1, 3, [4]]"},
+		{"let f = fn(x, y) { x - y }; [f(y: 1, x: 10), f(10, y: 1)]", "[9, 9]"},
+		{"let f = fn(x, y = 2, z = 3) { [x, y, z] }; f(1, z: 4)", "[1, 2, 4]"},
+		{"let f = fn(x, ...rest) { [x, rest] }; f(x: 1)", "[1, []]"},
+		{"let f = fn(x, y) { x }; f(1, 2, 3)", "1"},
+		{"let f = fn(x, y) { x }; f(1)", "ERROR: missing argument for parameter y"},
+		{"let f = fn(x, y) { x }; f(y: 1)", "ERROR: missing argument for parameter x"},
+		{"let f = fn(x) { x }; f(1, z: 2)", "ERROR: unknown parameter z"},
+		{"let f = fn(x) { x }; f(1, x: 2)", "ERROR: multiple values for parameter x"},
+		{"let f = fn(x, ...r) { x }; f(1, r: 2)", "ERROR: rest parameter r can not be passed by name"},
+		{"let f = fn(x = 1 + true) { x }; f()", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn(x = 1 + true) { x }; f(2)", "2"},
+		{"let f = fn(x, y = z) { x }; f(1)", "ERROR: identifier not found: z"},
+		{"try { fn(x) { x }() } catch (e) { e.kind }", `"ArgumentError"`},
+		{"let sum = fn(n, acc = 0) { if (n == 0) { acc } else { sum(n - 1, acc: acc + n) } }; sum(100)", "5050"},
+	}
+
+	for _, tt := range tests {
+		have := testEval(t, tt.input)
+		if inspect(have) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, inspect(have))
+		}
+	}
+}
+
+func TestFunctionStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn add(x, y) { x + y } add(1, 2)", "3"},
+		{"let a = twice(2); fn twice(x) { x * 2 }; a", "4"},
+		{"fn even(n) { if (n == 0) { true } else { odd(n - 1) } } fn odd(n) { if (n == 0) { false } else { even(n - 1) } } [even(10), odd(7), even(3)]", "[true, true, false]"},
+		{"let f = fn(x) { return g(x); fn g(y) { y + 1 } }; f(1)", "2"},
+		{"if (true) { let a = g(); fn g() { 5 }; a }", "5"},
+		{"let f = fn() { if (true) { fn g() { 1 } } g() }; f()", "1"},
+		{"fn f() { 1 } fn f() { 2 } f()", "2"},
+		{"fn f() { 1 }", "<nil>"},
+		{"fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } } let g = fact; g(5)", "120"},
+		{"fn f(a, b) { a } f(1)", "ERROR: f: missing argument for parameter b"},
+		{"fn f(a) { a } f(1, c: 2)", "ERROR: f: unknown parameter c"},
+		{"let f = fn(a) { a }; f()", "ERROR: missing argument for parameter a"},
+		{"g(); let x = 1; fn g() { x }", "ERROR: identifier not found: x"},
+		{"fn f(a, b = 1, ...r) { a } f", "fn f(a, b, ...r) { ... }"},
+		{"[fn(x) { x }, fn(...xs) { xs }]", "[fn(x) { ... }, fn(...xs) { ... }]"},
+	}
+
+	for _, tt := range tests {
+		have := testEval(t, tt.input)
+		if inspect(have) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, inspect(have))
+		}
+	}
+
+	fn, ok := testEval(t, "fn add(x, y = 1) { x + y } add").(*objects.Function)
+	if !ok || fn.Name != "add" {
+		t.Fatalf("wrong function. want add, have %+v", fn)
+	}
+
+	if want := "fn add(x, y) { ... }"; fn.Inspect() != want {
+		t.Errorf("wrong inspect. want=%q, have=%q", want, fn.Inspect())
+	}
+
+	if fn := testEval(t, "fn(x) { x }").(*objects.Function); fn.Name != "" {
+		t.Errorf("function expression has a name: %q", fn.Name)
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn g() { yield 1; yield 2; 3 } let it = g(); [it.next(), it.next(), it.next(), it.next()]",
+			`[{"value": 1, "done": false}, {"value": 2, "done": false}, {"value": 3, "done": true}, {"value": null, "done": true}]`},
+		{"fn acc() { let a = yield 0; let b = yield a + 1; a + b } let it = acc(); [it.next(5)[\"value\"], it.next(1)[\"value\"], it.next(value: 2)]",
+			`[0, 2, {"value": 3, "done": true}]`},
+		{"fn g() { yield 1; throw \"unreachable\" } g().next()", `{"value": 1, "done": false}`},
+		{"fn g() { yield 1; 1 + true } let it = g(); it.next(); it.next()", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"fn g() { yield 1; throw \"boom\" } let it = g(); it.next(); let m = try { it.next() } catch (e) { e.message }; [m, it.next()]",
+			`["boom", {"value": null, "done": true}]`},
+		{"fn g() { let x = try { yield 1; throw \"boom\" } catch (e) { yield e.message; 3 }; x } let it = g(); [it.next(), it.next(), it.next()]",
+			`[{"value": 1, "done": false}, {"value": "boom", "done": false}, {"value": 3, "done": true}]`},
+		{"fn g(a, b = a * 2) { yield a; yield b } let it = g(1); [it.next()[\"value\"], it.next()[\"value\"]]", "[1, 2]"},
+		{"fn g(a = 1 + true) { yield a } let it = g(); it.next()", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"fn g(a) { yield a } g()", "ERROR: g: missing argument for parameter a"},
+		{"fn g() { yield 1 } g().next(x: 1)", "ERROR: next: unknown parameter x"},
+		{"fn g() { yield it.next() } let it = g(); it.next()", "ERROR: generator is already running"},
+		{"fn g() { yield 1 } g().foo", "ERROR: generator has no member foo"},
+		{"fn g() { yield 1 } [g(), g().next]", "[generator g, generator g.next]"},
+		{"fn() { yield 1 }()", "generator"},
+		{"fn inner() { yield 1; yield 2 } fn outer() { let i = inner(); yield i.next()[\"value\"] * 10; yield i.next()[\"value\"] * 10 } let o = outer(); [o.next()[\"value\"], o.next()[\"value\"], o.next()[\"done\"]]",
+			"[10, 20, true]"},
+		{"fn g() { yield 1; return f(2) } fn f(x) { x * 3 } let it = g(); it.next(); it.next()", `{"value": 6, "done": true}`},
+		{"fn g() { yield 1; let x = 2; } let it = g(); it.next(); it.next()", `{"value": null, "done": true}`},
+		{"let f = fn() { fn() { yield 1 } }; f()().next()[\"value\"]", "1"},
+		{"fn counter() { yield 1; yield 2 } let it = counter(); let take = fn() { it.next()[\"value\"] }; [take(), take(), take()]", "[1, 2, null]"},
+		{"fn g() { yield 1 } let it = g(); it.next == it.next", "true"},
+		{"fn g() { [1, yield 2, 3] } let it = g(); it.next(); it.next(5)", `{"value": [1, 5, 3], "done": true}`},
+	}
+
+	for _, tt := range tests {
+		have := testEval(t, tt.input)
+		if inspect(have) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, inspect(have))
+		}
+	}
+}
+
+func TestGeneratorsAreStopped(t *testing.T) {
+	before := runtime.NumGoroutine()
+	program := parser.New(lexer.New("fn g(n) { yield n; yield n + 1 } let f = fn(n) { if (n > 0) { g(n).next(); f(n - 1) } }; f(100)")).ParseProgram()
+
+	// the generators are stopped when the evaluation returns.
+	Eval(program, objects.NewEnvironment())
+	waitForGoroutines(t, before)
+
+	EvalContext(context.Background(), program, objects.NewEnvironment(), Options{})
+	waitForGoroutines(t, before)
+
+	// the environment of the body of a generator stored in a
+	// variable refers to it, it is never unreachable.
+	stored := []string{
+		"let gen = fn() { yield 1; yield 2 }; let it = gen(); it.next();",
+		"let f = fn() { let gen = fn() { yield 1; yield 2 }; let it = gen(); it.next() }; f();",
+	}
+	for _, input := range stored {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		for i := 0; i < 200; i++ {
+			EvalContext(context.Background(), program, objects.NewEnvironment(), Options{})
+		}
+		waitForGoroutines(t, before)
+	}
+
+	// a stopped generator is done.
+	env := objects.NewEnvironment()
+	Eval(parser.New(lexer.New("fn g() { yield 1; yield 2 } let it = g(); it.next()")).ParseProgram(), env)
+	waitForGoroutines(t, before)
+
+	have := Eval(parser.New(lexer.New("it.next()")).ParseProgram(), env)
+	if want := `{"value": null, "done": true}`; inspect(have) != want {
+		t.Errorf("wrong result of a stopped generator. want=%s, have=%s", want, inspect(have))
+	}
+
+	// or at the end of the lifetime set by the options.
+	life, stop := context.WithCancel(context.Background())
+	opts := Options{Generators: life}
+	env = objects.NewEnvironment()
+	EvalWithOptions(parser.New(lexer.New("fn g() { yield 1; yield 2 } let it = g(); it.next()")).ParseProgram(), env, opts)
+
+	have = EvalWithOptions(parser.New(lexer.New("it.next()")).ParseProgram(), env, opts)
+	if want := `{"value": 2, "done": false}`; inspect(have) != want {
+		t.Errorf("wrong result of a generator resumed by a later evaluation. want=%s, have=%s", want, inspect(have))
+	}
+
+	stop()
+	waitForGoroutines(t, before)
+}
+
+// waitForGoroutines waits until at most n goroutines are running.
+func waitForGoroutines(t *testing.T, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines leaked. want=%d, have=%d", n, runtime.NumGoroutine())
+		}
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let c = chan(); spawn fn(x) { c.send(x * 2) }(21); c.recv()", "42"},
+		{"let c = chan(); spawn c.send(value: 9); c.recv()", "9"},
+		{"let c = chan(1); c.send(1); c.recv()", "1"},
+		{"let c = chan(2); c.send(1); c.close(); [c.recv(), c.recv(), c.recv()]", "[1, null, null]"},
+		{"let c = chan(); spawn fn() { c.recv() }(); 5", "5"},
+		{"let c = chan(); spawn fn() { let x = try { c.send(1) } catch (e) { e.message }; x }(); c.close(); 1", "1"},
+		{"let c = chan(); let d = chan(); spawn fn() { c.send(try { d.send(1) } catch (e) { e.message }) }(); d.close(); c.recv()", `"send on a closed channel"`},
+		{"let c = chan(); let p = fn(n) { if (n > 0) { c.send(n); p(n - 1) } else { c.close() } }; spawn p(100); let s = fn(acc) { let v = c.recv(); if (v) { s(acc + v) } else { acc } }; s(0)", "5050"},
+		{"let out = chan(3); let done = chan(); let worker = fn(id) { out.send(id); done.send(id) }; spawn worker(1); spawn worker(2); spawn worker(3); [done.recv(), done.recv(), done.recv(), out.recv() + out.recv() + out.recv()]", "[1, 2, 3, 6]"},
+		{"let sender = fn(c) { fn(x) { c.send(x) } }; let c = chan(); spawn sender(c)(2); spawn sender(c)(3); [c.recv(), c.recv()]", "[2, 3]"},
+		{"fn g(c) { yield c.recv() } let c = chan(1); c.send(4); g(c).next()", `{"value": 4, "done": false}`},
+		{"let c = chan(); select { c.recv() as v => v, _ => 7 }", "7"},
+		{"let c = chan(1); c.send(5); select { c.recv() as v => v, _ => 7 }", "5"},
+		{"let c = chan(); select { c.send(1) => 1, _ => 2 }", "2"},
+		{"let c = chan(1); select { c.send(1) => c.recv(), _ => 2 }", "1"},
+		{"let c = chan(); let d = chan(); spawn fn() { d.send(3) }(); select { c.recv() as v => [1, v], d.recv() as w => [2, w] }", "[2, 3]"},
+		{"let c = chan(); spawn fn() { c.recv() }(); select { c.send(8) => 1 }", "1"},
+		{"let c = chan(1); c.send(1); let f = fn() { select { c.recv() as v => v } }; f()", "1"},
+		{"let c = chan(); let d = chan(); spawn fn() { c.send(1) }(); spawn fn() { d.send(2) }(); let a = select { c.recv() as v => v, d.recv() as v => v }; let b = select { c.recv() as v => v, d.recv() as v => v }; a + b", "3"},
+		{"let c = chan(); c.close(); select { c.recv() as v => v, _ => 1 }", "null"},
+		{"let c = chan(); [c, c.recv, c == c, c == chan()]", "[channel, channel.recv, true, false]"},
+		{"let c = chan(); c.recv()", "ERROR: deadlock: all tasks are blocked"},
+		{"let c = chan(); select { }", "ERROR: deadlock: all tasks are blocked"},
+		{"let c = chan(); spawn fn() { c.recv() }(); c.send(1); c.send(2)", "ERROR: deadlock: all tasks are blocked"},
+		{"let c = chan(); spawn fn() { 1 + true }(); c.recv()", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"let c = chan(); spawn fn(a) { a }(); c.recv()", "ERROR: missing argument for parameter a"},
+		{"let c = chan(); spawn 1(); c.recv()", "ERROR: not a function: INTEGER"},
+		{"let c = chan(); c.close(); c.send(1)", "ERROR: send on a closed channel"},
+		{"let c = chan(); c.close(); c.close()", "ERROR: close of a closed channel"},
+		{"let c = chan(); spawn fn() { c.close() }(); c.send(1)", "ERROR: send on a closed channel"},
+		{"let c = chan(); c.send()", "ERROR: send: missing argument for parameter value"},
+		{"let c = chan(); c.recv(x: 1)", "ERROR: recv: unknown parameter x"},
+		{"let c = chan(); c.foo", "ERROR: channel has no member foo"},
+		{"chan(-1)", "ERROR: invalid channel capacity: -1"},
+		{`chan("1")`, `ERROR: invalid channel capacity: "1"`},
+		{"select { 1.recv() => 2 }", "ERROR: not a channel: INTEGER"},
+		{"let c = chan(); let e = try { c.recv() } catch (e) { e.kind }; e", `"DeadlockError"`},
+	}
+
+	for _, tt := range tests {
+		have := testEval(t, tt.input)
+		if inspect(have) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, inspect(have))
+		}
+	}
+}
+
+func TestTasksAreStopped(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	inputs := []string{
+		"let c = chan(); let f = fn(n) { if (n > 0) { spawn fn() { c.recv() }(); f(n - 1) } }; f(50); 3",
+		"let loop = fn() { loop() }; spawn loop(); let c = chan(); c.recv()",
+		"fn g(c) { yield c.recv() } let c = chan(); spawn fn() { g(c).next() }(); let d = chan(); d.recv()",
+	}
+
+	for _, input := range inputs {
+		program := parser.New(lexer.New(input)).ParseProgram()
+
+		// the tasks share the budget of the evaluation.
+		EvalWithOptions(program, objects.NewEnvironment(), Options{MaxSteps: 10000})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		EvalContext(ctx, program, objects.NewEnvironment(), Options{})
+		cancel()
+	}
+
+	waitForGoroutines(t, before)
+
+	program := parser.New(lexer.New("let loop = fn() { loop() }; spawn loop(); let c = chan(); c.recv()")).ParseProgram()
+	have := EvalWithOptions(program, objects.NewEnvironment(), Options{MaxSteps: 10000})
+	if want := "ERROR: step budget of 10000 exceeded"; inspect(have) != want {
+		t.Errorf("wrong result of a task exceeding the budget. want=%s, have=%s", want, inspect(have))
+	}
+}
+
+func TestConcurrentEvaluations(t *testing.T) {
+	definitions := `
+fn fib(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }
+let greeting = "hello";
+let config = {"factor": 3, "names": ["a", "b"]};
+fn scale(x) { x * config["factor"] }
+fn counter(n) { yield n; yield n + 1 }
+fn pipeline(n) { let c = chan(); spawn fn() { c.send(scale(n)) }(); c.recv() }
+let adder = fn(x) { fn(y) { x + y } };
+`
+	program := parser.New(lexer.New(definitions)).ParseProgram()
+	resolver.Resolve(program)
+
+	globals := objects.NewEnvironment()
+	if val := Eval(program, globals); isError(val) {
+		t.Fatalf("failed to evaluate the definitions: %s", val.Inspect())
+	}
+	globals.Freeze()
+
+	fibs := []int{0, 1, 1, 2, 3, 5, 8, 13, 21, 34}
+
+	const scripts = 2000
+	var wg sync.WaitGroup
+	for i := 0; i < scripts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			input := fmt.Sprintf(`let x = %d; let greeting = "bye";
+[fib(x - x / 10 * 10), scale(x), counter(x).next()["value"], pipeline(x), adder(x)(1), greeting, config["names"][x - x / 2 * 2]]`, i)
+			want := fmt.Sprintf(`[%d, %d, %d, %d, %d, "bye", "%s"]`, fibs[i%10], 3*i, i, 3*i, i+1, []string{"a", "b"}[i%2])
+
+			program := parser.New(lexer.New(input)).ParseProgram()
+			resolver.Resolve(program)
+
+			have := EvalContext(context.Background(), program, objects.NewEnclosedEnvironment(globals), Options{MaxSteps: 100000})
+			if have.Inspect() != want {
+				t.Errorf("wrong result for %q. want=%s, have=%s", input, want, have.Inspect())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if val, _ := globals.Get("greeting"); val.Inspect() != `"hello"` {
+		t.Errorf("the globals were changed, greeting=%s", val.Inspect())
+	}
+
+	have := Eval(parser.New(lexer.New("let y = 1;")).ParseProgram(), globals)
+	if want := "ERROR: cannot evaluate in a frozen environment"; inspect(have) != want {
+		t.Errorf("wrong result of an evaluation in the globals. want=%s, have=%s", want, inspect(have))
 	}
 }
 
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 
-	have := testEval(input)
+	have := testEval(t, input)
 	fn, ok := have.(*objects.Function)
 	if !ok {
 		t.Fatalf("object is not Function. have = %T (%+v)", have, have)
@@ -72,7 +769,7 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
 	}
 	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
 	}
 }
 
@@ -97,6 +794,10 @@ func TestErrorHandling(t *testing.T) {
 			"-true",
 			"unknown operator: -BOOLEAN",
 		},
+		{
+			"let a = 0; 10 / a",
+			"division by zero",
+		},
 		{
 			"true + false;",
 			"unknown operator: BOOLEAN + BOOLEAN",
@@ -122,7 +823,7 @@ if (10 > 1) {
 	}
 
 	for _, tt := range tests {
-		have := testEval(tt.input)
+		have := testEval(t, tt.input)
 
 		err, ok := have.(*objects.Error)
 		if !ok {
@@ -136,6 +837,308 @@ if (10 > 1) {
 	}
 }
 
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		input string
+		kind  string
+		line  int
+	}{
+		{"foobar", objects.NameError, 1},
+		{"1;\n5 + true", objects.TypeError, 2},
+		{"let f = fn() {\n  -true\n};\nf()", objects.TypeError, 2},
+		{"let a = 0;\n\n10 / a", objects.ArithmeticError, 3},
+		{"let f = fn(x) { x };\nlet g = fn() { 1(2) };\ng()", objects.TypeError, 2},
+		{"let f = fn() {\n  return 1(2);\n};\nf()", objects.TypeError, 2},
+		{`throw "bad input"`, objects.ThrownError, 1},
+		{"let x = 1;\nx.y", objects.TypeError, 2},
+		{"let f = fn(x) {\n  match (x) { 1 => 2 }\n};\nf(3)", objects.MatchError, 2},
+		{"let x = 1;\nlet [a, b] = [x];", objects.MatchError, 2},
+		{"let f = fn(a) { a };\nf(b: 1)", objects.ArgumentError, 2},
+		{"let f = fn(a) { a };\nlet g = fn() { f() };\ng()", objects.ArgumentError, 2},
+		{"let x = [1];\nx[true]", objects.TypeError, 2},
+		{"let c = chan();\nspawn fn() {\n  1 + true\n}();\nc.recv()", objects.TypeError, 3},
+		{"let c = chan();\n\nc.recv()", objects.DeadlockError, 3},
+		{"let c = chan();\nselect {\n  c.recv() => 1\n}", objects.DeadlockError, 2},
+		{"let c = chan();\nc.close();\nc.close()", objects.ChannelError, 3},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(t, tt.input).(*objects.Error)
+		if !ok {
+			t.Errorf("no error for %q", tt.input)
+			continue
+		}
+
+		if err.Kind != tt.kind || err.Line != tt.line {
+			t.Errorf("wrong error for %q. want kind=%s line=%d, have kind=%s line=%d", tt.input, tt.kind, tt.line, err.Kind, err.Line)
+		}
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { 1 } catch (e) { 2 }", "1"},
+		{"try { 1 + true } catch (e) { 2 }", "2"},
+		{"try { 1 + true } catch (e) { e.message }", `"type mismatch: INTEGER + BOOLEAN"`},
+		{"try { 1 + true } catch (e) { e.kind }", `"TypeError"`},
+		{"try { 1 + true } catch (e) { e.value }", "null"},
+		{"try {\n\n 1 + true } catch (e) { e.line }", "3"},
+		{"try { foobar } catch (e) { e.kind }", `"NameError"`},
+		{"try { 1 / 0 } catch (e) { e.kind }", `"ArithmeticError"`},
+		{`try { throw "bad input"; } catch (e) { e.message }`, `"bad input"`},
+		{`try { throw "bad input"; } catch (e) { e.kind }`, `"Error"`},
+		{"try { throw 5; } catch (e) { e.value + 1 }", "6"},
+		{"try { throw 5; } catch (e) { e.message }", `"5"`},
+		{"try { throw 5; } catch (e) { e }", "error(Error: 5)"},
+		{"try { throw 5; 6 } catch (e) { 7 }", "7"},
+		{"let x = try { throw 1; } catch (e) { 2 }; x * 10", "20"},
+		{"let f = fn(x) { if (x > 1) { throw x; } x }; try { f(1) + f(2) } catch (e) { e.value * 100 }", "200"},
+		{"let f = fn() { throw 3; }; let g = fn() { f() }; try { g() } catch (e) { e.value }", "3"},
+		{"let f = fn() { try { throw 1; } catch (e) { return 2; } 3 }; f()", "2"},
+		{"let f = fn() { try { return 1; } catch (e) { 2 } }; f()", "1"},
+		{"let f = fn() { 1 + true }; let g = fn() { try { return f(); } catch (e) { 9 } }; g()", "9"},
+		{"try { try { throw 1; } catch (e) { e.value + 1 } } catch (e) { 10 }", "2"},
+		{"try { try { throw 1; } catch (e) { throw e.value + 1; } } catch (e) { e.value }", "2"},
+		{"try { try { throw 1; } catch (e) { throw e; } } catch (e) { e.value }", "1"},
+		{"try { try { foobar } catch (e) { throw e; } } catch (e) { e.kind }", `"NameError"`},
+		// the catch clause has a scope of its own.
+		{"let e = 1; try { throw 2; } catch (e) { 0 }; e", "1"},
+		{"let f = fn() { let e = 5; try { throw 1 } catch (e) { 0 }; e }; f()", "5"},
+		{"try { throw 1; } catch (e) { let x = 2; x }; x", "ERROR: identifier not found: x"},
+		{"let x = 1; try { throw 2; } catch (e) { let x = e.value; x } + x", "3"},
+		{"let f = fn() { let x = 5; try { throw 1 } catch (e) { let x = x + 1; x } }; f()", "6"},
+		{"let x = 5; try { throw 1 } catch (e) { let x = x + e.value; x } + x", "11"},
+		{"let f = try { throw 4; } catch (e) { fn() { e.value } }; f()", "4"},
+		{"let f = fn() { let e = 1; try { throw 2; } catch (e) { 0 } finally { return e; } }; f()", "1"},
+		{"let f = fn() { let e = 1; try { throw 2; } catch (e) { return e.value; } finally { return e + 10; } }; f()", "11"},
+		{"let f = fn() { try { throw 1; } catch (e) { fn g() { e.value } g() } }; f()", "1"},
+		{"try { throw 1; } catch (e) { e.nothing }", "ERROR: error has no member nothing"},
+		{"try { throw 1; } catch (e) { 1 + true }", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"throw 1; 2", "ERROR: 1"},
+		{"let f = fn() { throw 1; 2 }; f(); 3", "ERROR: 1"},
+		{`let e = try { throw "a"; } catch (e) { e }; e == e`, "true"},
+	}
+
+	for _, tt := range tests {
+		have := testEval(t, tt.input)
+		if inspect(have) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, inspect(have))
+		}
+	}
+}
+
+func TestFinally(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { 1 } finally { 2 }", "1"},
+		{"try { 1 } finally { let ran = 2; }; ran", "2"},
+		{"try { throw 1; } catch (e) { 2 } finally { let ran = 3; }; ran", "3"},
+		{"try { 1 } catch (e) { 2 } finally { 3 }", "1"},
+		{"try { throw 1; } catch (e) { 2 } finally { 3 }", "2"},
+		{"try { throw 1; } finally { 2 }", "ERROR: 1"},
+		{"try { throw 1; } catch (e) { throw 2; } finally { 3 }", "ERROR: 2"},
+		{"try { throw 1; } finally { throw 2; }", "ERROR: 2"},
+		{"try { 1 } finally { 1 + true }", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn() { try { return 1; } finally { return 2; } }; f()", "2"},
+		{"let f = fn() { try { throw 1; } finally { return 2; } }; f()", "2"},
+		{"let f = fn() { try { throw 1; } catch (e) { return 2; } finally { return 3; } }; f()", "3"},
+		{"let f = fn() { try { try { return 1; } finally { return 2; } } finally { return 3; } }; f()", "3"},
+		{"let f = fn() { try { try { throw 1; } finally { 2 } } catch (e) { e.value + 10 } }; f()", "11"},
+		{"let f = fn() { try { try { return 1; } finally { throw 2; } } catch (e) { e.value + 20 } }; f()", "22"},
+		{"let f = fn() { try { try { return 1; } catch (e) { 5 } finally { throw 2; } } catch (e) { e.value + 30 } }; f()", "32"},
+		{"let f = fn(x) { try { return x; } finally { x } }; f(1) + f(2)", "3"},
+		{"let f = fn() { 1 + true }; let g = fn() { try { return f(); } finally { return 5; } }; g()", "5"},
+	}
+
+	for _, tt := range tests {
+		have := testEval(t, tt.input)
+		if inspect(have) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, inspect(have))
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (1) { 1 => 10, _ => 20 }", "10"},
+		{"match (2) { 1 => 10, _ => 20 }", "20"},
+		{"match (-3) { 3 => 1, -3 => 2 }", "2"},
+		{`match ("b") { "a" => 1, "b" => 2 }`, "2"},
+		{"match (1 < 2) { true => 1, false => 2 }", "1"},
+		{`match ("1") { 1 => "integer", "1" => "string" }`, `"string"`},
+		{"match (true) { 1 => 1, _ => 2 }", "2"},
+		{"match (5) { n => n * 2 }", "10"},
+		// each arm has a scope of its own.
+		{"match (5) { n => n }; n", "ERROR: identifier not found: n"},
+		{"let a = 9; match ([1, 2]) { [a, 3] => 0, _ => a }", "9"},
+		{"let n = 9; match (5) { n if n > 100 => 0, _ => n }", "9"},
+		{"let f = fn() { let n = 9; match (5) { n if n > 100 => 0, _ => n } }; f()", "9"},
+		{"match (1) { x => if (true) { let y = x + 1; y } }; y", "ERROR: identifier not found: y"},
+		{"let y = 1; match (2) { x => if (true) { let y = x + 1; y } } + y", "4"},
+		{"let x = 5; match (1) { 1 => if (true) { let x = x + 1; x } }", "6"},
+		{"let f = fn() { let x = 5; match (1) { n => if (true) { let x = x + n; x } } + x }; f()", "11"},
+		{"let f = match (3) { x => fn() { x } }; f()", "3"},
+		{"let f = fn(x) { match (x) { 0 => 0, n => g(n - 1) } }; let g = fn(x) { f(x) }; f(3)", "0"},
+		{"let f = fn() { try { throw 1; } catch (e) { match (e.value) { v if v > 0 => e.value + v } } }; f()", "2"},
+		{"match (5) { 1 => 1, }", "ERROR: no pattern matches 5"},
+		{"match (5) { }", "ERROR: no pattern matches 5"},
+		{`match ("x") { n if n == "y" => 1 }`, `ERROR: no pattern matches "x"`},
+		{"match (5) { n if n > 10 => 1, n if n > 1 => 2, _ => 3 }", "2"},
+		{"match (0) { n if n > 10 => 1, n if n > 1 => 2, _ => 3 }", "3"},
+		{"match (5) { n if n + true => 1 }", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"match (foobar) { _ => 1 }", "ERROR: identifier not found: foobar"},
+		{"match (1) { 1 => 1 + true }", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"match (1) { 1 => match (2) { 2 => 3 } }", "3"},
+		{"1 + match (1) { 1 => 2 } * 3", "7"},
+		{"let f = fn(x) { match (x) { 0 => 1, _ => x * f(x - 1) } }; f(5)", "120"},
+		{"let f = fn(x) { match (x) { 0 => if (true) { return 1; }, _ => 2 }; 3 }; f(0) + f(1)", "4"},
+		{"let f = fn(x) { match (x) { 0 => fn() { 1 }, _ => fn() { 2 } }() }; f(0) + f(1)", "3"},
+		{"try { match (1) { 2 => 3 } } catch (e) { e.kind }", `"MatchError"`},
+		{"let fib = fn(n) { match (n) { 0 => 0, 1 => 1, _ => fib(n - 1) + fib(n - 2) } }; fib(15)", "610"},
+	}
+
+	for _, tt := range tests {
+		have := testEval(t, tt.input)
+		if inspect(have) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, inspect(have))
+		}
+	}
+}
+
+func TestArraysAndHashes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[]", "[]"},
+		{"[1, 2 * 2, 3 + 3]", "[1, 4, 6]"},
+		{"let xs = [1, [2, 3]]; xs[1][0] + xs[0]", "3"},
+		{"[1, 2][2]", "null"},
+		{"[1, 2][-1]", "null"},
+		{`[1, 2]["a"]`, "ERROR: index operator not supported: ARRAY[STRING]"},
+		{"1[0]", "ERROR: index operator not supported: INTEGER[INTEGER]"},
+		{"[1, 1 + true]", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"{}", "{}"},
+		{`{name: "Ann", "age": 31, 1: true, false: 0}`, `{"name": "Ann", "age": 31, 1: true, false: 0}`},
+		{`let h = {name: "Ann"}; h["name"]`, `"Ann"`},
+		{`let key = "b"; {a: 1, b: 2}[key]`, "2"},
+		{"{a: 1, a: 2}", `{"a": 2}`},
+		{`{1: "x"}["1"]`, "null"},
+		{"{a: 1}[[]]", "ERROR: unusable as hash key: ARRAY"},
+		{"{[1]: 2}", "ERROR: unusable as hash key: ARRAY"},
+		{"[1, [2]] == [1, [2]]", "true"},
+		{"[1, 2] == [2, 1]", "false"},
+		{"{a: 1, b: 2} == {b: 2, a: 1}", "true"},
+		{"{a: 1} != {a: 2}", "true"},
+		{"let f = fn() { [1, 2] }; f()[1]", "2"},
+	}
+
+	for _, tt := range tests {
+		have := testEval(t, tt.input)
+		if inspect(have) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, inspect(have))
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = [1, 2]; a + b", "3"},
+		{"let [a, b, ...rest] = [1, 2, 3, 4]; rest", "[3, 4]"},
+		{"let [a, ...rest] = [1]; rest", "[]"},
+		{"let [...all] = []; all", "[]"},
+		{"let [_, b, ..._] = [1, 2, 3]; b", "2"},
+		{"let [x = 0, y = x + 1] = []; [x, y]", "[0, 1]"},
+		{"let [x = 0, y = 5] = [7]; [x, y]", "[7, 5]"},
+		{"let [a, [b, c]] = [1, [2, 3]]; a + b + c", "6"},
+		{`let {name, age: years} = {name: "Ann", age: 31}; [name, years]`, `["Ann", 31]`},
+		{`let {name, age = 0} = {name: "Ann"}; age`, "0"},
+		{`let {pos: [x, y], tags: {first}} = {pos: [1, 2], tags: {first: "a"}, other: 1}; [x, y, first]`, `[1, 2, "a"]`},
+		{"let divmod = fn(a, b) { [a / b, a - a / b * b] }; let [q, r] = divmod(7, 2); q * 10 + r", "31"},
+		{"let f = fn(p) { let {x, y} = p; x * y }; f({x: 3, y: 4})", "12"},
+		{"let [a, 1] = [0, 1]; a", "0"},
+		{"let [a, b] = [1];", "ERROR: value [1] does not match the pattern [a, b]"},
+		{"let [a] = [1, 2];", "ERROR: value [1, 2] does not match the pattern [a]"},
+		{"let [a] = 5;", "ERROR: value 5 does not match the pattern [a]"},
+		{"let {name} = {age: 1};", `ERROR: value {"age": 1} does not match the pattern {name}`},
+		{"let {name} = [1];", "ERROR: value [1] does not match the pattern {name}"},
+		{"let [x, [y, z]] = [1, [2]];", "ERROR: value [2] does not match the pattern [y, z]"},
+		{"let [a, 1] = [0, 2];", "ERROR: value 2 does not match the pattern 1"},
+		{"let [a = 1 + true] = [];", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"let [a = 1 + true] = [2]; a", "2"},
+		{"try { let [a] = []; } catch (e) { e.kind }", `"MatchError"`},
+		{"match ([1, 2]) { [] => 0, [x] => x, [x, y] => x + y }", "3"},
+		{"match ([1, 2, 3]) { [x, ...rest] => rest }", "[2, 3]"},
+		{"match ([1]) { [x, y = 10] => x + y }", "11"},
+		{"match ([0, [1]]) { [0, [2]] => 1, [0, [x]] => x + 10 }", "11"},
+		{`match ({kind: "circle", r: 2}) { {kind: "square", side} => side, {kind: "circle", r} => r * 3 }`, "6"},
+		{"match (5) { [x] => x, {x} => x, _ => 0 }", "0"},
+		{"match ([1, 2]) { [x, y] if x > y => 1, [x, y] => 2 }", "2"},
+		{"match ([1]) { [x, y] => 1 }", "ERROR: no pattern matches [1]"},
+	}
+
+	for _, tt := range tests {
+		have := testEval(t, tt.input)
+		if inspect(have) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, inspect(have))
+		}
+	}
+}
+
+func TestMatchTailCalls(t *testing.T) {
+	input := "let count = fn(n) { match (n) { 0 => 0, _ => count(n - 1) } }; count(100000)"
+
+	have := EvalWithOptions(parser.New(lexer.New(input)).ParseProgram(), objects.NewEnvironment(), Options{MaxDepth: 100})
+	if inspect(have) != "0" {
+		t.Errorf("wrong result. want=0, have=%s", inspect(have))
+	}
+}
+
+func TestLimitsAreNotCaught(t *testing.T) {
+	input := "let f = fn(n) { f(n + 1) }; try { f(0) } catch (e) { 1 } finally { 2 }"
+
+	have := EvalWithOptions(parser.New(lexer.New(input)).ParseProgram(), objects.NewEnvironment(), Options{MaxSteps: 1000})
+
+	err, ok := have.(*objects.Error)
+	if !ok || err.Kind != objects.LimitError || err.Value != "step budget of 1000 exceeded" {
+		t.Errorf("step budget caught. have=%s", inspect(have))
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"hello"`, `"hello"`},
+		{`"hello" + " " + "world"`, `"hello world"`},
+		{`"a" == "a"`, "true"},
+		{`"a" != "b"`, "true"},
+		{`"a" == 1`, "false"},
+		{`"a" - "b"`, "ERROR: unknown operator: STRING - STRING"},
+		{`"a" + 1`, "ERROR: type mismatch: STRING + INTEGER"},
+		{`let f = fn(s) { s + "!" }; f("hi")`, `"hi!"`},
+	}
+
+	for _, tt := range tests {
+		have := testEval(t, tt.input)
+		if inspect(have) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, inspect(have))
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -156,7 +1159,7 @@ func TestReturnStatements(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		have := testEval(tt.input)
+		have := testEval(t, tt.input)
 		testIntegerObject(t, have, tt.expected)
 	}
 }
@@ -176,7 +1179,7 @@ func TestIfElseExpressions(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		have := testEval(tt.input)
+		have := testEval(t, tt.input)
 		i, ok := tt.expected.(int)
 		if ok {
 			testIntegerObject(t, have, int64(i))
@@ -200,7 +1203,7 @@ func TestBangOperator(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		have := testEval(tt.input)
+		have := testEval(t, tt.input)
 		testBooleanObject(t, have, tt.expected)
 	}
 }
@@ -232,7 +1235,7 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		have := testEval(tt.input)
+		have := testEval(t, tt.input)
 		testBooleanObject(t, have, tt.expected)
 	}
 }
@@ -260,18 +1263,82 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		have := testEval(tt.input)
+		have := testEval(t, tt.input)
 		testIntegerObject(t, have, tt.expected)
 	}
 }
 
-func testEval(input string) objects.Object {
+// testEval evaluates input and checks that the virtual machine
+// produces the same result.
+func testEval(t *testing.T, input string) objects.Object {
+	t.Helper()
+	return testEvalWithOptions(t, input, Options{})
+}
+
+// testEvalWithOptions evaluates input with opts and checks that the
+// virtual machine, limited to the same depth of calls, agrees.
+func testEvalWithOptions(t *testing.T, input string, opts Options) objects.Object {
+	t.Helper()
+
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
 	env := objects.NewEnvironment()
 
-	return Eval(program, env)
+	have := EvalWithOptions(program, env, opts)
+
+	compared := testRun(t, input, opts.MaxDepth)
+	if !sameObject(have, compared) {
+		t.Errorf("engines disagree for %q. eval=%s, vm=%s", input, inspect(have), inspect(compared))
+	}
+
+	return have
+}
+
+// testRun compiles input and runs it on the virtual machine,
+// a maxDepth of zero keeps the default maximum depth of calls.
+func testRun(t *testing.T, input string, maxDepth int) objects.Object {
+	t.Helper()
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := vm.New(c.Bytecode())
+	if maxDepth > 0 {
+		machine.SetMaxDepth(maxDepth)
+	}
+	if err := machine.Run(); err != nil {
+		if e, ok := err.(*objects.Error); ok {
+			return e
+		}
+		return &objects.Error{Value: err.Error()}
+	}
+
+	return machine.LastPopped()
+}
+
+// sameObject reports whether the engines agree on a value,
+// errors must also agree on their kinds and lines.
+func sameObject(a, b objects.Object) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if ae, ok := a.(*objects.Error); ok {
+		be, ok := b.(*objects.Error)
+		return ok && ae.Value == be.Value && ae.Kind == be.Kind && ae.Line == be.Line
+	}
+	return a.Type() == b.Type() && a.Inspect() == b.Inspect()
+}
+
+func inspect(o objects.Object) string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.Inspect()
 }
 
 func testIntegerObject(t *testing.T, obj objects.Object, expected int64) bool {
@@ -305,7 +1372,7 @@ func testBooleanObject(t *testing.T, obj objects.Object, expected bool) bool {
 }
 
 func testNullObject(t *testing.T, o objects.Object) bool {
-	if o != NULL {
+	if o != objects.NewNull() {
 		t.Errorf("object is not NULL. have=%T (%+v)", o, o)
 		return false
 	}
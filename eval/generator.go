@@ -0,0 +1,219 @@
+package eval
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/sched"
+)
+
+// generator is the iterator returned by a call of a generator function.
+// Its body is evaluated on demand by a goroutine of its own, which runs
+// while the generator is resumed by next and waits otherwise, so only
+// one of the caller and the goroutine runs at any time.
+//
+// The goroutine of a generator is stopped when the evaluation that
+// created it returns, or once the lifetime of the generators set by
+// its Options is done. The generator is done from then on. A generator
+// that is not resumed anymore may be stopped earlier, once it is
+// unreachable, unless the environment of its body refers to it.
+type generator struct {
+	*coroutine
+}
+
+// coroutine is the state of a generator shared with its goroutine, which
+// does not refer to the generator so that it can become unreachable.
+type coroutine struct {
+	fn      *objects.Function
+	env     *objects.Environment
+	missing []int // the parameters whose defaults are evaluated first.
+
+	e    *evaluator      // evaluates the body.
+	life context.Context // done once the generator is stopped.
+
+	// only used by the callers of next.
+	started bool
+	running bool
+	done    bool
+
+	resume chan objects.Object // the argument of next.
+	out    chan yielded
+	stop   chan struct{} // closed once the generator is unreachable.
+	exited chan struct{} // closed when the goroutine exits.
+	once   sync.Once
+}
+
+// yielded is a value yielded or returned by the body of a generator.
+type yielded struct {
+	value objects.Object
+	done  bool
+}
+
+// stopped is panicked by a yield expression of a generator that will
+// not be resumed anymore, it unwinds its goroutine without evaluating
+// any more of the script, e.g. the finally clauses it is in.
+type stopped struct{}
+
+// newGenerator returns a generator running the body of fn in env, the
+// environment of the call with the arguments bound, missing holds the
+// parameters whose default values are evaluated when it is started.
+func (e *evaluator) newGenerator(fn *objects.Function, env *objects.Environment, missing []int) *generator {
+	c := &coroutine{
+		fn:      fn,
+		env:     env,
+		missing: missing,
+		life:    e.life,
+		resume:  make(chan objects.Object),
+		out:     make(chan yielded),
+		stop:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	c.e = &evaluator{coroutine: c}
+
+	g := &generator{c}
+	runtime.SetFinalizer(g, (*generator).close)
+
+	return g
+}
+
+// close stops the goroutine of g if it waits to be resumed.
+func (g *generator) close() {
+	g.once.Do(func() { close(g.stop) })
+}
+
+// implement Object interface
+func (g *generator) Type() objects.Type { return objects.GENERATOR }
+func (g *generator) Equals(other objects.Object) bool {
+	o, ok := other.(*generator)
+	return ok && o == g
+}
+func (g *generator) Inspect() string {
+	if g.fn.Name == "" {
+		return "generator"
+	}
+	return "generator " + g.fn.Name
+}
+
+// callMethod calls the method m with args, the last of which are named by names.
+func (e *evaluator) callMethod(m *objects.Method, args []objects.Object, names []string) objects.Object {
+	var g *generator
+	switch r := m.Receiver.(type) {
+	case *generator:
+		g = r
+	case *sched.Channel:
+		return e.callChannel(r, m.Name, args, names)
+	default:
+		return objects.Errorf(objects.TypeError, "not a function: %s", m.Type())
+	}
+
+	slots := make([]objects.Object, 1)
+	if err := objects.BindArguments(m.Name, slots, []string{"value"}, 1, "", args, names); err != nil {
+		return err
+	}
+	if slots[0] == nil {
+		slots[0] = objects.NewNull()
+	}
+
+	return e.resume(g, slots[0])
+}
+
+// resume evaluates the body of g until it yields or returns, arg is the
+// value of the yield expression it was suspended at. It returns the
+// iteration {value: v, done: d} or the error the body failed with.
+func (e *evaluator) resume(g *generator, arg objects.Object) objects.Object {
+	defer runtime.KeepAlive(g)
+
+	c := g.coroutine
+	if c.life.Err() != nil {
+		// stopped at the end of its lifetime.
+		c.done = true
+	}
+
+	switch {
+	case c.done:
+		return e.allocated(objects.NewIteration(objects.NewNull(), true))
+	case c.running:
+		return objects.Errorf(objects.TypeError, "generator is already running")
+	}
+
+	c.running = true
+	defer func() { c.running = false }()
+
+	c.e.budget, c.e.task = e.budget, e.task
+	c.e.base = e.base + e.depth
+
+	if !c.started {
+		c.started = true
+		go c.run()
+	} else {
+		select {
+		case c.resume <- arg:
+		case <-c.exited:
+			// stopped meanwhile.
+			c.done = true
+			return e.allocated(objects.NewIteration(objects.NewNull(), true))
+		}
+	}
+
+	var y yielded
+	select {
+	case y = <-c.out:
+	case <-c.exited:
+		// its body was stopped along with the task resuming it.
+		panic(stopped{})
+	}
+
+	// the values allocated by the body may be kept by its environment.
+	e.closures++
+
+	if y.done {
+		c.done = true
+	}
+	if isError(y.value) {
+		return y.value
+	}
+
+	return e.allocated(objects.NewIteration(y.value, y.done))
+}
+
+// run evaluates the body of the generator in its goroutine.
+func (c *coroutine) run() {
+	defer close(c.exited)
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(stopped); !ok {
+				panic(r)
+			}
+		}
+	}()
+
+	e := c.e
+	if err := e.bindDefaults(c.fn, c.env, c.missing); err != nil {
+		c.out <- yielded{value: err, done: true}
+		return
+	}
+
+	val := unwrapreturnValue(e.evalTailBlock(c.fn.Body, c.env))
+	if call, ok := val.(*tailCall); ok {
+		val = e.applyTail(call)
+	}
+
+	c.out <- yielded{value: val, done: true}
+}
+
+// yield hands val to the caller of next and waits until the generator
+// is resumed, it returns the argument of the next call of next.
+func (c *coroutine) yield(val objects.Object) objects.Object {
+	c.out <- yielded{value: val}
+
+	select {
+	case arg := <-c.resume:
+		return arg
+	case <-c.stop:
+	case <-c.life.Done():
+	}
+
+	panic(stopped{})
+}
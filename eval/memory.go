@@ -0,0 +1,88 @@
+package eval
+
+import (
+	"unsafe"
+
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/sched"
+)
+
+// The approximate sizes in bytes of the values and
+// environments allocated by an evaluation.
+const (
+	integerSize     = int64(unsafe.Sizeof(objects.Integer{}))
+	stringSize      = int64(unsafe.Sizeof(objects.String{}))
+	arraySize       = int64(unsafe.Sizeof(objects.Array{}))
+	hashSize        = int64(unsafe.Sizeof(objects.Hash{}))
+	functionSize    = int64(unsafe.Sizeof(objects.Function{}))
+	environmentSize = int64(unsafe.Sizeof(objects.Environment{}))
+	slotSize        = int64(unsafe.Sizeof(objects.Object(nil)))
+	channelSize     = int64(unsafe.Sizeof(sched.Channel{}))
+
+	// bindingSize is the size of a name bound in the map of an
+	// environment, a string header, a value and the map overhead.
+	bindingSize = int64(unsafe.Sizeof("")) + slotSize + 16
+
+	// pairSize is the size of a pair of a hash, its key in the map,
+	// the index of the pair, the key and the value.
+	pairSize = int64(unsafe.Sizeof(objects.HashKey{})) + 8 + 2*slotSize
+)
+
+// Usage reports the resources used by an evaluation, it is written
+// by the evaluation when it returns.
+type Usage struct {
+	Steps       int
+	Allocations int
+
+	// Memory is the approximate number of bytes of the values and
+	// environments that are reachable at the end of the evaluation,
+	// PeakMemory the maximum during the evaluation.
+	Memory     int64
+	PeakMemory int64
+}
+
+// sizeOf returns the size of the values allocated by the evaluator.
+func sizeOf(o objects.Object) int64 {
+	switch o := o.(type) {
+	case *objects.Integer:
+		return integerSize
+	case *objects.String:
+		return stringSize + int64(len(o.Value))
+	case *objects.Array:
+		return arraySize + int64(len(o.Elements))*slotSize
+	case *objects.Hash:
+		return hashSize + int64(o.Len())*pairSize
+	case *objects.Function:
+		return functionSize
+	default:
+		return 0
+	}
+}
+
+// scalar reports whether o refers to no other values.
+func scalar(o objects.Object) bool {
+	switch o.(type) {
+	case nil, *objects.Integer, *objects.String, *objects.Boolean, *objects.Null:
+		return true
+	default:
+		return false
+	}
+}
+
+// environmentSizeOf returns the size of the environment of a call of fn.
+func environmentSizeOf(fn *objects.Function) int64 {
+	if fn.Locals == nil {
+		params := len(fn.Parameters)
+		if fn.Rest != nil {
+			params++
+		}
+		return environmentSize + int64(params)*bindingSize
+	}
+	return environmentSize + int64(len(fn.Locals))*slotSize
+}
+
+// scopeSize returns the size of the environment of a scope with
+// the given resolved locals, its bindings are charged once bound.
+func scopeSize(locals []string) int64 {
+	return environmentSize + int64(len(locals))*slotSize
+}
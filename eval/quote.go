@@ -0,0 +1,87 @@
+package eval
+
+import (
+	"strconv"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/token"
+)
+
+// evalQuote returns the quoted node, the calls of unquote in it are
+// replaced by the value of their argument converted back to an ast.
+// The node is copied, so that the program is left unchanged.
+func (e *evaluator) evalQuote(call *ast.CallExpression, env *objects.Environment) objects.Object {
+	if len(call.Arguments) != 1 {
+		return objects.Errorf(objects.ArgumentError, "wrong number of arguments to quote: want=1, have=%d", len(call.Arguments))
+	}
+
+	node := ast.Clone(call.Arguments[0])
+
+	unquotes := map[ast.Node]bool{}
+	for _, u := range ast.Unquotes(node) {
+		unquotes[u] = true
+	}
+
+	var err objects.Object
+	node = ast.Modify(node, func(n ast.Node) ast.Node {
+		if !unquotes[n] || err != nil {
+			return n
+		}
+
+		u := n.(*ast.CallExpression)
+		if len(u.Arguments) != 1 {
+			err = objects.Errorf(objects.ArgumentError, "wrong number of arguments to unquote: want=1, have=%d", len(u.Arguments))
+			return n
+		}
+
+		val := e.eval(u.Arguments[0], env)
+		if isError(val) {
+			err = val
+			return n
+		}
+
+		converted, ok := toNode(val, ast.Pos(u))
+		if !ok {
+			err = objects.Errorf(objects.TypeError, "unquote: can not convert %s to an expression", typeOf(val))
+			return n
+		}
+
+		return converted
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return &objects.Quote{Node: node}
+}
+
+// toNode converts an object to the ast of an expression that evaluates to it.
+func toNode(o objects.Object, pos token.Position) (ast.Node, bool) {
+	switch o := o.(type) {
+	case *objects.Integer:
+		return &ast.IntegerLiteral{
+			Token: token.Token{Typ: token.INTEGER, Literal: strconv.FormatInt(o.Value, 10), Pos: pos},
+			Value: int(o.Value),
+		}, true
+	case *objects.Boolean:
+		return ast.NewBoolean(o.Value, pos), true
+	case *objects.String:
+		return &ast.StringLiteral{
+			Token: token.Token{Typ: token.STRING, Literal: o.Value, Pos: pos},
+			Value: o.Value,
+		}, true
+	case *objects.Quote:
+		return ast.Clone(o.Node), true
+	default:
+		return nil, false
+	}
+}
+
+func typeOf(o objects.Object) objects.Type {
+	if o == nil {
+		return "nothing"
+	}
+	return o.Type()
+}
@@ -0,0 +1,83 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/Despire/interpreter/lexer"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/parser"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(5)", "QUOTE(5)"},
+		{"quote(5 + 8)", "QUOTE((5 + 8))"},
+		{"quote(foobar)", "QUOTE(foobar)"},
+		{"quote(foobar + barfoo)", "QUOTE((foobar + barfoo))"},
+		{"quote(unquote(4))", "QUOTE(4)"},
+		{"quote(unquote(4 + 4))", "QUOTE(8)"},
+		{"quote(8 + unquote(4 + 4))", "QUOTE((8 + 8))"},
+		{"quote(unquote(4 + 4) + 8)", "QUOTE((8 + 8))"},
+		{"let foobar = 8; quote(foobar)", "QUOTE(foobar)"},
+		{"let foobar = 8; quote(unquote(foobar))", "QUOTE(8)"},
+		{"quote(unquote(true))", "QUOTE(true)"},
+		{"quote(unquote(true == false))", "QUOTE(false)"},
+		{"quote(unquote(quote(4 + 4)))", "QUOTE((4 + 4))"},
+		{"let q = quote(4 + 4); quote(unquote(4 + 4) + unquote(q))", "QUOTE((8 + (4 + 4)))"},
+		{"quote(quote(unquote(x)))", "QUOTE(quote(unquote(x)))"},
+		{"let x = 2; quote(quote(unquote(unquote(x))))", "QUOTE(quote(unquote(2)))"},
+		{"let f = fn(x) { quote(unquote(x) + 1) }; f(1); f(2)", "QUOTE((2 + 1))"},
+	}
+
+	for _, tt := range tests {
+		have := testQuote(tt.input)
+
+		if _, ok := have.(*objects.Quote); !ok {
+			t.Errorf("expected a quote for %q. have=%T (%s)", tt.input, have, inspect(have))
+			continue
+		}
+
+		if have.Inspect() != tt.expected {
+			t.Errorf("wrong quote for %q. want=%s, have=%s", tt.input, tt.expected, have.Inspect())
+		}
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote()", "wrong number of arguments to quote: want=1, have=0"},
+		{"quote(1, 2)", "wrong number of arguments to quote: want=1, have=2"},
+		{"quote(unquote(1, 2))", "wrong number of arguments to unquote: want=1, have=2"},
+		{"unquote(1)", "unquote outside of quote"},
+		{"quote(unquote(fn() {}))", "unquote: can not convert FUNCTION to an expression"},
+		{"quote(unquote(x))", "identifier not found: x"},
+		{"macro(x) { x }", "macros must be bound by a top-level let statement"},
+	}
+
+	for _, tt := range tests {
+		have := testQuote(tt.input)
+
+		err, ok := have.(*objects.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. have=%T (%s)", tt.input, have, inspect(have))
+			continue
+		}
+
+		if err.Value != tt.expected {
+			t.Errorf("wrong error message for %q. want=%q, have=%q", tt.input, tt.expected, err.Value)
+		}
+	}
+}
+
+// testQuote evaluates input with the evaluator only,
+// the virtual machine does not support quotes.
+func testQuote(input string) objects.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	return Eval(program, objects.NewEnvironment())
+}
@@ -0,0 +1,121 @@
+package eval
+
+import (
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/sched"
+)
+
+// spawn starts a task applying fn to args, the last of which are named
+// by names. The task is evaluated by an evaluator of its own sharing
+// the budget of e, its errors are raised in the main task.
+func (e *evaluator) spawn(fn objects.Object, args []objects.Object, names []string) {
+	b := e.budget
+	e.task.Spawn(func(t *sched.Task) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(stopped); !ok {
+					panic(r)
+				}
+				err = sched.ErrStopped
+			}
+		}()
+
+		val := (&evaluator{budget: b, task: t}).applyFunction(fn, args, names)
+		if err, ok := val.(*objects.Error); ok {
+			return err
+		}
+		return nil
+	})
+
+	// the task may keep the values allocated by the spawner.
+	e.closures++
+}
+
+// callChannel calls the method name of the channel c with args, the
+// last of which are named by names.
+func (e *evaluator) callChannel(c *sched.Channel, name string, args []objects.Object, names []string) objects.Object {
+	val, err := e.task.Call(c, name, args, names)
+	if err := e.blocked(err); err != nil {
+		return err
+	}
+	return val
+}
+
+// blocked returns the error of a channel operation, which may have
+// passed the turn to other tasks. A task stopped meanwhile is unwound,
+// the stopped tasks run concurrently and must not touch the budget.
+func (e *evaluator) blocked(err error) *objects.Error {
+	if err == sched.ErrStopped {
+		panic(stopped{})
+	}
+
+	// the other tasks may keep the values allocated meanwhile.
+	e.closures++
+
+	if err != nil {
+		return err.(*objects.Error)
+	}
+	return nil
+}
+
+// selectArm performs the operation of the first arm of a select
+// expression that can proceed and returns the arm. The value received
+// is bound in env to the name of the arm, like those of a let statement.
+func (e *evaluator) selectArm(node *ast.SelectExpression, env *objects.Environment) (*ast.SelectArm, objects.Object) {
+	var (
+		arms  []*ast.SelectArm
+		cases []sched.Case
+		def   *ast.SelectArm
+	)
+
+	for _, arm := range node.Arms {
+		if arm.IsDefault() {
+			def = arm
+			continue
+		}
+
+		channel := e.eval(arm.Channel, env)
+		if isError(channel) {
+			return nil, channel
+		}
+
+		c := sched.Case{}
+		if arm.Value != nil {
+			if c.Value = e.eval(arm.Value, env); isError(c.Value) {
+				return nil, c.Value
+			}
+			c.Send = true
+		}
+
+		ch, ok := channel.(*sched.Channel)
+		if !ok {
+			return nil, objects.Errorf(objects.TypeError, "not a channel: %s", channel.Type())
+		}
+		c.Channel = ch
+
+		arms = append(arms, arm)
+		cases = append(cases, c)
+	}
+
+	i, val, err := e.task.Select(cases, def == nil)
+	if err := e.blocked(err); err != nil {
+		// the error is annotated here, it may be returned from a tail position.
+		if err.Line == 0 {
+			err.Line = node.Token.Pos.Line
+		}
+		return nil, err
+	}
+
+	if i < 0 {
+		return def, nil
+	}
+
+	arm := arms[i]
+	if arm.Binding != nil && arm.Binding.Value != ast.Wildcard {
+		if err := e.bind(arm.Binding, val, env); err != nil {
+			return nil, err
+		}
+	}
+	return arm, nil
+}
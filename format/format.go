@@ -0,0 +1,63 @@
+// Package format implements the canonical formatting of source code.
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/lexer"
+	"github.com/Despire/interpreter/parser"
+)
+
+// Source parses src and returns it in the canonical style.
+// If src contains syntax errors, an error is returned
+// and src is left untouched.
+func Source(src []byte) ([]byte, error) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	buff := new(bytes.Buffer)
+	if err := Node(buff, program); err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
+
+// Node writes the canonical source of node to w.
+// If node is an *ast.Program its comments are preserved.
+func Node(w io.Writer, node ast.Node) error {
+	p := new(printer)
+
+	switch node := node.(type) {
+	case *ast.Program:
+		p.comments = node.Comments
+		p.statements(node.Statement, eof)
+	case ast.Statement:
+		p.statement(node)
+	case ast.Expression:
+		p.expression(node)
+	default:
+		return fmt.Errorf("format: unsupported node %T", node)
+	}
+
+	_, err := io.WriteString(w, p.out.String())
+	return err
+}
+
+// String returns the canonical source of node.
+func String(node ast.Node) string {
+	buff := new(strings.Builder)
+
+	// writing into a strings.Builder never fails.
+	Node(buff, node)
+
+	return buff.String()
+}
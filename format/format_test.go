@@ -0,0 +1,240 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/Despire/interpreter/lexer"
+	"github.com/Despire/interpreter/parser"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let a=5",
+			"let a = 5;\n",
+		},
+		{
+			"a+b*c;(a+b)*c;a-(b-c);(a-b)-c",
+			"a + b * c;\n(a + b) * c;\na - (b - c);\na - b - c;\n",
+		},
+		{
+			"-(5+5);!-a;-a*b;(3<5)==true;3<(5==true)",
+			"-(5 + 5);\n!-a;\n-a * b;\n3 < 5 == true;\n3 < (5 == true);\n",
+		},
+		{
+			"add(a,b,1,2*3,add(6,7*8))",
+			"add(a, b, 1, 2 * 3, add(6, 7 * 8));\n",
+		},
+		{
+			`import   "lib/math.mk"  as math;export let a=-math.f(1) .b`,
+			"import \"lib/math.mk\" as math;\nexport let a = -math.f(1).b;\n",
+		},
+		{
+			"fn add(x,y=1){x+y};add(1);fn f(){}",
+			"fn add(x, y = 1) {\n    x + y;\n}\nadd(1);\nfn f() {}\n",
+		},
+		{
+			"if(x<y){x}else{y}",
+			"if (x < y) {\n    x;\n} else {\n    y;\n};\n",
+		},
+		{
+			"if (x) {}",
+			"if (x) {};\n",
+		},
+		{
+			"let add = fn(x,y){ return x+y; }; add(1, 2)",
+			"let add = fn(x, y) {\n    return x + y;\n};\nadd(1, 2);\n",
+		},
+		{
+			"fn(x){fn(y){x+y}}(1)(2)",
+			"fn(x) {\n    fn(y) {\n        x + y;\n    };\n}(1)(2);\n",
+		},
+		{
+			"let a = 010;",
+			"let a = 8;\n",
+		},
+		{
+			"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;",
+			"let a = 1;\n\nlet b = 2;\nlet c = 3;\n",
+		},
+		{
+			"// leading\nlet a = 1; // trailing\n\n// before b\nlet b = 2;\n// last",
+			"// leading\nlet a = 1; // trailing\n\n// before b\nlet b = 2;\n// last\n",
+		},
+		{
+			"let a = 1; let b = 2; // b",
+			"let a = 1;\nlet b = 2; // b\n",
+		},
+		{
+			"let f = fn() {\n// only a comment\n};",
+			"let f = fn() {\n    // only a comment\n};\n",
+		},
+		{
+			"let f = fn() { // first\n  1\n  // last\n};",
+			"let f = fn() {\n    // first\n    1;\n    // last\n};\n",
+		},
+		{
+			"someVeryLongFunctionName(firstArgumentName, secondArgumentName, thirdArgument + 1)",
+			"someVeryLongFunctionName(\n    firstArgumentName,\n    secondArgumentName,\n    thirdArgument + 1\n);\n",
+		},
+		{
+			`try{throw "bad"}catch(e){e.message}finally{1}`,
+			"try {\n    throw \"bad\";\n} catch (e) {\n    e.message;\n} finally {\n    1;\n};\n",
+		},
+		{
+			"match(x){0=>\"zero\",-1=>\"minus\" // negative\n,n if n>1=>n,_=>0}",
+			"match (x) {\n    0 => \"zero\",\n    -1 => \"minus\", // negative\n    n if n > 1 => n,\n    _ => 0,\n};\n",
+		},
+		{
+			"match (x) {}",
+			"match (x) {};\n",
+		},
+		{
+			`let [a,b=1,...rest]=[1,2*3];let {name,age:years}={name:"Ann","age":31,1:true};xs[0][a]`,
+			"let [a, b = 1, ...rest] = [1, 2 * 3];\nlet {name, age: years} = {name: \"Ann\", \"age\": 31, 1: true};\nxs[0][a];\n",
+		},
+		{
+			"let f=fn(x,y=x*2,...rest){x};f(1,y:2)",
+			"let f = fn(x, y = x * 2, ...rest) {\n    x;\n};\nf(1, y: 2);\n",
+		},
+		{
+			"fn g(x){let y=yield x+1;(yield y)*2}",
+			"fn g(x) {\n    let y = yield x + 1;\n    (yield y) * 2;\n}\n",
+		},
+		{
+			"let c=chan(1);spawn f(c,n:2);select{c.recv() as v=>v,c.send(1)=>2,_=>chan()}",
+			"let c = chan(1);\nspawn f(c, n: 2);\nselect {\n    c.recv() as v => v,\n    c.send(1) => 2,\n    _ => chan(),\n};\n",
+		},
+		{
+			"add(1, // one\n 2)",
+			"add(1, // one\n    2);\n",
+		},
+		{
+			"add(1,\n// one\n2) // add",
+			"add(1,\n    // one\n    2); // add\n",
+		},
+		{
+			"if (x) {1} // c\nelse {2}",
+			"if (x) {\n    1;\n} // c\nelse {\n    2;\n};\n",
+		},
+		{
+			"let f=fn(a, // first\nb){[a, // a\n1]}",
+			"let f = fn(a, // first\n    b) {\n    [a, // a\n        1];\n};\n",
+		},
+		{
+			"let xs=[firstElementName,secondElementName,thirdElementName,fourthElementName]",
+			"let xs = [\n    firstElementName,\n    secondElementName,\n    thirdElementName,\n    fourthElementName\n];\n",
+		},
+	}
+
+	for _, tt := range tests {
+		have, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Source(%q) failed: %v", tt.input, err)
+			continue
+		}
+
+		if string(have) != tt.expected {
+			t.Errorf("Source(%q)\nhave:\n%s\nwant:\n%s", tt.input, have, tt.expected)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	if _, err := Source([]byte("let = 5;")); err == nil {
+		t.Errorf("expected an error for invalid source")
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	inputs := []string{
+		"add(a, b, 1, 2* 3, 4 + 5, add(6, 7 * 8))",
+		"add(a + b + c * d / f + g)",
+		"-(5 + 5)",
+		"!-a",
+		"a + b * c + d / e - f",
+		"5 > 4 == 3 < 4",
+		"3 + 4 * 5 == 3 * 1 + 4 * 5",
+		"- -5; !!true; -(-a); (-a)(b)",
+		"1 + if (x) { 2 } else { 3 } * 4",
+		"-if (x) { 1 } else { 2 } + 3",
+		"if (a) { if (b) { return 1; } 2 } else { 3 }",
+		"let m = macro(a,b) { quote(unquote(a) + unquote(b)) }; m(1, 2)",
+		`import "lib/math.mk" as math; export let sq = fn(x) { math.mul(x, x).y }`,
+		`try { throw "bad"; } catch (e) { e.message } finally { 1 }; 1 + try { 2 } finally { 3 }`,
+		`let [a, {b: [c], d = [1, 2]}, ..._] = x; {k: {v: [1]}, "s": -a[0]}; match (y) { [] => 0, {k, w = 1} => k }`,
+		"let f = fn(x, y = x * 2, ...rest) { rest }; f(1, y: -2)(z: fn(...r) { r })",
+		"fn g(x) { let y = yield x + 1; yield (yield y) * 2; f(yield 1, 1 + yield 2) }",
+		"let c = chan(); spawn f(1)(2); 1 + spawn g(); select {}; let f = fn() {\n  select {\n    // first\n    c.recv() => 1,\n    // default\n    _ => 2\n  }\n}",
+		"fn even(n) { if (n == 0) { true } else { odd(n - 1) } } // even\nfn odd(n) { even(n - 1) }; let g = fn() { fn h() {} h };",
+		"let f = fn(x) {\n  match (x) {\n    // zero\n    0 => 1,\n    n if n > 0 => match (n) { 1 => 2, _ => 3 },\n    // rest\n  }\n}",
+		`
+// a program
+let a = 5;
+let b = 10; // ten
+
+
+let sum = fn(x, y) {
+    // add them
+    x + y;
+}; // sum
+
+let sum_result = sum(a, b);
+let c = if (sum_result < 15) {
+    return true;
+} else {
+    return false; // false
+};
+// done`,
+		`
+let newAdder = fn(x) {
+ fn (y) {x + y };
+};
+
+let addTwo = newAdder(2);
+addTwo(2);`,
+		"aVeryLongFunctionNameForTesting(fn(x) { x }, anotherArgumentName, yetAnotherArgument)",
+		"outer(aVeryLongFunctionNameForTesting(firstArgument, secondArgument, thirdArgumentXy))",
+		"add(1, // one\n 2)",
+		"if (x) {1} // c\nelse {2}",
+		"try { f(x, // x\n  y) } // try\n catch (e) { e } // catch\n finally { 1 }",
+		"let x = 1 + // one\n 2 * // two\n 3; let {a, // a\n b} = h; xs[ // i\n 0]",
+		"someVeryLongFunctionName(firstArgumentName, // first\n secondArgumentName, thirdArgument + 1)",
+	}
+
+	for _, input := range inputs {
+		first, err := Source([]byte(input))
+		if err != nil {
+			t.Errorf("Source(%q) failed: %v", input, err)
+			continue
+		}
+
+		second, err := Source(first)
+		if err != nil {
+			t.Errorf("Source(%q) failed: %v", first, err)
+			continue
+		}
+
+		if string(first) != string(second) {
+			t.Errorf("formatting is not idempotent for %q\nfirst:\n%s\nsecond:\n%s", input, first, second)
+		}
+
+		if have, want := parse(t, string(first)), parse(t, input); have != want {
+			t.Errorf("formatting changed the program %q\nhave: %s\nwant: %s", input, have, want)
+		}
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	for _, err := range p.Errors() {
+		t.Errorf("parser error: %q", err)
+	}
+
+	return program.String()
+}
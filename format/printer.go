@@ -0,0 +1,682 @@
+package format
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/token"
+)
+
+const (
+	// indentation used for each nested block.
+	indentation = "    "
+
+	// maxLineWidth is the width after which call
+	// arguments are broken one per line.
+	maxLineWidth = 80
+)
+
+type precedence int
+
+// mirrors the precedences used by the parser.
+const (
+	lowest precedence = iota
+	equals
+	ltgt
+	sum
+	product
+	prefix
+	call
+)
+
+var precedences = map[string]precedence{
+	token.EQUAL:    equals,
+	token.NEQUAL:   equals,
+	token.LESST:    ltgt,
+	token.GREATERT: ltgt,
+	token.PLUS:     sum,
+	token.MINUS:    sum,
+	token.SLASH:    product,
+	token.ASTERISK: product,
+}
+
+// eof is a position after every other position,
+// used to flush all remaining comments.
+var eof = token.Position{Line: math.MaxInt32}
+
+// printer writes the canonical source of an ast.
+type printer struct {
+	out      bytes.Buffer
+	indent   int
+	column   int            // column in the output.
+	line     int            // last source line that was printed.
+	comments []*ast.Comment // comments that were not printed yet.
+	broken   bool           // the line was broken after a comment.
+}
+
+func (p *printer) write(s string) {
+	if p.broken {
+		s = strings.TrimPrefix(s, " ")
+		p.broken = false
+	}
+
+	p.out.WriteString(s)
+
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		p.column = len(s) - i - 1
+	} else {
+		p.column += len(s)
+	}
+}
+
+// newline ends the current line, dropping its trailing blanks.
+func (p *printer) newline() {
+	b := p.out.Bytes()
+	p.out.Truncate(len(bytes.TrimRight(b, " ")))
+	p.write("\n")
+}
+
+func (p *printer) writeIndent() {
+	p.write(strings.Repeat(indentation, p.indent))
+}
+
+// seen records that the source at pos is printed next. The comments
+// before pos are printed first.
+func (p *printer) seen(pos token.Position) {
+	p.interior(pos, 1)
+
+	if pos.IsValid() && pos.Line > p.line {
+		p.line = pos.Line
+	}
+}
+
+// interior prints the comments before pos that appear within a statement.
+// A comment is kept after the preceding token if it follows it on the same
+// line, otherwise it is printed on its own line. The line is broken after
+// each comment and the statement continues indented by extra levels.
+func (p *printer) interior(pos token.Position, extra int) {
+	for p.hasCommentsBefore(pos) {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+
+		if !p.lineEmpty() {
+			if c.Pos().Line > p.line {
+				p.newline()
+				p.write(strings.Repeat(indentation, p.indent+extra))
+			} else if !bytes.HasSuffix(p.out.Bytes(), []byte(" ")) {
+				p.write(" ")
+			}
+		}
+
+		p.write(c.Text())
+		p.newline()
+		p.write(strings.Repeat(indentation, p.indent+extra))
+		p.broken = true
+
+		if c.Pos().Line > p.line {
+			p.line = c.Pos().Line
+		}
+	}
+}
+
+// lineEmpty reports whether only blanks were printed on the current line.
+func (p *printer) lineEmpty() bool {
+	b := p.out.Bytes()
+	return len(bytes.TrimSpace(b[bytes.LastIndexByte(b, '\n')+1:])) == 0
+}
+
+// statements prints each statement on its own line. Comments that
+// appear in the source before a statement are printed above it, a
+// comment on the same line after a statement is kept on that line.
+// Comments that appear before end are printed after the last statement.
+func (p *printer) statements(list []ast.Statement, end token.Position) {
+	first := true
+
+	for i, s := range list {
+		if e, ok := s.(*ast.ExpressionStatement); ok && e.Expression == nil {
+			continue
+		}
+
+		pos := ast.Pos(s)
+		first = p.leadingComments(pos, first)
+
+		if !first && pos.IsValid() && pos.Line > p.line+1 {
+			p.newline()
+		}
+
+		p.writeIndent()
+		p.statement(s)
+		// a function declaration ends with its body.
+		if _, ok := s.(*ast.FunctionStatement); !ok {
+			p.write(";")
+		}
+
+		next := end
+		if i+1 < len(list) {
+			next = ast.Pos(list[i+1])
+		}
+
+		p.trailingComment(next)
+		p.newline()
+
+		first = false
+	}
+
+	p.leadingComments(end, first)
+}
+
+// leadingComments prints all comments before pos each on its own line.
+// It returns whether nothing was printed since the start of the block.
+func (p *printer) leadingComments(pos token.Position, first bool) bool {
+	for len(p.comments) > 0 && before(p.comments[0].Pos(), pos) {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+
+		if !first && c.Pos().Line > p.line+1 {
+			p.newline()
+		}
+
+		p.writeIndent()
+		p.write(c.Text())
+		p.newline()
+		p.seen(c.Pos())
+
+		first = false
+	}
+
+	return first
+}
+
+// trailingComment prints a comment that follows the last printed
+// source line, unless it belongs to the next statement.
+func (p *printer) trailingComment(next token.Position) {
+	if len(p.comments) == 0 || p.line == 0 {
+		return
+	}
+
+	c := p.comments[0]
+	if c.Pos().Line != p.line || !before(c.Pos(), next) {
+		return
+	}
+
+	p.comments = p.comments[1:]
+	p.write(" " + c.Text())
+}
+
+func (p *printer) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		p.seen(s.Token.Pos)
+		if s.Exported {
+			p.write("export ")
+		}
+		p.write("let ")
+		if s.Pattern != nil {
+			p.pattern(s.Pattern)
+		} else {
+			p.expression(s.Identifier)
+		}
+		p.write(" = ")
+		p.expression(s.Expression)
+	case *ast.FunctionStatement:
+		p.expression(s.Function)
+	case *ast.ImportStatement:
+		p.seen(s.Token.Pos)
+		p.write("import \"" + s.Path + "\" as ")
+		p.expression(s.Name)
+	case *ast.ReturnStatement:
+		p.seen(s.Token.Pos)
+		p.write("return ")
+		p.expression(s.Expression)
+	case *ast.ThrowStatement:
+		p.seen(s.Token.Pos)
+		p.write("throw ")
+		p.expression(s.Expression)
+	case *ast.ExpressionStatement:
+		p.expression(s.Expression)
+	case *ast.BlockStatement:
+		p.block(s)
+	}
+}
+
+func (p *printer) block(b *ast.BlockStatement) {
+	p.seen(b.Token.Pos)
+
+	if len(b.Statements) == 0 && !p.hasCommentsBefore(b.End.Pos) {
+		p.write("{}")
+		p.seen(b.End.Pos)
+		return
+	}
+
+	p.write("{")
+	p.newline()
+
+	p.indent++
+	p.statements(b.Statements, b.End.Pos)
+	p.indent--
+
+	p.writeIndent()
+	p.write("}")
+	p.seen(b.End.Pos)
+}
+
+func (p *printer) expression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.seen(e.Token.Pos)
+		p.write(e.Value)
+	case *ast.IntegerLiteral:
+		p.seen(e.Token.Pos)
+		p.write(strconv.Itoa(e.Value))
+	case *ast.StringLiteral:
+		p.seen(e.Token.Pos)
+		p.write("\"" + e.Value + "\"")
+	case *ast.BooleanLiteral:
+		p.seen(e.Token.Pos)
+		p.write(strconv.FormatBool(e.Value))
+	case *ast.PrefixExpression:
+		p.seen(e.Token.Pos)
+		p.write(e.Operator)
+		p.operand(e.Right, prefix, false)
+	case *ast.YieldExpression:
+		p.seen(e.Token.Pos)
+		p.write("yield ")
+		p.expression(e.Value)
+	case *ast.SpawnExpression:
+		p.seen(e.Token.Pos)
+		p.write("spawn ")
+		p.expression(e.Call)
+	case *ast.ChanExpression:
+		p.seen(e.Token.Pos)
+		p.write("chan(")
+		if e.Capacity != nil {
+			p.expression(e.Capacity)
+		}
+		p.write(")")
+	case *ast.SelectExpression:
+		p.selectExpression(e)
+	case *ast.InfixExpression:
+		pr := precedences[e.Operator]
+		p.operand(e.Left, pr, false)
+		p.seen(e.Token.Pos)
+		p.write(" " + e.Operator + " ")
+		p.operand(e.Right, pr, true)
+	case *ast.IfExpression:
+		p.seen(e.Token.Pos)
+		p.write("if (")
+		p.expression(e.Condition)
+		p.write(") ")
+		p.block(e.Consequence)
+
+		if e.Alternative != nil {
+			p.interior(e.Alternative.Token.Pos, 0)
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.TryExpression:
+		p.seen(e.Token.Pos)
+		p.write("try ")
+		p.block(e.Body)
+
+		if e.Catch != nil {
+			p.interior(e.Parameter.Token.Pos, 0)
+			p.write(" catch (")
+			p.expression(e.Parameter)
+			p.write(") ")
+			p.block(e.Catch)
+		}
+
+		if e.Finally != nil {
+			p.interior(e.Finally.Token.Pos, 0)
+			p.write(" finally ")
+			p.block(e.Finally)
+		}
+	case *ast.MatchExpression:
+		p.match(e)
+	case *ast.FunctionLiteral:
+		p.seen(e.Token.Pos)
+
+		p.write("fn")
+		if e.Name != nil {
+			p.write(" " + e.Name.Value)
+		}
+		p.write("(")
+		for i, param := range e.Parameters {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expression(param)
+
+			if d := e.Default(i); d != nil {
+				p.write(" = ")
+				p.expression(d)
+			}
+		}
+		if e.Rest != nil {
+			if len(e.Parameters) > 0 {
+				p.write(", ")
+			}
+			p.write("...")
+			p.expression(e.Rest)
+		}
+		p.write(") ")
+		p.block(e.Body)
+	case *ast.MacroLiteral:
+		p.seen(e.Token.Pos)
+
+		params := []string{}
+		for _, param := range e.Parameters {
+			params = append(params, param.Value)
+		}
+
+		p.write("macro(" + strings.Join(params, ", ") + ") ")
+		p.block(e.Body)
+	case *ast.CallExpression:
+		p.operand(e.Function, call, false)
+		p.arguments(e.Arguments, e.End.Pos)
+	case *ast.NamedArgument:
+		p.expression(e.Name)
+		p.write(": ")
+		p.expression(e.Value)
+	case *ast.MemberExpression:
+		p.operand(e.Left, call, false)
+		p.seen(e.Token.Pos)
+		p.write(".")
+		p.expression(e.Property)
+	case *ast.ArrayLiteral:
+		p.seen(e.Token.Pos)
+
+		items := []func(*printer){}
+		for _, element := range e.Elements {
+			items = append(items, expressionItem(element))
+		}
+
+		p.list("[", "]", e.End.Pos, items)
+	case *ast.HashLiteral:
+		p.seen(e.Token.Pos)
+
+		items := []func(*printer){}
+		for _, pair := range e.Pairs {
+			pair := pair
+			items = append(items, func(p *printer) {
+				p.expression(pair.Key)
+				p.write(": ")
+				p.expression(pair.Value)
+			})
+		}
+
+		p.list("{", "}", e.End.Pos, items)
+	case *ast.IndexExpression:
+		p.operand(e.Left, call, false)
+		p.seen(e.Token.Pos)
+		p.write("[")
+		p.expression(e.Index)
+		p.seen(e.End.Pos)
+		p.write("]")
+	}
+}
+
+// match prints the arms of a match expression each on its own line.
+func (p *printer) match(e *ast.MatchExpression) {
+	p.seen(e.Token.Pos)
+	p.write("match (")
+	p.expression(e.Value)
+	p.write(") ")
+
+	if len(e.Arms) == 0 && !p.hasCommentsBefore(e.End.Pos) {
+		p.write("{}")
+		p.seen(e.End.Pos)
+		return
+	}
+
+	p.write("{")
+	p.newline()
+	p.indent++
+
+	first := true
+	for i, a := range e.Arms {
+		first = p.leadingComments(ast.Pos(a.Pattern), first)
+
+		p.writeIndent()
+		p.pattern(a.Pattern)
+
+		if a.Guard != nil {
+			p.write(" if ")
+			p.expression(a.Guard)
+		}
+
+		p.write(" => ")
+		p.expression(a.Body)
+		p.write(",")
+
+		next := e.End.Pos
+		if i+1 < len(e.Arms) {
+			next = ast.Pos(e.Arms[i+1].Pattern)
+		}
+
+		p.trailingComment(next)
+		p.newline()
+
+		first = false
+	}
+
+	p.leadingComments(e.End.Pos, first)
+
+	p.indent--
+	p.writeIndent()
+	p.write("}")
+	p.seen(e.End.Pos)
+}
+
+// selectExpression prints the arms of a select expression each on its own line.
+func (p *printer) selectExpression(e *ast.SelectExpression) {
+	p.seen(e.Token.Pos)
+	p.write("select ")
+
+	if len(e.Arms) == 0 && !p.hasCommentsBefore(e.End.Pos) {
+		p.write("{}")
+		p.seen(e.End.Pos)
+		return
+	}
+
+	p.write("{")
+	p.newline()
+	p.indent++
+
+	first := true
+	for i, a := range e.Arms {
+		first = p.leadingComments(a.Token.Pos, first)
+
+		p.writeIndent()
+		switch {
+		case a.IsDefault():
+			p.seen(a.Token.Pos)
+			p.write(ast.Wildcard)
+		case a.Value != nil:
+			p.operand(a.Channel, call, false)
+			p.write(".send(")
+			p.expression(a.Value)
+			p.write(")")
+		default:
+			p.operand(a.Channel, call, false)
+			p.write(".recv()")
+			if a.Binding != nil {
+				p.write(" as " + a.Binding.Value)
+			}
+		}
+
+		p.write(" => ")
+		p.expression(a.Body)
+		p.write(",")
+
+		next := e.End.Pos
+		if i+1 < len(e.Arms) {
+			next = e.Arms[i+1].Token.Pos
+		}
+
+		p.trailingComment(next)
+		p.newline()
+
+		first = false
+	}
+
+	p.leadingComments(e.End.Pos, first)
+
+	p.indent--
+	p.writeIndent()
+	p.write("}")
+	p.seen(e.End.Pos)
+}
+
+func (p *printer) pattern(pat ast.Pattern) {
+	switch pat := pat.(type) {
+	case *ast.ArrayPattern:
+		p.seen(pat.Token.Pos)
+		p.write("[")
+		p.elements(pat.Elements)
+		if pat.Rest != nil {
+			if len(pat.Elements) > 0 {
+				p.write(", ")
+			}
+			p.write("...")
+			p.expression(pat.Rest)
+		}
+		p.seen(pat.End.Pos)
+		p.write("]")
+	case *ast.HashPattern:
+		p.seen(pat.Token.Pos)
+		p.write("{")
+		p.elements(pat.Elements)
+		p.seen(pat.End.Pos)
+		p.write("}")
+	case ast.Expression:
+		p.expression(pat)
+	}
+}
+
+// elements prints the elements of an array or a hash pattern.
+func (p *printer) elements(elements []*ast.PatternElement) {
+	for i, e := range elements {
+		if i > 0 {
+			p.write(", ")
+		}
+
+		if e.Key != nil && !e.Shorthand() {
+			p.expression(e.Key)
+			p.write(": ")
+		}
+		p.pattern(e.Pattern)
+
+		if e.Default != nil {
+			p.write(" = ")
+			p.expression(e.Default)
+		}
+	}
+}
+
+// operand prints e wrapped in parenthesis if it binds
+// less tightly than the operator it belongs to.
+func (p *printer) operand(e ast.Expression, parent precedence, right bool) {
+	pr := precedenceOf(e)
+
+	if pr < parent || right && pr == parent {
+		p.write("(")
+		p.expression(e)
+		p.write(")")
+		return
+	}
+
+	p.expression(e)
+}
+
+// arguments prints the arguments of a call on a single line
+// or, if that does not fit, each argument on its own line.
+func (p *printer) arguments(args []ast.Expression, end token.Position) {
+	items := []func(*printer){}
+	for _, a := range args {
+		items = append(items, expressionItem(a))
+	}
+
+	p.list("(", ")", end, items)
+}
+
+// list prints the items printed by the functions of items separated by
+// commas between open and close, located at end, on a single line or,
+// if that does not fit, each item on its own line. Lists that contain
+// comments are always printed on a single line broken by the comments.
+func (p *printer) list(open, close string, end token.Position, items []func(*printer)) {
+	rendered := []string{}
+	for _, item := range items {
+		r := &printer{indent: p.indent + 1}
+		item(r)
+		rendered = append(rendered, r.out.String())
+	}
+
+	single := strings.Join(rendered, ", ")
+	if len(items) == 0 || p.column+len(single)+2 <= maxLineWidth || strings.Contains(single, "\n") || p.hasCommentsBefore(end) {
+		p.write(open)
+		for i, item := range items {
+			if i > 0 {
+				p.write(", ")
+			}
+			item(p)
+		}
+		p.seen(end)
+		p.write(close)
+		return
+	}
+
+	p.write(open)
+	p.newline()
+	p.indent++
+
+	for i, item := range items {
+		p.writeIndent()
+		item(p)
+
+		if i < len(items)-1 {
+			p.write(",")
+		}
+		p.newline()
+	}
+
+	p.indent--
+	p.writeIndent()
+	p.seen(end)
+	p.write(close)
+}
+
+// expressionItem returns the printing of e as an item of a list.
+func expressionItem(e ast.Expression) func(*printer) {
+	return func(p *printer) { p.expression(e) }
+}
+
+func (p *printer) hasCommentsBefore(pos token.Position) bool {
+	return len(p.comments) > 0 && before(p.comments[0].Pos(), pos)
+}
+
+func precedenceOf(e ast.Expression) precedence {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return precedences[e.Operator]
+	case *ast.PrefixExpression:
+		return prefix
+	case *ast.YieldExpression:
+		return lowest
+	case *ast.SpawnExpression:
+		return prefix
+	default:
+		return call
+	}
+}
+
+// before reports whether a is located before b.
+func before(a, b token.Position) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return false
+	}
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
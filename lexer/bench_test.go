@@ -0,0 +1,47 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Despire/interpreter/token"
+)
+
+// generateProgram returns a program of n functions that
+// use all the kinds of tokens.
+func generateProgram(n int) string {
+	var b strings.Builder
+
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "// function number %d\n", i)
+		fmt.Fprintf(&b, "let %s = fn(x, y) {\n", name(i))
+		fmt.Fprintf(&b, "  if (x < %d) { return !(y == x); } else { return -x * y / 2; }\n", i)
+		fmt.Fprintf(&b, "  x + y - %d != true;\n", i)
+		fmt.Fprintf(&b, "};\n")
+		fmt.Fprintf(&b, "%s(%d, false);\n", name(i), i*7)
+	}
+
+	return b.String()
+}
+
+func BenchmarkLexer(b *testing.B) {
+	input := generateProgram(1000)
+	b.SetBytes(int64(len(input)))
+
+	for i := 0; i < b.N; i++ {
+		l := New(input)
+		for tok := l.NextToken(); tok.Typ != token.EOF; tok = l.NextToken() {
+		}
+	}
+}
+
+// name returns the name of the i-th function, identifiers
+// consist of letters only.
+func name(i int) string {
+	n := []byte{'v'}
+	for ; i > 0; i /= 26 {
+		n = append(n, byte('a'+i%26))
+	}
+	return string(n)
+}
@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"strings"
 	"unicode"
 
 	"github.com/Despire/interpreter/token"
@@ -12,17 +13,24 @@ const (
 )
 
 // Lexer is used to parse the input into individual tokens.
+// A Lexer is not safe for concurrent use, each goroutine
+// lexes its input with a lexer of its own.
 type Lexer struct {
 	input        string
 	position     int  // current reading position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	char         byte // current character
+	line         int  // line of the current character
+	column       int  // column of the current character
+
+	comments []token.Token // comments skipped while reading the input
 }
 
 // New returns an initialized Lexer on the given input.
 func New(input string) *Lexer {
 	l := &Lexer{
 		input: input,
+		line:  1,
 	}
 
 	// init fields
@@ -33,6 +41,12 @@ func New(input string) *Lexer {
 
 // readChar advances the pointers in the input buffer to the next character.
 func (l *Lexer) readChar() {
+	if l.char == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
 	if l.readPosition >= len(l.input) {
 		l.char = NULL
 	} else {
@@ -59,13 +73,47 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
+// skipComment advances the pointers in the input buffer past a '//' comment
+// and records it, so that tools like the formatter can preserve it.
+func (l *Lexer) skipComment() {
+	pos := l.pos()
+	curr := l.position
+
+	for l.char != '\n' && l.char != NULL {
+		l.readChar()
+	}
+
+	l.comments = append(l.comments, token.Token{
+		Typ:     token.COMMENT,
+		Literal: strings.TrimRightFunc(l.input[curr:l.position], unicode.IsSpace),
+		Pos:     pos,
+	})
+}
+
+// pos returns the position of the current character.
+func (l *Lexer) pos() token.Position {
+	return token.Position{Line: l.line, Column: l.column}
+}
+
+// Comments returns the comments read so far, in the order
+// in which they appear in the input.
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
 // NextToken returns the next token in the input buffer.
 func (l *Lexer) NextToken() token.Token {
 	var t token.Token
 
 	// if the current pointer is on a whitespace
-	// skip it.
+	// or a comment skip it.
 	l.skipWhitespace()
+	for l.char == charFromToken(token.SLASH) && l.peekChar() == charFromToken(token.SLASH) {
+		l.skipComment()
+		l.skipWhitespace()
+	}
+
+	pos := l.pos()
 
 	switch l.char {
 	case charFromToken(token.LEFTPARENTHESIS):
@@ -80,6 +128,25 @@ func (l *Lexer) NextToken() token.Token {
 		t = token.Token{Typ: token.SEMICOLON, Literal: string(l.char)}
 	case charFromToken(token.COMMA):
 		t = token.Token{Typ: token.COMMA, Literal: string(l.char)}
+	case charFromToken(token.LEFTSQUARE):
+		t = token.Token{Typ: token.LEFTSQUARE, Literal: string(l.char)}
+	case charFromToken(token.RIGHTSQUARE):
+		t = token.Token{Typ: token.RIGHTSQUARE, Literal: string(l.char)}
+	case charFromToken(token.COLON):
+		t = token.Token{Typ: token.COLON, Literal: string(l.char)}
+	case charFromToken(token.DOT):
+		if strings.HasPrefix(l.input[l.position:], token.ELLIPSIS) {
+			t = token.Token{Typ: token.ELLIPSIS, Literal: token.ELLIPSIS}
+
+			// advance in buffer.
+			l.readChar()
+			l.readChar()
+
+			break
+		}
+		t = token.Token{Typ: token.DOT, Literal: string(l.char)}
+	case '"':
+		t = l.readString()
 	case charFromToken(token.ASSIGN):
 		if l.peekChar() == charFromToken(token.ASSIGN) {
 			t = token.Token{Typ: token.EQUAL, Literal: string(l.char) + string(l.peekChar())}
@@ -89,6 +156,14 @@ func (l *Lexer) NextToken() token.Token {
 
 			break
 		}
+		if l.peekChar() == charFromToken(token.GREATERT) {
+			t = token.Token{Typ: token.ARROW, Literal: string(l.char) + string(l.peekChar())}
+
+			// advance in buffer.
+			l.readChar()
+
+			break
+		}
 		t = token.Token{Typ: token.ASSIGN, Literal: string(l.char)}
 	case charFromToken(token.PLUS):
 		t = token.Token{Typ: token.PLUS, Literal: string(l.char)}
@@ -125,7 +200,7 @@ func (l *Lexer) NextToken() token.Token {
 
 			literal := l.input[curr:l.position]
 
-			t = token.Token{Typ: token.LookupIdentifier(literal), Literal: literal}
+			t = token.Token{Typ: token.LookupIdentifier(literal), Literal: literal, Pos: pos}
 
 			// the pointer in the buffer is set to the first non ascii character
 			// so we just return the token.
@@ -139,7 +214,7 @@ func (l *Lexer) NextToken() token.Token {
 
 			literal := l.input[curr:l.position]
 
-			t = token.Token{Typ: token.INTEGER, Literal: literal}
+			t = token.Token{Typ: token.INTEGER, Literal: literal, Pos: pos}
 
 			// same as above.
 			return t
@@ -148,12 +223,32 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	}
 
+	t.Pos = pos
+
 	// advance in the buffer
 	l.readChar()
 
 	return t
 }
 
+// readString reads a string literal, the literal of the token is the
+// text between the quotes. Strings can not span lines, an unterminated
+// string is an ILLEGAL token.
+func (l *Lexer) readString() token.Token {
+	curr := l.position + 1
+
+	for {
+		l.readChar()
+
+		switch l.char {
+		case '"':
+			return token.Token{Typ: token.STRING, Literal: l.input[curr:l.position]}
+		case '\n', NULL:
+			return token.Token{Typ: token.ILLEGAL, Literal: l.input[curr-1 : l.position]}
+		}
+	}
+}
+
 func isLetter(char byte) bool {
 	return 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || char == '_'
 }
@@ -25,7 +25,16 @@ if (5 < 10) {
 }
 
 10 == 10;
-10 != 9;`
+10 != 9;
+macro(x) { x };
+import "lib/math.mk" as m;
+export let a = m.b;
+try { throw "bad input"; } catch (e) {} finally {}
+match (x) { _ => 1 }
+let [a, ...b] = {k: c};
+yield x;
+spawn chan select;
+"unterminated`
 
 	tests := []struct {
 		expectedType    token.Type
@@ -104,6 +113,73 @@ if (5 < 10) {
 		{token.NEQUAL, "!="},
 		{token.INTEGER, "9"},
 		{token.SEMICOLON, ";"},
+		{token.MACRO, "macro"},
+		{token.LEFTPARENTHESIS, "("},
+		{token.IDENTIFIER, "x"},
+		{token.RIGHTPARENTHESIS, ")"},
+		{token.LEFTBRACKET, "{"},
+		{token.IDENTIFIER, "x"},
+		{token.RIGHTBRACKET, "}"},
+		{token.SEMICOLON, ";"},
+		{token.IMPORT, "import"},
+		{token.STRING, "lib/math.mk"},
+		{token.AS, "as"},
+		{token.IDENTIFIER, "m"},
+		{token.SEMICOLON, ";"},
+		{token.EXPORT, "export"},
+		{token.LET, "let"},
+		{token.IDENTIFIER, "a"},
+		{token.ASSIGN, "="},
+		{token.IDENTIFIER, "m"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "b"},
+		{token.SEMICOLON, ";"},
+		{token.TRY, "try"},
+		{token.LEFTBRACKET, "{"},
+		{token.THROW, "throw"},
+		{token.STRING, "bad input"},
+		{token.SEMICOLON, ";"},
+		{token.RIGHTBRACKET, "}"},
+		{token.CATCH, "catch"},
+		{token.LEFTPARENTHESIS, "("},
+		{token.IDENTIFIER, "e"},
+		{token.RIGHTPARENTHESIS, ")"},
+		{token.LEFTBRACKET, "{"},
+		{token.RIGHTBRACKET, "}"},
+		{token.FINALLY, "finally"},
+		{token.LEFTBRACKET, "{"},
+		{token.RIGHTBRACKET, "}"},
+		{token.MATCH, "match"},
+		{token.LEFTPARENTHESIS, "("},
+		{token.IDENTIFIER, "x"},
+		{token.RIGHTPARENTHESIS, ")"},
+		{token.LEFTBRACKET, "{"},
+		{token.IDENTIFIER, "_"},
+		{token.ARROW, "=>"},
+		{token.INTEGER, "1"},
+		{token.RIGHTBRACKET, "}"},
+		{token.LET, "let"},
+		{token.LEFTSQUARE, "["},
+		{token.IDENTIFIER, "a"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENTIFIER, "b"},
+		{token.RIGHTSQUARE, "]"},
+		{token.ASSIGN, "="},
+		{token.LEFTBRACKET, "{"},
+		{token.IDENTIFIER, "k"},
+		{token.COLON, ":"},
+		{token.IDENTIFIER, "c"},
+		{token.RIGHTBRACKET, "}"},
+		{token.SEMICOLON, ";"},
+		{token.YIELD, "yield"},
+		{token.IDENTIFIER, "x"},
+		{token.SEMICOLON, ";"},
+		{token.SPAWN, "spawn"},
+		{token.CHAN, "chan"},
+		{token.SELECT, "select"},
+		{token.SEMICOLON, ";"},
+		{token.ILLEGAL, "\"unterminated"},
 		{token.EOF, "\x00"},
 	}
 
@@ -121,3 +197,52 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestPositions(t *testing.T) {
+	input := `let a = 5;
+// comment
+  a + 10; // trailing`
+
+	tests := []struct {
+		expectedType token.Type
+		expectedPos  token.Position
+	}{
+		{token.LET, token.Position{Line: 1, Column: 1}},
+		{token.IDENTIFIER, token.Position{Line: 1, Column: 5}},
+		{token.ASSIGN, token.Position{Line: 1, Column: 7}},
+		{token.INTEGER, token.Position{Line: 1, Column: 9}},
+		{token.SEMICOLON, token.Position{Line: 1, Column: 10}},
+		{token.IDENTIFIER, token.Position{Line: 3, Column: 3}},
+		{token.PLUS, token.Position{Line: 3, Column: 5}},
+		{token.INTEGER, token.Position{Line: 3, Column: 7}},
+		{token.SEMICOLON, token.Position{Line: 3, Column: 9}},
+		{token.EOF, token.Position{Line: 3, Column: 22}},
+	}
+
+	l := New(input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Typ != tt.expectedType {
+			t.Errorf("token type mismatch, have=%q, want=%q", tok.Typ, tt.expectedType)
+		}
+
+		if tok.Pos != tt.expectedPos {
+			t.Errorf("token %q position mismatch, have=%s, want=%s", tok.Literal, tok.Pos, tt.expectedPos)
+		}
+	}
+
+	comments := l.Comments()
+	if len(comments) != 2 {
+		t.Fatalf("expected 2 comments, have %d", len(comments))
+	}
+
+	if comments[0].Literal != "// comment" || comments[0].Pos != (token.Position{Line: 2, Column: 1}) {
+		t.Errorf("wrong first comment, have %q at %s", comments[0].Literal, comments[0].Pos)
+	}
+
+	if comments[1].Literal != "// trailing" || comments[1].Pos != (token.Position{Line: 3, Column: 11}) {
+		t.Errorf("wrong second comment, have %q at %s", comments[1].Literal, comments[1].Pos)
+	}
+}
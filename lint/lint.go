@@ -0,0 +1,229 @@
+// Package lint implements a configurable set of rules that
+// report suspicious constructs in a program.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/resolver"
+	"github.com/Despire/interpreter/token"
+)
+
+// DefaultMaxNesting is the nesting depth reported by the deep-nesting
+// rule when no other maximum is configured.
+const DefaultMaxNesting = 4
+
+// Rule checks a program for a single kind of problem. The rules are
+// not changed by running them, a Check may run concurrently with
+// itself on different programs.
+type Rule struct {
+	Name    string
+	Doc     string
+	Default bool // whether the rule runs unless disabled.
+	Check   func(p *Pass)
+}
+
+// Pass holds the state of running a single rule over a program.
+// It is only used by the goroutine running the rule.
+type Pass struct {
+	Program *ast.Program
+	Config  Config
+
+	rule        *Rule
+	diagnostics []Diagnostic
+	shared      *shared
+}
+
+// shared holds the analyses of a program computed once
+// per run and used by all the rules that need them.
+type shared struct {
+	resolved bool
+	resolver []resolver.Diagnostic
+}
+
+// resolve returns the diagnostics of resolving the program.
+func (p *Pass) resolve() []resolver.Diagnostic {
+	if !p.shared.resolved {
+		p.shared.resolver = resolver.Resolve(p.Program)
+		p.shared.resolved = true
+	}
+	return p.shared.resolver
+}
+
+// Report records a problem found by the rule at pos.
+func (p *Pass) Report(pos token.Position, format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Pos:     pos,
+		Rule:    p.rule.Name,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Diagnostic is a problem reported by a rule, a value that can be
+// used concurrently.
+type Diagnostic struct {
+	Pos     token.Position
+	Rule    string
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s (%s)", d.Pos, d.Message, d.Rule)
+}
+
+// Config selects the rules to run and configures them. It is only
+// read while linting, so one Config can be used by concurrent runs.
+type Config struct {
+	// Enable and Disable adjust the set of rules that run by default.
+	Enable  []string
+	Disable []string
+
+	// MaxNesting is the maximum nesting depth of blocks
+	// allowed by the deep-nesting rule.
+	MaxNesting int
+}
+
+// Rules returns all the known rules ordered by name.
+func Rules() []*Rule {
+	out := make([]*Rule, len(rules))
+	copy(out, rules)
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Lookup returns the rule with the given name.
+func Lookup(name string) (*Rule, bool) {
+	for _, r := range rules {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// Run checks program with the rules selected by config. Diagnostics
+// on lines suppressed by a "// nolint" directive are dropped. The
+// remaining diagnostics are returned ordered by position.
+//
+// A "// nolint" comment suppresses all rules, "// nolint:a,b" only
+// the listed ones. The directive applies to the line it is on and
+// to the line that follows it.
+func Run(program *ast.Program, config Config) ([]Diagnostic, error) {
+	selected, err := selectRules(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.MaxNesting <= 0 {
+		config.MaxNesting = DefaultMaxNesting
+	}
+
+	suppressed := directives(program.Comments)
+
+	var out []Diagnostic
+	analyses := new(shared)
+	for _, r := range selected {
+		pass := &Pass{
+			Program: program,
+			Config:  config,
+			rule:    r,
+			shared:  analyses,
+		}
+
+		r.Check(pass)
+
+		for _, d := range pass.diagnostics {
+			if !suppressed.match(d) {
+				out = append(out, d)
+			}
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i].Pos, out[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+
+	return out, nil
+}
+
+func selectRules(config Config) ([]*Rule, error) {
+	enabled := map[string]bool{}
+	for _, r := range rules {
+		enabled[r.Name] = r.Default
+	}
+
+	for _, name := range config.Enable {
+		if _, ok := enabled[name]; !ok {
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		enabled[name] = true
+	}
+
+	for _, name := range config.Disable {
+		if _, ok := enabled[name]; !ok {
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		enabled[name] = false
+	}
+
+	var out []*Rule
+	for _, r := range Rules() {
+		if enabled[r.Name] {
+			out = append(out, r)
+		}
+	}
+
+	return out, nil
+}
+
+// suppression lists the rules suppressed on a line.
+type suppression struct {
+	all   bool
+	rules map[string]bool
+}
+
+// suppressions maps a line to its suppressed rules.
+type suppressions map[int]*suppression
+
+func directives(comments []*ast.Comment) suppressions {
+	s := suppressions{}
+
+	for _, c := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text(), "//"))
+		if text != "nolint" && !strings.HasPrefix(text, "nolint:") {
+			continue
+		}
+
+		var names []string
+		if list := strings.TrimPrefix(text, "nolint:"); list != text {
+			names = strings.Split(list, ",")
+		}
+
+		for _, line := range []int{c.Pos().Line, c.Pos().Line + 1} {
+			sup, ok := s[line]
+			if !ok {
+				sup = &suppression{rules: map[string]bool{}}
+				s[line] = sup
+			}
+
+			if names == nil {
+				sup.all = true
+			}
+
+			for _, name := range names {
+				sup.rules[strings.TrimSpace(name)] = true
+			}
+		}
+	}
+
+	return s
+}
+
+func (s suppressions) match(d Diagnostic) bool {
+	sup, ok := s[d.Pos.Line]
+	return ok && (sup.all || sup.rules[d.Rule])
+}
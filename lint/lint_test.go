@@ -0,0 +1,185 @@
+package lint
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/lexer"
+	"github.com/Despire/interpreter/parser"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule       string
+		input      string
+		expected   []string
+		maxNesting int
+	}{
+		{"unreachable", "let f = fn() { return 1; 2; }; f();", []string{"1:26: unreachable code"}, 0},
+		{"unreachable", "return 1; let a = 2;", []string{"1:11: unreachable code"}, 0},
+		{"unreachable", "let f = fn(x) { if (x) { return 1; } else { return 2; } 3 }; f(1);", []string{"1:57: unreachable code"}, 0},
+		{"unreachable", "let f = fn(x) { if (x) { return 1; } 3 }; f(1);", nil, 0},
+		{"unreachable", "let f = fn() { throw 1; 2 }; f();", []string{"1:25: unreachable code"}, 0},
+		{"unreachable", "let f = fn() { return g(); fn g() { 1 } }; f();", nil, 0},
+		{"unreachable", "return 1; fn g() { 1 } 2;", []string{"1:24: unreachable code"}, 0},
+		{"missing-return", "fn f(x) { if (x) { return 1; } }; f(1);", []string{"1:1: function returns a value on some paths but not on others"}, 0},
+		{"unreachable", "let f = fn() { try { throw 1; } catch (e) { e } 2 }; f();", nil, 0},
+		{"unreachable", "let x = 1; match (x) { 1 => 2, n => n, _ => 3 };", []string{"1:40: unreachable match arm"}, 0},
+		{"unreachable", "let x = 1; match (x) { n if n > 1 => n, _ => 3 };", nil, 0},
+		{"non-exhaustive-match", "let x = 1; match (x) { 1 => 2, n if n > 1 => n };", []string{"1:12: match is not exhaustive, add a _ arm"}, 0},
+		{"non-exhaustive-match", "let x = 1; match (x) { 1 => 2, _ => 3 };", nil, 0},
+		{"non-exhaustive-match", "let x = 1; match (x) { 1 => 2, y => y };", nil, 0},
+		{"non-exhaustive-match", "let x = true; match (x) { true => 1, false => 2 };", nil, 0},
+		{"non-exhaustive-match", "let x = true; match (x) { true => 1, false if x => 2 };", []string{"1:15: match is not exhaustive, add a _ arm"}, 0},
+		{"constant-condition", "if (true) { 1 }", []string{"1:1: condition true is constant"}, 0},
+		{"constant-condition", "if (1 < -2) { 1 }", []string{"1:1: condition (1 < (-2)) is constant"}, 0},
+		{"constant-condition", "let a = 1; if (a < 2) { 1 }", nil, 0},
+		{"self-comparison", "let a = 1; a == a;", []string{"1:14: comparison of a to itself"}, 0},
+		{"self-comparison", "let a = 1; a + 1 != a + 1; a + 1 == 1 + a;", []string{"1:18: comparison of (a + 1) to itself"}, 0},
+		{"self-comparison", "let f = fn() { 1 }; f() == f();", nil, 0},
+		{"self-comparison", "let g = fn() { (yield 1) == (yield 1) }; g;", nil, 0},
+		{"self-comparison", "let f = fn() { 1 }; (spawn f()) == (spawn f()); chan() == chan();", nil, 0},
+		{"missing-return", "let f = fn(x) { if (x) { return 1; } }; f(1);", []string{"1:9: function returns a value on some paths but not on others"}, 0},
+		{"missing-return", "let f = fn(x) { if (x) { return 1; } let y = 2; }; f(1);", []string{"1:9: function returns a value on some paths but not on others"}, 0},
+		{"missing-return", "let f = fn(x) { if (x) { return 1; } x }; f(1);", nil, 0},
+		{"missing-return", "let f = fn(x) { if (x) { return 1; } else { 2 } }; f(1);", nil, 0},
+		{"missing-return", "let f = fn(x) { if (x) { 1 } }; f(1);", nil, 0},
+		{"duplicate-parameter", "let f = fn(x, y, x) { x + y }; f(1, 2, 3);", []string{"1:18: duplicate parameter x"}, 0},
+		{"duplicate-parameter", "let f = fn(x, y = 1, ...x) { x + y }; f(1);", []string{"1:25: duplicate parameter x"}, 0},
+		{"deep-nesting", "if (a) { if (a) { if (a) { if (a) { if (a) { 1 } } } } }", []string{"1:44: block is nested 5 levels deep, the maximum is 4"}, 0},
+		{"deep-nesting", "let f = fn() { if (a) { if (a) { fn() { 1 } } } };", []string{"1:39: block is nested 4 levels deep, the maximum is 3"}, 3},
+		{"undefined", "a + 1;", []string{"1:1: undefined: a"}, 0},
+		{"unused", "let a = 1;", []string{"1:5: a declared and not used"}, 0},
+		{"use-before-definition", "a; let a = 1;", []string{"1:1: a used before its definition at 1:8"}, 0},
+		{"shadow", "let a = 1; let f = fn(a) { a }; f(a);", []string{"1:23: declaration of a shadows the declaration at 1:5"}, 0},
+	}
+
+	for _, tt := range tests {
+		config := Config{Disable: allRulesExcept(tt.rule), Enable: []string{tt.rule}, MaxNesting: tt.maxNesting}
+
+		diagnostics, err := Run(parse(t, tt.input), config)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		var have []string
+		for _, d := range diagnostics {
+			if d.Rule != tt.rule {
+				t.Errorf("diagnostic reported by rule %q, expected %q", d.Rule, tt.rule)
+			}
+			have = append(have, d.Pos.String()+": "+d.Message)
+		}
+
+		if !reflect.DeepEqual(have, tt.expected) {
+			t.Errorf("wrong diagnostics of %s for %q\nhave: %q\nwant: %q", tt.rule, tt.input, have, tt.expected)
+		}
+	}
+}
+
+func TestSuppression(t *testing.T) {
+	input := `let a = 1;
+a == a; // nolint
+
+a == a; // nolint:unreachable
+// nolint:self-comparison, constant-condition
+a == a;
+if (true) { a == a };
+a == a;`
+
+	diagnostics, err := Run(parse(t, input), Config{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var have []string
+	for _, d := range diagnostics {
+		have = append(have, d.String())
+	}
+
+	expected := []string{
+		"4:3: comparison of a to itself (self-comparison)",
+		"7:1: condition true is constant (constant-condition)",
+		"7:15: comparison of a to itself (self-comparison)",
+		"8:3: comparison of a to itself (self-comparison)",
+	}
+
+	if !reflect.DeepEqual(have, expected) {
+		t.Errorf("wrong diagnostics\nhave: %q\nwant: %q", have, expected)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	program := parse(t, "let a = 1; let f = fn(a) { a }; f(a);")
+
+	diagnostics, err := Run(program, Config{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(diagnostics) != 0 {
+		t.Errorf("shadow must be disabled by default, have %v", diagnostics)
+	}
+
+	diagnostics, err = Run(program, Config{Enable: []string{"shadow"}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(diagnostics) != 1 || diagnostics[0].Rule != "shadow" {
+		t.Errorf("expected a single shadow diagnostic, have %v", diagnostics)
+	}
+
+	if _, err := Run(program, Config{Enable: []string{"nope"}}); err == nil {
+		t.Errorf("expected an error for an unknown rule")
+	}
+
+	if _, err := Run(program, Config{Disable: []string{"nope"}}); err == nil {
+		t.Errorf("expected an error for an unknown rule")
+	}
+}
+
+func TestResolverRules(t *testing.T) {
+	program := parse(t, "b; let b = 1; let f = fn(a) { let u = 1; c }; let a = 2; f(a);")
+
+	diagnostics, err := Run(program, Config{Enable: []string{"shadow"}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var have []string
+	for _, d := range diagnostics {
+		have = append(have, d.String())
+	}
+
+	expected := []string{
+		"1:1: b used before its definition at 1:8 (use-before-definition)",
+		"1:26: declaration of a shadows the declaration at 1:51 (shadow)",
+		"1:26: a declared and not used (unused)",
+		"1:35: u declared and not used (unused)",
+		"1:42: undefined: c (undefined)",
+	}
+
+	if !reflect.DeepEqual(have, expected) {
+		t.Errorf("wrong diagnostics\nhave: %q\nwant: %q", have, expected)
+	}
+}
+
+func allRulesExcept(name string) []string {
+	var names []string
+	for _, r := range Rules() {
+		if r.Name != name {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	for _, err := range p.Errors() {
+		t.Fatalf("parser error: %q", err)
+	}
+
+	return program
+}
@@ -0,0 +1,336 @@
+package lint
+
+import (
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/resolver"
+	"github.com/Despire/interpreter/token"
+)
+
+var rules = []*Rule{
+	{
+		Name:    "unreachable",
+		Doc:     "reports statements that follow a statement that always returns or throws",
+		Default: true,
+		Check:   checkUnreachable,
+	},
+	{
+		Name:    "constant-condition",
+		Doc:     "reports if expressions whose condition is a constant",
+		Default: true,
+		Check:   checkConstantCondition,
+	},
+	{
+		Name:    "self-comparison",
+		Doc:     "reports comparisons of a value to itself",
+		Default: true,
+		Check:   checkSelfComparison,
+	},
+	{
+		Name:    "missing-return",
+		Doc:     "reports functions that return a value on some paths but not on others",
+		Default: true,
+		Check:   checkMissingReturn,
+	},
+	{
+		Name:    "non-exhaustive-match",
+		Doc:     "reports match expressions that fail for the values none of their arms match",
+		Default: true,
+		Check:   checkNonExhaustiveMatch,
+	},
+	{
+		Name:    "duplicate-parameter",
+		Doc:     "reports functions that declare the same parameter more than once",
+		Default: true,
+		Check:   checkDuplicateParameter,
+	},
+	{
+		Name:    "deep-nesting",
+		Doc:     "reports blocks that are nested deeper than the configured maximum",
+		Default: true,
+		Check:   checkDeepNesting,
+	},
+	{
+		Name:    "undefined",
+		Doc:     "reports names that are not defined in any enclosing scope",
+		Default: true,
+		Check:   resolverRule(resolver.Undefined),
+	},
+	{
+		Name:    "unused",
+		Doc:     "reports bindings and parameters that are never used",
+		Default: true,
+		Check:   resolverRule(resolver.Unused),
+	},
+	{
+		Name:    "use-before-definition",
+		Doc:     "reports names used before their definition in the same scope",
+		Default: true,
+		Check:   resolverRule(resolver.UseBeforeDefinition),
+	},
+	{
+		Name:    "shadow",
+		Doc:     "reports declarations that shadow a declaration in an enclosing scope",
+		Default: false,
+		Check:   resolverRule(resolver.Shadowed),
+	},
+}
+
+func checkUnreachable(p *Pass) {
+	// function declarations are hoisted, they
+	// are reachable wherever they are placed.
+	check := func(list []ast.Statement) {
+		for i := 0; i+1 < len(list); i++ {
+			if !alwaysReturns(list[i]) {
+				continue
+			}
+			for _, s := range list[i+1:] {
+				if _, ok := s.(*ast.FunctionStatement); !ok {
+					p.Report(ast.Pos(s), "unreachable code")
+					return
+				}
+			}
+			return
+		}
+	}
+
+	check(p.Program.Statement)
+
+	ast.Inspect(p.Program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStatement:
+			check(n.Statements)
+		case *ast.MatchExpression:
+			for i := 0; i+1 < len(n.Arms); i++ {
+				if matchesAll(n.Arms[i]) {
+					p.Report(ast.Pos(n.Arms[i+1].Pattern), "unreachable match arm")
+					break
+				}
+			}
+		}
+		return true
+	})
+}
+
+func checkConstantCondition(p *Pass) {
+	ast.Inspect(p.Program, func(n ast.Node) bool {
+		if ie, ok := n.(*ast.IfExpression); ok && isConstant(ie.Condition) {
+			p.Report(ie.Token.Pos, "condition %s is constant", ie.Condition.String())
+		}
+		return true
+	})
+}
+
+func checkSelfComparison(p *Pass) {
+	ast.Inspect(p.Program, func(n ast.Node) bool {
+		ie, ok := n.(*ast.InfixExpression)
+		if !ok {
+			return true
+		}
+
+		switch ie.Operator {
+		case token.EQUAL, token.NEQUAL, token.LESST, token.GREATERT:
+			if isPure(ie.Left) && ast.Equal(ie.Left, ie.Right) {
+				p.Report(ie.Token.Pos, "comparison of %s to itself", ie.Left.String())
+			}
+		}
+		return true
+	})
+}
+
+func checkMissingReturn(p *Pass) {
+	ast.Inspect(p.Program, func(n ast.Node) bool {
+		fn, ok := n.(*ast.FunctionLiteral)
+		if !ok || fn.Body == nil {
+			return true
+		}
+
+		if containsReturn(fn.Body) && !producesValue(fn.Body) {
+			p.Report(fn.Token.Pos, "function returns a value on some paths but not on others")
+		}
+		return true
+	})
+}
+
+func checkNonExhaustiveMatch(p *Pass) {
+	ast.Inspect(p.Program, func(n ast.Node) bool {
+		me, ok := n.(*ast.MatchExpression)
+		if !ok {
+			return true
+		}
+
+		booleans := map[bool]bool{}
+		for _, a := range me.Arms {
+			if matchesAll(a) {
+				return true
+			}
+			if b, ok := a.Pattern.(*ast.BooleanLiteral); ok && a.Guard == nil {
+				booleans[b.Value] = true
+			}
+		}
+
+		if !booleans[true] || !booleans[false] {
+			p.Report(me.Token.Pos, "match is not exhaustive, add a _ arm")
+		}
+		return true
+	})
+}
+
+// matchesAll reports whether arm is taken for every value.
+func matchesAll(arm *ast.MatchArm) bool {
+	return arm.Guard == nil && ast.Irrefutable(arm.Pattern)
+}
+
+func checkDuplicateParameter(p *Pass) {
+	ast.Inspect(p.Program, func(n ast.Node) bool {
+		fn, ok := n.(*ast.FunctionLiteral)
+		if !ok {
+			return true
+		}
+
+		params := fn.Parameters
+		if fn.Rest != nil {
+			params = append(params[:len(params):len(params)], fn.Rest)
+		}
+
+		seen := map[string]bool{}
+		for _, param := range params {
+			if seen[param.Value] {
+				p.Report(param.Token.Pos, "duplicate parameter %s", param.Value)
+			}
+			seen[param.Value] = true
+		}
+		return true
+	})
+}
+
+// nestingVisitor tracks the number of blocks enclosing each node.
+type nestingVisitor struct {
+	pass  *Pass
+	depth int
+}
+
+func (v nestingVisitor) Visit(n ast.Node) ast.Visitor {
+	b, ok := n.(*ast.BlockStatement)
+	if !ok {
+		return v
+	}
+
+	if v.depth+1 > v.pass.Config.MaxNesting {
+		v.pass.Report(b.Token.Pos, "block is nested %d levels deep, the maximum is %d", v.depth+1, v.pass.Config.MaxNesting)
+		return nil
+	}
+
+	return nestingVisitor{pass: v.pass, depth: v.depth + 1}
+}
+
+func checkDeepNesting(p *Pass) {
+	ast.Walk(nestingVisitor{pass: p}, p.Program)
+}
+
+// resolverRule reports the diagnostics of the given kind found by the
+// resolver. The program is resolved once for all the resolver rules.
+func resolverRule(kind resolver.Kind) func(p *Pass) {
+	return func(p *Pass) {
+		for _, d := range p.resolve() {
+			if d.Kind == kind {
+				p.Report(d.Pos, "%s", d.Message)
+			}
+		}
+	}
+}
+
+// alwaysReturns reports whether every path through s
+// ends in a return statement or a throw statement.
+func alwaysReturns(s ast.Statement) bool {
+	switch s := s.(type) {
+	case *ast.ReturnStatement, *ast.ThrowStatement:
+		return true
+	case *ast.BlockStatement:
+		for _, st := range s.Statements {
+			if alwaysReturns(st) {
+				return true
+			}
+		}
+		return false
+	case *ast.ExpressionStatement:
+		ie, ok := s.Expression.(*ast.IfExpression)
+		if !ok || ie.Consequence == nil || ie.Alternative == nil {
+			return false
+		}
+		return alwaysReturns(ie.Consequence) && alwaysReturns(ie.Alternative)
+	default:
+		return false
+	}
+}
+
+// producesValue reports whether every path through the block ends in
+// a return statement or in an expression whose value is the result of
+// the block. An if expression without an else branch or a trailing let
+// statement produce no value on some path.
+func producesValue(b *ast.BlockStatement) bool {
+	for _, s := range b.Statements {
+		if alwaysReturns(s) {
+			return true
+		}
+	}
+
+	if len(b.Statements) == 0 {
+		return false
+	}
+
+	last, ok := b.Statements[len(b.Statements)-1].(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+
+	ie, ok := last.Expression.(*ast.IfExpression)
+	if !ok {
+		return true
+	}
+
+	return ie.Alternative != nil && producesValue(ie.Consequence) && producesValue(ie.Alternative)
+}
+
+// containsReturn reports whether the block returns from
+// its function on any path, nested functions are skipped.
+func containsReturn(b *ast.BlockStatement) bool {
+	found := false
+	ast.Inspect(b, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.ReturnStatement:
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// isConstant reports whether e consists only of literals.
+func isConstant(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.BooleanLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return isConstant(e.Right)
+	case *ast.InfixExpression:
+		return isConstant(e.Left) && isConstant(e.Right)
+	default:
+		return false
+	}
+}
+
+// isPure reports whether evaluating e has no side effects.
+func isPure(e ast.Expression) bool {
+	pure := true
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.CallExpression, *ast.YieldExpression,
+			*ast.SpawnExpression, *ast.ChanExpression, *ast.SelectExpression:
+			pure = false
+		}
+		return pure
+	})
+	return pure
+}
@@ -0,0 +1,151 @@
+// Package macro implements the expansion of macros, which runs over
+// the ast of a program before the program is evaluated or compiled.
+package macro
+
+import (
+	"fmt"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/eval"
+	"github.com/Despire/interpreter/objects"
+)
+
+// maxDepth is the maximum depth of macros expanding to calls of macros.
+const maxDepth = 100
+
+// DefineMacros binds the macros of the top-level let statements of
+// program in env and removes their definitions from the program.
+func DefineMacros(program *ast.Program, env *objects.Environment) {
+	statements := program.Statement[:0]
+
+	for _, s := range program.Statement {
+		let, ok := s.(*ast.LetStatement)
+		if !ok {
+			statements = append(statements, s)
+			continue
+		}
+
+		m, ok := let.Expression.(*ast.MacroLiteral)
+		if !ok || let.Identifier == nil {
+			statements = append(statements, s)
+			continue
+		}
+
+		env.Set(let.Identifier.Value, &objects.Macro{
+			Parameters: m.Parameters,
+			Body:       m.Body,
+			Env:        env,
+		})
+	}
+
+	program.Statement = statements
+}
+
+// ExpandMacros replaces the calls of the macros bound in env by the
+// quoted node their bodies evaluate to, when called with the quoted
+// arguments of the calls. The expansions are expanded in turn.
+func ExpandMacros(node ast.Node, env *objects.Environment) (ast.Node, error) {
+	return expand(node, env, 0)
+}
+
+func expand(node ast.Node, env *objects.Environment, depth int) (ast.Node, error) {
+	var err error
+
+	expanded := ast.Modify(node, func(n ast.Node) ast.Node {
+		if err != nil {
+			return n
+		}
+
+		call, ok := n.(*ast.CallExpression)
+		if !ok {
+			return n
+		}
+
+		m, ok := macroOf(call, env)
+		if !ok {
+			return n
+		}
+
+		if depth >= maxDepth {
+			err = errorf(call, objects.LimitError, "expansion of macro %s exceeds depth %d", call.Function, maxDepth)
+			return n
+		}
+
+		var result ast.Node
+		if result, err = apply(call, m); err != nil {
+			return n
+		}
+
+		result, err = expand(result, env, depth+1)
+		return result
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return expanded, nil
+}
+
+// apply evaluates the body of m with the quoted arguments of call.
+func apply(call *ast.CallExpression, m *objects.Macro) (ast.Node, error) {
+	if len(call.Arguments) != len(m.Parameters) {
+		return nil, errorf(call, objects.ArgumentError, "macro %s: wrong number of arguments: want=%d, have=%d",
+			call.Function, len(m.Parameters), len(call.Arguments))
+	}
+
+	for _, a := range call.Arguments {
+		if _, ok := a.(*ast.NamedArgument); ok {
+			return nil, errorf(call, objects.ArgumentError, "macro %s: named arguments are not supported", call.Function)
+		}
+	}
+
+	env := objects.NewEnclosedEnvironment(m.Env)
+	for i, p := range m.Parameters {
+		env.Set(p.Value, &objects.Quote{Node: call.Arguments[i]})
+	}
+
+	result := eval.Eval(m.Body, env)
+	if ret, ok := result.(*objects.Return); ok {
+		result = ret.Value
+	}
+
+	switch result := result.(type) {
+	case *objects.Quote:
+		return result.Node, nil
+	case *objects.Error:
+		return nil, errorf(call, result.Kind, "macro %s: %s", call.Function, result.Value)
+	default:
+		typ := objects.Type("nothing")
+		if result != nil {
+			typ = result.Type()
+		}
+		return nil, errorf(call, objects.TypeError, "macro %s returned %s, want a quote", call.Function, typ)
+	}
+}
+
+// errorf returns an error of kind raised by the expansion
+// of call, at the line of call.
+func errorf(call *ast.CallExpression, kind string, format string, a ...interface{}) *objects.Error {
+	pos := ast.Pos(call)
+
+	err := objects.Errorf(kind, "%s: %s", pos, fmt.Sprintf(format, a...))
+	err.Line = pos.Line
+	return err
+}
+
+// macroOf returns the macro called by call, if any.
+func macroOf(call *ast.CallExpression, env *objects.Environment) (*objects.Macro, bool) {
+	id, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(id.Value)
+	if !ok {
+		return nil, false
+	}
+
+	m, ok := obj.(*objects.Macro)
+	return m, ok
+}
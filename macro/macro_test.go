@@ -0,0 +1,187 @@
+package macro
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/lexer"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/parser"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+let number = 1;
+let function = fn(x, y) { x + y };
+let mymacro = macro(x, y) { x + y; };
+`
+
+	env := objects.NewEnvironment()
+	program := parse(t, input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statement) != 2 {
+		t.Fatalf("wrong number of statements. want=2, have=%d", len(program.Statement))
+	}
+
+	for _, name := range []string{"number", "function"} {
+		if _, ok := env.Get(name); ok {
+			t.Errorf("%s should not be defined", name)
+		}
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+
+	m, ok := obj.(*objects.Macro)
+	if !ok {
+		t.Fatalf("object is not a macro. have=%T", obj)
+	}
+
+	if len(m.Parameters) != 2 || m.Parameters[0].Value != "x" || m.Parameters[1].Value != "y" {
+		t.Errorf("wrong macro parameters %v", m.Parameters)
+	}
+
+	if m.Body.String() != "(x + y)" {
+		t.Errorf("wrong macro body. want=%q, have=%q", "(x + y)", m.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2); }; infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); }; reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+let unless = macro(condition, consequence, alternative) {
+	quote(if (!(unquote(condition))) {
+		unquote(consequence);
+	} else {
+		unquote(alternative);
+	});
+};
+
+unless(10 > 5, puts(1), puts(2));
+`,
+			`if (!(10 > 5)) { puts(1) } else { puts(2) }`,
+		},
+		{
+			`let twice = macro(x) { quote(unquote(x) + unquote(x)); }; let four = macro(x) { quote(twice(twice(unquote(x)))); }; four(a);`,
+			`(a + a) + (a + a)`,
+		},
+		{
+			`let m = macro(x) { quote(unquote(x)); }; let f = fn() { m(1) + m(2) };`,
+			`let f = fn() { 1 + 2 };`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := parse(t, tt.expected)
+		program := parse(t, tt.input)
+
+		env := objects.NewEnvironment()
+		DefineMacros(program, env)
+
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Errorf("expansion of %q failed: %s", tt.input, err)
+			continue
+		}
+
+		if !ast.Equal(expanded, expected) {
+			t.Errorf("wrong expansion of %q. want=%q, have=%q", tt.input, expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		kind     string
+	}{
+		{
+			`let m = macro(x) { x }; m();`,
+			"1:25: macro m: wrong number of arguments: want=1, have=0",
+			objects.ArgumentError,
+		},
+		{
+			`let m = macro() { 1 }; m();`,
+			"1:24: macro m returned INTEGER, want a quote",
+			objects.TypeError,
+		},
+		{
+			`let m = macro(x) { x }; m(x: 1);`,
+			"1:25: macro m: named arguments are not supported",
+			objects.ArgumentError,
+		},
+		{
+			`let m = macro() { y }; m();`,
+			"1:24: macro m: identifier not found: y",
+			objects.NameError,
+		},
+		{
+			`let m = macro() { quote(m()) }; m();`,
+			"1:25: expansion of macro m exceeds depth 100",
+			objects.LimitError,
+		},
+		{
+			"let m = macro() { 1 + true };\n\nm();",
+			"3:1: macro m: type mismatch: INTEGER + BOOLEAN",
+			objects.TypeError,
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+
+		env := objects.NewEnvironment()
+		DefineMacros(program, env)
+
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Errorf("expected an error for %q", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, have=%q", tt.input, tt.expected, err)
+		}
+
+		e, ok := err.(*objects.Error)
+		if !ok {
+			t.Errorf("error for %q is not an Error. have=%T", tt.input, err)
+			continue
+		}
+
+		if line := strings.Split(tt.expected, ":")[0]; e.Kind != tt.kind || fmt.Sprint(e.Line) != line {
+			t.Errorf("wrong kind or line for %q. want=%s at %s, have=%s at %d", tt.input, tt.kind, line, e.Kind, e.Line)
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, errs)
+	}
+
+	return program
+}
@@ -1,7 +1,53 @@
 package main
 
-import "os"
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// commands maps the name of a subcommand to its implementation.
+// Each command receives the arguments following its name and
+// returns the exit code of the process.
+var commands = map[string]func(args []string) int{
+	"ast":     astCommand,
+	"bench":   benchCommand,
+	"compile": compileCommand,
+	"disasm":  disasmCommand,
+	"fmt":     fmtCommand,
+	"lint":    lintCommand,
+	"run":     runCommand,
+}
+
+// engineFlag selects the engine of the REPL and the default engine of run.
+var engineFlag = flag.String("engine", "eval", "engine that executes programs, eval or vm")
+
+// optimizeFlag enables the optimizer in the REPL and by default in the other commands.
+var optimizeFlag = flag.Bool("optimize", false, "optimize programs before executing or compiling them")
 
 func main() {
-	Start(os.Stdin, os.Stdout)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: interpreter [-engine eval|vm] [-optimize] [command] [args...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		s, err := newSession(*engineFlag, *optimizeFlag, ".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+
+		Start(os.Stdin, os.Stdout, s)
+		return
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		os.Exit(2)
+	}
+
+	os.Exit(cmd(flag.Args()[1:]))
 }
@@ -0,0 +1,216 @@
+// Package module loads the modules imported by programs. A module is
+// a source file, the names bound by its top-level 'export let'
+// statements are the members visible to the programs importing it.
+package module
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/lexer"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/parser"
+)
+
+// Bindings looks up the names bound at the top level of a program.
+// The bindings are only looked up by the Loader using them.
+type Bindings interface {
+	Get(name string) (objects.Object, bool)
+}
+
+// Executor executes the program of a module with one of the engines,
+// the imports of the program are loaded by imports. It returns the
+// names bound by the program, or the error the program failed with,
+// an *objects.Error keeps its kind and line in the error of the import.
+// It is called by the goroutine using the Loader.
+type Executor func(program *ast.Program, imports objects.Importer) (Bindings, error)
+
+// Loader loads modules from source files and caches them, so each
+// module is executed once no matter how often it is imported.
+// A Loader is not safe for concurrent use.
+type Loader struct {
+	exec Executor
+	path []string
+
+	modules map[string]*objects.Module // by absolute file name.
+	loading []string                   // the files being loaded, the last one imports the next.
+}
+
+// NewLoader returns a loader that executes modules with exec. The
+// imports that are not found relative to the importing file are
+// looked up in the directories of path, in order.
+func NewLoader(exec Executor, path []string) *Loader {
+	return &Loader{
+		exec:    exec,
+		path:    path,
+		modules: map[string]*objects.Module{},
+	}
+}
+
+// Importer returns the importer of the programs of the files in
+// dir, the paths of their imports are relative to dir.
+func (l *Loader) Importer(dir string) objects.Importer {
+	return importer{loader: l, dir: dir}
+}
+
+type importer struct {
+	loader *Loader
+	dir    string
+}
+
+func (i importer) Import(path string) (*objects.Module, error) {
+	return i.loader.load(i.dir, path)
+}
+
+func (l *Loader) load(dir, path string) (*objects.Module, error) {
+	file, err := l.find(dir, path)
+	if err != nil {
+		return nil, err
+	}
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if m, ok := l.modules[abs]; ok {
+		return m, nil
+	}
+
+	for i, f := range l.loading {
+		if f == abs {
+			cycle := append(append([]string{}, l.loading[i:]...), abs)
+			for j := range cycle {
+				cycle[j] = relative(cycle[j])
+			}
+			return nil, fmt.Errorf("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	l.loading = append(l.loading, abs)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	m, err := l.execute(file)
+	if err != nil {
+		return nil, err
+	}
+
+	l.modules[abs] = m
+	return m, nil
+}
+
+// find returns the name of the file imported by path from a file in dir.
+func (l *Loader) find(dir, path string) (string, error) {
+	if path == "" {
+		return "", errors.New("import of an empty path")
+	}
+
+	if filepath.IsAbs(path) {
+		if exists(path) {
+			return filepath.Clean(path), nil
+		}
+		return "", fmt.Errorf("module %q not found", path)
+	}
+
+	for _, d := range append([]string{dir}, l.path...) {
+		if file := filepath.Join(d, path); exists(file) {
+			return file, nil
+		}
+	}
+
+	return "", fmt.Errorf("module %q not found", path)
+}
+
+// execute parses and executes the module in file.
+func (l *Loader) execute(file string) (*objects.Module, error) {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s: %s", file, strings.Join(p.Errors(), "\n"))
+	}
+
+	// the exports are collected before the execution,
+	// which may rewrite the program, e.g. expand macros.
+	exports := Exports(program)
+
+	bindings, err := l.exec(program, l.Importer(filepath.Dir(file)))
+	if e, ok := err.(*objects.Error); ok && e.Line > 0 {
+		return nil, fmt.Errorf("%s:%d: %w", file, e.Line, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	m := &objects.Module{
+		Path:    file,
+		Members: map[string]objects.Object{},
+	}
+
+	for _, name := range exports {
+		if val, ok := bindings.Get(name); ok {
+			m.Members[name] = val
+		}
+	}
+
+	return m, nil
+}
+
+// Exports returns the names exported by program.
+func Exports(program *ast.Program) []string {
+	var names []string
+
+	for _, s := range program.Statement {
+		if let, ok := s.(*ast.LetStatement); ok && let != nil && let.Exported {
+			if let.Pattern != nil {
+				for _, id := range ast.Bindings(let.Pattern) {
+					names = append(names, id.Value)
+				}
+				continue
+			}
+			names = append(names, let.Identifier.Value)
+		}
+	}
+
+	return names
+}
+
+func exists(file string) bool {
+	info, err := os.Stat(file)
+	return err == nil && !info.IsDir()
+}
+
+// relative returns file relative to the working
+// directory if it is below it, used in errors.
+func relative(file string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return file
+	}
+
+	return relativeTo(wd, file)
+}
+
+// relativeTo returns file relative to dir if it is below dir. Below the
+// root of the file system the absolute name is kept, it is not shorter.
+func relativeTo(dir, file string) string {
+	if filepath.Dir(dir) == dir {
+		return file
+	}
+
+	rel, err := filepath.Rel(dir, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return file
+	}
+
+	return rel
+}
@@ -0,0 +1,203 @@
+package module
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/eval"
+	"github.com/Despire/interpreter/lexer"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/parser"
+)
+
+func TestImport(t *testing.T) {
+	dir := files(t, map[string]string{
+		"lib/math.mk":  `import "util.mk" as util; export let square = fn(x) { util.mul(x, x) }; let hidden = 1;`,
+		"path/util.mk": `export let mul = fn(a, b) { a * b }; export let answer = 42;`,
+		"main.mk":      `import "lib/math.mk" as m; m.square(3)`,
+	})
+
+	executed := 0
+	exec := func(program *ast.Program, imports objects.Importer) (Bindings, error) {
+		executed++
+		return evaluate(program, imports)
+	}
+
+	l := NewLoader(exec, []string{filepath.Join(dir, "path")})
+	imports := l.Importer(dir)
+
+	m, err := imports.Import("lib/math.mk")
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+
+	if names := members(m); !reflect.DeepEqual(names, []string{"square"}) {
+		t.Errorf("wrong members. want=[square], have=%v", names)
+	}
+
+	result := eval.EvalWithOptions(parse(t, `import "lib/math.mk" as m; m.square(7)`), objects.NewEnvironment(), eval.Options{Importer: imports})
+	if i, ok := result.(*objects.Integer); !ok || i.Value != 49 {
+		t.Errorf("wrong result. want=49, have=%s", result.Inspect())
+	}
+
+	again, err := l.Importer(filepath.Join(dir, "lib")).Import("../lib/./math.mk")
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+
+	if again != m {
+		t.Errorf("the module was loaded twice")
+	}
+
+	if executed != 2 {
+		t.Errorf("wrong number of executed modules. want=2, have=%d", executed)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := files(t, map[string]string{
+		"a.mk":      `import "b.mk" as b; export let a = 1;`,
+		"b.mk":      `import "c.mk" as c; export let b = 1;`,
+		"c.mk":      `import "a.mk" as a; export let c = 1;`,
+		"self.mk":   `import "self.mk" as s;`,
+		"syntax.mk": `let = 1;`,
+		"fail.mk":   `export let a = 1 + true;`,
+		"lib/x.mk":  `1`,
+	})
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"", "import of an empty path"},
+		{"nope.mk", `module "nope.mk" not found`},
+		{"lib", `module "lib" not found`},
+		{"syntax.mk", "expected next token to be IDENTIFIER, got = instead"},
+		{"fail.mk", "fail.mk:1: type mismatch: INTEGER + BOOLEAN"},
+		{"a.mk", "import cycle: "},
+		{"self.mk", "import cycle: "},
+	}
+
+	for _, tt := range tests {
+		l := NewLoader(evaluate, nil)
+
+		_, err := l.Importer(dir).Import(tt.path)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("wrong error for %q. want=%q, have=%v", tt.path, tt.expected, err)
+		}
+	}
+
+	l := NewLoader(evaluate, nil)
+
+	// the error raised by the module keeps its kind and line.
+	_, err := l.Importer(dir).Import("fail.mk")
+	var e *objects.Error
+	if !errors.As(err, &e) || e.Kind != objects.TypeError || e.Line != 1 {
+		t.Errorf("wrong error of the module. want=%s at 1, have=%#v", objects.TypeError, e)
+	}
+
+	_, err = l.Importer(dir).Import("a.mk")
+
+	cycle := err.Error()[strings.Index(err.Error(), "import cycle: "):]
+	files := strings.Split(strings.TrimPrefix(cycle, "import cycle: "), " -> ")
+
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+
+	if want := []string{"a.mk", "b.mk", "c.mk", "a.mk"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("wrong cycle. want=%v, have=%v", want, names)
+	}
+
+	// a failed import is not cached, nor does it leave the files being loaded.
+	if _, err := l.Importer(dir).Import("lib/x.mk"); err != nil {
+		t.Errorf("import after a cycle failed: %v", err)
+	}
+}
+
+func TestRelative(t *testing.T) {
+	tests := []struct {
+		dir      string
+		file     string
+		expected string
+	}{
+		{"/tmp", "/tmp/mods/a.mk", "mods/a.mk"},
+		{"/tmp/mods", "/tmp/mods/a.mk", "a.mk"},
+		{"/tmp/mods", "/tmp/a.mk", "/tmp/a.mk"},
+		{"/tmp/mods", "/tmp/mods2/a.mk", "/tmp/mods2/a.mk"},
+		{"/tmp", "/tmp/..a.mk", "..a.mk"},
+		{"/", "/tmp/mods/a.mk", "/tmp/mods/a.mk"},
+	}
+
+	for _, tt := range tests {
+		dir, file := filepath.FromSlash(tt.dir), filepath.FromSlash(tt.file)
+		if have := relativeTo(dir, file); have != filepath.FromSlash(tt.expected) {
+			t.Errorf("relativeTo(%q, %q) wrong. want=%q, have=%q", tt.dir, tt.file, tt.expected, have)
+		}
+	}
+}
+
+func TestExports(t *testing.T) {
+	program := parse(t, `export let a = 1; let b = 2; if (true) { let c = 3; }; export let d = fn() { let e = 4; }; export let [f, {g: h}, ...i] = [1, {g: 2}];`)
+
+	if names := Exports(program); !reflect.DeepEqual(names, []string{"a", "d", "f", "h", "i"}) {
+		t.Errorf("wrong exports. want=[a d f h i], have=%v", names)
+	}
+}
+
+// evaluate executes the program of a module with the evaluator.
+func evaluate(program *ast.Program, imports objects.Importer) (Bindings, error) {
+	env := objects.NewEnvironment()
+
+	result := eval.EvalWithOptions(program, env, eval.Options{Importer: imports})
+	if err, ok := result.(*objects.Error); ok {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// files writes the files to a temporary directory and returns it.
+func files(t *testing.T, contents map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, src := range contents {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func members(m *objects.Module) []string {
+	var names []string
+	for name := range m.Members {
+		names = append(names, name)
+	}
+	return names
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, errs)
+	}
+
+	return program
+}
@@ -0,0 +1,73 @@
+package objects
+
+// BindArguments assigns the arguments of a call of a function to the
+// slots of its parameters, whose names are params. The last optional
+// parameters have a default value, rest is the name of the rest
+// parameter, "" if the function has none. The named arguments follow
+// the positional ones in args, names holds their names.
+//
+// The slot of a missing optional parameter is left nil, the function
+// assigns the default value itself. The slot after the parameters is
+// assigned the array of the surplus positional arguments if there is a
+// rest parameter, the surplus arguments are ignored otherwise.
+//
+// The messages of the errors start with the name of the function
+// called, fn, unless it is anonymous ("").
+func BindArguments(fn string, slots []Object, params []string, optional int, rest string, args []Object, names []string) *Error {
+	err := bindArguments(slots, params, optional, rest, args, names)
+	if err != nil && fn != "" {
+		err.Value = fn + ": " + err.Value
+	}
+	return err
+}
+
+func bindArguments(slots []Object, params []string, optional int, rest string, args []Object, names []string) *Error {
+	positional := len(args) - len(names)
+
+	copy(slots, args[:min(positional, len(params))])
+
+	if rest != "" {
+		surplus := []Object{}
+		if positional > len(params) {
+			surplus = append(surplus, args[len(params):positional]...)
+		}
+		slots[len(params)] = &Array{Elements: surplus}
+	}
+
+	for i, name := range names {
+		slot := indexOf(params, name)
+		switch {
+		case slot < 0 && name == rest:
+			return Errorf(ArgumentError, "rest parameter %s can not be passed by name", name)
+		case slot < 0:
+			return Errorf(ArgumentError, "unknown parameter %s", name)
+		case slots[slot] != nil:
+			return Errorf(ArgumentError, "multiple values for parameter %s", name)
+		}
+		slots[slot] = args[positional+i]
+	}
+
+	for i := 0; i < len(params)-optional; i++ {
+		if slots[i] == nil {
+			return Errorf(ArgumentError, "missing argument for parameter %s", params[i])
+		}
+	}
+
+	return nil
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
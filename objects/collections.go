@@ -0,0 +1,127 @@
+package objects
+
+import "strings"
+
+const (
+	ARRAY = "ARRAY"
+	HASH  = "HASH"
+)
+
+type (
+	// Array is an ordered list of values ([1, 2, 3]). Programs never
+	// change an array once created, so it can be shared if its
+	// elements can.
+	Array struct {
+		Elements []Object
+	}
+
+	// Hash maps keys to values ({"name": "Ann", "age": 31}), the
+	// pairs are kept in the order in which their keys were inserted.
+	// A hash is only changed by Set while it is built, it can be shared
+	// like an array from then on.
+	Hash struct {
+		Pairs  map[HashKey]int // the index of the pair of each key.
+		Keys   []Hashable
+		Values []Object
+	}
+
+	// HashKey identifies the value of a key of a hash, it is a value
+	// that is safe to use concurrently.
+	HashKey struct {
+		Type  Type
+		Value string
+	}
+
+	// Hashable is implemented by the values that can be keys of a hash,
+	// HashKey is called concurrently by the goroutines sharing the hash.
+	Hashable interface {
+		Object
+		HashKey() HashKey
+	}
+)
+
+// NewHash returns an empty hash.
+func NewHash() *Hash {
+	return &Hash{Pairs: map[HashKey]int{}}
+}
+
+// Set binds key to val, a new key is appended to the keys of h.
+func (h *Hash) Set(key Hashable, val Object) {
+	k := key.HashKey()
+	if i, ok := h.Pairs[k]; ok {
+		h.Values[i] = val
+		return
+	}
+
+	h.Pairs[k] = len(h.Keys)
+	h.Keys = append(h.Keys, key)
+	h.Values = append(h.Values, val)
+}
+
+// Get returns the value bound to key.
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	i, ok := h.Pairs[key.HashKey()]
+	if !ok {
+		return nil, false
+	}
+	return h.Values[i], true
+}
+
+// Len returns the number of pairs of h.
+func (h *Hash) Len() int { return len(h.Keys) }
+
+// implement Hashable interface
+func (i *Integer) HashKey() HashKey { return HashKey{Type: INTEGER, Value: i.Inspect()} }
+func (b *Boolean) HashKey() HashKey { return HashKey{Type: BOOLEAN, Value: b.Inspect()} }
+func (s *String) HashKey() HashKey  { return HashKey{Type: STRING, Value: s.Value} }
+
+// implement Object interface
+func (a *Array) Type() Type { return ARRAY }
+func (a *Array) Inspect() string {
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+func (a *Array) Equals(other Object) bool {
+	o, ok := other.(*Array)
+	if !ok || len(o.Elements) != len(a.Elements) {
+		return false
+	}
+
+	for i, e := range a.Elements {
+		if !e.Equals(o.Elements[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// implement Object interface
+func (h *Hash) Type() Type { return HASH }
+func (h *Hash) Inspect() string {
+	pairs := []string{}
+	for i, k := range h.Keys {
+		pairs = append(pairs, k.Inspect()+": "+h.Values[i].Inspect())
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+func (h *Hash) Equals(other Object) bool {
+	o, ok := other.(*Hash)
+	if !ok || o.Len() != h.Len() {
+		return false
+	}
+
+	for i, k := range h.Keys {
+		val, ok := o.Get(k)
+		if !ok || !h.Values[i].Equals(val) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,121 @@
+package objects
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Despire/interpreter/code"
+)
+
+const COMPILED_FUNCTION = "COMPILED_FUNCTION"
+
+type (
+	// CompiledFunction is a function lowered to instructions by the
+	// compiler, it is not changed once compiled and can be shared.
+	CompiledFunction struct {
+		// Name is the name of a declared function,
+		// it is empty for function expressions.
+		Name          string
+		Instructions  code.Instructions
+		Lines         code.LineTable
+		NumLocals     int
+		NumParameters int
+		// NumDefaults is the number of the last parameters
+		// that have a default value, which the function
+		// assigns itself when the argument is missing.
+		NumDefaults int
+		// Rest is set if the function has a rest parameter, in
+		// the slot after the parameters, that collects the
+		// surplus arguments of a call.
+		Rest bool
+		// Generator is set if the function yields, a call of it
+		// returns a generator that runs it on demand.
+		Generator bool
+		// Names holds the name bound to each slot of the scope
+		// of the function, the parameters come first.
+		Names []string
+	}
+
+	// Closure is a compiled function together with the scope it
+	// was created in and the constant pool of the program it was
+	// compiled in, which may differ from the one of its caller
+	// when it was imported from a module. A closure is immutable,
+	// but its scope is not synchronised, see Scope.
+	Closure struct {
+		Fn        *CompiledFunction
+		Env       *Scope
+		Constants []Object
+	}
+
+	// Scope holds the values bound in a program or a function
+	// call, indexed by the slots assigned by the compiler. A scope
+	// is not safe for concurrent use, it must only be used by one
+	// virtual machine at a time.
+	Scope struct {
+		Values []Object
+		Names  []string
+		Outer  *Scope
+	}
+)
+
+// implement Object interface
+func (f *CompiledFunction) Type() Type { return COMPILED_FUNCTION }
+func (f *CompiledFunction) Equals(other Object) bool {
+	o, ok := other.(*CompiledFunction)
+	return ok && o == f
+}
+func (f *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("compiled %s", f.signature())
+}
+
+// signature returns the name and the parameters of f, fn name(a, b).
+func (f *CompiledFunction) signature() string {
+	if f.Name == "" {
+		return "fn(" + f.parameters() + ")"
+	}
+	return "fn " + f.Name + "(" + f.parameters() + ")"
+}
+
+// parameters returns the list of the names of the parameters of f.
+func (f *CompiledFunction) parameters() string {
+	params := strings.Join(f.Names[:f.NumParameters], ", ")
+	if !f.Rest {
+		return params
+	}
+	if f.NumParameters > 0 {
+		params += ", "
+	}
+	return params + "..." + f.Names[f.NumParameters]
+}
+
+// implement Object interface
+func (c *Closure) Type() Type { return FUNCTION }
+func (c *Closure) Equals(other Object) bool {
+	o, ok := other.(*Closure)
+	return ok && o == c
+}
+func (c *Closure) Inspect() string {
+	return c.Fn.signature() + " { ... }"
+}
+
+// NewScope returns a scope for the slots of fn enclosed by outer.
+func NewScope(fn *CompiledFunction, outer *Scope) *Scope {
+	return &Scope{
+		Values: make([]Object, fn.NumLocals),
+		Names:  fn.Names,
+		Outer:  outer,
+	}
+}
+
+// Lookup looks up the value bound to name in s and its enclosing
+// scopes, slots that have not been assigned yet are skipped.
+func (s *Scope) Lookup(name string) (Object, bool) {
+	for ; s != nil; s = s.Outer {
+		for i, n := range s.Names {
+			if n == name && i < len(s.Values) && s.Values[i] != nil {
+				return s.Values[i], true
+			}
+		}
+	}
+	return nil, false
+}
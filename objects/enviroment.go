@@ -1,11 +1,18 @@
 package objects
 
+import "sync"
+
+// NewEnvironment returns an environment that binds names in a map,
+// it is used for the program and for functions that were not resolved.
 func NewEnvironment() *Environment {
 	return &Environment{
 		store: make(map[string]Object),
 	}
 }
 
+// NewEnclosedEnvironment returns an environment enclosed by outer, e.g.
+// the environment of a request evaluated against a frozen environment
+// of global definitions.
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
@@ -13,20 +20,149 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
+// NewSlotEnvironment returns an environment enclosed by outer that
+// binds the given names in slots, indexed by their position in names.
+// Names that are not among them are bound in a map on demand.
+func NewSlotEnvironment(names []string, outer *Environment) *Environment {
+	return &Environment{
+		slots: make([]Object, len(names)),
+		names: names,
+		outer: outer,
+	}
+}
+
+// Environment holds the bindings of the program or of a function call.
+// A slot that has not been assigned yet holds nil and is skipped by the
+// lookups, so the name is looked up in the enclosing environments.
+//
+// An Environment is safe for concurrent use, e.g. by the tasks of a
+// program, which share the environments captured by their closures.
+// Once frozen it is immutable and its lookups take no lock, so it can
+// be shared by evaluations running concurrently, each in an environment
+// of its own enclosed by it. The values bound in it are shared as well,
+// they must not be generators or channels, whose state is changed by
+// the evaluations using them.
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	mu     sync.RWMutex
+	frozen bool
+	store  map[string]Object
+	slots  []Object
+	names  []string // the name bound to each slot.
+	outer  *Environment
+}
+
+// Freeze makes e immutable, binding a name in it panics from then on.
+// It must be called before e is shared, the environments enclosing e
+// are not frozen.
+func (e *Environment) Freeze() {
+	e.mu.Lock()
+	e.frozen = true
+	e.mu.Unlock()
+}
+
+// Frozen reports whether e was frozen.
+func (e *Environment) Frozen() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.frozen
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
-	o, ok := e.store[name]
-	if !ok && e.outer != nil {
-		o, ok = e.outer.Get(name)
+	for env := e; env != nil; env = env.outer {
+		if o, ok := env.lookup(name); ok {
+			return o, true
+		}
 	}
+	return nil, false
+}
+
+// lookup looks up name in e only.
+func (e *Environment) lookup(name string) (Object, bool) {
+	if !e.frozen {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+	}
+
+	for i, n := range e.names {
+		if n == name && e.slots[i] != nil {
+			return e.slots[i], true
+		}
+	}
+	o, ok := e.store[name]
 	return o, ok
 }
 
+// GetAt looks up the binding in slot of the environment depth levels
+// up from e, skipping the lookups in the environments in between. If
+// that environment does not use slots or the slot has not been assigned
+// yet, name is looked up from there on.
+func (e *Environment) GetAt(depth, slot int, name string) (Object, bool) {
+	env := e
+	for i := 0; i < depth && env.outer != nil; i++ {
+		env = env.outer
+	}
+
+	if slot < len(env.slots) {
+		o := env.slot(slot)
+
+		if o != nil {
+			return o, true
+		}
+		return env.outer.Get(name)
+	}
+
+	return env.Get(name)
+}
+
+// slot returns the value of slot of e.
+func (e *Environment) slot(slot int) Object {
+	if e.frozen {
+		return e.slots[slot]
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.slots[slot]
+}
+
+// Set binds val to name in e.
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.mutable()
+
+	for i, n := range e.names {
+		if n == name {
+			e.slots[i] = val
+			return val
+		}
+	}
+
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
+
+// SetAt binds val to slot of e, or to name if e does not use slots.
+func (e *Environment) SetAt(slot int, name string, val Object) Object {
+	if slot < len(e.slots) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		e.mutable()
+		e.slots[slot] = val
+		return val
+	}
+	return e.Set(name, val)
+}
+
+// mutable panics if e is frozen, binding a name in a frozen
+// environment would race with the lookups sharing it.
+func (e *Environment) mutable() {
+	if e.frozen {
+		panic("objects: binding a name in a frozen environment")
+	}
+}
@@ -0,0 +1,100 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+)
+
+const ERROR_VALUE = "ERROR_VALUE"
+
+// The kinds of errors. Errors of kind LimitError are raised once an
+// evaluation exceeds a budget or is cancelled, they can not be caught.
+const (
+	ThrownError     = "Error"
+	TypeError       = "TypeError"
+	NameError       = "NameError"
+	ArithmeticError = "ArithmeticError"
+	ArgumentError   = "ArgumentError"
+	ImportError     = "ImportError"
+	MatchError      = "MatchError"
+	LimitError      = "LimitError"
+	ChannelError    = "ChannelError"
+	DeadlockError   = "DeadlockError"
+)
+
+// ErrorValue is an error caught by a try expression, bound to the
+// parameter of its catch clause. Unlike an Error it does not unwind
+// the evaluation, it is a value like any other until it is thrown.
+// It can be shared like the error it holds once that was caught.
+type ErrorValue struct {
+	Error *Error
+}
+
+// Errorf returns an error of kind with the formatted message.
+func Errorf(kind string, format string, a ...interface{}) *Error {
+	return &Error{Value: fmt.Sprintf(format, a...), Kind: kind}
+}
+
+// NewImportError returns the error of an import that failed with
+// err. The error raised by the imported module keeps its kind.
+func NewImportError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return &Error{Value: err.Error(), Kind: e.Kind}
+	}
+	return Errorf(ImportError, "%v", err)
+}
+
+// NewThrown returns the error thrown by 'throw val', a thrown
+// string is the message of the error. An ErrorValue is thrown
+// as the error it holds.
+func NewThrown(val Object) *Error {
+	switch val := val.(type) {
+	case *ErrorValue:
+		return val.Error
+	case *String:
+		return &Error{Value: val.Value, Kind: ThrownError, Thrown: val}
+	default:
+		return &Error{Value: val.Inspect(), Kind: ThrownError, Thrown: val}
+	}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string { return e.Value }
+
+// Catchable reports whether e can be caught by a try expression.
+func (e *Error) Catchable() bool { return e.Kind != LimitError }
+
+// implement Object interface
+func (e *ErrorValue) Type() Type { return ERROR_VALUE }
+func (e *ErrorValue) Inspect() string {
+	return "error(" + e.Error.Kind + ": " + e.Error.Value + ")"
+}
+func (e *ErrorValue) Equals(other Object) bool {
+	o, ok := other.(*ErrorValue)
+	return ok && o.Error == e.Error
+}
+
+// Member returns the member name of the error: its message, its
+// kind, the line at which it was raised or the value it was thrown
+// with. The line and the value are null if they are not known.
+func (e *ErrorValue) Member(name string) (Object, bool) {
+	switch name {
+	case "message":
+		return &String{Value: e.Error.Value}, true
+	case "kind":
+		return &String{Value: e.Error.Kind}, true
+	case "line":
+		if e.Error.Line == 0 {
+			return NewNull(), true
+		}
+		return NewInteger(int64(e.Error.Line)), true
+	case "value":
+		if e.Error.Thrown == nil {
+			return NewNull(), true
+		}
+		return e.Error.Thrown, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,38 @@
+package objects
+
+const (
+	GENERATOR = "GENERATOR"
+	METHOD    = "METHOD"
+	CHANNEL   = "CHANNEL"
+)
+
+// Method is a method of a value bound to it by a member expression
+// (g.next), calling it calls the method of the receiver. The methods
+// are implemented by the engines, which own the values that have any,
+// or by the package of the value, e.g. those of channels. A method
+// can be shared like its receiver, calling it may change the receiver.
+type Method struct {
+	Receiver Object
+	Name     string
+}
+
+// NewIteration returns the result of a step of an iterator, the hash
+// {value: value, done: done}. A missing value (nil) is null.
+func NewIteration(value Object, done bool) *Hash {
+	if value == nil {
+		value = NewNull()
+	}
+
+	h := NewHash()
+	h.Set(&String{Value: "value"}, value)
+	h.Set(&String{Value: "done"}, NewBoolean(done))
+	return h
+}
+
+// implement Object interface
+func (m *Method) Type() Type      { return METHOD }
+func (m *Method) Inspect() string { return m.Receiver.Inspect() + "." + m.Name }
+func (m *Method) Equals(other Object) bool {
+	o, ok := other.(*Method)
+	return ok && o.Receiver.Equals(m.Receiver) && o.Name == m.Name
+}
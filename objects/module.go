@@ -0,0 +1,30 @@
+package objects
+
+const MODULE = "MODULE"
+
+type (
+	// Module is a module loaded by an import statement,
+	// its members are the names exported by the module. It is
+	// not changed once loaded, so it can be shared if its
+	// members can.
+	Module struct {
+		Path    string
+		Members map[string]Object
+	}
+
+	// Importer loads the modules imported by a program, the path is
+	// the one of the import statement. A module is loaded once, every
+	// import of it returns the same Module. An importer shared by
+	// evaluations running concurrently must be safe for concurrent use.
+	Importer interface {
+		Import(path string) (*Module, error)
+	}
+)
+
+// implement Object interface
+func (m *Module) Type() Type      { return MODULE }
+func (m *Module) Inspect() string { return "module(" + m.Path + ")" }
+func (m *Module) Equals(other Object) bool {
+	o, ok := other.(*Module)
+	return ok && o == m
+}
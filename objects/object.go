@@ -1,7 +1,16 @@
+// Package objects defines the values of programs, shared by both
+// engines, and the environments binding them.
+//
+// The values are not synchronised, most of them are never changed once
+// created and can be shared by goroutines freely. The others, e.g. the
+// generators and channels of the engines and the scopes of the virtual
+// machine, belong to the evaluation that created them; the documentation
+// of each type states which it is.
 package objects
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/Despire/interpreter/ast"
@@ -14,36 +23,101 @@ const (
 	RETURN        = "RETURN_VALUE"
 	ERROR         = "ERROR"
 	FUNCTION      = "FUNCTION"
+	QUOTE         = "QUOTE"
+	MACRO         = "MACRO"
+	STRING        = "STRING"
 )
 
 type (
+	// Type is the type of a value, safe to use concurrently.
 	Type string
 
+	// Object is a value of a program. The implementations state
+	// whether they can be shared by goroutines.
 	Object interface {
 		Type() Type
 		Inspect() string
+		// Equals reports whether the object is equal to other, values
+		// are compared by value, functions by identity.
+		Equals(other Object) bool
 	}
 )
 
 type (
+	// Return wraps the value of a return statement while it unwinds
+	// the calls of the evaluation that returned it.
 	Return struct {
 		Value Object
 	}
+
+	// Integer is immutable, the small ones are shared by every
+	// goroutine through NewInteger.
 	Integer struct {
 		Value int64
 	}
 
+	// Boolean is immutable, true and false are shared by every
+	// goroutine through NewBoolean.
 	Boolean struct {
 		Value bool
 	}
 
+	// Null is immutable, NewNull returns the one shared by all.
 	Null struct{}
 
-	Error struct {
+	// String is a string constant, e.g. the path of an import
+	// or the name of a member of a module in compiled programs.
+	// It is immutable, safe to share.
+	String struct {
 		Value string
 	}
 
+	// Error is an error raised at runtime or thrown by a script, it
+	// unwinds the evaluation until it is caught by a try expression.
+	// It belongs to the evaluation that raised it, which sets its Line
+	// while it unwinds; once returned it is not changed anymore.
+	Error struct {
+		Value string // the message.
+		Kind  string
+		// Line is the source line at which the error was
+		// raised, it is zero if the line is not known.
+		Line int
+		// Thrown is the value passed to throw, it
+		// is nil for the errors raised at runtime.
+		Thrown Object
+	}
+
+	// Function is a function of the evaluator, immutable once created.
+	// Its calls bind their arguments in new environments, so it can be
+	// called concurrently if its environment can be shared, e.g. if it
+	// is frozen.
 	Function struct {
+		// Name is the name of a declared function,
+		// it is empty for function expressions.
+		Name       string
+		Parameters []*ast.Identifier
+		Defaults   []ast.Expression // as in ast.FunctionLiteral.
+		Rest       *ast.Identifier
+		Body       *ast.BlockStatement
+		Env        *Environment
+		// Generator is set if the body yields, a call
+		// of the function returns a generator.
+		Generator bool
+		// Locals holds the names of the slots of the environment
+		// of a call, it is nil if the function was not resolved.
+		Locals []string
+	}
+
+	// Quote holds the unevaluated expression passed to quote. The
+	// expression is a copy owned by the quote, it is not changed.
+	Quote struct {
+		Node ast.Node
+	}
+
+	// Macro is a macro bound by a top-level let statement, it is
+	// called with the quoted arguments when macros are expanded,
+	// which does not change it.
+	Macro struct {
 		Parameters []*ast.Identifier
 		Body       *ast.BlockStatement
 		Env        *Environment
@@ -53,39 +127,93 @@ type (
 // implement Object interface
 func (i *Integer) Inspect() string { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() Type      { return INTEGER }
+func (i *Integer) Equals(other Object) bool {
+	o, ok := other.(*Integer)
+	return ok && o.Value == i.Value
+}
 
 // implement Object interface
 func (b *Boolean) Inspect() string { return fmt.Sprintf("%t", b.Value) }
 func (b *Boolean) Type() Type      { return BOOLEAN }
+func (b *Boolean) Equals(other Object) bool {
+	o, ok := other.(*Boolean)
+	return ok && o.Value == b.Value
+}
 
 // implement Object interface
 func (n *Null) Inspect() string { return "null" }
 func (n *Null) Type() Type      { return NULL }
+func (n *Null) Equals(other Object) bool {
+	_, ok := other.(*Null)
+	return ok
+}
+
+// implement Object interface
+func (s *String) Inspect() string { return strconv.Quote(s.Value) }
+func (s *String) Type() Type      { return STRING }
+func (s *String) Equals(other Object) bool {
+	o, ok := other.(*String)
+	return ok && o.Value == s.Value
+}
 
 // implement Object interface
 func (r *Return) Inspect() string { return r.Value.Inspect() }
 func (r *Return) Type() Type      { return RETURN }
+func (r *Return) Equals(other Object) bool {
+	o, ok := other.(*Return)
+	return ok && (o.Value == r.Value || r.Value != nil && r.Value.Equals(o.Value))
+}
 
 // implement Object interface
 func (e *Error) Inspect() string { return "ERROR: " + e.Value }
 func (e *Error) Type() Type      { return ERROR }
+func (e *Error) Equals(other Object) bool {
+	o, ok := other.(*Error)
+	return ok && o.Value == e.Value
+}
 
 // implement Object interface
 func (f *Function) Type() Type { return FUNCTION }
+func (f *Function) Equals(other Object) bool {
+	o, ok := other.(*Function)
+	return ok && o == f
+}
 func (f *Function) Inspect() string {
-	buff := new(strings.Builder)
-
 	params := []string{}
 	for _, p := range f.Parameters {
 		params = append(params, p.String())
 	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
+	}
 
-	buff.WriteString("fn")
-	buff.WriteString("(")
-	buff.WriteString(strings.Join(params, ", "))
-	buff.WriteString(") {\n")
-	buff.WriteString(f.Body.String())
-	buff.WriteString("\n}")
+	// printed like the closures of the virtual machine, which
+	// do not keep the source of their defaults and bodies.
+	if f.Name == "" {
+		return "fn(" + strings.Join(params, ", ") + ") { ... }"
+	}
+	return "fn " + f.Name + "(" + strings.Join(params, ", ") + ") { ... }"
+}
 
-	return buff.String()
+// implement Object interface
+func (q *Quote) Type() Type      { return QUOTE }
+func (q *Quote) Inspect() string { return "QUOTE(" + q.Node.String() + ")" }
+func (q *Quote) Equals(other Object) bool {
+	o, ok := other.(*Quote)
+	return ok && ast.Equal(o.Node, q.Node)
+}
+
+// implement Object interface
+func (m *Macro) Type() Type { return MACRO }
+func (m *Macro) Inspect() string {
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	return "macro(" + strings.Join(params, ", ") + ") {\n" + m.Body.String() + "\n}"
+}
+func (m *Macro) Equals(other Object) bool {
+	o, ok := other.(*Macro)
+	return ok && o == m
 }
@@ -0,0 +1,132 @@
+package objects
+
+import "testing"
+
+func TestNewInteger(t *testing.T) {
+	tests := []struct {
+		value  int64
+		shared bool
+	}{
+		{0, true},
+		{MinCachedInteger, true},
+		{MaxCachedInteger, true},
+		{MinCachedInteger - 1, false},
+		{MaxCachedInteger + 1, false},
+	}
+
+	for _, tt := range tests {
+		a, b := NewInteger(tt.value), NewInteger(tt.value)
+
+		if a.Value != tt.value {
+			t.Errorf("wrong value. want=%d, have=%d", tt.value, a.Value)
+		}
+		if (a == b) != tt.shared {
+			t.Errorf("integer %d shared=%t, want %t", tt.value, a == b, tt.shared)
+		}
+	}
+}
+
+func TestSingletons(t *testing.T) {
+	if NewBoolean(true) != NewBoolean(true) || NewBoolean(false) != NewBoolean(false) {
+		t.Errorf("booleans are not shared")
+	}
+	if NewBoolean(true).Value != true || NewBoolean(false).Value != false {
+		t.Errorf("wrong values of the booleans")
+	}
+	if NewNull() != NewNull() {
+		t.Errorf("null is not shared")
+	}
+}
+
+func TestEquals(t *testing.T) {
+	fn := &Function{}
+	closure := &Closure{}
+
+	hash := func(pairs ...Object) *Hash {
+		h := NewHash()
+		for i := 0; i < len(pairs); i += 2 {
+			h.Set(pairs[i].(Hashable), pairs[i+1])
+		}
+		return h
+	}
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{NewInteger(5000), NewInteger(5000), true},
+		{&Integer{Value: 1}, NewInteger(1), true},
+		{NewInteger(1), NewInteger(2), false},
+		{&Boolean{Value: true}, NewBoolean(true), true},
+		{&Boolean{Value: false}, NewBoolean(true), false},
+		{&Null{}, NewNull(), true},
+		{NewInteger(1), NewBoolean(true), false},
+		{NewInteger(0), NewNull(), false},
+		{&Error{Value: "a"}, &Error{Value: "a"}, true},
+		{&Return{Value: NewInteger(3000)}, &Return{Value: NewInteger(3000)}, true},
+		{fn, fn, true},
+		{fn, &Function{}, false},
+		{closure, closure, true},
+		{closure, &Closure{}, false},
+		{&Array{Elements: []Object{NewInteger(1), &Array{}}}, &Array{Elements: []Object{NewInteger(1), &Array{}}}, true},
+		{&Array{Elements: []Object{NewInteger(1)}}, &Array{Elements: []Object{NewInteger(2)}}, false},
+		{&Array{Elements: []Object{NewInteger(1)}}, &Array{}, false},
+		{hash(&String{Value: "a"}, NewInteger(1), NewInteger(1), NewBoolean(true)), hash(NewInteger(1), NewBoolean(true), &String{Value: "a"}, NewInteger(1)), true},
+		{hash(&String{Value: "1"}, NewInteger(1)), hash(NewInteger(1), NewInteger(1)), false},
+		{hash(&String{Value: "a"}, NewInteger(1)), hash(&String{Value: "a"}, NewInteger(1), &String{Value: "b"}, NewInteger(1)), false},
+		{hash(), &Array{}, false},
+	}
+
+	for _, tt := range tests {
+		if tt.a.Equals(tt.b) != tt.expected || tt.b.Equals(tt.a) != tt.expected {
+			t.Errorf("%s equals %s, want %t", tt.a.Inspect(), tt.b.Inspect(), tt.expected)
+		}
+	}
+}
+
+// BenchmarkNewInteger shows that the cached integers are not allocated.
+func BenchmarkNewInteger(b *testing.B) {
+	var sink *Integer
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sink = NewInteger(int64(i % MaxCachedInteger))
+		}
+	})
+
+	b.Run("allocated", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sink = NewInteger(int64(MaxCachedInteger + 1 + i))
+		}
+	})
+
+	_ = sink
+}
+
+func TestFrozenEnvironment(t *testing.T) {
+	globals := NewEnvironment()
+	globals.Set("a", NewInteger(1))
+	globals.Freeze()
+
+	env := NewEnclosedEnvironment(globals)
+	env.Set("a", NewInteger(2))
+
+	if o, ok := env.Get("a"); !ok || o.Inspect() != "2" {
+		t.Errorf("wrong value of a in the enclosed environment. want=2, have=%v", o)
+	}
+	if o, ok := globals.Get("a"); !ok || o.Inspect() != "1" {
+		t.Errorf("wrong value of a in the frozen environment. want=1, have=%v", o)
+	}
+	if env.Frozen() || !globals.Frozen() {
+		t.Errorf("wrong frozen flags. want=false, true, have=%t, %t", env.Frozen(), globals.Frozen())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("binding a name in a frozen environment did not panic")
+		}
+	}()
+	globals.Set("b", NewInteger(3))
+}
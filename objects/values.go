@@ -0,0 +1,46 @@
+package objects
+
+// The integers in [MinCachedInteger, MaxCachedInteger]
+// are allocated once and shared by NewInteger.
+const (
+	MinCachedInteger = -128
+	MaxCachedInteger = 1024
+)
+
+var (
+	trueValue  = &Boolean{Value: true}
+	falseValue = &Boolean{Value: false}
+	nullValue  = &Null{}
+
+	integers [MaxCachedInteger - MinCachedInteger + 1]Integer
+)
+
+func init() {
+	for i := range integers {
+		integers[i].Value = int64(i + MinCachedInteger)
+	}
+}
+
+// NewInteger returns an integer of value v, small integers are shared.
+func NewInteger(v int64) *Integer {
+	if IsCachedInteger(v) {
+		return &integers[v-MinCachedInteger]
+	}
+	return &Integer{Value: v}
+}
+
+// IsCachedInteger reports whether NewInteger shares the integer of value v.
+func IsCachedInteger(v int64) bool {
+	return v >= MinCachedInteger && v <= MaxCachedInteger
+}
+
+// NewBoolean returns the shared boolean of value b.
+func NewBoolean(b bool) *Boolean {
+	if b {
+		return trueValue
+	}
+	return falseValue
+}
+
+// NewNull returns the shared null.
+func NewNull() *Null { return nullValue }
@@ -0,0 +1,251 @@
+// Package optimizer implements a pass that simplifies the ast
+// before it is executed, without changing the result of a program.
+package optimizer
+
+import (
+	"strconv"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/token"
+)
+
+// Optimize simplifies node in place and returns the result:
+//
+//   - prefix and infix expressions over literals are folded into
+//     a literal, unless evaluating them fails (e.g. division by zero),
+//   - if expressions with a literal condition are replaced by the
+//     branch that is taken,
+//   - additions and subtractions of 0 and multiplications and
+//     divisions by 1 are replaced by the other operand when it
+//     is known to evaluate to an integer or to fail.
+//
+// The code quoted by calls of quote is left unchanged.
+func Optimize(node ast.Node) ast.Node {
+	quoted := map[ast.Node]bool{}
+	ast.Inspect(node, func(n ast.Node) bool {
+		if !ast.IsCallTo(n, ast.Quote) {
+			return true
+		}
+		for _, a := range n.(*ast.CallExpression).Arguments {
+			ast.Inspect(a, func(n ast.Node) bool {
+				quoted[n] = true
+				return true
+			})
+		}
+		return false
+	})
+
+	return ast.Modify(node, func(n ast.Node) ast.Node {
+		if quoted[n] {
+			return n
+		}
+		return optimize(n)
+	})
+}
+
+func optimize(node ast.Node) ast.Node {
+	switch n := node.(type) {
+	case *ast.PrefixExpression:
+		return foldPrefix(n)
+	case *ast.InfixExpression:
+		return foldInfix(n)
+	case *ast.IfExpression:
+		return pruneIf(n)
+	default:
+		return node
+	}
+}
+
+func foldPrefix(n *ast.PrefixExpression) ast.Expression {
+	pos := ast.Pos(n)
+
+	switch right := n.Right.(type) {
+	case *ast.IntegerLiteral:
+		switch n.Operator {
+		case token.MINUS:
+			return integer(-int64(right.Value), pos)
+		case token.BANG:
+			return ast.NewBoolean(false, pos)
+		}
+	case *ast.BooleanLiteral:
+		if n.Operator == token.BANG {
+			return ast.NewBoolean(!right.Value, pos)
+		}
+	}
+
+	return n
+}
+
+func foldInfix(n *ast.InfixExpression) ast.Expression {
+	pos := ast.Pos(n)
+
+	switch left := n.Left.(type) {
+	case *ast.IntegerLiteral:
+		if right, ok := n.Right.(*ast.IntegerLiteral); ok {
+			return foldIntegers(n, int64(left.Value), int64(right.Value))
+		}
+		if _, ok := n.Right.(*ast.BooleanLiteral); ok {
+			return foldMixed(n)
+		}
+	case *ast.BooleanLiteral:
+		switch right := n.Right.(type) {
+		case *ast.BooleanLiteral:
+			switch n.Operator {
+			case token.EQUAL:
+				return ast.NewBoolean(left.Value == right.Value, pos)
+			case token.NEQUAL:
+				return ast.NewBoolean(left.Value != right.Value, pos)
+			}
+		case *ast.IntegerLiteral:
+			return foldMixed(n)
+		}
+	}
+
+	return simplify(n)
+}
+
+func foldIntegers(n *ast.InfixExpression, l, r int64) ast.Expression {
+	pos := ast.Pos(n)
+
+	switch n.Operator {
+	case token.PLUS:
+		return integer(l+r, pos)
+	case token.MINUS:
+		return integer(l-r, pos)
+	case token.ASTERISK:
+		return integer(l*r, pos)
+	case token.SLASH:
+		if r == 0 {
+			return n
+		}
+		return integer(l/r, pos)
+	case token.LESST:
+		return ast.NewBoolean(l < r, pos)
+	case token.GREATERT:
+		return ast.NewBoolean(l > r, pos)
+	case token.EQUAL:
+		return ast.NewBoolean(l == r, pos)
+	case token.NEQUAL:
+		return ast.NewBoolean(l != r, pos)
+	}
+
+	return n
+}
+
+// foldMixed folds the comparison of an integer and a boolean,
+// which are never equal. Other operators fail at runtime.
+func foldMixed(n *ast.InfixExpression) ast.Expression {
+	switch n.Operator {
+	case token.EQUAL:
+		return ast.NewBoolean(false, ast.Pos(n))
+	case token.NEQUAL:
+		return ast.NewBoolean(true, ast.Pos(n))
+	}
+	return n
+}
+
+// simplify removes the neutral operand of an arithmetic identity.
+func simplify(n *ast.InfixExpression) ast.Expression {
+	switch n.Operator {
+	case token.PLUS:
+		if isLiteral(n.Right, 0) && isInteger(n.Left) {
+			return n.Left
+		}
+		if isLiteral(n.Left, 0) && isInteger(n.Right) {
+			return n.Right
+		}
+	case token.MINUS:
+		if isLiteral(n.Right, 0) && isInteger(n.Left) {
+			return n.Left
+		}
+	case token.ASTERISK:
+		if isLiteral(n.Right, 1) && isInteger(n.Left) {
+			return n.Left
+		}
+		if isLiteral(n.Left, 1) && isInteger(n.Right) {
+			return n.Right
+		}
+	case token.SLASH:
+		if isLiteral(n.Right, 1) && isInteger(n.Left) {
+			return n.Left
+		}
+	}
+
+	return n
+}
+
+// pruneIf replaces an if expression with a literal condition by the
+// branch that is taken. A branch of a single expression is replaced by
+// the expression, otherwise by an if expression that always takes it.
+func pruneIf(n *ast.IfExpression) ast.Expression {
+	var taken bool
+
+	switch c := n.Condition.(type) {
+	case *ast.IntegerLiteral:
+		taken = true
+	case *ast.BooleanLiteral:
+		taken = c.Value
+	default:
+		return n
+	}
+
+	branch := n.Alternative
+	if taken {
+		branch = n.Consequence
+	}
+
+	if branch == nil {
+		// the value is null, the consequence is never evaluated.
+		return &ast.IfExpression{
+			Token:     n.Token,
+			Condition: ast.NewBoolean(false, ast.Pos(n.Condition)),
+			Consequence: &ast.BlockStatement{
+				Token: n.Consequence.Token,
+				End:   n.Consequence.End,
+			},
+		}
+	}
+
+	if len(branch.Statements) == 1 {
+		if es, ok := branch.Statements[0].(*ast.ExpressionStatement); ok {
+			return es.Expression
+		}
+	}
+
+	return &ast.IfExpression{
+		Token:       n.Token,
+		Condition:   ast.NewBoolean(true, ast.Pos(n.Condition)),
+		Consequence: branch,
+	}
+}
+
+// isInteger reports whether e evaluates to an integer or fails.
+func isInteger(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return e.Operator == token.MINUS
+	case *ast.InfixExpression:
+		switch e.Operator {
+		case token.PLUS:
+			// strings are concatenated.
+			return isInteger(e.Left) && isInteger(e.Right)
+		case token.MINUS, token.ASTERISK, token.SLASH:
+			return true
+		}
+	}
+	return false
+}
+
+func isLiteral(e ast.Expression, v int) bool {
+	i, ok := e.(*ast.IntegerLiteral)
+	return ok && i.Value == v
+}
+
+func integer(v int64, pos token.Position) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{
+		Token: token.Token{Typ: token.INTEGER, Literal: strconv.FormatInt(v, 10), Pos: pos},
+		Value: int(v),
+	}
+}
@@ -0,0 +1,116 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/eval"
+	"github.com/Despire/interpreter/lexer"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/parser"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 * 60 * 60", "7200"},
+		{"!true", "false"},
+		{"!!5", "true"},
+		{"-(1 + 2)", "-3"},
+		{"1 - 2 * 3 < 0", "true"},
+		{"1 == true", "false"},
+		{"true != (1 < 2)", "false"},
+		{"10 / 0", "(10 / 0)"},
+		{"(1 - 1) + (5 / 0)", "(5 / 0)"},
+		{"let a = 1; a * 1", "let a = 1;(a * 1)"},
+		{"let a = 1; (a + 2) * 1 + 0", "let a = 1;((a + 2) * 1)"},
+		{"let a = 1; (a - 2) * 1 + 0", "let a = 1;(a - 2)"},
+		{"(1 + 2) * 1 + 0", "3"},
+		{`("a" + "b") + 0`, `(("a" + "b") + 0)`},
+		{"let f = fn(x, y) { (x + y) * 1 }; f(1, 2)", "let f = fn(x, y) ((x + y) * 1);f(1, 2)"},
+		{"let a = 1; 1 * -a - 0", "let a = 1;(-a)"},
+		{"let a = 1; 0 - a", "let a = 1;(0 - a)"},
+		{"true + 0", "(true + 0)"},
+		{"-true", "(-true)"},
+		{"if (1 < 2) { 10 } else { 20 }", "10"},
+		{"if (1 > 2) { 10 } else { 20 }", "20"},
+		{"if (1 > 2) { 10 }", "iffalse "},
+		{"if (0) { let a = 1; a } else { 2 }", "iftrue let a = 1;a"},
+		{"let f = fn(x) { if (true) { return x; } 1 }; f(2 + 2)", "let f = fn(x) iftrue return x;1;f(4)"},
+		{"let a = 1; if (a) { 1 + 1 }", "let a = 1;ifa 2"},
+		{"quote(1 + 1 * unquote(2 * 3))", "quote((1 + (1 * unquote((2 * 3)))))"},
+		{"quote(1 + 1) + (2 * 3)", "(quote((1 + 1)) + 6)"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		optimized := Optimize(program)
+
+		if optimized.String() != tt.expected {
+			t.Errorf("wrong optimization of %q. want=%q, have=%q", tt.input, tt.expected, optimized.String())
+		}
+	}
+}
+
+func TestOptimizePreservesResults(t *testing.T) {
+	inputs := []string{
+		"2 * 60 * 60",
+		"!(1 < 2) == !true",
+		"10 / 0",
+		"let a = true; a + 0",
+		"let a = true; 1 * a",
+		"let a = 5; -a * 1 + 0 - 0 / 1",
+		"if (1 > 2) { 10 }",
+		"if (2) { 1; 2; 3 } else { 4 }",
+		"if (false) { let x = 1; } x",
+		"let f = fn(n) { if (n < 1) { return 0; } n + f(n - 1) }; f(10 * 1)",
+		"-9223372036854775807 - 1 - 1",
+		`("a" + "b") + 0`,
+		`let f = fn(x, y) { (x + y) * 1 }; f("a", "b")`,
+		`let f = fn(x) { 0 + (x + x) }; f("a")`,
+	}
+
+	for _, input := range inputs {
+		want := eval.Eval(parse(t, input), objects.NewEnvironment())
+		have := eval.Eval(Optimize(parse(t, input)), objects.NewEnvironment())
+
+		if inspect(want) != inspect(have) {
+			t.Errorf("optimization of %q changed the result. want=%s, have=%s", input, inspect(want), inspect(have))
+		}
+	}
+}
+
+func TestOptimizeIdempotent(t *testing.T) {
+	for _, input := range []string{
+		"if (1 > 2) { 10 }",
+		"if (true) { let a = 1; a }",
+		"let a = 1; (a + 2) * 1 + 0",
+	} {
+		once := Optimize(parse(t, input))
+		twice := Optimize(ast.Clone(once))
+
+		if !ast.Equal(once, twice) {
+			t.Errorf("optimizing %q twice differs. once=%q, twice=%q", input, once.String(), twice.String())
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	for _, err := range p.Errors() {
+		t.Fatalf("parser error: %q", err)
+	}
+
+	return program
+}
+
+func inspect(o objects.Object) string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.Inspect()
+}
@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Despire/interpreter/lexer"
+)
+
+// generateProgram returns a program of n functions that
+// use all the kinds of expressions and statements.
+func generateProgram(n int) string {
+	var b strings.Builder
+
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "let %s = fn(x, y) {\n", name(i))
+		fmt.Fprintf(&b, "  if (x < %d) { return !(y == x); } else { return -x * y / 2; }\n", i)
+		fmt.Fprintf(&b, "  let g = fn(z) { z + x - y * (%d + z) };\n", i)
+		fmt.Fprintf(&b, "  g(x + 1) != g(y)\n")
+		fmt.Fprintf(&b, "};\n")
+		fmt.Fprintf(&b, "%s(%d, %s(1, 2));\n", name(i), i*7, name(i))
+	}
+
+	return b.String()
+}
+
+func BenchmarkParser(b *testing.B) {
+	input := generateProgram(1000)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			b.Fatalf("parser errors: %v", p.Errors())
+		}
+	}
+}
+
+// name returns the name of the i-th function, identifiers
+// consist of letters only.
+func name(i int) string {
+	n := []byte{'v'}
+	for ; i > 0; i /= 26 {
+		n = append(n, byte('a'+i%26))
+	}
+	return string(n)
+}
@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/lexer"
+)
+
+// jsonTestInputs returns the inputs of the parser tests in this package
+// together with programs that exercise the remaining node types.
+func jsonTestInputs() []string {
+	inputs := []string{
+		integerLiteralInput,
+		identifierInput,
+		returnInput,
+		letInput,
+		macroInput,
+		moduleInput,
+		matchInput,
+	}
+
+	for _, tt := range precedenceTests {
+		inputs = append(inputs, tt.input)
+	}
+	for _, tt := range infixTests {
+		inputs = append(inputs, tt.in)
+	}
+	for _, tt := range prefixTests {
+		inputs = append(inputs, tt.in)
+	}
+	for _, tt := range tryTests {
+		inputs = append(inputs, tt.input)
+	}
+	for _, tt := range letPatternTests {
+		inputs = append(inputs, tt.input)
+	}
+	for _, tt := range parameterTests {
+		inputs = append(inputs, tt.input)
+	}
+	for _, tt := range functionStatementTests {
+		inputs = append(inputs, tt.input)
+	}
+	for _, tt := range yieldTests {
+		inputs = append(inputs, tt.input)
+	}
+	for _, tt := range concurrencyTests {
+		inputs = append(inputs, tt.input)
+	}
+
+	return append(inputs,
+		"let a = 010;",
+		"if (x < y) { x } else { y }",
+		"// comment\nlet add = fn(x, y) { x + y; }; // add\nadd(1, 2)(3);",
+		`match (x) { [] => 0, [a, [b], ..._] => a, {k: {v}, w = 2} => v, true => 1 }; match (y) {}`,
+	)
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, input := range jsonTestInputs() {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		encoded, err := ast.EncodeJSON(program)
+		if err != nil {
+			t.Fatalf("EncodeJSON(%q) failed: %v", input, err)
+		}
+
+		decoded, err := ast.DecodeProgramJSON(encoded)
+		if err != nil {
+			t.Fatalf("DecodeJSON(%s) failed: %v", encoded, err)
+		}
+
+		if !ast.Equal(program, decoded) {
+			t.Errorf("decoded program differs for %q\nhave: %s\nwant: %s", input, decoded.String(), program.String())
+		}
+
+		if decoded.String() != program.String() {
+			t.Errorf("decoded program prints differently for %q\nhave: %s\nwant: %s", input, decoded.String(), program.String())
+		}
+
+		reencoded, err := ast.EncodeJSON(decoded)
+		if err != nil {
+			t.Fatalf("EncodeJSON(%q) failed: %v", input, err)
+		}
+
+		if string(reencoded) != string(encoded) {
+			t.Errorf("encoding is not stable for %q\nfirst:  %s\nsecond: %s", input, encoded, reencoded)
+		}
+	}
+}
+
+func TestJSONDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{`, "unexpected end of JSON input"},
+		{`{"kind":"Program","version":2,"statements":[]}`, "unsupported version 2, expected 1"},
+		{`{"kind":"Program","version":1}`, `missing field "statements"`},
+		{`{"kind":"Foo"}`, `unknown node kind "Foo"`},
+		{
+			`{"kind":"Program","version":1,"statements":[{"kind":"Identifier","name":"a"}]}`,
+			"statements[0]: expected a statement, got Identifier",
+		},
+		{
+			`{"kind":"LetStatement","name":{"kind":"IntegerLiteral","value":1},"value":null}`,
+			"name: expected Identifier, got IntegerLiteral",
+		},
+		{
+			`{"kind":"FunctionStatement","function":{"kind":"FunctionLiteral","parameters":[],"body":null}}`,
+			"function: missing name",
+		},
+		{
+			`{"kind":"InfixExpression","operator":"+","left":{"kind":"IntegerLiteral","value":"1"},"right":null}`,
+			"left: value: json: cannot unmarshal string into Go value of type int",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := ast.DecodeJSON([]byte(tt.input))
+		if err == nil {
+			t.Errorf("DecodeJSON(%s) expected an error", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("DecodeJSON(%s) wrong error\nhave: %v\nwant: %v", tt.input, err, tt.expected)
+		}
+	}
+}
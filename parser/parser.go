@@ -19,6 +19,7 @@ const (
 	PRODUCT
 	PREFIX
 	FNCALL
+	INDEX
 )
 
 var precedences = map[token.Type]precedence{
@@ -31,16 +32,22 @@ var precedences = map[token.Type]precedence{
 	token.SLASH:           PRODUCT,
 	token.ASTERISK:        PRODUCT,
 	token.LEFTPARENTHESIS: FNCALL,
+	token.DOT:             FNCALL,
+	token.LEFTSQUARE:      INDEX,
 }
 
 // Parser parses the token from the lexer,
 // to create a data structure (ast) to represent
-// the source code.
+// the source code. A Parser is not safe for
+// concurrent use, but parsers of their own can
+// run concurrently.
 type Parser struct {
 	lexer     *lexer.Lexer
 	errors    []string
 	token     token.Token
 	peekToken token.Token
+	depth     int // the number of enclosing blocks.
+	functions int // the number of enclosing function bodies, reset in macros.
 
 	prefixParseHandlers map[token.Type]ast.PrefixParseHandler
 	infixParseHandlers  map[token.Type]ast.InfixParseHandler
@@ -59,11 +66,21 @@ func New(lexer *lexer.Lexer) *Parser {
 	p.registerPrefix(token.FALSE, p.parseBool)
 	p.registerPrefix(token.IDENTIFIER, p.parseIdentifier)
 	p.registerPrefix(token.INTEGER, p.parseIntegerLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.LEFTPARENTHESIS, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.YIELD, p.parseYieldExpression)
+	p.registerPrefix(token.SPAWN, p.parseSpawnExpression)
+	p.registerPrefix(token.CHAN, p.parseChanExpression)
+	p.registerPrefix(token.SELECT, p.parseSelectExpression)
+	p.registerPrefix(token.LEFTSQUARE, p.parseArrayLiteral)
+	p.registerPrefix(token.LEFTBRACKET, p.parseHashLiteral)
 
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
@@ -74,6 +91,8 @@ func New(lexer *lexer.Lexer) *Parser {
 	p.registerInfix(token.LESST, p.parseInfixExpression)
 	p.registerInfix(token.GREATERT, p.parseInfixExpression)
 	p.registerInfix(token.LEFTPARENTHESIS, p.parseCallExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.LEFTSQUARE, p.parseIndexExpression)
 
 	// read two token to set 'token', 'peekToken' fields.
 	p.nextToken()
@@ -108,6 +127,10 @@ func (p *Parser) ParseProgram() *ast.Program {
 		p.nextToken()
 	}
 
+	for _, c := range p.lexer.Comments() {
+		program.Comments = append(program.Comments, &ast.Comment{Token: c})
+	}
+
 	return program
 }
 
@@ -116,13 +139,26 @@ func (p *Parser) parseStatement() ast.Statement {
 	switch p.token.Typ {
 	case token.LET:
 		return p.parseLetStatement()
+	case token.FUNCTION:
+		if p.peekToken.Typ != token.IDENTIFIER {
+			return p.parseExpressionStatement()
+		}
+		return p.parseFunctionStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
+// parseCallArguments parses the arguments of a call, the named
+// arguments (y: 2) come after the positional ones.
 func (p *Parser) parseCallArguments() []ast.Expression {
 	args := []ast.Expression{}
 
@@ -131,29 +167,148 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 		return args
 	}
 
+	named := false
+
+	for {
+		p.nextToken()
+
+		if p.curTokenIs(token.IDENTIFIER) && p.peekToken.Typ == token.COLON {
+			arg := &ast.NamedArgument{
+				Name: &ast.Identifier{
+					Token: p.token,
+					Value: p.token.Literal,
+				},
+			}
+
+			p.nextToken()
+			p.nextToken()
+
+			arg.Value = p.parseExpression(LOWEST)
+			args = append(args, arg)
+			named = true
+		} else {
+			if named {
+				p.errors = append(p.errors, "positional argument follows a named argument")
+				return nil
+			}
+			args = append(args, p.parseExpression(LOWEST))
+		}
+
+		if p.peekToken.Typ != token.COMMA {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RIGHTPARENTHESIS) {
+		return nil
+	}
+
+	return args
+}
+
+// parseExpressionList parses the expressions separated
+// by commas up to the closing token end.
+func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
+	list := []ast.Expression{}
+
+	if p.peekToken.Typ == end {
+		p.nextToken()
+		return list
+	}
+
 	p.nextToken()
 
-	args = append(args, p.parseExpression(LOWEST))
+	list = append(list, p.parseExpression(LOWEST))
 
 	for p.peekToken.Typ == token.COMMA {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(token.RIGHTPARENTHESIS) {
+	if !p.expectPeek(end) {
 		return nil
 	}
 
-	return args
+	return list
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	literal := &ast.ArrayLiteral{
+		Token: p.token,
+	}
+
+	literal.Elements = p.parseExpressionList(token.RIGHTSQUARE)
+	if literal.Elements == nil {
+		return nil
+	}
+
+	literal.End = p.token
+
+	return literal
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	literal := &ast.HashLiteral{
+		Token: p.token,
+	}
+
+	for p.peekToken.Typ != token.RIGHTBRACKET {
+		p.nextToken()
+
+		pair := &ast.HashPair{
+			Key: p.parseExpression(LOWEST),
+		}
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+
+		pair.Value = p.parseExpression(LOWEST)
+		literal.Pairs = append(literal.Pairs, pair)
+
+		if p.peekToken.Typ != token.RIGHTBRACKET && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+	literal.End = p.token
+
+	return literal
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	expression := &ast.IndexExpression{
+		Token: p.token,
+		Left:  left,
+	}
+
+	p.nextToken()
+
+	expression.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RIGHTSQUARE) {
+		return nil
+	}
+
+	expression.End = p.token
+
+	return expression
 }
 
 func (p *Parser) parseCallExpression(fn ast.Expression) ast.Expression {
 	expression := &ast.CallExpression{
-		Token:     p.token,
-		Function:  fn,
-		Arguments: p.parseCallArguments(),
+		Token:    p.token,
+		Function: fn,
 	}
+
+	expression.Arguments = p.parseCallArguments()
+	expression.End = p.token
+
 	return expression
 }
 
@@ -189,11 +344,135 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	return identifiers
 }
 
+// parseParameters parses the parameters of a function literal. The
+// parameters with a default value (y = 10) come after the others and
+// the rest parameter (...rest) comes last.
+func (p *Parser) parseParameters(literal *ast.FunctionLiteral) bool {
+	literal.Parameters = []*ast.Identifier{}
+
+	var defaults []ast.Expression
+	defaulted := false
+
+	for p.peekToken.Typ != token.RIGHTPARENTHESIS {
+		if p.peekToken.Typ == token.ELLIPSIS {
+			p.nextToken()
+
+			if !p.expectPeek(token.IDENTIFIER) {
+				return false
+			}
+
+			literal.Rest = &ast.Identifier{
+				Token: p.token,
+				Value: p.token.Literal,
+			}
+
+			// the rest comes last.
+			break
+		}
+
+		if !p.expectPeek(token.IDENTIFIER) {
+			return false
+		}
+
+		param := &ast.Identifier{
+			Token: p.token,
+			Value: p.token.Literal,
+		}
+
+		var def ast.Expression
+		if p.peekToken.Typ == token.ASSIGN {
+			p.nextToken()
+			p.nextToken()
+
+			def = p.parseExpression(LOWEST)
+			defaulted = true
+		} else if defaulted {
+			msg := fmt.Sprintf("parameter %s without a default value follows a parameter with one", param.Value)
+			p.errors = append(p.errors, msg)
+			return false
+		}
+
+		literal.Parameters = append(literal.Parameters, param)
+		defaults = append(defaults, def)
+
+		if p.peekToken.Typ != token.RIGHTPARENTHESIS && !p.expectPeek(token.COMMA) {
+			return false
+		}
+	}
+
+	if defaulted {
+		literal.Defaults = defaults
+	}
+
+	return p.expectPeek(token.RIGHTPARENTHESIS)
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	literal := &ast.FunctionLiteral{
 		Token: p.token,
 	}
 
+	if !p.parseFunction(literal) {
+		return nil
+	}
+
+	return literal
+}
+
+// parseFunction parses the parameters and the body of literal.
+func (p *Parser) parseFunction(literal *ast.FunctionLiteral) bool {
+	if !p.expectPeek(token.LEFTPARENTHESIS) {
+		return false
+	}
+
+	if !p.parseParameters(literal) {
+		return false
+	}
+
+	if !p.expectPeek(token.LEFTBRACKET) {
+		return false
+	}
+
+	p.functions++
+	literal.Body = p.parseBlockStatement()
+	p.functions--
+
+	literal.Generator = ast.Yields(literal.Body)
+
+	return true
+}
+
+// parseFunctionStatement parses the declaration of a named function.
+func (p *Parser) parseFunctionStatement() *ast.FunctionStatement {
+	literal := &ast.FunctionLiteral{
+		Token: p.token,
+	}
+
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	literal.Name = &ast.Identifier{
+		Token: p.token,
+		Value: p.token.Literal,
+	}
+
+	if !p.parseFunction(literal) {
+		return nil
+	}
+
+	if p.peekToken.Typ == token.SEMICOLON {
+		p.nextToken()
+	}
+
+	return &ast.FunctionStatement{Function: literal}
+}
+
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	literal := &ast.MacroLiteral{
+		Token: p.token,
+	}
+
 	if !p.expectPeek(token.LEFTPARENTHESIS) {
 		return nil
 	}
@@ -204,7 +483,11 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
+	// the body of a macro is not the one of the enclosing function.
+	functions := p.functions
+	p.functions = 0
 	literal.Body = p.parseBlockStatement()
+	p.functions = functions
 
 	return literal
 }
@@ -214,6 +497,9 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		Token: p.token,
 	}
 
+	p.depth++
+	defer func() { p.depth-- }()
+
 	p.nextToken()
 
 	for !p.curTokenIs(token.RIGHTBRACKET) && !p.curTokenIs(token.EOF) {
@@ -226,6 +512,8 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		p.nextToken()
 	}
 
+	block.End = p.token
+
 	return block
 }
 
@@ -265,6 +553,275 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{
+		Token: p.token,
+	}
+
+	if !p.expectPeek(token.LEFTBRACKET) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+
+	if p.peekToken.Typ == token.CATCH {
+		p.nextToken()
+
+		if !p.expectPeek(token.LEFTPARENTHESIS) || !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+
+		expression.Parameter = &ast.Identifier{
+			Token: p.token,
+			Value: p.token.Literal,
+		}
+
+		if !p.expectPeek(token.RIGHTPARENTHESIS) || !p.expectPeek(token.LEFTBRACKET) {
+			return nil
+		}
+
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekToken.Typ == token.FINALLY {
+		p.nextToken()
+
+		if !p.expectPeek(token.LEFTBRACKET) {
+			return nil
+		}
+
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		p.errors = append(p.errors, "try without catch or finally")
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{
+		Token: p.token,
+	}
+
+	if !p.expectPeek(token.LEFTPARENTHESIS) {
+		return nil
+	}
+
+	p.nextToken()
+
+	expression.Value = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RIGHTPARENTHESIS) || !p.expectPeek(token.LEFTBRACKET) {
+		return nil
+	}
+
+	for p.peekToken.Typ != token.RIGHTBRACKET {
+		p.nextToken()
+
+		arm := &ast.MatchArm{
+			Pattern: p.parsePattern(),
+		}
+
+		if arm.Pattern == nil {
+			return nil
+		}
+
+		if p.peekToken.Typ == token.IF {
+			p.nextToken()
+			p.nextToken()
+			arm.Guard = p.parseExpression(LOWEST)
+		}
+
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+
+		p.nextToken()
+
+		arm.Body = p.parseExpression(LOWEST)
+		expression.Arms = append(expression.Arms, arm)
+
+		if p.peekToken.Typ != token.COMMA {
+			break
+		}
+
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RIGHTBRACKET) {
+		return nil
+	}
+
+	expression.End = p.token
+
+	return expression
+}
+
+// parsePattern parses the pattern of a match arm
+// or of a destructuring let statement.
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.token.Typ {
+	case token.LEFTSQUARE:
+		return p.parseArrayPattern()
+	case token.LEFTBRACKET:
+		return p.parseHashPattern()
+	case token.IDENTIFIER:
+		return &ast.Identifier{
+			Token: p.token,
+			Value: p.token.Literal,
+		}
+	case token.INTEGER:
+		if literal, ok := p.parseIntegerLiteral().(*ast.IntegerLiteral); ok {
+			return literal
+		}
+		return nil
+	case token.MINUS:
+		minus := p.token
+
+		if !p.expectPeek(token.INTEGER) {
+			return nil
+		}
+
+		literal, ok := p.parseIntegerLiteral().(*ast.IntegerLiteral)
+		if !ok {
+			return nil
+		}
+
+		literal.Token = token.Token{Typ: token.INTEGER, Literal: "-" + literal.Token.Literal, Pos: minus.Pos}
+		literal.Value = -literal.Value
+
+		return literal
+	case token.STRING:
+		return &ast.StringLiteral{
+			Token: p.token,
+			Value: p.token.Literal,
+		}
+	case token.TRUE, token.FALSE:
+		return &ast.BooleanLiteral{
+			Token: p.token,
+			Value: p.curTokenIs(token.TRUE),
+		}
+	default:
+		msg := fmt.Sprintf("expected a pattern, got %s instead", p.token.Typ)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{
+		Token: p.token,
+	}
+
+	for p.peekToken.Typ != token.RIGHTSQUARE {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENTIFIER) {
+				return nil
+			}
+
+			pattern.Rest = &ast.Identifier{
+				Token: p.token,
+				Value: p.token.Literal,
+			}
+
+			// the rest comes last.
+			break
+		}
+
+		element := p.parsePatternElement(nil)
+		if element == nil {
+			return nil
+		}
+
+		pattern.Elements = append(pattern.Elements, element)
+
+		if p.peekToken.Typ != token.RIGHTSQUARE && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RIGHTSQUARE) {
+		return nil
+	}
+
+	pattern.End = p.token
+
+	return pattern
+}
+
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{
+		Token: p.token,
+	}
+
+	for p.peekToken.Typ != token.RIGHTBRACKET {
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+
+		key := &ast.Identifier{
+			Token: p.token,
+			Value: p.token.Literal,
+		}
+
+		element := p.parsePatternElement(key)
+		if element == nil {
+			return nil
+		}
+
+		pattern.Elements = append(pattern.Elements, element)
+
+		if p.peekToken.Typ != token.RIGHTBRACKET && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+	pattern.End = p.token
+
+	return pattern
+}
+
+// parsePatternElement parses an element of an array pattern, or of
+// a hash pattern if key is not nil, with its default if it has one.
+// The element of a hash pattern without a pattern binds its key.
+func (p *Parser) parsePatternElement(key *ast.Identifier) *ast.PatternElement {
+	element := &ast.PatternElement{
+		Key: key,
+	}
+
+	switch {
+	case key == nil:
+		element.Pattern = p.parsePattern()
+	case p.peekToken.Typ == token.COLON:
+		p.nextToken()
+		p.nextToken()
+		element.Pattern = p.parsePattern()
+	default:
+		element.Pattern = &ast.Identifier{
+			Token: key.Token,
+			Value: key.Value,
+		}
+	}
+
+	if element.Pattern == nil {
+		return nil
+	}
+
+	if p.peekToken.Typ == token.ASSIGN {
+		p.nextToken()
+		p.nextToken()
+		element.Default = p.parseExpression(LOWEST)
+	}
+
+	return element
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 
@@ -277,6 +834,24 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	expression := &ast.MemberExpression{
+		Token: p.token,
+		Left:  left,
+	}
+
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	expression.Property = &ast.Identifier{
+		Token: p.token,
+		Value: p.token.Literal,
+	}
+
+	return expression
+}
+
 func (p *Parser) parseBool() ast.Expression {
 	return &ast.BooleanLiteral{
 		Token: p.token,
@@ -311,6 +886,169 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseYieldExpression() ast.Expression {
+	expression := &ast.YieldExpression{
+		Token: p.token,
+	}
+
+	if p.functions == 0 {
+		p.errors = append(p.errors, "yield outside of a function")
+		return nil
+	}
+
+	p.nextToken()
+
+	expression.Value = p.parseExpression(LOWEST)
+
+	return expression
+}
+
+func (p *Parser) parseSpawnExpression() ast.Expression {
+	expression := &ast.SpawnExpression{
+		Token: p.token,
+	}
+
+	p.nextToken()
+
+	exp := p.parseExpression(PREFIX)
+	if exp == nil {
+		return nil
+	}
+
+	call, ok := exp.(*ast.CallExpression)
+	if !ok {
+		p.errors = append(p.errors, fmt.Sprintf("spawn requires a call, got %s", exp))
+		return nil
+	}
+
+	expression.Call = call
+
+	return expression
+}
+
+func (p *Parser) parseChanExpression() ast.Expression {
+	expression := &ast.ChanExpression{
+		Token: p.token,
+	}
+
+	if !p.expectPeek(token.LEFTPARENTHESIS) {
+		return nil
+	}
+
+	if p.peekToken.Typ != token.RIGHTPARENTHESIS {
+		p.nextToken()
+		expression.Capacity = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.RIGHTPARENTHESIS) {
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseSelectExpression() ast.Expression {
+	expression := &ast.SelectExpression{
+		Token: p.token,
+	}
+
+	if !p.expectPeek(token.LEFTBRACKET) {
+		return nil
+	}
+
+	hasDefault := false
+
+	for p.peekToken.Typ != token.RIGHTBRACKET {
+		p.nextToken()
+
+		arm := p.parseSelectArm()
+		if arm == nil {
+			return nil
+		}
+
+		if arm.IsDefault() {
+			if hasDefault {
+				p.errors = append(p.errors, "select with more than one default arm")
+				return nil
+			}
+			hasDefault = true
+		}
+
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+
+		p.nextToken()
+
+		arm.Body = p.parseExpression(LOWEST)
+		expression.Arms = append(expression.Arms, arm)
+
+		if p.peekToken.Typ != token.COMMA {
+			break
+		}
+
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RIGHTBRACKET) {
+		return nil
+	}
+
+	expression.End = p.token
+
+	return expression
+}
+
+// parseSelectArm parses the operation of a select arm: a call of recv,
+// optionally followed by 'as' and the name its value is bound to, a
+// call of send or the wildcard of the default arm.
+func (p *Parser) parseSelectArm() *ast.SelectArm {
+	arm := &ast.SelectArm{Token: p.token}
+
+	if p.curTokenIs(token.IDENTIFIER) && p.token.Literal == ast.Wildcard && p.peekToken.Typ == token.ARROW {
+		return arm
+	}
+
+	exp := p.parseExpression(LOWEST)
+	if exp == nil {
+		return nil
+	}
+
+	if call, ok := exp.(*ast.CallExpression); ok {
+		if member, ok := call.Function.(*ast.MemberExpression); ok {
+			switch args := call.Arguments; {
+			case member.Property.Value == "recv" && len(args) == 0:
+				arm.Channel = member.Left
+			case member.Property.Value == "send" && len(args) == 1 && !isNamedArgument(args[0]):
+				arm.Channel, arm.Value = member.Left, args[0]
+			}
+		}
+	}
+
+	if arm.Channel == nil {
+		p.errors = append(p.errors, fmt.Sprintf("expected a recv() or send(value) call in a select arm, got %s", exp))
+		return nil
+	}
+
+	if arm.Value == nil && p.peekToken.Typ == token.AS {
+		p.nextToken()
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+		arm.Binding = &ast.Identifier{
+			Token: p.token,
+			Value: p.token.Literal,
+		}
+	}
+
+	return arm
+}
+
+func isNamedArgument(exp ast.Expression) bool {
+	_, ok := exp.(*ast.NamedArgument)
+	return ok
+}
+
 func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{
 		Token: p.token,
@@ -335,6 +1073,13 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return literal
 }
 
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{
+		Token: p.token,
+		Value: p.token.Literal,
+	}
+}
+
 func (p *Parser) parseExpression(pr precedence) ast.Expression {
 	prefix, ok := p.prefixParseHandlers[p.token.Typ]
 	if !ok {
@@ -360,10 +1105,11 @@ func (p *Parser) parseExpression(pr precedence) ast.Expression {
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	statement := &ast.ExpressionStatement{
-		Token:      p.token,
-		Expression: p.parseExpression(LOWEST),
+		Token: p.token,
 	}
 
+	statement.Expression = p.parseExpression(LOWEST)
+
 	if p.peekToken.Typ == token.SEMICOLON {
 		p.nextToken()
 	}
@@ -387,18 +1133,43 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return statement
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
-	statement := &ast.LetStatement{
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	statement := &ast.ThrowStatement{
 		Token: p.token,
 	}
 
-	if !p.expectPeek(token.IDENTIFIER) {
-		return nil
+	p.nextToken()
+
+	statement.Expression = p.parseExpression(LOWEST)
+
+	if p.peekToken.Typ == token.SEMICOLON {
+		p.nextToken()
 	}
 
-	statement.Identifier = &ast.Identifier{
+	return statement
+}
+
+func (p *Parser) parseLetStatement() *ast.LetStatement {
+	statement := &ast.LetStatement{
 		Token: p.token,
-		Value: p.token.Literal,
+	}
+
+	switch p.peekToken.Typ {
+	case token.LEFTSQUARE, token.LEFTBRACKET:
+		p.nextToken()
+
+		if statement.Pattern = p.parsePattern(); statement.Pattern == nil {
+			return nil
+		}
+	default:
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+
+		statement.Identifier = &ast.Identifier{
+			Token: p.token,
+			Value: p.token.Literal,
+		}
 	}
 
 	if !p.expectPeek(token.ASSIGN) {
@@ -416,6 +1187,58 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return statement
 }
 
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	statement := &ast.ImportStatement{
+		Token: p.token,
+	}
+
+	if p.depth > 0 {
+		p.topLevelError()
+	}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	statement.Path = p.token.Literal
+
+	if !p.expectPeek(token.AS) || !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	statement.Name = &ast.Identifier{
+		Token: p.token,
+		Value: p.token.Literal,
+	}
+
+	if p.peekToken.Typ == token.SEMICOLON {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+// parseExportStatement parses 'export let', which
+// is a let statement with the name exported.
+func (p *Parser) parseExportStatement() ast.Statement {
+	if p.depth > 0 {
+		p.topLevelError()
+	}
+
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+
+	statement := p.parseLetStatement()
+	if statement == nil {
+		return nil
+	}
+
+	statement.Exported = true
+
+	return statement
+}
+
 func (p *Parser) registerPrefix(typ token.Type, fn ast.PrefixParseHandler) {
 	p.prefixParseHandlers[typ] = fn
 }
@@ -458,6 +1281,11 @@ func (p *Parser) peekError(t token.Type) {
 	p.errors = append(p.errors, msg)
 }
 
+func (p *Parser) topLevelError() {
+	msg := fmt.Sprintf("%s is only allowed at the top level of a program", p.token.Literal)
+	p.errors = append(p.errors, msg)
+}
+
 func (p *Parser) noPrefixParseError(t token.Type) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
 	p.errors = append(p.errors, msg)
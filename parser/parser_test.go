@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/Despire/interpreter/ast"
@@ -10,94 +11,115 @@ import (
 	"github.com/Despire/interpreter/token"
 )
 
-func TestOperatorPrecedenceParse(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{
-			"add(a, b, 1, 2* 3, 4 + 5, add(6, 7 * 8))",
-			"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))",
-		},
-		{
-			"add(a + b + c * d / f + g)",
-			"add((((a + b) + ((c * d) / f)) + g))",
-		},
-		{
-			"(5 + 5) * 2",
-			"((5 + 5) * 2)",
-		},
-		{
-			"2 / (5 + 5)",
-			"(2 / (5 + 5))",
-		},
-		{
-			"-(5 + 5)",
-			"(-(5 + 5))",
-		},
-		{
-			"3 < 5 == true",
-			"((3 < 5) == true)",
-		},
-		{
-			"true",
-			"true",
-		},
-		{
-			"false",
-			"false",
-		},
-		{
-			"3 > 5 == false",
-			"((3 > 5) == false)",
-		},
-		{
-			"-a * b",
-			"((-a) * b)",
-		},
-		{
-			"!-a",
-			"(!(-a))",
-		},
-		{
-			"a + b + c",
-			"((a + b) + c)",
-		},
-		{
-			"a + b - c",
-			"((a + b) - c)",
-		},
-		{
-			"a * b * c",
-			"((a * b) * c)",
-		},
-		{
-			"a * b / c",
-			"((a * b) / c)",
-		},
-		{
-			"a + b / c",
-			"(a + (b / c))",
-		},
-		{
-			"a + b * c + d / e - f",
-			"(((a + (b * c)) + (d / e)) - f)",
-		},
-		{
-			"5 > 4 == 3 < 4",
-			"((5 > 4) == (3 < 4))",
-		},
-		{
-			"5 < 4 != 3 > 4",
-			"((5 < 4) != (3 > 4))",
-		},
-		{
-			"3 + 4 * 5 == 3 * 1 + 4 * 5",
-			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
-		},
-	}
+// precedenceTests are programs and their fully parenthesized string.
+var precedenceTests = []struct {
+	input    string
+	expected string
+}{
+	{
+		"add(a, b, 1, 2* 3, 4 + 5, add(6, 7 * 8))",
+		"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))",
+	},
+	{
+		"add(a + b + c * d / f + g)",
+		"add((((a + b) + ((c * d) / f)) + g))",
+	},
+	{
+		"(5 + 5) * 2",
+		"((5 + 5) * 2)",
+	},
+	{
+		"-m.a * m.f(b).c",
+		"((-m.a) * m.f(b).c)",
+	},
+	{
+		"2 / (5 + 5)",
+		"(2 / (5 + 5))",
+	},
+	{
+		"-(5 + 5)",
+		"(-(5 + 5))",
+	},
+	{
+		"3 < 5 == true",
+		"((3 < 5) == true)",
+	},
+	{
+		"true",
+		"true",
+	},
+	{
+		"false",
+		"false",
+	},
+	{
+		"3 > 5 == false",
+		"((3 > 5) == false)",
+	},
+	{
+		"-a * b",
+		"((-a) * b)",
+	},
+	{
+		"!-a",
+		"(!(-a))",
+	},
+	{
+		"a + b + c",
+		"((a + b) + c)",
+	},
+	{
+		"a + b - c",
+		"((a + b) - c)",
+	},
+	{
+		"a * b * c",
+		"((a * b) * c)",
+	},
+	{
+		"a * b / c",
+		"((a * b) / c)",
+	},
+	{
+		"a + b / c",
+		"(a + (b / c))",
+	},
+	{
+		"a + b * c + d / e - f",
+		"(((a + (b * c)) + (d / e)) - f)",
+	},
+	{
+		"5 > 4 == 3 < 4",
+		"((5 > 4) == (3 < 4))",
+	},
+	{
+		"5 < 4 != 3 > 4",
+		"((5 < 4) != (3 > 4))",
+	},
+	{
+		"3 + 4 * 5 == 3 * 1 + 4 * 5",
+		"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
+	},
+	{
+		"a * [1, 2, 3][b * c] * d",
+		"((a * ([1, 2, 3][(b * c)])) * d)",
+	},
+	{
+		"add(a * b[2], b[1], 2 * [1, 2][1])",
+		"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+	},
+	{
+		`{a: 1, "b": 2 + 3, 1: x,}`,
+		`{a: 1, "b": (2 + 3), 1: x}`,
+	},
+	{
+		"f(x)[0].y",
+		"(f(x)[0]).y",
+	},
+}
 
-	for _, tt := range tests {
+func TestOperatorPrecedenceParse(t *testing.T) {
+	for _, tt := range precedenceTests {
 		l := lexer.New(tt.input)
 		p := New(l)
 		program := p.ParseProgram()
@@ -111,24 +133,25 @@ func TestOperatorPrecedenceParse(t *testing.T) {
 	}
 }
 
-func TestInfixExpressions(t *testing.T) {
-	tests := []struct {
-		in    string
-		left  int
-		op    string
-		right int
-	}{
-		{"5 + 5", 5, "+", 5},
-		{"5 - 5", 5, "-", 5},
-		{"5 * 5", 5, "*", 5},
-		{"5 / 5", 5, "/", 5},
-		{"5 > 5", 5, ">", 5},
-		{"5 < 5", 5, "<", 5},
-		{"5 == 5", 5, "==", 5},
-		{"5 != 5", 5, "!=", 5},
-	}
+// infixTests are infix expressions of two integers.
+var infixTests = []struct {
+	in    string
+	left  int
+	op    string
+	right int
+}{
+	{"5 + 5", 5, "+", 5},
+	{"5 - 5", 5, "-", 5},
+	{"5 * 5", 5, "*", 5},
+	{"5 / 5", 5, "/", 5},
+	{"5 > 5", 5, ">", 5},
+	{"5 < 5", 5, "<", 5},
+	{"5 == 5", 5, "==", 5},
+	{"5 != 5", 5, "!=", 5},
+}
 
-	for i, tt := range tests {
+func TestInfixExpressions(t *testing.T) {
+	for i, tt := range infixTests {
 		t.Run("infix-expressions "+strconv.Itoa(i), func(t *testing.T) {
 			l := lexer.New(tt.in)
 			p := New(l)
@@ -164,17 +187,18 @@ func TestInfixExpressions(t *testing.T) {
 	}
 }
 
-func TestPrefixExpressions(t *testing.T) {
-	tests := []struct {
-		in  string
-		op  string
-		val int64
-	}{
-		{"!5", "!", 5},
-		{"-15", "-", 15},
-	}
+// prefixTests are prefix expressions of an integer.
+var prefixTests = []struct {
+	in  string
+	op  string
+	val int64
+}{
+	{"!5", "!", 5},
+	{"-15", "-", 15},
+}
 
-	for i, tt := range tests {
+func TestPrefixExpressions(t *testing.T) {
+	for i, tt := range prefixTests {
 		t.Run("prefix-tests-"+strconv.Itoa(i), func(t *testing.T) {
 			l := lexer.New(tt.in)
 			p := New(l)
@@ -206,10 +230,11 @@ func TestPrefixExpressions(t *testing.T) {
 	}
 }
 
-func TestIntegerLiteralExpression(t *testing.T) {
-	input := `5;`
+// integerLiteralInput is a single integer expression.
+const integerLiteralInput = `5;`
 
-	l := lexer.New(input)
+func TestIntegerLiteralExpression(t *testing.T) {
+	l := lexer.New(integerLiteralInput)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
@@ -235,10 +260,11 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
-func TestIdentifierExpression(t *testing.T) {
-	input := `foobar;`
+// identifierInput is a single identifier expression.
+const identifierInput = `foobar;`
 
-	l := lexer.New(input)
+func TestIdentifierExpression(t *testing.T) {
+	l := lexer.New(identifierInput)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
@@ -295,14 +321,15 @@ func TestString(t *testing.T) {
 	}
 }
 
-func TestReturnStatements(t *testing.T) {
-	input := `
+// returnInput is a program of three return statements.
+const returnInput = `
 return 5;
 return 10;
 return 993322;
 `
 
-	l := lexer.New(input)
+func TestReturnStatements(t *testing.T) {
+	l := lexer.New(returnInput)
 	p := New(l)
 
 	program := p.ParseProgram()
@@ -326,14 +353,15 @@ return 993322;
 
 }
 
-func TestLetStatements(t *testing.T) {
-	input := `
+// letInput is a program of three let statements.
+const letInput = `
 let x = 5;
 let y = 10;
 let foobar = 838383;
 `
 
-	l := lexer.New(input)
+func TestLetStatements(t *testing.T) {
+	l := lexer.New(letInput)
 	p := New(l)
 
 	program := p.ParseProgram()
@@ -384,6 +412,37 @@ func testIdentifier(t *testing.T, expression ast.Expression, val string) bool {
 	return true
 }
 
+// macroInput is a single macro literal.
+const macroInput = `macro(x, y) { x + y; }`
+
+func TestMacroLiteral(t *testing.T) {
+	p := New(lexer.New(macroInput))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statement) != 1 {
+		t.Fatalf("program.Statement does not contain 1 statement. have=%d", len(program.Statement))
+	}
+
+	statement, ok := program.Statement[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ExpressionStatement. have=%T", program.Statement[0])
+	}
+
+	macro, ok := statement.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("statement.Expression is not *ast.MacroLiteral. have=%T", statement.Expression)
+	}
+
+	if len(macro.Parameters) != 2 || macro.Parameters[0].Value != "x" || macro.Parameters[1].Value != "y" {
+		t.Fatalf("wrong macro parameters %v", macro.Parameters)
+	}
+
+	if macro.String() != "macro(x, y) (x + y)" {
+		t.Errorf("macro.String() wrong. have=%q", macro.String())
+	}
+}
+
 func testIntegerLiteral(t *testing.T, il ast.Expression, val int) bool {
 	v, ok := il.(*ast.IntegerLiteral)
 	if !ok {
@@ -443,3 +502,481 @@ func checkParserErrors(t *testing.T, p *Parser) {
 
 	t.FailNow()
 }
+
+// moduleInput is a program with an import and an export.
+const moduleInput = `import "lib/math.mk" as math;
+export let square = fn(x) { math.mul(x, x) };`
+
+func TestModuleStatements(t *testing.T) {
+	p := New(lexer.New(moduleInput))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statement) != 2 {
+		t.Fatalf("program.Statement does not contain 2 statements. have=%d", len(program.Statement))
+	}
+
+	imp, ok := program.Statement[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ImportStatement. have=%T", program.Statement[0])
+	}
+
+	if imp.Path != "lib/math.mk" || imp.Name.Value != "math" {
+		t.Errorf("wrong import. have=%q", imp.String())
+	}
+
+	let, ok := program.Statement[1].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.LetStatement. have=%T", program.Statement[1])
+	}
+
+	if !let.Exported || !testLetStatement(t, let, "square") {
+		t.Errorf("wrong export. have=%q", let.String())
+	}
+
+	expected := `import "lib/math.mk" as math;export let square = fn(x) math.mul(x, x);`
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. want=%q, have=%q", expected, program.String())
+	}
+}
+
+func TestModuleStatementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import lib as m;`, "expected next token to be STRING, got IDENTIFIER instead"},
+		{`import "lib" m;`, "expected next token to be AS, got IDENTIFIER instead"},
+		{`export fn() {};`, "expected next token to be LET, got FUNCTION instead"},
+		{`fn() { import "lib" as m; }`, "import is only allowed at the top level of a program"},
+		{`if (true) { export let a = 1; }`, "export is only allowed at the top level of a program"},
+		{`m.1`, "expected next token to be IDENTIFIER, got INTEGER instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want=%q, have=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+// tryTests are try expressions and the clauses they have.
+var tryTests = []struct {
+	input     string
+	parameter string
+	catch     bool
+	finally   bool
+}{
+	{`try { throw "bad"; } catch (e) { e }`, "e", true, false},
+	{`try { 1 } finally { 2 }`, "", false, true},
+	{`try { 1 } catch (err) { 2 } finally { 3 }`, "err", true, true},
+}
+
+func TestTryExpression(t *testing.T) {
+	for _, tt := range tryTests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statement[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("statement is not *ast.ExpressionStatement. have=%T", program.Statement[0])
+		}
+
+		te, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("expression is not *ast.TryExpression. have=%T", stmt.Expression)
+		}
+
+		if te.Body == nil || len(te.Body.Statements) != 1 {
+			t.Errorf("wrong body for %q. have=%v", tt.input, te.Body)
+		}
+
+		if tt.parameter != "" && (te.Parameter == nil || te.Parameter.Value != tt.parameter) {
+			t.Errorf("wrong parameter for %q. want=%q, have=%v", tt.input, tt.parameter, te.Parameter)
+		}
+
+		if (te.Catch != nil) != tt.catch || (te.Finally != nil) != tt.finally {
+			t.Errorf("wrong clauses for %q. have=%q", tt.input, te.String())
+		}
+	}
+
+	program := New(lexer.New(`throw "bad";`)).ParseProgram()
+	throw, ok := program.Statement[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ThrowStatement. have=%T", program.Statement[0])
+	}
+
+	if s, ok := throw.Expression.(*ast.StringLiteral); !ok || s.Value != "bad" {
+		t.Errorf("wrong thrown expression. have=%q", throw.Expression)
+	}
+}
+
+func TestTryExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { 1 }`, "try without catch or finally"},
+		{`try { 1 } catch e { 2 }`, "expected next token to be (, got IDENTIFIER instead"},
+		{`try { 1 } catch (1) { 2 }`, "expected next token to be IDENTIFIER, got INTEGER instead"},
+		{`try 1 catch (e) { 2 }`, "expected next token to be {, got INTEGER instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want=%q, have=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+// matchInput is a match expression with literal, binding, guarded and wildcard arms.
+const matchInput = `match (x) { 0 => "zero", -1 => "minus one", n if n > 1 => n, _ => 2, }`
+
+func TestMatchExpression(t *testing.T) {
+	p := New(lexer.New(matchInput))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statement[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ExpressionStatement. have=%T", program.Statement[0])
+	}
+
+	me, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("expression is not *ast.MatchExpression. have=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, me.Value, "x") {
+		return
+	}
+
+	if len(me.Arms) != 4 {
+		t.Fatalf("wrong number of arms. want=4, have=%d", len(me.Arms))
+	}
+
+	if lit, ok := me.Arms[1].Pattern.(*ast.IntegerLiteral); !ok || lit.Value != -1 {
+		t.Errorf("wrong pattern of arm 1. want=-1, have=%s", me.Arms[1].Pattern)
+	}
+
+	if me.Arms[2].Guard == nil || me.Arms[2].Guard.String() != "(n > 1)" {
+		t.Errorf("wrong guard of arm 2. have=%v", me.Arms[2].Guard)
+	}
+
+	expected := `match x {0 => "zero", -1 => "minus one", n if (n > 1) => n, _ => 2}`
+	if me.String() != expected {
+		t.Errorf("wrong string. want=%q, have=%q", expected, me.String())
+	}
+}
+
+// letPatternTests are let statements with patterns and the names they bind.
+var letPatternTests = []struct {
+	input    string
+	expected string
+	bindings []string
+}{
+	{"let [a, b] = x;", "let [a, b] = x;", []string{"a", "b"}},
+	{"let [a, b = 1, ...rest] = x;", "let [a, b = 1, ...rest] = x;", []string{"a", "b", "rest"}},
+	{"let [_, [c, d], ..._] = x;", "let [_, [c, d], ..._] = x;", []string{"c", "d"}},
+	{"let {name, age: years,} = x;", "let {name, age: years} = x;", []string{"name", "years"}},
+	{"let {a: [b, {c}], d = 2 * 3} = x;", "let {a: [b, {c}], d = (2 * 3)} = x;", []string{"b", "c", "d"}},
+	{"let [] = x;", "let [] = x;", nil},
+}
+
+func TestLetPatterns(t *testing.T) {
+	for _, tt := range letPatternTests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statement[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("statement is not *ast.LetStatement. have=%T", program.Statement[0])
+		}
+
+		if stmt.Identifier != nil || stmt.Pattern == nil {
+			t.Fatalf("wrong let statement for %q. have identifier=%v, pattern=%v", tt.input, stmt.Identifier, stmt.Pattern)
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("wrong string. want=%q, have=%q", tt.expected, stmt.String())
+		}
+
+		var names []string
+		for _, id := range ast.Bindings(stmt.Pattern) {
+			names = append(names, id.Value)
+		}
+
+		if strings.Join(names, " ") != strings.Join(tt.bindings, " ") {
+			t.Errorf("wrong bindings for %q. want=%v, have=%v", tt.input, tt.bindings, names)
+		}
+	}
+}
+
+// parameterTests are functions and calls with default, rest and named parameters.
+var parameterTests = []struct {
+	input    string
+	expected string
+}{
+	{"fn() {}", "fn() "},
+	{"fn(x, y) { x }", "fn(x, y) x"},
+	{"fn(x, y = 10 * 2) { x }", "fn(x, y = (10 * 2)) x"},
+	{"fn(x = 1, y = x, ...rest) { x }", "fn(x = 1, y = x, ...rest) x"},
+	{"fn(...rest) { rest }", "fn(...rest) rest"},
+	{"fn(x,) { x }", "fn(x) x"},
+	{"f(y: 2, x: 1 + 1)", "f(y: 2, x: (1 + 1))"},
+	{"f(1, b: g(c: 3))", "f(1, b: g(c: 3))"},
+	{"f(a)(b: 1)", "f(a)(b: 1)"},
+}
+
+func TestParameters(t *testing.T) {
+	for _, tt := range parameterTests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong string for %q. want=%q, have=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("fn(x, y = 1, ...r) {}"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	fn := program.Statement[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(fn.Parameters) != 2 || fn.Default(0) != nil || fn.Default(1) == nil || fn.Rest == nil || fn.Rest.Value != "r" {
+		t.Errorf("wrong parameters. have=%s", fn)
+	}
+
+	p = New(lexer.New("f(1, y: 2)"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+
+	call := program.Statement[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if named, ok := call.Arguments[1].(*ast.NamedArgument); !ok || named.Name.Value != "y" {
+		t.Errorf("argument 1 is not named y. have=%s", call.Arguments[1])
+	}
+}
+
+// functionStatementTests are programs with function statements.
+var functionStatementTests = []struct {
+	input    string
+	expected string
+}{
+	{"fn f() {}", "fn f() "},
+	{"fn add(x, y = 1) { x + y }; add(1)", "fn add(x, y = 1) (x + y)add(1)"},
+	{"fn f() { 1 } fn g() { 2 }", "fn f() 1fn g() 2"},
+	{"fn(x) { x }(1)", "fn(x) x(1)"},
+	{"let f = fn() { fn g() { 1 } g() }", "let f = fn() fn g() 1g();"},
+}
+
+func TestFunctionStatements(t *testing.T) {
+	for _, tt := range functionStatementTests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong string for %q. want=%q, have=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("fn add(x, y) { x + y }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	s, ok := program.Statement[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("statement is not a FunctionStatement. have=%T", program.Statement[0])
+	}
+
+	if s.Name().Value != "add" || len(s.Function.Parameters) != 2 {
+		t.Errorf("wrong function. have=%s", s)
+	}
+}
+
+// yieldTests are functions and whether they are generators.
+var yieldTests = []struct {
+	input     string
+	expected  string
+	generator bool
+}{
+	{"fn() { yield 1 + 2 }", "fn() (yield (1 + 2))", true},
+	{"fn() { let x = yield 1; yield x }", "fn() let x = (yield 1);(yield x)", true},
+	{"fn() { f(yield a, yield b) }", "fn() f((yield a), (yield b))", true},
+	{"fn() { 1 + yield 2 }", "fn() (1 + (yield 2))", true},
+	{"fn() { fn() { yield 1 } }", "fn() fn() (yield 1)", false},
+	{"fn() { if (x) { yield 1 } }", "fn() ifx (yield 1)", true},
+	{"fn() { 1 }", "fn() 1", false},
+}
+
+func TestYieldExpressions(t *testing.T) {
+	for _, tt := range yieldTests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong string for %q. want=%q, have=%q", tt.input, tt.expected, program.String())
+		}
+
+		fn := program.Statement[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if fn.Generator != tt.generator {
+			t.Errorf("wrong generator flag for %q. want=%t, have=%t", tt.input, tt.generator, fn.Generator)
+		}
+	}
+}
+
+func TestYieldExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"yield 1", "yield outside of a function"},
+		{"let m = macro() { yield 1 };", "yield outside of a function"},
+		{"fn() { yield }", "no prefix parse function for } found"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want=%q, have=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+// concurrencyTests are programs using spawn, chan and select.
+var concurrencyTests = []struct {
+	input    string
+	expected string
+}{
+	{"spawn f(1, x: 2)", "(spawn f(1, x: 2))"},
+	{"spawn f(1)(2)", "(spawn f(1)(2))"},
+	{"1 + spawn f()", "(1 + (spawn f()))"},
+	{"let c = chan(); chan(n + 1)", "let c = chan();chan((n + 1))"},
+	{"select { c.recv() as v => v, d.send(1 + 2) => 2, _ => 3, }", "select {c.recv() as v => v, d.send((1 + 2)) => 2, _ => 3}"},
+	{"select { c.recv() => 1 }", "select {c.recv() => 1}"},
+	{"select {}", "select {}"},
+}
+
+func TestConcurrencyExpressions(t *testing.T) {
+	for _, tt := range concurrencyTests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong string for %q. want=%q, have=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestConcurrencyExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"spawn f", "spawn requires a call, got f"},
+		{"chan 1", "expected next token to be (, got INTEGER instead"},
+		{"chan(1", "expected next token to be ), got EOF instead"},
+		{"select { c.foo() => 1 }", "expected a recv() or send(value) call in a select arm, got c.foo()"},
+		{"select { c.recv(1) => 1 }", "expected a recv() or send(value) call in a select arm, got c.recv(1)"},
+		{"select { c.send(x: 1) => 1 }", "expected a recv() or send(value) call in a select arm, got c.send(x: 1)"},
+		{"select { c.send(1) as v => 1 }", "expected next token to be =>, got AS instead"},
+		{"select { c.recv() as 1 => 1 }", "expected next token to be IDENTIFIER, got INTEGER instead"},
+		{"select { _ => 1, _ => 2 }", "select with more than one default arm"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want=%q, have=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(1) {}", "expected next token to be IDENTIFIER, got INTEGER instead"},
+		{"fn(x = 1, y) {}", "parameter y without a default value follows a parameter with one"},
+		{"fn(...r, x) {}", "expected next token to be ), got , instead"},
+		{"fn(x y) {}", "expected next token to be ,, got IDENTIFIER instead"},
+		{"f(x: 1, 2)", "positional argument follows a named argument"},
+		{"f(x: 1", "expected next token to be ), got EOF instead"},
+		{"fn f {}", "expected next token to be (, got { instead"},
+		{"let g = fn f() {}", "expected next token to be (, got IDENTIFIER instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want=%q, have=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestCollectionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2", "expected next token to be ], got EOF instead"},
+		{"a[1", "expected next token to be ], got EOF instead"},
+		{"{a 1}", "expected next token to be :, got INTEGER instead"},
+		{"{a: 1 b: 2}", "expected next token to be ,, got IDENTIFIER instead"},
+		{"let [...rest, a] = x;", "expected next token to be ], got , instead"},
+		{"let [...1] = x;", "expected next token to be IDENTIFIER, got INTEGER instead"},
+		{"let {1} = x;", "expected next token to be IDENTIFIER, got INTEGER instead"},
+		{"let {a: } = x;", "expected a pattern, got } instead"},
+		{"let 1 = x;", "expected next token to be IDENTIFIER, got INTEGER instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want=%q, have=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestMatchExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match x { _ => 1 }`, "expected next token to be (, got IDENTIFIER instead"},
+		{`match (x) { 1 + 2 => 1 }`, "expected next token to be =>, got + instead"},
+		{`match (x) { fn => 1 }`, "expected a pattern, got FUNCTION instead"},
+		{`match (x) { -a => 1 }`, "expected next token to be INTEGER, got IDENTIFIER instead"},
+		{`match (x) { 1 => 2 3 => 4 }`, "expected next token to be }, got INTEGER instead"},
+		{`match (x) { 1 => 2`, "expected next token to be }, got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want=%q, have=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
@@ -5,9 +5,7 @@ import (
 	"fmt"
 	"io"
 
-	"github.com/Despire/interpreter/eval"
 	"github.com/Despire/interpreter/lexer"
-	"github.com/Despire/interpreter/objects"
 	"github.com/Despire/interpreter/parser"
 )
 
@@ -15,11 +13,10 @@ const (
 	prompt = ">>> "
 )
 
-// Start reads the input from reader, processes it
-// and writes the output to writer.
-func Start(reader io.Reader, writer io.Writer) {
+// Start reads the input from reader, executes it
+// with s and writes the output to writer.
+func Start(reader io.Reader, writer io.Writer, s session) {
 	sc := bufio.NewScanner(reader)
-	env := objects.NewEnvironment()
 
 	for sc.Scan() {
 		if _, err := fmt.Fprintf(writer, prompt); err != nil {
@@ -35,7 +32,7 @@ func Start(reader io.Reader, writer io.Writer) {
 			continue
 		}
 
-		e := eval.Eval(program, env)
+		e := s.Run(program)
 		if e != nil {
 			io.WriteString(writer, e.Inspect())
 			io.WriteString(writer, "\n")
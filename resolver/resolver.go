@@ -0,0 +1,528 @@
+// Package resolver implements a static pass over the ast that binds
+// every identifier to its declaration before the program is executed.
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/token"
+)
+
+// Kind classifies the diagnostics reported by the resolver,
+// it is safe to use concurrently.
+type Kind string
+
+const (
+	Undefined           Kind = "undefined"
+	Unused              Kind = "unused"
+	Shadowed            Kind = "shadowed"
+	UseBeforeDefinition Kind = "use-before-definition"
+)
+
+// Diagnostic is a problem found by the resolver. Like the diagnostics
+// of the linter it is a value that can be used concurrently.
+type Diagnostic struct {
+	Pos     token.Position
+	Kind    Kind
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Pos, d.Message)
+}
+
+// binding is a name declared in a scope, by a let, function
+// or import statement, as a function parameter, by a catch
+// clause or by the pattern of a match arm.
+type binding struct {
+	name    string
+	slot    int
+	pos     token.Position
+	defined bool // whether the declaration precedes the current statement.
+	used    bool
+}
+
+// scope mirrors the environment that is created at runtime for the
+// program, for each function call, for each catch clause and for each
+// match arm that binds names. Other blocks do not open a new scope, a
+// let statement inside an if expression binds the name in the
+// enclosing function.
+type scope struct {
+	outer    *scope
+	bindings map[string]*binding
+	slots    int
+}
+
+type resolver struct {
+	scope       *scope
+	diagnostics []Diagnostic
+}
+
+// Resolve binds each identifier of program to its declaration and
+// annotates it with the depth and slot of the binding. Names that
+// are defined outside of the program (e.g. by the host or a previous
+// REPL input) are passed in predeclared. It returns the diagnostics
+// for undefined names, unused bindings, shadowed declarations and
+// uses of names before their definition, ordered by position.
+//
+// Identifiers that can not be resolved are left unresolved, the
+// evaluator then looks them up by name. Function literals are
+// annotated with the names of the slots of their environments.
+func Resolve(program *ast.Program, predeclared ...string) []Diagnostic {
+	r := new(resolver)
+	r.openScope()
+
+	for _, name := range predeclared {
+		b := r.declare(name, token.Position{})
+		b.defined, b.used = true, true
+	}
+
+	r.declareAll(program)
+	r.hoist(program.Statement)
+
+	for _, s := range program.Statement {
+		r.resolve(s)
+	}
+
+	r.closeScope()
+
+	sort.SliceStable(r.diagnostics, func(i, j int) bool {
+		a, b := r.diagnostics[i].Pos, r.diagnostics[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+
+	return r.diagnostics
+}
+
+func (r *resolver) openScope() {
+	r.scope = &scope{
+		outer:    r.scope,
+		bindings: map[string]*binding{},
+	}
+}
+
+func (r *resolver) closeScope() {
+	unused := []*binding{}
+	for _, b := range r.scope.bindings {
+		if !b.used && !strings.HasPrefix(b.name, "_") {
+			unused = append(unused, b)
+		}
+	}
+
+	sort.Slice(unused, func(i, j int) bool { return unused[i].slot < unused[j].slot })
+	for _, b := range unused {
+		r.report(b.pos, Unused, "%s declared and not used", b.name)
+	}
+
+	r.scope = r.scope.outer
+}
+
+// declare adds name to the current scope, unless it is already declared.
+func (r *resolver) declare(name string, pos token.Position) *binding {
+	if b, ok := r.scope.bindings[name]; ok {
+		return b
+	}
+
+	for s := r.scope.outer; s != nil; s = s.outer {
+		if b, ok := s.bindings[name]; ok {
+			if b.pos.IsValid() {
+				r.report(pos, Shadowed, "declaration of %s shadows the declaration at %s", name, b.pos)
+			} else {
+				r.report(pos, Shadowed, "declaration of %s shadows a predeclared name", name)
+			}
+			break
+		}
+	}
+
+	b := &binding{
+		name: name,
+		slot: r.scope.slots,
+		pos:  pos,
+	}
+
+	r.scope.bindings[name] = b
+	r.scope.slots++
+
+	return b
+}
+
+// declareAll declares all the let, function and import statements of the current
+// scope up front, so uses of a name before its let statement can be told
+// apart from uses of a name declared in an enclosing scope.
+func (r *resolver) declareAll(node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		case *ast.LetStatement:
+			ids := ast.Bindings(n.Pattern)
+			if n.Identifier != nil {
+				ids = []*ast.Identifier{n.Identifier}
+			}
+			for _, id := range ids {
+				b := r.declare(id.Value, id.Token.Pos)
+				// exported names are used by the importers.
+				b.used = b.used || n.Exported
+			}
+		case *ast.FunctionStatement:
+			if id := n.Name(); id != nil {
+				r.declare(id.Value, id.Token.Pos)
+			}
+		case *ast.ImportStatement:
+			if n.Name != nil {
+				r.declare(n.Name.Value, n.Name.Token.Pos)
+			}
+		case *ast.TryExpression:
+			// the catch clause is a scope of its own.
+			if n.Body != nil {
+				r.declareAll(n.Body)
+			}
+			if n.Finally != nil {
+				r.declareAll(n.Finally)
+			}
+			return false
+		case *ast.MatchExpression:
+			// the arms are scopes of their own.
+			if n.Value != nil {
+				r.declareAll(n.Value)
+			}
+			return false
+		case *ast.SelectExpression:
+			for _, a := range n.Arms {
+				if a.Binding != nil && a.Binding.Value != ast.Wildcard {
+					r.declare(a.Binding.Value, a.Binding.Token.Pos)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// hoist defines the names of the functions declared in
+// a block, they are bound before the block is evaluated.
+func (r *resolver) hoist(list []ast.Statement) {
+	for _, s := range list {
+		if fs, ok := s.(*ast.FunctionStatement); ok && fs.Name() != nil {
+			r.define(fs.Name())
+		}
+	}
+}
+
+func (r *resolver) resolve(node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStatement:
+			r.hoist(n.Statements)
+			return true
+		case *ast.FunctionStatement:
+			if n.Function != nil {
+				r.function(n.Function)
+			}
+			return false
+		case *ast.LetStatement:
+			if n.Expression != nil {
+				r.resolve(n.Expression)
+			}
+			if n.Identifier != nil {
+				r.define(n.Identifier)
+			}
+			if n.Pattern != nil {
+				r.pattern(n.Pattern)
+			}
+			return false
+		case *ast.ImportStatement:
+			if n.Name != nil {
+				r.define(n.Name)
+			}
+			return false
+		case *ast.TryExpression:
+			if n.Body != nil {
+				r.resolve(n.Body)
+			}
+			if n.Catch != nil {
+				r.catch(n)
+			}
+			if n.Finally != nil {
+				r.resolve(n.Finally)
+			}
+			return false
+		case *ast.MatchExpression:
+			if n.Value != nil {
+				r.resolve(n.Value)
+			}
+			for _, a := range n.Arms {
+				r.arm(a)
+			}
+			return false
+		case *ast.SelectExpression:
+			for _, a := range n.Arms {
+				if a.Channel != nil {
+					r.resolve(a.Channel)
+				}
+				if a.Value != nil {
+					r.resolve(a.Value)
+				}
+				if a.Binding != nil && a.Binding.Value != ast.Wildcard {
+					r.define(a.Binding)
+				}
+				if a.Body != nil {
+					r.resolve(a.Body)
+				}
+			}
+			return false
+		case *ast.HashLiteral:
+			// the keys that are identifiers are names of keys.
+			for _, p := range n.Pairs {
+				if _, ok := p.Key.(*ast.Identifier); !ok && p.Key != nil {
+					r.resolve(p.Key)
+				}
+				if p.Value != nil {
+					r.resolve(p.Value)
+				}
+			}
+			return false
+		case *ast.MemberExpression:
+			// the property is a member of the module, not a name of a scope.
+			if n.Left != nil {
+				r.resolve(n.Left)
+			}
+			return false
+		case *ast.FunctionLiteral:
+			r.function(n)
+			return false
+		case *ast.MacroLiteral:
+			r.macro(n)
+			return false
+		case *ast.CallExpression:
+			return r.call(n)
+		case *ast.NamedArgument:
+			// the name is a parameter of the function called.
+			if n.Value != nil {
+				r.resolve(n.Value)
+			}
+			return false
+		case *ast.Identifier:
+			r.use(n)
+			return false
+		}
+		return true
+	})
+}
+
+// pattern defines the names bound by p and resolves its defaults, in
+// the order in which they are matched: a default may use the names
+// bound by the elements before it.
+func (r *resolver) pattern(p ast.Pattern) {
+	switch p := p.(type) {
+	case *ast.Identifier:
+		if p.Value != ast.Wildcard {
+			r.define(p)
+		}
+	case *ast.ArrayPattern:
+		r.elements(p.Elements)
+		if p.Rest != nil && p.Rest.Value != ast.Wildcard {
+			r.define(p.Rest)
+		}
+	case *ast.HashPattern:
+		r.elements(p.Elements)
+	}
+}
+
+func (r *resolver) elements(elements []*ast.PatternElement) {
+	for _, e := range elements {
+		if e.Default != nil {
+			r.resolve(e.Default)
+		}
+		if e.Pattern != nil {
+			r.pattern(e.Pattern)
+		}
+	}
+}
+
+func (r *resolver) function(fn *ast.FunctionLiteral) {
+	r.openScope()
+
+	// the parameters take the first slots, a default value
+	// may use the parameters before its own.
+	for _, p := range fn.Parameters {
+		r.declare(p.Value, p.Token.Pos)
+	}
+	if fn.Rest != nil {
+		r.declare(fn.Rest.Value, fn.Rest.Token.Pos)
+	}
+
+	for i, p := range fn.Parameters {
+		if d := fn.Default(i); d != nil {
+			r.resolve(d)
+		}
+		r.define(p)
+	}
+	if fn.Rest != nil {
+		r.define(fn.Rest)
+	}
+
+	if fn.Body != nil {
+		r.declareAll(fn.Body)
+		r.resolve(fn.Body)
+	}
+
+	fn.Locals = r.scope.locals()
+	r.closeScope()
+}
+
+// locals returns the name bound to each slot of s.
+func (s *scope) locals() []string {
+	names := make([]string, s.slots)
+	for name, b := range s.bindings {
+		names[b.slot] = name
+	}
+	return names
+}
+
+// catch resolves the catch clause of te in a scope of its own.
+func (r *resolver) catch(te *ast.TryExpression) {
+	r.openScope()
+
+	if te.Parameter != nil {
+		r.declare(te.Parameter.Value, te.Parameter.Token.Pos)
+		r.define(te.Parameter)
+	}
+
+	r.declareAll(te.Catch)
+	r.resolve(te.Catch)
+
+	te.Locals = r.scope.locals()
+	r.closeScope()
+}
+
+// arm resolves a match arm in a scope of its own, unless it binds no
+// names: then it is resolved in the current scope.
+func (r *resolver) arm(a *ast.MatchArm) {
+	r.openScope()
+
+	for _, id := range ast.Bindings(a.Pattern) {
+		r.declare(id.Value, id.Token.Pos)
+	}
+	if a.Pattern != nil {
+		r.declareAll(a.Pattern)
+	}
+	if a.Guard != nil {
+		r.declareAll(a.Guard)
+	}
+	if a.Body != nil {
+		r.declareAll(a.Body)
+	}
+
+	empty := r.scope.slots == 0
+	if empty {
+		r.scope = r.scope.outer
+	}
+
+	if a.Pattern != nil {
+		r.pattern(a.Pattern)
+	}
+	if a.Guard != nil {
+		r.resolve(a.Guard)
+	}
+	if a.Body != nil {
+		r.resolve(a.Body)
+	}
+
+	if empty {
+		a.Locals = []string{}
+		return
+	}
+	a.Locals = r.scope.locals()
+	r.closeScope()
+}
+
+func (r *resolver) macro(m *ast.MacroLiteral) {
+	r.openScope()
+
+	for _, p := range m.Parameters {
+		r.declare(p.Value, p.Token.Pos)
+		r.define(p)
+	}
+
+	if m.Body != nil {
+		r.declareAll(m.Body)
+		r.resolve(m.Body)
+	}
+
+	r.closeScope()
+}
+
+// call resolves the quoted code of a call of quote, which is not
+// evaluated, except for the arguments of the unquotes in it. It
+// reports whether the children of other calls need to be resolved.
+func (r *resolver) call(call *ast.CallExpression) bool {
+	switch {
+	case ast.IsCallTo(call, ast.Quote):
+		for _, a := range call.Arguments {
+			for _, u := range ast.Unquotes(a) {
+				r.resolveAll(u.Arguments)
+			}
+		}
+		return false
+	case ast.IsCallTo(call, ast.Unquote):
+		r.resolveAll(call.Arguments)
+		return false
+	default:
+		return true
+	}
+}
+
+func (r *resolver) resolveAll(list []ast.Expression) {
+	for _, e := range list {
+		if e != nil {
+			r.resolve(e)
+		}
+	}
+}
+
+// define marks the binding of the declared identifier as defined.
+func (r *resolver) define(id *ast.Identifier) {
+	b := r.scope.bindings[id.Value]
+	b.defined = true
+
+	id.Resolved = true
+	id.Depth = 0
+	id.Slot = b.slot
+}
+
+func (r *resolver) use(id *ast.Identifier) {
+	depth := 0
+
+	for s := r.scope; s != nil; s = s.outer {
+		if b, ok := s.bindings[id.Value]; ok {
+			b.used = true
+
+			// functions may be called after the enclosing scope defined the
+			// name, only uses in the same scope are checked for the order.
+			if depth == 0 && !b.defined {
+				r.report(id.Token.Pos, UseBeforeDefinition, "%s used before its definition at %s", id.Value, b.pos)
+			}
+
+			id.Resolved = true
+			id.Depth = depth
+			id.Slot = b.slot
+			return
+		}
+
+		depth++
+	}
+
+	id.Resolved = false
+	r.report(id.Token.Pos, Undefined, "undefined: %s", id.Value)
+}
+
+func (r *resolver) report(pos token.Position, kind Kind, format string, args ...interface{}) {
+	r.diagnostics = append(r.diagnostics, Diagnostic{
+		Pos:     pos,
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
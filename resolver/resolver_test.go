@@ -0,0 +1,263 @@
+package resolver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/lexer"
+	"github.com/Despire/interpreter/parser"
+)
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let a = 1; a;",
+			nil,
+		},
+		{
+			"foobar;",
+			[]string{"1:1: undefined: foobar"},
+		},
+		{
+			"let a = 1;",
+			[]string{"1:5: a declared and not used"},
+		},
+		{
+			"let _a = 1;",
+			nil,
+		},
+		{
+			"let f = fn(x, y) { x }; f(1, 2);",
+			[]string{"1:15: y declared and not used"},
+		},
+		{
+			"let x = 1; let f = fn(x) { x }; f(x);",
+			[]string{"1:23: declaration of x shadows the declaration at 1:5"},
+		},
+		{
+			"let x = 1; let f = fn() { let x = 2; x }; f() + x;",
+			[]string{"1:31: declaration of x shadows the declaration at 1:5"},
+		},
+		{
+			"let b = a; let a = 1; b;",
+			[]string{"1:9: a used before its definition at 1:16"},
+		},
+		{
+			"let a = a + 1; a;",
+			[]string{"1:9: a used before its definition at 1:5"},
+		},
+		{
+			// functions are called after the name is defined.
+			"let f = fn() { g() }; let g = fn() { 1 }; f();",
+			nil,
+		},
+		{
+			"let f = fn() { f() }; f();",
+			nil,
+		},
+		{
+			// blocks do not open a new scope.
+			"if (true) { let a = 1; }; a;",
+			nil,
+		},
+		{
+			"let f = fn(n) { if (n) { return g; } h }; f(1);",
+			[]string{"1:33: undefined: g", "1:38: undefined: h"},
+		},
+		{
+			"puts(x);",
+			[]string{"1:6: undefined: x"},
+		},
+		{
+			`import "lib.mk" as m; m.a(m.b);`,
+			nil,
+		},
+		{
+			`import "lib.mk" as m;`,
+			[]string{"1:20: m declared and not used"},
+		},
+		{
+			// exported names are used by the importers.
+			"export let a = 1; let b = 2;",
+			[]string{"1:23: b declared and not used"},
+		},
+		{
+			`let f = fn() { m.a }; import "lib.mk" as m; f();`,
+			nil,
+		},
+		{
+			// quoted code is only resolved inside unquote.
+			"let m = macro(a) { quote(b + unquote(a) + unquote(c)) }; m(1);",
+			[]string{"1:51: undefined: c"},
+		},
+		{
+			"let m = macro(a, b) { quote(unquote(a)) }; m(1, 2);",
+			[]string{"1:18: b declared and not used"},
+		},
+		{
+			"try { throw 1; } catch (e) { e }",
+			nil,
+		},
+		{
+			"let f = fn(x) { match (x) { 0 => 1, n if n > 0 => n, _ => y } }; f(1);",
+			[]string{"1:59: undefined: y"},
+		},
+		{
+			"match (1) { n => 2 }",
+			[]string{"1:13: n declared and not used"},
+		},
+		{
+			"try { throw 1; } catch (e) { 2 } finally { x }",
+			[]string{"1:25: e declared and not used", "1:44: undefined: x"},
+		},
+		{
+			"let [a, b = a, ...rest] = [1]; b + rest[0];",
+			nil,
+		},
+		{
+			"let [a, b = c, c] = [1]; a + b + c;",
+			[]string{"1:13: c used before its definition at 1:16"},
+		},
+		{
+			"let {name, age: years, _} = {name: 1}; name;",
+			[]string{"1:17: years declared and not used"},
+		},
+		{
+			"let x = {name: 1}; x[name];",
+			[]string{"1:22: undefined: name"},
+		},
+		{
+			"let f = fn(a, b = a, ...rest) { b + rest[0] }; f(1, b: 2);",
+			nil,
+		},
+		{
+			"let f = fn(a = b, b = 1) { a + b }; f(a: 1);",
+			[]string{"1:16: b used before its definition at 1:19"},
+		},
+		{
+			"let f = fn(a, ...rest) { a }; f(1, a: 2, c: d);",
+			[]string{"1:18: rest declared and not used", "1:45: undefined: d"},
+		},
+		{
+			"match ([1]) { [n, ...r] => n }",
+			[]string{"1:22: r declared and not used"},
+		},
+		{
+			"even(2); fn even(n) { if (n == 0) { true } else { odd(n - 1) } } fn odd(n) { even(n) }",
+			nil,
+		},
+		{
+			"let f = fn() { g(); if (true) { fn g() { h() } fn h() { 1 } } g() }; f();",
+			[]string{"1:16: g used before its definition at 1:36"},
+		},
+		{
+			"fn f() { 1 } fn unused(x) { x }",
+			[]string{"1:4: f declared and not used", "1:17: unused declared and not used"},
+		},
+		{
+			"let c = chan(); select { c.recv() as v => v, c.recv() as w => 1, c.send(x) => 2, _ => 3 }",
+			[]string{"1:58: w declared and not used", "1:73: undefined: x"},
+		},
+		{
+			"let c = chan(); spawn f(c); fn f(ch) { ch.recv() }",
+			nil,
+		},
+		{
+			"let f = fn() { let e = 5; try { throw 1 } catch (e) { 0 }; e }; f();",
+			[]string{"1:50: declaration of e shadows the declaration at 1:20", "1:50: e declared and not used"},
+		},
+		{
+			"try { throw 1; } catch (e) { let x = e; x }; x;",
+			[]string{"1:46: undefined: x"},
+		},
+		{
+			"let a = 9; match ([1, 2]) { [a, 3] => 0, _ => a };",
+			[]string{"1:30: declaration of a shadows the declaration at 1:5", "1:30: a declared and not used"},
+		},
+		{
+			"match (5) { n => n }; n;",
+			[]string{"1:23: undefined: n"},
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+
+		var have []string
+		for _, d := range Resolve(program, "puts") {
+			have = append(have, d.String())
+		}
+
+		if !reflect.DeepEqual(have, tt.expected) {
+			t.Errorf("wrong diagnostics for %q\nhave: %q\nwant: %q", tt.input, have, tt.expected)
+		}
+	}
+}
+
+func TestDiagnosticKinds(t *testing.T) {
+	program := parse(t, "let x = 1; let f = fn(x) { y; z; let z = 1; }; f(x);")
+
+	var kinds []Kind
+	for _, d := range Resolve(program) {
+		kinds = append(kinds, d.Kind)
+	}
+
+	expected := []Kind{Shadowed, Unused, Undefined, UseBeforeDefinition}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("wrong kinds, have %v, want %v", kinds, expected)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	input := `
+let a = 1;
+let f = fn(x, y) {
+	let z = x + y;
+	fn(w) { w + z + a + unknown };
+};`
+
+	program := parse(t, input)
+	Resolve(program)
+
+	type annotation struct {
+		Resolved    bool
+		Depth, Slot int
+	}
+
+	have := map[string][]annotation{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Identifier); ok {
+			have[id.Value] = append(have[id.Value], annotation{id.Resolved, id.Depth, id.Slot})
+		}
+		return true
+	})
+
+	expected := map[string][]annotation{
+		"a":       {{true, 0, 0}, {true, 2, 0}},
+		"f":       {{true, 0, 1}},
+		"x":       {{true, 0, 0}, {true, 0, 0}},
+		"y":       {{true, 0, 1}, {true, 0, 1}},
+		"z":       {{true, 0, 2}, {true, 1, 2}},
+		"w":       {{true, 0, 0}, {true, 0, 0}},
+		"unknown": {{false, 0, 0}},
+	}
+
+	if !reflect.DeepEqual(have, expected) {
+		t.Errorf("wrong annotations\nhave: %v\nwant: %v", have, expected)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	for _, err := range p.Errors() {
+		t.Fatalf("parser error: %q", err)
+	}
+
+	return program
+}
@@ -0,0 +1,261 @@
+package sched
+
+import "github.com/Despire/interpreter/objects"
+
+// Channel is a channel created by a chan expression, it buffers up to
+// its capacity of values sent and not received yet. A send to a full
+// channel blocks until a value is received, a receive from an empty
+// one until a value is sent, so an unbuffered channel hands each value
+// from a sender to a receiver directly.
+//
+// A channel is used by the tasks of one evaluation at a time, it must
+// not be shared by evaluations running concurrently.
+type Channel struct {
+	capacity int
+	buffer   []objects.Object
+	closed   bool
+
+	// the operations blocked on the channel, in the order they blocked.
+	senders   []operation
+	receivers []operation
+}
+
+// waiting is the state of a task blocked on the operations of a select.
+type waiting struct {
+	task *Task
+	done bool // set once one of the operations proceeded, or the wait was cancelled.
+
+	index int // the operation that proceeded.
+	value objects.Object
+	err   *objects.Error
+}
+
+// operation is an operation a task is blocked on.
+type operation struct {
+	w     *waiting
+	index int
+	value objects.Object // the value sent.
+}
+
+// Case is an operation of a select: a receive from Channel,
+// or a send of Value to it if Send is set. It is a value used
+// by the task performing the select.
+type Case struct {
+	Channel *Channel
+	Send    bool
+	Value   objects.Object
+}
+
+// NewChannel returns a channel buffering up to capacity values,
+// which must be a non-negative integer.
+func NewChannel(capacity objects.Object) (*Channel, *objects.Error) {
+	n, ok := capacity.(*objects.Integer)
+	if !ok || n.Value < 0 {
+		return nil, objects.Errorf(objects.ArgumentError, "invalid channel capacity: %s", capacity.Inspect())
+	}
+	return &Channel{capacity: int(n.Value)}, nil
+}
+
+// implement Object interface
+func (c *Channel) Type() objects.Type { return objects.CHANNEL }
+func (c *Channel) Inspect() string    { return "channel" }
+func (c *Channel) Equals(other objects.Object) bool {
+	o, ok := other.(*Channel)
+	return ok && o == c
+}
+
+// Member returns the method name of the channel: send, recv or close.
+func (c *Channel) Member(name string) (objects.Object, bool) {
+	switch name {
+	case "send", "recv", "close":
+		return &objects.Method{Receiver: c, Name: name}, true
+	default:
+		return nil, false
+	}
+}
+
+// Call calls the method name of c with args, the last of which are
+// named by names: send(value) sends the value, recv() returns the value
+// received, null once the channel is closed and drained, and close()
+// closes the channel.
+func (t *Task) Call(c *Channel, name string, args []objects.Object, names []string) (objects.Object, error) {
+	switch name {
+	case "send":
+		slots := make([]objects.Object, 1)
+		if err := objects.BindArguments(name, slots, []string{"value"}, 0, "", args, names); err != nil {
+			return nil, err
+		}
+		_, _, err := t.Select([]Case{{Channel: c, Send: true, Value: slots[0]}}, true)
+		return objects.NewNull(), err
+	case "recv":
+		if err := objects.BindArguments(name, nil, nil, 0, "", args, names); err != nil {
+			return nil, err
+		}
+		_, val, err := t.Select([]Case{{Channel: c}}, true)
+		return val, err
+	case "close":
+		if err := objects.BindArguments(name, nil, nil, 0, "", args, names); err != nil {
+			return nil, err
+		}
+		if err := c.close(); err != nil {
+			return nil, err
+		}
+		return objects.NewNull(), nil
+	default:
+		return nil, objects.Errorf(objects.NameError, "channel has no member %s", name)
+	}
+}
+
+// Select performs the first of cases that can proceed. If none can it
+// blocks until one can, unless block is not set, then it returns -1.
+// It returns the index of the case performed and the value received,
+// null for a send or a receive from a closed channel.
+func (t *Task) Select(cases []Case, block bool) (int, objects.Object, error) {
+	for i, c := range cases {
+		if c.Send {
+			ok, err := c.Channel.send(c.Value)
+			if err != nil {
+				return -1, nil, err
+			}
+			if ok {
+				return i, objects.NewNull(), nil
+			}
+			continue
+		}
+
+		if val, ok := c.Channel.recv(); ok {
+			return i, val, nil
+		}
+	}
+
+	if !block {
+		return -1, objects.NewNull(), nil
+	}
+
+	w := &waiting{task: t}
+	for i, c := range cases {
+		op := operation{w: w, index: i, value: c.Value}
+		if c.Send {
+			c.Channel.senders = append(c.Channel.senders, op)
+		} else {
+			c.Channel.receivers = append(c.Channel.receivers, op)
+		}
+	}
+
+	err := t.park(w)
+	if err == ErrStopped {
+		// the other tasks being stopped may still use the channels.
+		return -1, nil, err
+	}
+
+	for _, c := range cases {
+		c.Channel.senders = pending(c.Channel.senders)
+		c.Channel.receivers = pending(c.Channel.receivers)
+	}
+
+	switch {
+	case err != nil:
+		return -1, nil, err
+	case w.err != nil:
+		return -1, nil, w.err
+	}
+
+	return w.index, w.value, nil
+}
+
+// send sends val unless the channel is full, it reports whether it did.
+func (c *Channel) send(val objects.Object) (bool, *objects.Error) {
+	if c.closed {
+		return false, objects.Errorf(objects.ChannelError, "send on a closed channel")
+	}
+
+	if r, ok := first(&c.receivers); ok {
+		r.proceed(val, nil)
+		return true, nil
+	}
+
+	if len(c.buffer) < c.capacity {
+		c.buffer = append(c.buffer, val)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// recv receives a value unless the channel is empty, it reports whether it did.
+func (c *Channel) recv() (objects.Object, bool) {
+	if len(c.buffer) > 0 {
+		val := c.buffer[0]
+		c.buffer[0] = nil
+		c.buffer = c.buffer[1:]
+
+		// the first blocked sender takes the free place.
+		if s, ok := first(&c.senders); ok {
+			c.buffer = append(c.buffer, s.value)
+			s.proceed(objects.NewNull(), nil)
+		}
+		return val, true
+	}
+
+	if s, ok := first(&c.senders); ok {
+		s.proceed(objects.NewNull(), nil)
+		return s.value, true
+	}
+
+	if c.closed {
+		return objects.NewNull(), true
+	}
+
+	return nil, false
+}
+
+// close closes the channel, the blocked receivers receive null
+// and the blocked senders fail.
+func (c *Channel) close() *objects.Error {
+	if c.closed {
+		return objects.Errorf(objects.ChannelError, "close of a closed channel")
+	}
+	c.closed = true
+
+	for r, ok := first(&c.receivers); ok; r, ok = first(&c.receivers) {
+		r.proceed(objects.NewNull(), nil)
+	}
+	for s, ok := first(&c.senders); ok; s, ok = first(&c.senders) {
+		s.proceed(nil, objects.Errorf(objects.ChannelError, "send on a closed channel"))
+	}
+
+	return nil
+}
+
+// proceed completes the select op belongs to with the value
+// received or the error raised, its task becomes runnable.
+func (op operation) proceed(val objects.Object, err *objects.Error) {
+	w := op.w
+	w.done = true
+	w.index, w.value, w.err = op.index, val, err
+	w.task.ready()
+}
+
+// first removes the first operation of ops whose select is still
+// blocked, it reports whether there is one.
+func first(ops *[]operation) (operation, bool) {
+	for len(*ops) > 0 {
+		op := (*ops)[0]
+		*ops = (*ops)[1:]
+		if !op.w.done {
+			return op, true
+		}
+	}
+	return operation{}, false
+}
+
+// pending returns the operations of ops whose select is still blocked.
+func pending(ops []operation) []operation {
+	kept := ops[:0]
+	for _, op := range ops {
+		if !op.w.done {
+			kept = append(kept, op)
+		}
+	}
+	return kept
+}
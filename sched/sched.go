@@ -0,0 +1,195 @@
+// Package sched schedules the tasks started by the spawn expressions
+// of a program and implements the channels they communicate over. It
+// is used by both engines, which run the code of the tasks.
+//
+// Every task runs on a goroutine of its own, but the tasks of an
+// evaluation take turns: a task runs until it blocks on a channel or
+// ends, then the task that became runnable first runs. So the code of a
+// task between two channel operations is never interleaved with the
+// code of another task, and the environments the tasks share through
+// their closures are only ever accessed by the task whose turn it is.
+//
+// The main task is the evaluation of the program itself, the other
+// tasks are stopped once it ends. An error a task does not catch is
+// raised in the main task by the channel operation it is blocked on,
+// so is the DeadlockError raised once every task is blocked.
+package sched
+
+import (
+	"errors"
+
+	"github.com/Despire/interpreter/objects"
+)
+
+// ErrStopped is returned by the operations of a task that was stopped
+// because the main task ended. The engines unwind the task without
+// evaluating any more of its code.
+var ErrStopped = errors.New("task stopped")
+
+// scheduler holds the tasks of an evaluation. Its state is only
+// accessed by the task whose turn it is.
+type scheduler struct {
+	main     *Task
+	runnable []*Task // in the order in which they became runnable.
+	tasks    []*Task // the spawned tasks that have not ended.
+
+	// failure is the error raised in the main task when it resumes.
+	failure *objects.Error
+}
+
+// Task is a task of an evaluation. Its methods must only be called
+// by the goroutines running it while it is its turn.
+type Task struct {
+	s    *scheduler
+	wake chan struct{} // signalled when it is the turn of the task.
+
+	// wait is the operation the task is blocked on, nil if it is not.
+	wait    *waiting
+	stopped bool
+
+	exited chan struct{} // closed once a spawned task ended.
+}
+
+// New returns the main task of a new evaluation, whose turn it is.
+func New() *Task {
+	s := &scheduler{}
+	s.main = newTask(s)
+	return s.main
+}
+
+func newTask(s *scheduler) *Task {
+	return &Task{
+		s:      s,
+		wake:   make(chan struct{}, 1),
+		exited: make(chan struct{}),
+	}
+}
+
+// Spawn starts a task calling run. It runs once the tasks
+// that are runnable before it had their turn.
+func (t *Task) Spawn(run func(*Task) error) {
+	s := t.s
+	n := newTask(s)
+	s.tasks = append(s.tasks, n)
+	s.runnable = append(s.runnable, n)
+
+	go func() {
+		defer close(n.exited)
+
+		<-n.wake
+		if n.stopped {
+			return
+		}
+
+		n.exit(run(n))
+	}()
+}
+
+// exit ends the task, err is the error it failed with, if any.
+func (t *Task) exit(err error) {
+	if t.stopped {
+		return
+	}
+
+	s := t.s
+	for i, n := range s.tasks {
+		if n == t {
+			s.tasks = append(s.tasks[:i], s.tasks[i+1:]...)
+			break
+		}
+	}
+
+	if err != nil {
+		e, ok := err.(*objects.Error)
+		if !ok {
+			e = objects.Errorf(objects.TypeError, "%v", err)
+		}
+		s.fail(e)
+		return
+	}
+
+	s.next()
+}
+
+// Stop stops the other tasks, it is called by the main task when it
+// ends. It returns once their goroutines have exited.
+func (t *Task) Stop() {
+	s := t.s
+	tasks := s.tasks
+	s.tasks, s.runnable = nil, nil
+
+	for _, n := range tasks {
+		n.stopped = true
+		if n.wait != nil {
+			n.wait.done = true
+			n.wait = nil
+		}
+		n.wake <- struct{}{}
+	}
+
+	for _, n := range tasks {
+		<-n.exited
+	}
+}
+
+// park blocks the task on w until an operation of w proceeds. The turn
+// passes to the next runnable task meanwhile.
+func (t *Task) park(w *waiting) error {
+	t.wait = w
+	t.s.next()
+
+	<-t.wake
+
+	s := t.s
+	switch {
+	case t.stopped:
+		return ErrStopped
+	case t == s.main && s.failure != nil:
+		err := s.failure
+		s.failure = nil
+		return err
+	}
+
+	return nil
+}
+
+// next passes the turn to the task that became runnable first. If
+// there is none every task is blocked, which fails the main task.
+func (s *scheduler) next() {
+	if len(s.runnable) == 0 {
+		s.fail(objects.Errorf(objects.DeadlockError, "deadlock: all tasks are blocked"))
+		return
+	}
+
+	n := s.runnable[0]
+	s.runnable = s.runnable[1:]
+	n.wake <- struct{}{}
+}
+
+// fail passes the turn to the main task, err is raised in it.
+func (s *scheduler) fail(err *objects.Error) {
+	if s.failure == nil {
+		s.failure = err
+	}
+
+	main := s.main
+	if main.wait != nil {
+		main.wait.done = true
+		main.wait = nil
+	}
+
+	for i, n := range s.runnable {
+		if n == main {
+			s.runnable = append(s.runnable[:i], s.runnable[i+1:]...)
+			break
+		}
+	}
+
+	main.wake <- struct{}{}
+}
+
+// ready makes t runnable, it takes its turn after the tasks runnable before.
+func (t *Task) ready() {
+	t.wait = nil
+	t.s.runnable = append(t.s.runnable, t)
+}
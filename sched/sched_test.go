@@ -0,0 +1,80 @@
+package sched
+
+import (
+	"testing"
+
+	"github.com/Despire/interpreter/objects"
+)
+
+func TestSelect(t *testing.T) {
+	main := New()
+	defer main.Stop()
+
+	full, e := NewChannel(objects.NewInteger(1))
+	if e != nil {
+		t.Fatalf("failed to create a channel: %s", e)
+	}
+	empty, _ := NewChannel(objects.NewInteger(0))
+
+	cases := []Case{{Channel: empty}, {Channel: full, Send: true, Value: objects.NewInteger(1)}}
+	if i, _, err := main.Select(cases, false); i != 1 || err != nil {
+		t.Fatalf("wrong case performed. want=1, have=%d (%v)", i, err)
+	}
+	if i, _, err := main.Select(cases, false); i != -1 || err != nil {
+		t.Fatalf("wrong case performed. want=-1, have=%d (%v)", i, err)
+	}
+
+	i, val, err := main.Select([]Case{{Channel: empty}, {Channel: full}}, true)
+	if i != 1 || err != nil || val.Inspect() != "1" {
+		t.Fatalf("wrong value received. want=1, have=%d %v (%v)", i, val, err)
+	}
+}
+
+func TestTasks(t *testing.T) {
+	main := New()
+	defer main.Stop()
+
+	c, _ := NewChannel(objects.NewInteger(0))
+	for i := int64(1); i <= 3; i++ {
+		n := objects.NewInteger(i)
+		main.Spawn(func(t *Task) error {
+			_, err := t.Call(c, "send", []objects.Object{n}, nil)
+			return err
+		})
+	}
+
+	for want := 1; want <= 3; want++ {
+		val, err := main.Call(c, "recv", nil, nil)
+		if err != nil || val.Inspect() != objects.NewInteger(int64(want)).Inspect() {
+			t.Fatalf("wrong value received. want=%d, have=%v (%v)", want, val, err)
+		}
+	}
+
+	_, err := main.Call(c, "recv", nil, nil)
+	if e, ok := err.(*objects.Error); !ok || e.Kind != objects.DeadlockError {
+		t.Fatalf("wrong error of a deadlock. have=%v", err)
+	}
+}
+
+func TestStop(t *testing.T) {
+	main := New()
+	c, _ := NewChannel(objects.NewInteger(0))
+
+	stopped := make(chan error, 1)
+	main.Spawn(func(t *Task) error {
+		_, err := t.Call(c, "recv", nil, nil)
+		stopped <- err
+		return err
+	})
+
+	// the task blocks too, which fails the main task.
+	d, _ := NewChannel(objects.NewInteger(0))
+	if _, err := main.Call(d, "recv", nil, nil); err == nil {
+		t.Fatalf("no deadlock reported")
+	}
+
+	main.Stop()
+	if err := <-stopped; err != ErrStopped {
+		t.Fatalf("wrong error of a stopped task. want=%v, have=%v", ErrStopped, err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Despire/interpreter/ast"
+	"github.com/Despire/interpreter/compiler"
+	"github.com/Despire/interpreter/lexer"
+	"github.com/Despire/interpreter/macro"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/optimizer"
+	"github.com/Despire/interpreter/parser"
+)
+
+// readSource reads the file at path, or the standard
+// input if path is empty or "-".
+func readSource(path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(path)
+}
+
+// parseSource parses src into a program, all the
+// syntax errors are returned as a single error.
+func parseSource(src []byte) (*ast.Program, error) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	return program, nil
+}
+
+// expandMacros defines the macros of program in env and
+// returns the program with the calls of macros expanded.
+func expandMacros(program *ast.Program, env *objects.Environment) (*ast.Program, error) {
+	macro.DefineMacros(program, env)
+
+	expanded, err := macro.ExpandMacros(program, env)
+	if err != nil {
+		return nil, err
+	}
+
+	return expanded.(*ast.Program), nil
+}
+
+// compileSource parses, expands the macros, optionally optimizes, and
+// compiles src to bytecode, or decodes it if it already is encoded bytecode.
+func compileSource(src []byte, optimize bool) (*compiler.Bytecode, error) {
+	if compiler.IsBytecode(src) {
+		b := new(compiler.Bytecode)
+		if err := b.UnmarshalBinary(src); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+
+	program, err := parseSource(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if program, err = expandMacros(program, objects.NewEnvironment()); err != nil {
+		return nil, err
+	}
+
+	if optimize {
+		optimizer.Optimize(program)
+	}
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		return nil, err
+	}
+
+	return c.Bytecode(), nil
+}
+
+// parseFlags parses args with flags, allowing the flags to follow
+// the positional arguments. It returns the positional arguments.
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
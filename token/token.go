@@ -1,13 +1,17 @@
 package token
 
+import "fmt"
+
 const (
 	// Meta
 	ILLEGAL Type = "ILLEGAL"
 	EOF          = "EOF"
+	COMMENT      = "COMMENT" // "// ..."
 
 	// Idettifiers, literals
 	IDENTIFIER = "IDENTIFIER" // "subtract", "foo", "bar"..
 	INTEGER    = "INTEGER"    // 1, 5, 1231...
+	STRING     = "STRING"     // "lib/math.mk", "bad input"
 
 	// OPERATORS
 	ASSIGN   = "="
@@ -20,14 +24,20 @@ const (
 	GREATERT = ">"
 	EQUAL    = "=="
 	NEQUAL   = "!="
+	ARROW    = "=>"
 
 	// Delimiters
 	COMMA            = ","
+	COLON            = ":"
+	DOT              = "."
+	ELLIPSIS         = "..."
 	SEMICOLON        = ";"
 	LEFTPARENTHESIS  = "("
 	RIGHTPARENTHESIS = ")"
 	LEFTBRACKET      = "{"
 	RIGHTBRACKET     = "}"
+	LEFTSQUARE       = "["
+	RIGHTSQUARE      = "]"
 
 	// Keywords
 	FUNCTION = "FUNCTION"
@@ -37,26 +47,75 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	MACRO    = "MACRO"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	AS       = "AS"
+	THROW    = "THROW"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	MATCH    = "MATCH"
+	YIELD    = "YIELD"
+	SPAWN    = "SPAWN"
+	CHAN     = "CHAN"
+	SELECT   = "SELECT"
 )
 
 var reservedKeywords = map[string]Type{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":      FUNCTION,
+	"let":     LET,
+	"true":    TRUE,
+	"false":   FALSE,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"macro":   MACRO,
+	"import":  IMPORT,
+	"export":  EXPORT,
+	"as":      AS,
+	"throw":   THROW,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"match":   MATCH,
+	"yield":   YIELD,
+	"spawn":   SPAWN,
+	"chan":    CHAN,
+	"select":  SELECT,
 }
 
-// Type represents the type of the token.
+// Type represents the type of the token. Like the other types
+// of the package it is a value, safe to use concurrently.
 type Type string
 
+// Position represents the location of a token
+// in the source code. Lines and columns start at 1,
+// a zero Position means the location is unknown.
+// Positions are copied by value, so they can be used
+// by any number of goroutines.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position is known.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
 // Token aggregates the Type and its Literal
-// to make the process of parsing easier.
+// to make the process of parsing easier. A Token is
+// a value, its copies can be used concurrently.
 type Token struct {
 	Typ     Type
 	Literal string
+	Pos     Position
 }
 
 // LookupIdentifier checks whether s is a reserved keyword
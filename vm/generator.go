@@ -0,0 +1,118 @@
+package vm
+
+import (
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/sched"
+)
+
+// generatorStackSize is the initial size of the stack of a generator.
+const generatorStackSize = 64
+
+// generator is the iterator returned by a call of a generator function.
+// It holds the frames and the stack of the function while it is
+// suspended, they are swapped with those of the VM while it runs.
+type generator struct {
+	cl     *objects.Closure
+	frames []*frame
+	stack  []objects.Object
+	sp     int
+
+	started bool
+	running bool
+	done    bool
+}
+
+// yield is returned by run when the current generator yields value.
+type yield struct {
+	value objects.Object
+}
+
+func (y *yield) Error() string { return "yield outside of a generator" }
+
+// newGenerator returns a generator that runs the closure cl in scope,
+// the scope of the call with the arguments bound.
+func newGenerator(cl *objects.Closure, scope *objects.Scope) *generator {
+	return &generator{
+		cl:     cl,
+		frames: []*frame{{cl: cl, scope: scope}},
+		stack:  make([]objects.Object, generatorStackSize),
+	}
+}
+
+// implement Object interface
+func (g *generator) Type() objects.Type { return objects.GENERATOR }
+func (g *generator) Equals(other objects.Object) bool {
+	o, ok := other.(*generator)
+	return ok && o == g
+}
+func (g *generator) Inspect() string {
+	if g.cl.Fn.Name == "" {
+		return "generator"
+	}
+	return "generator " + g.cl.Fn.Name
+}
+
+// callMethod calls the method m with args, the last of which are named by names.
+func (vm *VM) callMethod(m *objects.Method, args []objects.Object, names []string) (objects.Object, error) {
+	var g *generator
+	switch r := m.Receiver.(type) {
+	case *generator:
+		g = r
+	case *sched.Channel:
+		return vm.task.Call(r, m.Name, args, names)
+	default:
+		return nil, objects.Errorf(objects.TypeError, "not a function: %s", m.Type())
+	}
+
+	slots := make([]objects.Object, 1)
+	if err := objects.BindArguments(m.Name, slots, []string{"value"}, 1, "", args, names); err != nil {
+		return nil, err
+	}
+	if slots[0] == nil {
+		slots[0] = objects.NewNull()
+	}
+
+	return vm.resume(g, slots[0])
+}
+
+// resume runs the function of g until it yields or returns, arg is the
+// value of the yield expression it was suspended at. It returns the
+// iteration {value: v, done: d} or the error the function failed with.
+func (vm *VM) resume(g *generator, arg objects.Object) (objects.Object, error) {
+	switch {
+	case g.done:
+		return objects.NewIteration(objects.NewNull(), true), nil
+	case g.running:
+		return nil, objects.Errorf(objects.TypeError, "generator is already running")
+	}
+
+	// the calls of the frames resuming g and the call of next nest
+	// the calls of g, each resume runs on the Go stack of the last.
+	frames, stack, sp, popped, base := vm.frames, vm.stack, vm.sp, vm.lastPopped, vm.base
+	vm.frames, vm.stack, vm.sp = g.frames, g.stack, g.sp
+	vm.base += len(frames)
+
+	if g.started {
+		vm.push(arg)
+	}
+	g.started, g.running = true, true
+
+	err := vm.execute()
+
+	g.running = false
+	g.frames, g.stack, g.sp = vm.frames, vm.stack, vm.sp
+	returned := vm.lastPopped
+	vm.frames, vm.stack, vm.sp, vm.lastPopped, vm.base = frames, stack, sp, popped, base
+
+	if y, ok := err.(*yield); ok {
+		return objects.NewIteration(y.value, false), nil
+	}
+
+	g.done = true
+	g.frames, g.stack = nil, nil
+	if err != nil {
+		return nil, err
+	}
+
+	return objects.NewIteration(returned, true), nil
+}
@@ -0,0 +1,36 @@
+package vm
+
+import (
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/sched"
+)
+
+// spawn starts a task calling fn with args, the last of which are
+// named by names. The task is executed by a VM of its own sharing the
+// globals of vm, its errors are raised in the main task.
+func (vm *VM) spawn(fn objects.Object, args []objects.Object, names []string) {
+	vm.task.Spawn(func(t *sched.Task) error {
+		task := &VM{
+			globals:  vm.globals,
+			stack:    make([]objects.Object, generatorStackSize),
+			imports:  vm.imports,
+			maxDepth: vm.maxDepth,
+			task:     t,
+		}
+
+		task.push(fn)
+		for _, arg := range args {
+			task.push(arg)
+		}
+
+		// the errors of the call itself are not raised in a frame.
+		if err := task.call(len(args), names, false); err != nil {
+			return err
+		}
+		if len(task.frames) == 0 {
+			return nil
+		}
+
+		return task.execute()
+	})
+}
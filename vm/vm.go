@@ -0,0 +1,793 @@
+// Package vm implements a stack based virtual machine
+// that executes the bytecode produced by the compiler.
+package vm
+
+import (
+	"fmt"
+
+	"github.com/Despire/interpreter/code"
+	"github.com/Despire/interpreter/compiler"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/sched"
+)
+
+const initialStackSize = 2048
+
+// DefaultMaxDepth is the maximum depth of nested function calls unless
+// another is set, it is the same as the default of the evaluator.
+const DefaultMaxDepth = 10000
+
+var operators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpLessThan:    "<",
+	code.OpGreaterThan: ">",
+}
+
+// frame is the state of a function call.
+type frame struct {
+	cl       *objects.Closure
+	ip       int
+	bp       int // the stack pointer before the call.
+	scope    *objects.Scope
+	handlers []handler // the handlers installed by OpTry, innermost last.
+}
+
+// handler is the target of the errors raised in a try expression.
+type handler struct {
+	ip int // the start of the catch clause.
+	sp int // the stack pointer at OpTry.
+}
+
+// VM executes a compiled program. A VM is not safe for concurrent use
+// and neither are its globals, which must not be shared by VMs running
+// concurrently. VMs running the same Bytecode can run concurrently.
+type VM struct {
+	globals *objects.Scope
+
+	stack []objects.Object
+	sp    int // points to the next free slot, the top of the stack is stack[sp-1].
+
+	frames []*frame
+
+	imports objects.Importer
+
+	lastPopped objects.Object
+
+	// base is the depth of the calls that resumed the running
+	// generator, maxDepth the maximum depth of nested calls.
+	base     int
+	maxDepth int
+
+	task *sched.Task // the task executed.
+}
+
+// New returns a virtual machine for bytecode.
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobals(bytecode, &objects.Scope{})
+}
+
+// NewWithGlobals returns a virtual machine for bytecode that binds
+// the names of the program in globals, e.g. the scope shared by
+// the inputs of a REPL.
+func NewWithGlobals(bytecode *compiler.Bytecode, globals *objects.Scope) *VM {
+	if n := len(bytecode.Globals) - len(globals.Values); n > 0 {
+		globals.Values = append(globals.Values, make([]objects.Object, n)...)
+	}
+	globals.Names = bytecode.Globals
+
+	main := &objects.Closure{
+		Fn: &objects.CompiledFunction{
+			Instructions: bytecode.Instructions,
+			Lines:        bytecode.Lines,
+			NumLocals:    len(bytecode.Globals),
+			Names:        bytecode.Globals,
+		},
+		Constants: bytecode.Constants,
+	}
+
+	return &VM{
+		globals:  globals,
+		stack:    make([]objects.Object, initialStackSize),
+		frames:   []*frame{{cl: main, scope: globals}},
+		maxDepth: DefaultMaxDepth,
+		task:     sched.New(),
+	}
+}
+
+// SetImporter sets the importer that loads the modules of the
+// import statements, without one executing an import fails.
+func (vm *VM) SetImporter(imports objects.Importer) { vm.imports = imports }
+
+// SetMaxDepth sets the maximum depth of nested function calls, calls in
+// tail position do not nest. Exceeding it raises a LimitError.
+func (vm *VM) SetMaxDepth(depth int) { vm.maxDepth = depth }
+
+// LastPopped returns the last value popped off the stack,
+// after Run it is the value of the program.
+func (vm *VM) LastPopped() objects.Object { return vm.lastPopped }
+
+// Run executes the program. The errors raised by the program are
+// returned as an *objects.Error unless they are caught, they have the
+// same messages and kinds as the errors of the evaluator. The tasks
+// spawned by the program are stopped when it returns.
+func (vm *VM) Run() error {
+	defer vm.task.Stop()
+	return vm.execute()
+}
+
+// execute executes the instructions of the current frame until the
+// program or the task ends, catching the errors raised meanwhile.
+func (vm *VM) execute() error {
+	for {
+		err := vm.run()
+		if err == nil || !vm.catch(err) {
+			return err
+		}
+	}
+}
+
+// run executes the instructions of the current frame
+// until the program ends or an error is raised.
+func (vm *VM) run() error {
+	f := vm.frames[len(vm.frames)-1]
+	ins := f.cl.Fn.Instructions
+	constants := f.cl.Constants
+
+	for f.ip < len(ins) {
+		op := code.Opcode(ins[f.ip])
+		f.ip++
+
+		switch op {
+		case code.OpConstant:
+			idx := code.ReadUint16(ins[f.ip:])
+			f.ip += 2
+			vm.push(constants[idx])
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpDup:
+			vm.push(vm.stack[vm.sp-1])
+
+		case code.OpNil:
+			vm.push(nil)
+
+		case code.OpTrue:
+			vm.push(objects.NewBoolean(true))
+
+		case code.OpFalse:
+			vm.push(objects.NewBoolean(false))
+
+		case code.OpNull:
+			vm.push(objects.NewNull())
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpGreaterThan:
+			right := vm.pop()
+			left := vm.pop()
+
+			result, err := vm.infix(op, left, right)
+			if err != nil {
+				return err
+			}
+			vm.push(result)
+
+		case code.OpMinus:
+			operand := vm.pop()
+			i, ok := operand.(*objects.Integer)
+			if !ok {
+				return objects.Errorf(objects.TypeError, "unknown operator: -%s", operand.Type())
+			}
+			vm.push(objects.NewInteger(-i.Value))
+
+		case code.OpBang:
+			vm.push(nativeBool(!isTruthy(vm.pop())))
+
+		case code.OpJump:
+			f.ip = int(code.ReadUint16(ins[f.ip:]))
+
+		case code.OpJumpNotTruthy:
+			target := int(code.ReadUint16(ins[f.ip:]))
+			f.ip += 2
+			if !isTruthy(vm.pop()) {
+				f.ip = target
+			}
+
+		case code.OpGet:
+			depth := int(code.ReadUint16(ins[f.ip:]))
+			slot := int(code.ReadUint16(ins[f.ip+2:]))
+			f.ip += 4
+
+			val, err := get(f.scope, depth, slot)
+			if err != nil {
+				return err
+			}
+			vm.push(val)
+
+		case code.OpSet:
+			slot := code.ReadUint16(ins[f.ip:])
+			f.ip += 2
+			f.scope.Values[slot] = vm.pop()
+
+		case code.OpClosure:
+			idx := code.ReadUint16(ins[f.ip:])
+			f.ip += 2
+			vm.push(&objects.Closure{
+				Fn:        constants[idx].(*objects.CompiledFunction),
+				Env:       f.scope,
+				Constants: constants,
+			})
+
+		case code.OpCall:
+			argc := int(code.ReadUint8(ins[f.ip:]))
+			f.ip++
+
+			if err := vm.call(argc, nil, vm.inTail(f)); err != nil {
+				return err
+			}
+
+			f = vm.frames[len(vm.frames)-1]
+			ins = f.cl.Fn.Instructions
+			constants = f.cl.Constants
+
+		case code.OpCallNamed:
+			argc := int(code.ReadUint8(ins[f.ip:]))
+			named := int(code.ReadUint8(ins[f.ip+1:]))
+			first := int(code.ReadUint16(ins[f.ip+2:]))
+			f.ip += 4
+
+			names := make([]string, named)
+			for i := range names {
+				names[i] = constants[first+i].(*objects.String).Value
+			}
+
+			if err := vm.call(argc, names, vm.inTail(f)); err != nil {
+				return err
+			}
+
+			f = vm.frames[len(vm.frames)-1]
+			ins = f.cl.Fn.Instructions
+			constants = f.cl.Constants
+
+		case code.OpJumpBound:
+			target := int(code.ReadUint16(ins[f.ip:]))
+			slot := code.ReadUint16(ins[f.ip+2:])
+			f.ip += 4
+
+			if f.scope.Values[slot] != nil {
+				f.ip = target
+			}
+
+		case code.OpReturnValue:
+			val := vm.pop()
+
+			if len(vm.frames) == 1 {
+				vm.lastPopped = val
+				return nil
+			}
+
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.sp = f.bp
+			vm.push(val)
+
+			f = vm.frames[len(vm.frames)-1]
+			ins = f.cl.Fn.Instructions
+			constants = f.cl.Constants
+
+		case code.OpYield:
+			return &yield{value: vm.pop()}
+
+		case code.OpSpawn:
+			argc := int(code.ReadUint8(ins[f.ip:]))
+			named := int(code.ReadUint8(ins[f.ip+1:]))
+			first := int(code.ReadUint16(ins[f.ip+2:]))
+			f.ip += 4
+
+			names := make([]string, named)
+			for i := range names {
+				names[i] = constants[first+i].(*objects.String).Value
+			}
+
+			args := make([]objects.Object, argc)
+			copy(args, vm.stack[vm.sp-argc:vm.sp])
+			vm.spawn(vm.stack[vm.sp-1-argc], args, names)
+
+			vm.drop(argc + 1)
+			vm.push(objects.NewNull())
+
+		case code.OpChannel:
+			ch, err := sched.NewChannel(vm.pop())
+			if err != nil {
+				return err
+			}
+			vm.push(ch)
+
+		case code.OpSelect:
+			n := int(code.ReadUint16(ins[f.ip:]))
+			def := code.ReadUint8(ins[f.ip+2:]) == 1
+			f.ip += 3
+
+			cases := make([]sched.Case, n)
+			for i, o := range vm.stack[vm.sp-3*n : vm.sp] {
+				c := &cases[i/3]
+				switch i % 3 {
+				case 0:
+					ch, ok := o.(*sched.Channel)
+					if !ok {
+						return objects.Errorf(objects.TypeError, "not a channel: %s", o.Type())
+					}
+					c.Channel = ch
+				case 1:
+					c.Send = o == objects.NewBoolean(true)
+				case 2:
+					c.Value = o
+				}
+			}
+
+			i, val, err := vm.task.Select(cases, !def)
+			if err != nil {
+				return err
+			}
+
+			vm.drop(3 * n)
+			vm.push(val)
+			vm.push(objects.NewInteger(int64(i)))
+
+		case code.OpImport:
+			path := constants[code.ReadUint16(ins[f.ip:])].(*objects.String).Value
+			f.ip += 2
+
+			mod, err := vm.importModule(path)
+			if err != nil {
+				return err
+			}
+			vm.push(mod)
+
+		case code.OpMember:
+			name := constants[code.ReadUint16(ins[f.ip:])].(*objects.String).Value
+			f.ip += 2
+
+			val, err := member(vm.pop(), name)
+			if err != nil {
+				return err
+			}
+			vm.push(val)
+
+		case code.OpArray:
+			n := int(code.ReadUint16(ins[f.ip:]))
+			f.ip += 2
+
+			elements := make([]objects.Object, n)
+			copy(elements, vm.stack[vm.sp-n:vm.sp])
+			vm.drop(n)
+			vm.push(&objects.Array{Elements: elements})
+
+		case code.OpHash:
+			n := int(code.ReadUint16(ins[f.ip:]))
+			f.ip += 2
+
+			hash, err := newHash(vm.stack[vm.sp-2*n : vm.sp])
+			if err != nil {
+				return err
+			}
+			vm.drop(2 * n)
+			vm.push(hash)
+
+		case code.OpIndex:
+			idx := vm.pop()
+			left := vm.pop()
+
+			val, err := index(left, idx)
+			if err != nil {
+				return err
+			}
+			vm.push(val)
+
+		case code.OpTry:
+			target := int(code.ReadUint16(ins[f.ip:]))
+			f.ip += 2
+			f.handlers = append(f.handlers, handler{ip: target, sp: vm.sp})
+
+		case code.OpEndTry:
+			if len(f.handlers) == 0 {
+				return fmt.Errorf("no handler to remove")
+			}
+			f.handlers = f.handlers[:len(f.handlers)-1]
+
+		case code.OpThrow:
+			return objects.NewThrown(vm.pop())
+
+		case code.OpNoMatch:
+			return objects.Errorf(objects.MatchError, "no pattern matches %s", vm.pop().Inspect())
+
+		case code.OpMatchArray:
+			n := int(code.ReadUint16(ins[f.ip:]))
+			rest := code.ReadUint8(ins[f.ip+2:]) == 1
+			f.ip += 3
+
+			arr, ok := vm.stack[vm.sp-1].(*objects.Array)
+			vm.push(nativeBool(ok && (rest || len(arr.Elements) <= n)))
+
+		case code.OpMatchHash:
+			_, ok := vm.stack[vm.sp-1].(*objects.Hash)
+			vm.push(nativeBool(ok))
+
+		case code.OpElement:
+			target := int(code.ReadUint16(ins[f.ip:]))
+			i := int(code.ReadUint16(ins[f.ip+2:]))
+			f.ip += 4
+
+			arr, ok := vm.stack[vm.sp-1].(*objects.Array)
+			if !ok || i >= len(arr.Elements) {
+				f.ip = target
+				break
+			}
+			vm.push(arr.Elements[i])
+
+		case code.OpRest:
+			i := int(code.ReadUint16(ins[f.ip:]))
+			f.ip += 2
+
+			rest := []objects.Object{}
+			if arr, ok := vm.stack[vm.sp-1].(*objects.Array); ok && i < len(arr.Elements) {
+				rest = append(rest, arr.Elements[i:]...)
+			}
+			vm.push(&objects.Array{Elements: rest})
+
+		case code.OpEntry:
+			target := int(code.ReadUint16(ins[f.ip:]))
+			key := constants[code.ReadUint16(ins[f.ip+2:])].(*objects.String)
+			f.ip += 4
+
+			hash, ok := vm.stack[vm.sp-1].(*objects.Hash)
+			if !ok {
+				f.ip = target
+				break
+			}
+			val, ok := hash.Get(key)
+			if !ok {
+				f.ip = target
+				break
+			}
+			vm.push(val)
+
+		case code.OpMismatch:
+			pattern := constants[code.ReadUint16(ins[f.ip:])].(*objects.String).Value
+			f.ip += 2
+			return objects.Errorf(objects.MatchError, "value %s does not match the pattern %s", vm.pop().Inspect(), pattern)
+
+		default:
+			return fmt.Errorf("unknown opcode %d", op)
+		}
+	}
+
+	return nil
+}
+
+// call calls the function below argc arguments on the stack, the
+// last of which are named by names. The function and the arguments
+// are replaced by a new frame for the function, or by the result of
+// a method or the generator returned by a generator function. A call
+// in tail position replaces the frame of the caller instead.
+func (vm *VM) call(argc int, names []string, tail bool) error {
+	if !tail && vm.base+len(vm.frames)-1 >= vm.maxDepth {
+		return objects.Errorf(objects.LimitError, "maximum call depth %d exceeded", vm.maxDepth)
+	}
+
+	callee := vm.stack[vm.sp-1-argc]
+
+	if m, ok := callee.(*objects.Method); ok {
+		val, err := vm.callMethod(m, vm.stack[vm.sp-argc:vm.sp], names)
+		if err != nil {
+			return err
+		}
+		vm.drop(argc + 1)
+		vm.push(val)
+		return nil
+	}
+
+	cl, ok := callee.(*objects.Closure)
+	if !ok {
+		return objects.Errorf(objects.TypeError, "not a function: %s", callee.Type())
+	}
+
+	fn := cl.Fn
+	scope := objects.NewScope(fn, cl.Env)
+
+	// the arguments of most calls are bound as they are.
+	if len(names) == 0 && argc >= fn.NumParameters && !fn.Rest {
+		copy(scope.Values, vm.stack[vm.sp-argc:vm.sp-argc+fn.NumParameters])
+	} else {
+		rest := ""
+		if fn.Rest {
+			rest = fn.Names[fn.NumParameters]
+		}
+
+		args := vm.stack[vm.sp-argc : vm.sp]
+		if err := objects.BindArguments(fn.Name, scope.Values, fn.Names[:fn.NumParameters], fn.NumDefaults, rest, args, names); err != nil {
+			return err
+		}
+	}
+
+	vm.sp -= argc + 1
+	if fn.Generator {
+		vm.push(newGenerator(cl, scope))
+		return nil
+	}
+
+	if tail {
+		// the values of the caller are discarded with its frame.
+		caller := vm.frames[len(vm.frames)-1]
+		vm.drop(vm.sp - caller.bp)
+		vm.frames[len(vm.frames)-1] = &frame{cl: cl, bp: caller.bp, scope: scope}
+		return nil
+	}
+
+	vm.frames = append(vm.frames, &frame{
+		cl:    cl,
+		bp:    vm.sp,
+		scope: scope,
+	})
+
+	return nil
+}
+
+// inTail reports whether the call before the instruction pointer of f,
+// the current frame, is in tail position: the value of the call is
+// returned by a function called by another frame, outside of a try.
+func (vm *VM) inTail(f *frame) bool {
+	if len(vm.frames) == 1 || len(f.handlers) > 0 {
+		return false
+	}
+
+	ins := f.cl.Fn.Instructions
+
+	// the jumps at the end of the branches of if, match and select.
+	ip := f.ip
+	for ip < len(ins) && code.Opcode(ins[ip]) == code.OpJump {
+		ip = int(code.ReadUint16(ins[ip+1:]))
+	}
+
+	return ip < len(ins) && code.Opcode(ins[ip]) == code.OpReturnValue
+}
+
+// catch moves to the innermost handler installed in any frame, the
+// frames and the values of the stack above it are discarded and the
+// caught error is pushed. It reports whether err was caught.
+func (vm *VM) catch(err error) bool {
+	e, ok := err.(*objects.Error)
+	if !ok {
+		return false
+	}
+
+	if f := vm.frames[len(vm.frames)-1]; e.Line == 0 {
+		e.Line = f.cl.Fn.Lines.LineAt(f.ip - 1)
+	}
+
+	if !e.Catchable() {
+		return false
+	}
+
+	for i := len(vm.frames) - 1; i >= 0; i-- {
+		f := vm.frames[i]
+		if len(f.handlers) == 0 {
+			continue
+		}
+
+		h := f.handlers[len(f.handlers)-1]
+		f.handlers = f.handlers[:len(f.handlers)-1]
+
+		vm.frames = vm.frames[:i+1]
+		for vm.sp > h.sp {
+			vm.sp--
+			vm.stack[vm.sp] = nil
+		}
+		vm.push(&objects.ErrorValue{Error: e})
+
+		f.ip = h.ip
+		return true
+	}
+
+	return false
+}
+
+func (vm *VM) importModule(path string) (*objects.Module, error) {
+	if vm.imports == nil {
+		return nil, objects.Errorf(objects.ImportError, "import %q: imports are not supported", path)
+	}
+
+	mod, err := vm.imports.Import(path)
+	if err != nil {
+		return nil, objects.NewImportError(err)
+	}
+
+	return mod, nil
+}
+
+func member(o objects.Object, name string) (objects.Object, error) {
+	if g, ok := o.(*generator); ok {
+		if name != "next" {
+			return nil, objects.Errorf(objects.NameError, "generator has no member %s", name)
+		}
+		return &objects.Method{Receiver: g, Name: name}, nil
+	}
+
+	if ch, ok := o.(*sched.Channel); ok {
+		val, ok := ch.Member(name)
+		if !ok {
+			return nil, objects.Errorf(objects.NameError, "channel has no member %s", name)
+		}
+		return val, nil
+	}
+
+	if ev, ok := o.(*objects.ErrorValue); ok {
+		val, ok := ev.Member(name)
+		if !ok {
+			return nil, objects.Errorf(objects.NameError, "error has no member %s", name)
+		}
+		return val, nil
+	}
+
+	mod, ok := o.(*objects.Module)
+	if !ok {
+		return nil, objects.Errorf(objects.TypeError, "not a module: %s", o.Type())
+	}
+
+	val, ok := mod.Members[name]
+	if !ok {
+		return nil, objects.Errorf(objects.NameError, "%s has no exported member %s", mod.Path, name)
+	}
+
+	return val, nil
+}
+
+// newHash returns a hash of the pairs of keys and values.
+func newHash(pairs []objects.Object) (*objects.Hash, error) {
+	hash := objects.NewHash()
+
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(objects.Hashable)
+		if !ok {
+			return nil, objects.Errorf(objects.TypeError, "unusable as hash key: %s", pairs[i].Type())
+		}
+		hash.Set(key, pairs[i+1])
+	}
+
+	return hash, nil
+}
+
+// index returns the element of an array at idx, or the value of
+// a hash for the key idx. Both are null if there is none.
+func index(left, idx objects.Object) (objects.Object, error) {
+	switch left := left.(type) {
+	case *objects.Array:
+		i, ok := idx.(*objects.Integer)
+		if !ok {
+			break
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return objects.NewNull(), nil
+		}
+		return left.Elements[i.Value], nil
+	case *objects.Hash:
+		key, ok := idx.(objects.Hashable)
+		if !ok {
+			return nil, objects.Errorf(objects.TypeError, "unusable as hash key: %s", idx.Type())
+		}
+		if val, ok := left.Get(key); ok {
+			return val, nil
+		}
+		return objects.NewNull(), nil
+	}
+
+	return nil, objects.Errorf(objects.TypeError, "index operator not supported: %s[%s]", left.Type(), idx.Type())
+}
+
+// get returns the value in slot of the scope depth levels up from s.
+// If the slot has not been assigned yet the name is looked up in the
+// enclosing scopes, like the evaluator does with its environments.
+func get(s *objects.Scope, depth, slot int) (objects.Object, error) {
+	for i := 0; i < depth; i++ {
+		s = s.Outer
+	}
+
+	if slot < len(s.Values) && s.Values[slot] != nil {
+		return s.Values[slot], nil
+	}
+
+	name := s.Names[slot]
+	if val, ok := s.Outer.Lookup(name); ok {
+		return val, nil
+	}
+
+	return nil, objects.Errorf(objects.NameError, "identifier not found: %s", name)
+}
+
+func (vm *VM) infix(op code.Opcode, left, right objects.Object) (objects.Object, error) {
+	l, lok := left.(*objects.Integer)
+	r, rok := right.(*objects.Integer)
+
+	switch {
+	case lok && rok:
+		return integerInfix(op, l.Value, r.Value)
+	case op == code.OpAdd && left.Type() == objects.STRING && right.Type() == objects.STRING:
+		return &objects.String{Value: left.(*objects.String).Value + right.(*objects.String).Value}, nil
+	case op == code.OpEqual:
+		return nativeBool(left.Equals(right)), nil
+	case op == code.OpNotEqual:
+		return nativeBool(!left.Equals(right)), nil
+	case left.Type() != right.Type():
+		return nil, objects.Errorf(objects.TypeError, "type mismatch: %s %s %s", left.Type(), operators[op], right.Type())
+	default:
+		return nil, objects.Errorf(objects.TypeError, "unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+}
+
+func integerInfix(op code.Opcode, l, r int64) (objects.Object, error) {
+	switch op {
+	case code.OpAdd:
+		return objects.NewInteger(l + r), nil
+	case code.OpSub:
+		return objects.NewInteger(l - r), nil
+	case code.OpMul:
+		return objects.NewInteger(l * r), nil
+	case code.OpDiv:
+		if r == 0 {
+			return nil, objects.Errorf(objects.ArithmeticError, "division by zero")
+		}
+		return objects.NewInteger(l / r), nil
+	case code.OpLessThan:
+		return nativeBool(l < r), nil
+	case code.OpGreaterThan:
+		return nativeBool(l > r), nil
+	case code.OpEqual:
+		return nativeBool(l == r), nil
+	default:
+		return nativeBool(l != r), nil
+	}
+}
+
+func (vm *VM) push(o objects.Object) {
+	if vm.sp >= len(vm.stack) {
+		vm.stack = append(vm.stack, make([]objects.Object, len(vm.stack))...)
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+}
+
+// drop discards the n values on the top of the stack.
+func (vm *VM) drop(n int) {
+	for i := 0; i < n; i++ {
+		vm.sp--
+		vm.stack[vm.sp] = nil
+	}
+}
+
+func (vm *VM) pop() objects.Object {
+	vm.sp--
+	o := vm.stack[vm.sp]
+	vm.stack[vm.sp] = nil
+	vm.lastPopped = o
+	return o
+}
+
+func isTruthy(o objects.Object) bool {
+	switch o := o.(type) {
+	case *objects.Null:
+		return false
+	case *objects.Boolean:
+		return o.Value
+	default:
+		return true
+	}
+}
+
+func nativeBool(b bool) *objects.Boolean {
+	return objects.NewBoolean(b)
+}
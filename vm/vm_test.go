@@ -0,0 +1,305 @@
+package vm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Despire/interpreter/compiler"
+	"github.com/Despire/interpreter/lexer"
+	"github.com/Despire/interpreter/objects"
+	"github.com/Despire/interpreter/parser"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"let a = 1;", "<nil>"},
+		{"let f = fn() {}; f()", "<nil>"},
+		{"if (false) { 1 }", "null"},
+		{"let f = fn(n) { if (n < 2) { return n; } f(n - 1) + f(n - 2) }; f(15)", "610"},
+		{"let f = fn(a, a) { a }; f(1, 2)", "2"},
+		{"let f = fn(a) { a }; f(1, 2)", "1"},
+		{"let c = fn() { let n = 0; fn() { n + 1 } }; c()()", "1"},
+		{"let f = fn() { g() }; let g = fn() { 7 }; f()", "7"},
+		{"let x = 1; let f = fn() { let y = x; let x = 2; y + x }; f()", "3"},
+		{"let f = fn() { return 1; 2 }; f() + 10", "11"},
+		{"fn(x) { x }", "fn(x) { ... }"},
+		{"fn f(x, ...r) { x } f", "fn f(x, ...r) { ... }"},
+		{"let a = even(4); fn even(n) { if (n == 0) { true } else { odd(n - 1) } } fn odd(n) { if (n == 0) { false } else { even(n - 1) } } a", "true"},
+		{"if (true) { fn g() { 1 } }", "<nil>"},
+		{"fn g(x) { let y = yield x; [x, y] } let it = g(1); [it.next(), it.next(2), it.next()]", `[{"value": 1, "done": false}, {"value": [1, 2], "done": true}, {"value": null, "done": true}]`},
+		{"fn g() { yield 1 } g()", "generator g"},
+		{"let c = chan(); spawn fn(x) { c.send(x) }(1); select { c.recv() as v => v + 1 }", "2"},
+	}
+
+	for _, tt := range tests {
+		have := run(t, tt.input)
+		if inspect(have) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, inspect(have))
+		}
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x", "identifier not found: x"},
+		{"let f = fn() { g }; f()", "identifier not found: g"},
+		{"1()", "not a function: INTEGER"},
+		{"let f = fn(a, b) { a }; f(1)", "missing argument for parameter b"},
+		{"let f = fn(a) { a }; f(b: 1)", "unknown parameter b"},
+		{"fn f(a, b) { a } f(1)", "f: missing argument for parameter b"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"1 + true", "type mismatch: INTEGER + BOOLEAN"},
+		{"true < false", "unknown operator: BOOLEAN < BOOLEAN"},
+		{"fn g() { yield 1 } g().next(1, x: 2)", "next: unknown parameter x"},
+		{"fn g() { yield g().next() } try { g().next() } catch (e) { 1 }", "maximum call depth 10000 exceeded"},
+		{"let f = fn() { 1 + f() }; try { f() } catch (e) { 1 }", "maximum call depth 10000 exceeded"},
+		{"let c = chan(); spawn fn() { c.send(1, x: 2) }(); c.recv()", "send: unknown parameter x"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		c := compiler.New()
+		if err := c.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err := New(c.Bytecode()).Run()
+		if err == nil {
+			t.Errorf("expected an error for %q", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, have=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(n) { if (n == 0) { throw 0; } 1 + f(n - 1) }; 10 + try { f(50) } catch (e) { e.value + 1 }", "11"},
+		{"let f = fn(a, b) { a }; try { f(1) } catch (e) { e.kind }", `"ArgumentError"`},
+		{"let f = fn() { try { 1 + true } catch (e) { return e.line; } }; 1 + f()", "2"},
+		{"let f = fn() { try { return 1; } finally { 2 } }; let g = fn() { throw 3; }; f() + try { g() } catch (e) { e.value }", "4"},
+	}
+
+	for _, tt := range tests {
+		have := run(t, tt.input)
+		if inspect(have) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, inspect(have))
+		}
+	}
+}
+
+func TestUncaughtErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		kind  string
+		line  int
+	}{
+		{"1;\nx", objects.NameError, 2},
+		{"let f = fn() {\n  throw 1;\n};\nf()", objects.ThrownError, 2},
+		{"let f = fn(a) {\n  a\n};\n\nf()", objects.ArgumentError, 5},
+		{"try {\n  throw 1;\n} finally {\n  2\n}", objects.ThrownError, 2},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		c := compiler.New()
+		if err := c.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err, ok := New(c.Bytecode()).Run().(*objects.Error)
+		if !ok {
+			t.Errorf("expected an error for %q", tt.input)
+			continue
+		}
+
+		if err.Kind != tt.kind || err.Line != tt.line {
+			t.Errorf("wrong error for %q. want kind=%s line=%d, have kind=%s line=%d", tt.input, tt.kind, tt.line, err.Kind, err.Line)
+		}
+	}
+}
+
+func TestGlobalsAcrossPrograms(t *testing.T) {
+	symbols := compiler.NewSymbolTable()
+	globals := &objects.Scope{}
+	var constants []objects.Object
+
+	inputs := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 1; let inc = fn(x) { x + a };", "<nil>"},
+		{"let b = inc(b);", "ERROR: identifier not found: b"},
+		{"let b = inc(10); b", "11"},
+		{"let a = 100; inc(b)", "111"},
+		{"fn gen() { yield 1; yield 2 } let it = gen(); it.next()[\"value\"]", "1"},
+		{"it.next()[\"value\"]", "2"},
+		{"let c = chan(1); spawn fn() { c.recv() }(); c.send(3)", "null"},
+		// the tasks were stopped when the program returned.
+		{"c.recv()", "3"},
+		{"c.recv()", "ERROR: deadlock: all tasks are blocked"},
+	}
+
+	for _, tt := range inputs {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		c := compiler.NewWithState(symbols, constants)
+		if err := c.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		constants = c.Constants()
+
+		machine := NewWithGlobals(c.Bytecode(), globals)
+		var have objects.Object
+		if err := machine.Run(); err != nil {
+			have = &objects.Error{Value: err.Error()}
+		} else {
+			have = machine.LastPopped()
+		}
+
+		if inspect(have) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, inspect(have))
+		}
+	}
+}
+
+func TestHostValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"no == false", "true"},
+		{"no != false", "false"},
+		{"!no", "true"},
+		{"if (no) { 1 } else { 2 }", "2"},
+		{"if (nothing) { 1 } else { 2 }", "2"},
+		{"big == 100000", "true"},
+	}
+
+	for _, tt := range tests {
+		symbols := compiler.NewSymbolTable()
+		for _, name := range []string{"no", "nothing", "big"} {
+			symbols.Define(name)
+		}
+
+		globals := &objects.Scope{Values: []objects.Object{
+			&objects.Boolean{Value: false},
+			&objects.Null{},
+			&objects.Integer{Value: 100000},
+		}}
+
+		c := compiler.NewWithState(symbols, nil)
+		if err := c.Compile(parser.New(lexer.New(tt.input)).ParseProgram()); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		machine := NewWithGlobals(c.Bytecode(), globals)
+		if err := machine.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		if have := inspect(machine.LastPopped()); have != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, have)
+		}
+	}
+}
+
+// modules is an importer of the modules it holds.
+type modules map[string]*objects.Module
+
+func (m modules) Import(path string) (*objects.Module, error) {
+	if mod, ok := m[path]; ok {
+		return mod, nil
+	}
+	return nil, fmt.Errorf("module %q not found", path)
+}
+
+func TestImports(t *testing.T) {
+	// the functions of the module index the constants of its own program.
+	lib := run(t, "let offset = 1000; fn(x) { x * x + offset }")
+
+	importer := modules{
+		"math.mk": {
+			Path: "math.mk",
+			Members: map[string]objects.Object{
+				"square": lib,
+				"answer": objects.NewInteger(42),
+			},
+		},
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "math.mk" as m; m.answer`, "42"},
+		{`import "math.mk" as m; m.square(m.answer)`, "2764"},
+		{`import "math.mk" as m; let f = fn(x) { m.square(x) + 1 }; f(3)`, "1010"},
+		{`import "math.mk" as m; import "math.mk" as n; m == n`, "true"},
+		{`import "math.mk" as m;`, "<nil>"},
+		{`import "math.mk" as m; m.cube`, "ERROR: math.mk has no exported member cube"},
+		{`let m = 1; m.answer`, "ERROR: not a module: INTEGER"},
+		{`import "nope.mk" as m; 1`, `ERROR: module "nope.mk" not found`},
+	}
+
+	for _, tt := range tests {
+		c := compiler.New()
+		if err := c.Compile(parser.New(lexer.New(tt.input)).ParseProgram()); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		machine := New(c.Bytecode())
+		machine.SetImporter(importer)
+
+		var have objects.Object
+		if err := machine.Run(); err != nil {
+			have = &objects.Error{Value: err.Error()}
+		} else {
+			have = machine.LastPopped()
+		}
+
+		if inspect(have) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, have=%s", tt.input, tt.expected, inspect(have))
+		}
+	}
+}
+
+func run(t *testing.T, input string) objects.Object {
+	t.Helper()
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := New(c.Bytecode())
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error for %q: %s", input, err)
+	}
+
+	return machine.LastPopped()
+}
+
+func inspect(o objects.Object) string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.Inspect()
+}